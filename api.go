@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"log"
+	"net/http"
+	"strconv"
+	"vortenixgo/assets"
+	"vortenixgo/bot"
+)
+
+// Bounds for ?scale= on world.png; 32 is the game's own tile size
+const (
+	defaultWorldTileSize = 8
+	maxWorldTileSize     = 32
+)
+
+// HandleWorldPNG serves GET /api/bots/{id}/world.png, optional ?scale=<pixels per tile>
+func HandleWorldPNG(icons *assets.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, ok := bot.BotManager.GetBot(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "bot not found", http.StatusNotFound)
+			return
+		}
+
+		b.Lock()
+		width, height := b.Local.World.Width, b.Local.World.Height
+		b.Unlock()
+		if width == 0 || height == 0 {
+			http.Error(w, "bot is not in a world", http.StatusNotFound)
+			return
+		}
+
+		tileSize := defaultWorldTileSize
+		if s := r.URL.Query().Get("scale"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > maxWorldTileSize {
+				http.Error(w, "scale must be between 1 and 32", http.StatusBadRequest)
+				return
+			}
+			tileSize = n
+		}
+
+		var textures bot.TileTextures
+		if icons != nil && icons.GameDir != "" {
+			textures = icons
+		}
+		img := b.RenderWorld(tileSize, textures)
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			log.Printf("[HTTP] world.png for %s: %v", b.ID, err)
+			http.Error(w, "failed to encode image", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(buf.Bytes())
+	}
+}
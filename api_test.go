@@ -0,0 +1,69 @@
+package main
+
+import (
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vortenixgo/bot"
+)
+
+func TestHandleWorldPNG(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/bots/{id}/world.png", HandleWorldPNG(nil))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	lost, err := bot.BotManager.AddBot(bot.BotTypeLegacy, "png_lost", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bot.BotManager.RemoveBot(lost.ID) })
+	b, err := bot.BotManager.AddBot(bot.BotTypeLegacy, "png_world", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bot.BotManager.RemoveBot(b.ID) })
+	b.Lock()
+	b.Local.World = bot.World{Name: "PNG", Width: 5, Height: 3, Tiles: make([]bot.Tile, 15)}
+	b.Unlock()
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		w, h   int // Of the PNG, when the status is 200
+	}{
+		{"unknown bot", "/api/bots/bot_nobody/world.png", http.StatusNotFound, 0, 0},
+		{"not in a world", "/api/bots/bot_png_lost/world.png", http.StatusNotFound, 0, 0},
+		{"bad scale", "/api/bots/bot_png_world/world.png?scale=33", http.StatusBadRequest, 0, 0},
+		{"default scale", "/api/bots/bot_png_world/world.png", http.StatusOK, 40, 24},
+		{"scale", "/api/bots/bot_png_world/world.png?scale=2", http.StatusOK, 10, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "image/png" {
+				t.Errorf("content type %q", ct)
+			}
+			img, err := png.Decode(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b := img.Bounds(); b.Dx() != tt.w || b.Dy() != tt.h {
+				t.Errorf("image is %v, want %dx%d", b, tt.w, tt.h)
+			}
+		})
+	}
+}
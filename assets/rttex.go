@@ -0,0 +1,161 @@
+package assets
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// Upper bound for a decompressed RTTEX, protects against a corrupt header
+const maxRTTEXSize = 64 * 1024 * 1024
+
+// Pixel formats used by Growtopia's RTTEX files (OpenGL type constants)
+const (
+	rttexFormatUnsignedByte = 5121  // RGBA8888, or RGB888 without alpha
+	rttexFormatRGBA4444     = 32819 // GL_UNSIGNED_SHORT_4_4_4_4
+	rttexFormatRGB565       = 33635 // GL_UNSIGNED_SHORT_5_6_5
+)
+
+const (
+	rtpackHeaderSize   = 32
+	rttexHeaderSize    = 100
+	rttexMipHeaderSize = 24
+)
+
+// rttexHeader follows the "RTTXTR" magic
+type rttexHeader struct {
+	Magic          [6]byte
+	Version        uint8
+	Reserved       uint8
+	Height         int32
+	Width          int32
+	Format         int32
+	OriginalHeight int32
+	OriginalWidth  int32
+	UsesAlpha      uint8
+	Compressed     uint8
+	ReservedFlags  [2]uint8
+	MipmapCount    int32
+	Reserved2      [16]int32
+}
+
+// rttexMipHeader precedes the pixels of each mip level; only the first is decoded
+type rttexMipHeader struct {
+	Height   int32
+	Width    int32
+	DataSize int32
+	MipLevel int32
+	Reserved [2]int32
+}
+
+// DecodeRTTEX decodes a Proton SDK texture (optionally RTPACK zlib-wrapped) into
+// a top-down NRGBA image of the first mip level
+func DecodeRTTEX(data []byte) (*image.NRGBA, error) {
+	data, err := unpackRTPACK(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < rttexHeaderSize+rttexMipHeaderSize || string(data[:6]) != "RTTXTR" {
+		return nil, fmt.Errorf("not an RTTEX file")
+	}
+
+	reader := bytes.NewReader(data)
+	var header rttexHeader
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	var mip rttexMipHeader
+	if err := binary.Read(reader, binary.LittleEndian, &mip); err != nil {
+		return nil, err
+	}
+
+	width, height := int(mip.Width), int(mip.Height)
+	if width <= 0 || height <= 0 || width > 8192 || height > 8192 {
+		return nil, fmt.Errorf("invalid texture size %dx%d", width, height)
+	}
+
+	bpp, err := bytesPerPixel(header.Format, header.UsesAlpha != 0)
+	if err != nil {
+		return nil, err
+	}
+	pixels := data[rttexHeaderSize+rttexMipHeaderSize:]
+	if len(pixels) < width*height*bpp {
+		return nil, fmt.Errorf("texture data truncated: %d bytes, want %d", len(pixels), width*height*bpp)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		// Rows are stored bottom-up (OpenGL)
+		row := pixels[(height-1-y)*width*bpp:]
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, decodePixel(row[x*bpp:], header.Format, header.UsesAlpha != 0))
+		}
+	}
+	return img, nil
+}
+
+func unpackRTPACK(data []byte) ([]byte, error) {
+	if len(data) < 6 || string(data[:6]) != "RTPACK" {
+		return data, nil
+	}
+	if len(data) < rtpackHeaderSize {
+		return nil, fmt.Errorf("RTPACK header truncated")
+	}
+	r, err := zlib.NewReader(bytes.NewReader(data[rtpackHeaderSize:]))
+	if err != nil {
+		return nil, fmt.Errorf("RTPACK: %w", err)
+	}
+	defer r.Close()
+
+	out, err := io.ReadAll(io.LimitReader(r, maxRTTEXSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("RTPACK: %w", err)
+	}
+	if len(out) > maxRTTEXSize {
+		return nil, fmt.Errorf("RTPACK exceeds %d bytes", maxRTTEXSize)
+	}
+	return out, nil
+}
+
+func bytesPerPixel(format int32, alpha bool) (int, error) {
+	switch format {
+	case rttexFormatUnsignedByte:
+		if alpha {
+			return 4, nil
+		}
+		return 3, nil
+	case rttexFormatRGBA4444, rttexFormatRGB565:
+		return 2, nil
+	}
+	return 0, fmt.Errorf("unsupported RTTEX pixel format %d", format)
+}
+
+func decodePixel(p []byte, format int32, alpha bool) color.NRGBA {
+	switch format {
+	case rttexFormatRGBA4444:
+		v := binary.LittleEndian.Uint16(p)
+		return color.NRGBA{
+			R: uint8(v>>12&0xF) * 17,
+			G: uint8(v>>8&0xF) * 17,
+			B: uint8(v>>4&0xF) * 17,
+			A: uint8(v&0xF) * 17,
+		}
+	case rttexFormatRGB565:
+		v := binary.LittleEndian.Uint16(p)
+		return color.NRGBA{
+			R: uint8(v>>11&0x1F) << 3,
+			G: uint8(v>>5&0x3F) << 2,
+			B: uint8(v&0x1F) << 3,
+			A: 0xFF,
+		}
+	}
+	if alpha {
+		return color.NRGBA{R: p[0], G: p[1], B: p[2], A: p[3]}
+	}
+	return color.NRGBA{R: p[0], G: p[1], B: p[2], A: 0xFF}
+}
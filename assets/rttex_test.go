@@ -0,0 +1,105 @@
+package assets
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image/color"
+	"testing"
+)
+
+// rttex encodes one mip level of pixels, given top-down, in format
+func rttex(width, height int, format int32, alpha bool, pixels [][]byte) []byte {
+	header := rttexHeader{Height: int32(height), Width: int32(width), Format: format, OriginalHeight: int32(height), OriginalWidth: int32(width), MipmapCount: 1}
+	copy(header.Magic[:], "RTTXTR")
+	if alpha {
+		header.UsesAlpha = 1
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, header)
+	var data []byte
+	for y := height - 1; y >= 0; y-- { // Bottom-up
+		for x := 0; x < width; x++ {
+			data = append(data, pixels[y*width+x]...)
+		}
+	}
+	binary.Write(&buf, binary.LittleEndian, rttexMipHeader{Height: int32(height), Width: int32(width), DataSize: int32(len(data))})
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// rtpack wraps data in a zlib-compressed RTPACK
+func rtpack(data []byte) []byte {
+	header := make([]byte, rtpackHeaderSize)
+	copy(header, "RTPACK")
+	var buf bytes.Buffer
+	buf.Write(header)
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecodeRTTEX(t *testing.T) {
+	red, green := color.NRGBA{R: 255, A: 255}, color.NRGBA{G: 255, A: 128}
+	rgba := rttex(2, 2, rttexFormatUnsignedByte, true, [][]byte{
+		{255, 0, 0, 255}, {0, 255, 0, 128},
+		{0, 0, 255, 255}, {1, 2, 3, 4},
+	})
+	tests := []struct {
+		name string
+		data []byte
+		want []color.NRGBA // Top-down
+	}{
+		{"RGBA8888", rgba, []color.NRGBA{red, green, {B: 255, A: 255}, {1, 2, 3, 4}}},
+		{"RGBA8888 in RTPACK", rtpack(rgba), []color.NRGBA{red, green, {B: 255, A: 255}, {1, 2, 3, 4}}},
+		{"RGB888", rttex(1, 2, rttexFormatUnsignedByte, false, [][]byte{{10, 20, 30}, {40, 50, 60}}),
+			[]color.NRGBA{{10, 20, 30, 255}, {40, 50, 60, 255}}},
+		{"RGBA4444", rttex(2, 1, rttexFormatRGBA4444, true, [][]byte{{0x0F, 0xF0}, {0x84, 0x21}}),
+			[]color.NRGBA{{255, 0, 0, 255}, {34, 17, 136, 68}}},
+		{"RGB565", rtpack(rttex(2, 1, rttexFormatRGB565, false, [][]byte{{0x00, 0xF8}, {0x1F, 0x00}})),
+			[]color.NRGBA{{248, 0, 0, 255}, {0, 0, 248, 255}}},
+	}
+	for _, tt := range tests {
+		img, err := DecodeRTTEX(tt.data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		w := img.Bounds().Dx()
+		if len(tt.want) != w*img.Bounds().Dy() {
+			t.Errorf("%s: size %v", tt.name, img.Bounds())
+			continue
+		}
+		for i, want := range tt.want {
+			if got := img.NRGBAAt(i%w, i/w); got != want {
+				t.Errorf("%s: pixel (%d,%d) %v, want %v", tt.name, i%w, i/w, got, want)
+			}
+		}
+	}
+}
+
+func TestDecodeRTTEXMalformed(t *testing.T) {
+	good := rttex(2, 2, rttexFormatUnsignedByte, true, [][]byte{{1, 1, 1, 1}, {2, 2, 2, 2}, {3, 3, 3, 3}, {4, 4, 4, 4}})
+	badFormat := rttex(1, 1, 1234, false, [][]byte{{1, 2, 3}})
+	huge := rttex(1, 1, rttexFormatUnsignedByte, false, [][]byte{{1, 2, 3}})
+	binary.LittleEndian.PutUint32(huge[rttexHeaderSize+4:], 9000) // Mip width
+
+	tests := map[string][]byte{
+		"empty":           nil,
+		"not a texture":   []byte("hello world"),
+		"header only":     good[:rttexHeaderSize],
+		"truncated":       good[:len(good)-1],
+		"unknown format":  badFormat,
+		"too wide":        huge,
+		"RTPACK header":   []byte("RTPACK"),
+		"RTPACK not zlib": append(make([]byte, rtpackHeaderSize), 1, 2, 3),
+		"RTPACK cut":      rtpack(good)[:rtpackHeaderSize+10],
+	}
+	copy(tests["RTPACK not zlib"], "RTPACK")
+	for name, data := range tests {
+		if _, err := DecodeRTTEX(data); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
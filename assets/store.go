@@ -0,0 +1,219 @@
+package assets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"vortenixgo/database"
+)
+
+// TileSize is the size of one item sprite in the texture atlases
+const TileSize = 32
+
+// maxCachedAtlases bounds the decoded atlases kept in memory (a 1024x1024 atlas is 4MB)
+const maxCachedAtlases = 16
+
+// ErrNoTextures is returned when no game directory is configured
+var ErrNoTextures = errors.New("no game directory configured")
+
+// Store loads textures from a local Growtopia install and caches item icons
+type Store struct {
+	GameDir string
+	Items   *database.Provider
+
+	mu       sync.Mutex
+	atlases  map[string]*image.NRGBA
+	lastUsed []string         // Atlas names, least recently used first
+	failed   map[string]error // Atlases that couldn't be read or decoded
+	icons    map[uint32][]byte
+}
+
+// NewStore creates a texture store. The icon cache and the atlases that failed
+// to load are forgotten whenever items.dat is reloaded.
+func NewStore(gameDir string, items *database.Provider) *Store {
+	s := &Store{
+		GameDir: gameDir,
+		Items:   items,
+		atlases: make(map[string]*image.NRGBA),
+		failed:  make(map[string]error),
+		icons:   make(map[uint32][]byte),
+	}
+	if items != nil {
+		items.Subscribe(func(*database.ItemDatabase) {
+			s.mu.Lock()
+			s.icons = make(map[uint32][]byte)
+			s.failed = make(map[string]error)
+			s.mu.Unlock()
+		})
+	}
+	return s
+}
+
+// Atlas returns the decoded texture file (e.g. "tiles_page1.rttex"). A file
+// that is missing or broken isn't tried again until items.dat is reloaded.
+func (s *Store) Atlas(name string) (*image.NRGBA, error) {
+	if s.GameDir == "" {
+		return nil, ErrNoTextures
+	}
+	// Texture names come from items.dat, never let them leave the game directory
+	name = filepath.Base(name)
+
+	s.mu.Lock()
+	if img, ok := s.atlases[name]; ok {
+		s.touch(name)
+		s.mu.Unlock()
+		return img, nil
+	}
+	if err, ok := s.failed[name]; ok {
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.mu.Unlock()
+
+	img, err := s.loadAtlas(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.failed[name] = err
+		return nil, err
+	}
+	if _, ok := s.atlases[name]; !ok && len(s.atlases) >= maxCachedAtlases {
+		oldest := s.lastUsed[0]
+		s.lastUsed = s.lastUsed[1:]
+		delete(s.atlases, oldest)
+	}
+	s.atlases[name] = img
+	s.touch(name)
+	return img, nil
+}
+
+func (s *Store) loadAtlas(name string) (*image.NRGBA, error) {
+	data, err := s.readTexture(name)
+	if err != nil {
+		return nil, err
+	}
+	img, err := DecodeRTTEX(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return img, nil
+}
+
+// touch must be called with s.mu held
+func (s *Store) touch(name string) {
+	for i, n := range s.lastUsed {
+		if n == name {
+			s.lastUsed = append(s.lastUsed[:i], s.lastUsed[i+1:]...)
+			break
+		}
+	}
+	s.lastUsed = append(s.lastUsed, name)
+}
+
+// readTexture looks in <game>/game first (the layout of an install), then <game>
+func (s *Store) readTexture(name string) ([]byte, error) {
+	var lastErr error
+	for _, dir := range []string{filepath.Join(s.GameDir, "game"), s.GameDir} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return data, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// ItemIconImage crops the item's sprite out of its texture atlas
+func (s *Store) ItemIconImage(id uint32) (image.Image, error) {
+	if s.Items == nil {
+		return nil, fmt.Errorf("item database not available")
+	}
+	item := s.Items.DB().GetItem(id)
+	if item == nil {
+		return nil, fmt.Errorf("item %d not found", id)
+	}
+	if item.TextureFileName == "" {
+		return nil, fmt.Errorf("item %d has no texture", id)
+	}
+
+	atlas, err := s.Atlas(item.TextureFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	rect := image.Rect(0, 0, TileSize, TileSize).Add(image.Pt(int(item.TextureX)*TileSize, int(item.TextureY)*TileSize))
+	if !rect.In(atlas.Bounds()) {
+		return nil, fmt.Errorf("item %d sprite (%d,%d) is outside %s", id, item.TextureX, item.TextureY, item.TextureFileName)
+	}
+
+	icon := image.NewNRGBA(image.Rect(0, 0, TileSize, TileSize))
+	draw.Draw(icon, icon.Bounds(), atlas, rect.Min, draw.Src)
+	return icon, nil
+}
+
+// ItemIcon returns the item's sprite encoded as PNG
+func (s *Store) ItemIcon(id uint32) ([]byte, error) {
+	s.mu.Lock()
+	cached, ok := s.icons[id]
+	s.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	img, err := s.ItemIconImage(id)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.icons[id] = buf.Bytes()
+	s.mu.Unlock()
+	return buf.Bytes(), nil
+}
+
+// ServeItemIcon handles GET /items/{id}/icon.png
+func (s *Store) ServeItemIcon(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "invalid item id", http.StatusBadRequest)
+		return
+	}
+	if s.Items == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Icons only change with items.dat, so its hash makes a stable ETag
+	etag := fmt.Sprintf(`"%d-%d"`, s.Items.DB().Hash, id)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, err := s.ItemIcon(uint32(id))
+	if err != nil {
+		if !errors.Is(err, ErrNoTextures) && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[Assets] Icon %d: %v", id, err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("ETag", etag)
+	w.Write(data)
+}
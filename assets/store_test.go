@@ -0,0 +1,145 @@
+package assets
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"vortenixgo/database"
+)
+
+// tileColor is the color of the 32x32 tile at (x, y) in the test atlas
+func tileColor(x, y int) []byte {
+	return []byte{uint8(x * 100), uint8(y * 100), 7, 255}
+}
+
+// testStore serves a 2x2 tile atlas "tiles.rttex" from <dir>/game, with items
+// 0 (tile 1,1), 1 (outside the atlas), 2 (no texture) and 3 (missing file)
+func testStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	dir := t.TempDir()
+	pixels := make([][]byte, 64*64)
+	for i := range pixels {
+		pixels[i] = tileColor(i%64/TileSize, i/64/TileSize)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "game"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "game", "tiles.rttex"), rtpack(rttex(64, 64, rttexFormatUnsignedByte, true, pixels)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	src := database.NewItemDatabase()
+	src.Version = database.MaxItemsDatVersion
+	for id, item := range []database.Item{
+		{TextureFileName: "tiles.rttex", TextureX: 1, TextureY: 1},
+		{TextureFileName: "tiles.rttex", TextureX: 2},
+		{},
+		{TextureFileName: "missing.rttex"},
+	} {
+		item.ID, item.Name = uint32(id), "Item"
+		src.Items[item.ID] = &item
+	}
+	data, err := src.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := database.NewProvider()
+	if err := items.Update(data, filepath.Join(dir, "items.dat")); err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(dir, items), dir
+}
+
+func TestItemIconImage(t *testing.T) {
+	s, _ := testStore(t)
+	icon, err := s.ItemIconImage(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icon.Bounds().Dx() != TileSize || icon.Bounds().Dy() != TileSize {
+		t.Fatalf("icon size %v", icon.Bounds())
+	}
+	want := tileColor(1, 1)
+	for _, p := range [][2]int{{0, 0}, {31, 0}, {0, 31}, {31, 31}} {
+		if c := color.NRGBAModel.Convert(icon.At(p[0], p[1])).(color.NRGBA); c != (color.NRGBA{want[0], want[1], want[2], want[3]}) {
+			t.Errorf("pixel %v is %v, want tile (1,1)", p, c)
+		}
+	}
+
+	for _, id := range []uint32{1, 2, 3, 99} {
+		if _, err := s.ItemIconImage(id); err == nil {
+			t.Errorf("item %d has an icon", id)
+		}
+	}
+}
+
+func TestServeItemIcon(t *testing.T) {
+	s, _ := testStore(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}/icon.png", s.ServeItemIcon)
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/items/0/icon.png", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("status %d, type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	img, err := png.Decode(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil || img.Bounds().Dx() != TileSize {
+		t.Fatalf("icon %v, %v", img, err)
+	}
+	etag := rec.Header().Get("ETag")
+	if rec := get("/items/0/icon.png", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("status %d with a matching ETag", rec.Code)
+	}
+
+	for path, want := range map[string]int{
+		"/items/x/icon.png":           http.StatusBadRequest,
+		"/items/99999999999/icon.png": http.StatusBadRequest,
+		"/items/1/icon.png":           http.StatusNotFound,
+		"/items/3/icon.png":           http.StatusNotFound,
+		"/items/99/icon.png":          http.StatusNotFound,
+	} {
+		if rec := get(path, ""); rec.Code != want {
+			t.Errorf("%s: status %d, want %d", path, rec.Code, want)
+		}
+	}
+}
+
+func TestAtlasFailureCached(t *testing.T) {
+	s, dir := testStore(t)
+	if _, err := s.ItemIcon(3); err == nil {
+		t.Fatal("icon from a missing texture")
+	}
+
+	// Appearing later doesn't help until items.dat is reloaded
+	data, err := os.ReadFile(filepath.Join(dir, "game", "tiles.rttex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "missing.rttex"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ItemIcon(3); !os.IsNotExist(err) {
+		t.Fatalf("missing texture read again: %v", err)
+	}
+
+	if err := s.Items.LoadFile(filepath.Join(dir, "items.dat")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ItemIcon(3); err != nil {
+		t.Fatalf("after a reload: %v", err)
+	}
+}
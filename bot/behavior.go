@@ -0,0 +1,142 @@
+package bot
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"vortenixgo/config"
+)
+
+// Personality is how a bot's behavior differs from the config's averages.
+// It follows from its seed alone, so a bot behaves the same way every run.
+type Personality struct {
+	Seed     uint64  `json:"seed"`
+	Pace     float64 `json:"pace"`     // Delays are this many times the average, 0.8 to 1.3
+	Restless float64 `json:"restless"` // Idle moves come this many times as often, 0.7 to 1.4
+	Chatty   float64 `json:"chatty"`   // Times the chat chance, 0.5 to 1.5
+	Stride   float64 `json:"stride"`   // Anti-AFK steps last this many times 200ms, 0.5 to 1.5
+}
+
+func newPersonality(seed uint64) Personality {
+	r := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	return Personality{
+		Seed:     seed,
+		Pace:     0.8 + r.Float64()*0.5,
+		Restless: 0.7 + r.Float64()*0.7,
+		Chatty:   0.5 + r.Float64(),
+		Stride:   0.5 + r.Float64(),
+	}
+}
+
+// personalitySeed mixes the configured seed with the bot ID, so every bot
+// gets its own personality and a new seed gives them all new ones
+func personalitySeed(configured int, botID string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(botID))
+	return uint64(configured) ^ h.Sum64()
+}
+
+// idleAction is what an idle bot does on its next anti-AFK tick: a step to one
+// side and back, and maybe a phrase or emote
+type idleAction struct {
+	Wait time.Duration // Until the action
+	Left bool          // The first step's direction
+	Hold time.Duration // Of each step
+	Say  string
+}
+
+// behavior is a bot's random source and personality. Its methods may be
+// called from any goroutine.
+type behavior struct {
+	mu          sync.Mutex
+	rng         *rand.Rand
+	personality Personality
+}
+
+func newBehavior(seed uint64) *behavior {
+	return &behavior{
+		rng:         rand.New(rand.NewPCG(seed, seed>>1|1)),
+		personality: newPersonality(seed),
+	}
+}
+
+// spread returns d times the personality factor f, jittered by ±jitter
+func (bh *behavior) spread(d time.Duration, f, jitter float64) time.Duration {
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+	return time.Duration(float64(d) * f * (1 + jitter*(2*bh.rng.Float64()-1)))
+}
+
+// delay humanizes an action delay
+func (bh *behavior) delay(d time.Duration, cfg *config.BehaviorConfig) time.Duration {
+	return bh.spread(d, bh.personality.Pace, cfg.Jitter)
+}
+
+// next plans the next anti-AFK tick
+func (bh *behavior) next(cfg *config.BehaviorConfig) idleAction {
+	p := bh.personality
+	act := idleAction{
+		Wait: bh.spread(cfg.IdleInterval.D(), 1/p.Restless, cfg.Jitter),
+		Hold: bh.spread(200*time.Millisecond, p.Stride, cfg.Jitter),
+	}
+
+	bh.mu.Lock()
+	defer bh.mu.Unlock()
+	act.Left = bh.rng.IntN(2) == 0
+	if bh.rng.Float64() < cfg.ChatChance*p.Chatty {
+		lines := append(append([]string(nil), cfg.Phrases...), cfg.Emotes...)
+		if len(lines) > 0 {
+			act.Say = lines[bh.rng.IntN(len(lines))]
+		}
+	}
+	return act
+}
+
+// humanize stretches the delay after a queued action by the bot's pace, with
+// the configured action gap and jitter. Packets that must not wait are left
+// alone, as is everything while behavior is off.
+func (b *Bot) humanize(p *QueuedPacket) {
+	cfg := &config.Get().Behavior
+	if !cfg.Enabled || p.Priority == PriorityHigh {
+		return
+	}
+	p.Delay = b.behavior.delay(p.Delay+cfg.ActionGap.D(), cfg)
+}
+
+// BehaviorLoop keeps an idle bot from looking idle while it's in a world: now
+// and then it steps aside and back, sometimes saying something. Ticks are
+// jittered and shaped by the bot's personality. It ends when the bot stops.
+func (b *Bot) BehaviorLoop() {
+	defer b.RecoverPanic("Behavior", nil)
+	b.mu.Lock()
+	stop := b.stop
+	b.mu.Unlock()
+
+	for {
+		cfg := config.Get().Behavior
+		act := b.behavior.next(&cfg)
+		select {
+		case <-stop:
+			return
+		case <-time.After(act.Wait):
+		}
+		if !config.Get().Behavior.Enabled {
+			continue
+		}
+		b.idle(act)
+	}
+}
+
+// idle carries out an anti-AFK action
+func (b *Bot) idle(act idleAction) {
+	first, back := MoveInput{Left: act.Left, Right: !act.Left}, MoveInput{Left: !act.Left, Right: act.Left}
+	if err := b.Nudge(first, act.Hold); err != nil {
+		return // Not in a world
+	}
+	time.AfterFunc(act.Hold+act.Hold/2, func() { b.Nudge(back, act.Hold) })
+	if act.Say != "" {
+		b.Say(act.Say)
+	}
+}
@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"vortenixgo/config"
+	"vortenixgo/config/configtest"
+)
+
+func TestPersonality(t *testing.T) {
+	a, b := personalitySeed(0, "bot_a"), personalitySeed(0, "bot_b")
+	if a == b || personalitySeed(42, "bot_a") == a {
+		t.Fatal("seeds don't depend on the bot ID and the configured seed")
+	}
+	p := newPersonality(a)
+	if !reflect.DeepEqual(p, newPersonality(a)) {
+		t.Fatal("same seed, different personality")
+	}
+	if reflect.DeepEqual(p, newPersonality(b)) {
+		t.Error("different seeds, same personality")
+	}
+	for seed := uint64(1); seed < 200; seed++ {
+		p := newPersonality(seed)
+		if p.Pace < 0.8 || p.Pace > 1.3 || p.Restless < 0.7 || p.Restless > 1.4 || p.Chatty < 0.5 || p.Chatty > 1.5 || p.Stride < 0.5 || p.Stride > 1.5 {
+			t.Fatalf("personality out of range: %+v", p)
+		}
+	}
+}
+
+func TestBehaviorReproducible(t *testing.T) {
+	cfg := config.Default().Behavior
+	cfg.ChatChance = 1
+	one, two := newBehavior(7), newBehavior(7)
+	p := one.personality
+	lines := map[string]bool{}
+	for _, l := range append(cfg.Phrases, cfg.Emotes...) {
+		lines[l] = true
+	}
+
+	said := 0
+	for i := 0; i < 100; i++ {
+		act := one.next(&cfg)
+		if other := two.next(&cfg); act != other {
+			t.Fatalf("tick %d: %+v and %+v from the same seed", i, act, other)
+		}
+		avg := float64(cfg.IdleInterval) / p.Restless
+		if w := float64(act.Wait); w < avg*(1-cfg.Jitter) || w > avg*(1+cfg.Jitter) {
+			t.Errorf("wait %v outside ±%.0f%% of %v", act.Wait, cfg.Jitter*100, time.Duration(avg))
+		}
+		if act.Say != "" {
+			said++
+			if !lines[act.Say] {
+				t.Errorf("said %q", act.Say)
+			}
+		}
+	}
+	if said == 0 {
+		t.Error("never said anything at chat chance 1")
+	}
+
+	cfg.ChatChance = 0
+	for i := 0; i < 100; i++ {
+		if act := one.next(&cfg); act.Say != "" {
+			t.Fatalf("said %q at chat chance 0", act.Say)
+		}
+	}
+}
+
+func TestHumanize(t *testing.T) {
+	configtest.Use(t, "behavior:\n  enabled: true\n  action_gap: 100ms\n  jitter: 0.2\n")
+
+	b := NewBot("bot_human", BotTypeLegacy, "human", "", "")
+	pace := b.Personality.Pace
+	for i := 0; i < 50; i++ {
+		p := QueuedPacket{Priority: PriorityLow, Delay: 2 * time.Second}
+		b.humanize(&p)
+		avg := float64(2100*time.Millisecond) * pace
+		if d := float64(p.Delay); d < avg*0.8 || d > avg*1.2 {
+			t.Fatalf("delay %v around %v", p.Delay, time.Duration(avg))
+		}
+	}
+
+	// Ping replies and state packets never wait
+	p := QueuedPacket{Priority: PriorityHigh}
+	b.humanize(&p)
+	if p.Delay != 0 {
+		t.Errorf("high priority packet delayed %v", p.Delay)
+	}
+}
@@ -1,12 +1,16 @@
 package bot
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"vortenixgo/config"
 	"vortenixgo/database"
-	"vortenixgo/network/enet"
+	"vortenixgo/network/transport"
 )
 
 // BotType defines the authentication method
@@ -18,6 +22,16 @@ const (
 	BotTypeApple  BotType = "apple"  // Uses Token
 )
 
+// Flag is a bool that can be read and set without the bot's lock. It reads as
+// a plain bool in JSON.
+type Flag struct {
+	atomic.Bool
+}
+
+func (f *Flag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.Load())
+}
+
 // ExternalAuth holds 3rd party auth info
 type ExternalAuth struct {
 	IP           string `json:"ip"`
@@ -26,23 +40,6 @@ type ExternalAuth struct {
 	UseForGoogle bool   `json:"use_for_google" default:"true"`
 }
 
-var (
-	GlobalItemDatabase *database.ItemDatabase
-	GlobalItemsDatHash uint32
-	itemDatabaseOnce   sync.Once
-)
-
-func init() {
-	itemDatabaseOnce.Do(func() {
-		GlobalItemDatabase = database.NewItemDatabase()
-	})
-}
-
-type QueuedPacket struct {
-	Data  []byte
-	Delay time.Duration
-}
-
 // Bot represents a single bot instance
 type Bot struct {
 	ID     string  `json:"id"`
@@ -71,12 +68,11 @@ type Bot struct {
 	DisplayName      string `json:"display_name"`      // Computed display name (Email or InGameName)
 	Proxy            string `json:"proxy"`             // Socks5 Proxy (ip:port:user:pass)
 
-	// Enet Client
-	Client *enet.Host
-	Peer   *enet.Peer
+	// Enet Client, made by NewTransport on connect
+	Client transport.Transport `json:"-"`
 
 	Ping     int  `json:"ping"`
-	ShowENet bool `json:"show_enet"`
+	ShowENet Flag `json:"show_enet"` // Read by packet handlers without b.mu
 
 	Local     Local     `json:"local"`
 	CreatedAt time.Time `json:"created_at"`
@@ -84,13 +80,24 @@ type Bot struct {
 	LastPacketReceivedAt time.Time  `json:"-"`
 	Ping500StartedAt     *time.Time `json:"-"`
 
-	ItemDatabase *database.ItemDatabase `json:"-"`
+	Items *database.Provider `json:"-"` // Injected by the Manager
+
+	LastPanic *PanicReport `json:"last_panic,omitempty"` // Set when a panic disconnected the bot
+	Capture   string       `json:"capture,omitempty"`    // File being recorded by StartCapture
+	SendQueue *SendQueue   `json:"send_queue"`           // Outgoing packets and their counters
+
+	Personality Personality `json:"personality"` // Shapes the behavior module, see BehaviorLoop
 
 	// Concurrency control
 	mu           sync.Mutex
 	stop         chan struct{}
 	enetLoopDone chan struct{} // Signals that EventListener has exited
-	packetQueue  chan QueuedPacket
+	capture      atomic.Pointer[CaptureWriter]
+	replaying    bool     // Set by Replay: never connect or send
+	movement     movement // The character's held keys and loop, see Move
+	behavior     *behavior
+	join         *pendingJoin // The Warp waiting for its world, see Warp
+	panics       atomic.Int32 // Panics recovered by RecoverPanic
 
 	// Callbacks
 	OnDebug  func(category, message string, isError bool) `json:"-"`
@@ -105,6 +112,22 @@ func (b *Bot) Unlock() {
 	b.mu.Unlock()
 }
 
+// withLock runs fn with b.mu held. The lock is released even when fn panics,
+// so RecoverPanic can still take it to stop the bot.
+func (b *Bot) withLock(fn func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fn()
+}
+
+// itemDB returns the current item database, or nil if no provider was injected
+func (b *Bot) itemDB() *database.ItemDatabase {
+	if b.Items == nil {
+		return nil
+	}
+	return b.Items.DB()
+}
+
 func (b *Bot) GetElapsedMS() uint32 {
 	return uint32(time.Since(b.CreatedAt).Milliseconds())
 }
@@ -154,6 +177,7 @@ func (b *Bot) ResetEnetData() {
 	b.Local.Players = []Players{} // Clear player list
 
 	b.World = ""
+	b.endJoinLocked(ErrNotConnected)
 	b.Connected = false
 	b.Status = "Idle"
 }
@@ -166,10 +190,11 @@ func NewBot(id string, botType BotType, name string, password string, glog strin
 		Glog:         glog,
 		stop:         make(chan struct{}),
 		enetLoopDone: make(chan struct{}),
-		packetQueue:  make(chan QueuedPacket, 100),
+		SendQueue:    newSendQueue(),
 		CreatedAt:    time.Now(),
-		ItemDatabase: GlobalItemDatabase,
+		behavior:     newBehavior(personalitySeed(config.Get().Behavior.Seed, id)),
 	}
+	bot.Personality = bot.behavior.personality
 	close(bot.enetLoopDone) // Start as "dead" so ConnectClient knows to start listener
 
 	// Parse Glog string if provided: ip:port:accessKey
@@ -183,27 +208,28 @@ func NewBot(id string, botType BotType, name string, password string, glog strin
 		}
 	}
 
-	// Initialize LoginPacket with defaults
+	// Initialize LoginPacket with defaults from config
 	bot.Login = &bot.Local.Login
 	bot.Server = &bot.Local.ServerInfo
 
+	defaults := config.Get().Login
 	*bot.Login = LoginPacket{
 		F:             "1",
-		Protocol:      "225",
-		GameVersion:   "5.39",
-		Fz:            "22647480",
-		Cbits:         "1024",
-		PlayerAge:     "17",
-		GDPR:          "1",
-		Category:      "_-5100",
+		Protocol:      defaults.Protocol,
+		GameVersion:   Versions.Current(),
+		Fz:            defaults.Fz,
+		Cbits:         defaults.Cbits,
+		PlayerAge:     defaults.PlayerAge,
+		GDPR:          defaults.GDPR,
+		Category:      defaults.Category,
 		TotalPlaytime: "0",
-		FHash:         "-716928004",
-		PlatformID:    "0,1,1",
-		DeviceVersion: "0",
-		Country:       "us",
-		Zf:            "-1986240656",
-		LMode:         "1",
-		Aat:           "2",
+		FHash:         defaults.FHash,
+		PlatformID:    defaults.PlatformID,
+		DeviceVersion: defaults.DeviceVersion,
+		Country:       defaults.Country,
+		Zf:            defaults.Zf,
+		LMode:         defaults.LMode,
+		Aat:           defaults.Aat,
 	}
 
 	if (botType == BotTypeGmail || botType == BotTypeApple) && contains(password, "|") {
@@ -298,6 +324,7 @@ func (b *Bot) Disconnect() {
 
 	b.Status = "Idle"
 	b.Connected = false
+	b.endJoinLocked(ErrNotConnected)
 
 	// Safely close stop channel
 	defer func() {
@@ -312,10 +339,5 @@ func (b *Bot) Disconnect() {
 
 func (b *Bot) Say(text string) {
 	pkt := fmt.Sprintf("action|input\n|text|%s\n", text)
-	b.SendPacketWithDelay(pkt, NET_MESSAGE_GENERIC_TEXT, 2000*time.Millisecond)
-}
-
-func (b *Bot) Warp(world string) {
-	pkt := fmt.Sprintf("action|join_request\nname|%s\ninvitedWorld|0\n", world)
-	b.SendPacketWithDelay(pkt, NET_MESSAGE_GAME_MESSAGE, 4000*time.Millisecond)
+	b.Enqueue(context.Background(), QueuedPacket{Data: textPacket(pkt, NET_MESSAGE_GENERIC_TEXT), Priority: PriorityLow, Delay: config.Get().Timing.SayDelay.D()})
 }
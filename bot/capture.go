@@ -0,0 +1,290 @@
+package bot
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Capture files (.vxcap) record every ENet payload a bot sends or receives.
+// All integers are little endian:
+//
+//	header: "VXCAP", version u8, start time (unix ns) i64, bot ID (u16 length + bytes)
+//	record: direction u8, µs since the previous record (uvarint), length (uvarint), payload
+const (
+	captureMagic   = "VXCAP"
+	captureVersion = 1
+
+	// Matches the ENet host's packet size limit
+	maxCapturePacket = 16 * 1024 * 1024
+)
+
+var errCaptureClosed = errors.New("capture closed")
+
+// CaptureDirection tells whether a captured packet was received or sent
+type CaptureDirection uint8
+
+const (
+	CaptureIn  CaptureDirection = 0
+	CaptureOut CaptureDirection = 1
+)
+
+func (d CaptureDirection) String() string {
+	if d == CaptureOut {
+		return "out"
+	}
+	return "in"
+}
+
+// CaptureRecord is one captured ENet payload, starting with the 4 byte message type
+type CaptureRecord struct {
+	Time time.Time
+	Dir  CaptureDirection
+	Data []byte
+}
+
+// CaptureWriter appends records to a capture file. It is safe for concurrent use.
+type CaptureWriter struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer
+	last   time.Time
+	count  int
+	err    error // First write error, later writes are dropped
+}
+
+// NewCaptureWriter writes the capture header to w. If w is an io.Closer, Close closes it.
+func NewCaptureWriter(w io.Writer, botID string, start time.Time) (*CaptureWriter, error) {
+	if len(botID) > 0xFFFF {
+		return nil, fmt.Errorf("bot ID too long")
+	}
+	c := &CaptureWriter{w: bufio.NewWriter(w), last: start}
+	if closer, ok := w.(io.Closer); ok {
+		c.closer = closer
+	}
+
+	header := append([]byte(captureMagic), captureVersion)
+	header = binary.LittleEndian.AppendUint64(header, uint64(start.UnixNano()))
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(botID)))
+	header = append(header, botID...)
+	if _, err := c.w.Write(header); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Write appends one record. Records must be written in time order; an earlier
+// time is stored as a zero delta.
+func (c *CaptureWriter) Write(rec CaptureRecord) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+
+	delta := rec.Time.Sub(c.last).Microseconds()
+	if delta < 0 {
+		delta = 0
+	}
+	c.last = c.last.Add(time.Duration(delta) * time.Microsecond)
+
+	head := []byte{byte(rec.Dir)}
+	head = binary.AppendUvarint(head, uint64(delta))
+	head = binary.AppendUvarint(head, uint64(len(rec.Data)))
+	if _, err := c.w.Write(head); err != nil {
+		c.err = err
+		return err
+	}
+	if _, err := c.w.Write(rec.Data); err != nil {
+		c.err = err
+		return err
+	}
+	c.count++
+	return nil
+}
+
+// Count returns the number of records written so far
+func (c *CaptureWriter) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
+
+// Close flushes the buffered records and closes the underlying file
+func (c *CaptureWriter) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.w.Flush()
+	if c.closer != nil {
+		if cerr := c.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if c.err == nil {
+		c.err = errCaptureClosed
+	}
+	return err
+}
+
+// CaptureReader reads a capture file record by record
+type CaptureReader struct {
+	BotID string
+	Start time.Time
+
+	r    *bufio.Reader
+	last time.Time
+}
+
+// NewCaptureReader reads and checks the capture header
+func NewCaptureReader(r io.Reader) (*CaptureReader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(captureMagic)+1+8+2)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("capture header: %w", err)
+	}
+	if string(header[:len(captureMagic)]) != captureMagic {
+		return nil, fmt.Errorf("not a capture file")
+	}
+	if v := header[len(captureMagic)]; v != captureVersion {
+		return nil, fmt.Errorf("unsupported capture version %d", v)
+	}
+
+	start := time.Unix(0, int64(binary.LittleEndian.Uint64(header[len(captureMagic)+1:])))
+	id := make([]byte, binary.LittleEndian.Uint16(header[len(header)-2:]))
+	if _, err := io.ReadFull(br, id); err != nil {
+		return nil, fmt.Errorf("capture header: %w", err)
+	}
+	return &CaptureReader{BotID: string(id), Start: start, r: br, last: start}, nil
+}
+
+// Next returns the next record, or io.EOF after the last one
+func (c *CaptureReader) Next() (CaptureRecord, error) {
+	dir, err := c.r.ReadByte()
+	if err != nil {
+		return CaptureRecord{}, err // io.EOF between records is the normal end
+	}
+	if dir > byte(CaptureOut) {
+		return CaptureRecord{}, fmt.Errorf("capture record: invalid direction %d", dir)
+	}
+	delta, err := binary.ReadUvarint(c.r)
+	if err != nil {
+		return CaptureRecord{}, fmt.Errorf("capture record: %w", unexpectedEOF(err))
+	}
+	n, err := binary.ReadUvarint(c.r)
+	if err != nil {
+		return CaptureRecord{}, fmt.Errorf("capture record: %w", unexpectedEOF(err))
+	}
+	if n > maxCapturePacket || delta > uint64(100*365*24*time.Hour/time.Microsecond) {
+		return CaptureRecord{}, fmt.Errorf("capture record: implausible length %d or delay %dµs", n, delta)
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return CaptureRecord{}, fmt.Errorf("capture record: %w", unexpectedEOF(err))
+	}
+	c.last = c.last.Add(time.Duration(delta) * time.Microsecond)
+	return CaptureRecord{Time: c.last, Dir: CaptureDirection(dir), Data: data}, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// StartCapture records every packet the bot sends or receives to a new file in dir
+func (b *Bot) StartCapture(dir string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.capture.Load() != nil {
+		return "", fmt.Errorf("already capturing to %s", b.Capture)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.vxcap", fileSafeID(b.ID), now.Format("20060102-150405.000")))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	c, err := NewCaptureWriter(f, b.ID, now)
+	if err != nil {
+		f.Close()
+		return "", err
+	}
+	b.capture.Store(c)
+	b.Capture = path
+	log.Printf("[Capture][%s] Recording to %s", b.ID, path)
+	return path, nil
+}
+
+// StopCapture closes the current capture, if any
+func (b *Bot) StopCapture() error {
+	b.mu.Lock()
+	c := b.capture.Swap(nil)
+	path := b.Capture
+	b.Capture = ""
+	b.mu.Unlock()
+
+	if c == nil {
+		return nil
+	}
+	err := c.Close()
+	log.Printf("[Capture][%s] Stopped, %d packets in %s", b.ID, c.Count(), path)
+	return err
+}
+
+// capturePacket records data if a capture is running. It never takes b.mu, so
+// it can be called from code that holds it.
+func (b *Bot) capturePacket(dir CaptureDirection, data []byte) {
+	c := b.capture.Load()
+	if c == nil {
+		return
+	}
+	if err := c.Write(CaptureRecord{Time: time.Now(), Dir: dir, Data: data}); err != nil && !errors.Is(err, errCaptureClosed) {
+		log.Printf("[Capture][%s] Write failed, capture stopped: %v", b.ID, err)
+		b.capture.CompareAndSwap(c, nil)
+	}
+}
+
+// Replay feeds the inbound records of a capture through the bot's packet
+// handlers, as the EventListener would. The bot never connects or sends while
+// replaying. onRecord, if set, sees every record (both directions) before it is
+// handled. Replay stops at the first record whose handler panicked.
+func (b *Bot) Replay(r *CaptureReader, onRecord func(int, CaptureRecord)) (int, error) {
+	b.mu.Lock()
+	b.replaying = true
+	b.mu.Unlock()
+
+	for i := 0; ; i++ {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return i, nil
+		}
+		if err != nil {
+			return i, fmt.Errorf("record %d: %w", i, err)
+		}
+		if onRecord != nil {
+			onRecord(i, rec)
+		}
+		if rec.Dir != CaptureIn {
+			continue
+		}
+
+		panics := b.panics.Load()
+		b.HandlePacket(rec.Data)
+		if b.panics.Load() != panics {
+			return i + 1, fmt.Errorf("record %d: packet handler panicked", i)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// gamePacket builds an inbound NET_MESSAGE_GAME_PACKET payload
+func gamePacket(p TankPacketStruct, extra []byte) []byte {
+	p.ExtendedDataLength = uint32(len(extra))
+	data := binary.LittleEndian.AppendUint32(nil, NET_MESSAGE_GAME_PACKET)
+	data = append(data, p.Serialize()...)
+	return append(data, extra...)
+}
+
+func sampleCapture(t *testing.T) ([]byte, []CaptureRecord) {
+	t.Helper()
+	start := time.Unix(1760000000, 123456000)
+	records := []CaptureRecord{
+		{Time: start.Add(1500 * time.Microsecond), Dir: CaptureOut, Data: []byte{2, 0, 0, 0, 'h', 'i'}},
+		{Time: start.Add(2 * time.Second), Dir: CaptureIn, Data: gamePacket(TankPacketStruct{Type: uint8(NET_GAME_PACKET_SET_CHARACTER_STATE), Value: 7}, nil)},
+		{Time: start.Add(3 * time.Second), Dir: CaptureIn, Data: gamePacket(TankPacketStruct{Type: uint8(NET_GAME_PACKET_CALL_FUNCTION), NetID: -1},
+			(&variantWriter{}).str("OnSetBux").int(1234).bytes())},
+		{Time: start.Add(3 * time.Second), Dir: CaptureIn, Data: []byte{}},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewCaptureWriter(&buf, "bot_test", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), records
+}
+
+func TestCaptureRoundTrip(t *testing.T) {
+	data, want := sampleCapture(t)
+	r, err := NewCaptureReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.BotID != "bot_test" || r.Start.UnixNano() != 1760000000123456000 {
+		t.Fatalf("header = %q %v", r.BotID, r.Start)
+	}
+	for i, rec := range want {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if !got.Time.Equal(rec.Time) || got.Dir != rec.Dir || !bytes.Equal(got.Data, rec.Data) {
+			t.Fatalf("record %d = %v %v %x, want %v %v %x", i, got.Time, got.Dir, got.Data, rec.Time, rec.Dir, rec.Data)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("after the last record: %v", err)
+	}
+}
+
+func TestCaptureReaderMalformed(t *testing.T) {
+	data, _ := sampleCapture(t)
+
+	// Offsets where a record ends; only prefixes cut there read to a clean EOF
+	rd := bytes.NewReader(data)
+	r, _ := NewCaptureReader(rd)
+	offset := func() int { return len(data) - rd.Len() - r.r.Buffered() }
+	headerLen := offset()
+	boundary := map[int]bool{headerLen: true}
+	for {
+		if _, err := r.Next(); err != nil {
+			break
+		}
+		boundary[offset()] = true
+	}
+
+	for n := 0; n <= len(data); n++ {
+		r, err := NewCaptureReader(bytes.NewReader(data[:n]))
+		if err != nil {
+			if n >= headerLen {
+				t.Fatalf("%d bytes: %v", n, err)
+			}
+			continue
+		}
+		for err == nil {
+			_, err = r.Next()
+		}
+		if (err == io.EOF) != boundary[n] {
+			t.Fatalf("%d of %d bytes ended with %v", n, len(data), err)
+		}
+	}
+
+	// A record claiming a terabyte fails before allocating
+	huge := binary.AppendUvarint(append(data[:headerLen:headerLen], byte(CaptureIn), 0), 1<<40)
+	r, _ = NewCaptureReader(bytes.NewReader(huge))
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Fatalf("huge record: %v", err)
+	}
+
+	if _, err := NewCaptureReader(bytes.NewReader(append([]byte("PCAP"), data[4:]...))); err == nil {
+		t.Fatal("bad magic accepted")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	data, _ := sampleCapture(t)
+	r, err := NewCaptureReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBot(r.BotID, BotTypeLegacy, "test", "", "")
+	var dirs []CaptureDirection
+	n, err := b.Replay(r, func(i int, rec CaptureRecord) { dirs = append(dirs, rec.Dir) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 || !reflect.DeepEqual(dirs, []CaptureDirection{CaptureOut, CaptureIn, CaptureIn, CaptureIn}) {
+		t.Fatalf("replayed %d records: %v", n, dirs)
+	}
+	if b.Local.HackType != 7 || b.Local.GemCount != 1234 {
+		t.Fatalf("hack type %d, gems %d", b.Local.HackType, b.Local.GemCount)
+	}
+
+	// Sends are dropped while replaying instead of filling the queue
+	b.Connected = true
+	b.Say("hello")
+	if b.SendQueue.Stats().Queued != 0 {
+		t.Fatal("replaying bot queued a packet")
+	}
+}
+
+func TestExportPcapng(t *testing.T) {
+	data, want := sampleCapture(t)
+	r, _ := NewCaptureReader(bytes.NewReader(data))
+	var out bytes.Buffer
+	n, err := ExportPcapng(&out, r)
+	if err != nil || n != len(want) {
+		t.Fatalf("exported %d packets: %v", n, err)
+	}
+
+	le := binary.LittleEndian
+	pcap := out.Bytes()
+	var types []uint32
+	var packets int
+	for len(pcap) > 0 {
+		if len(pcap) < 12 {
+			t.Fatalf("%d trailing bytes", len(pcap))
+		}
+		blockType, total := le.Uint32(pcap), le.Uint32(pcap[4:])
+		if total%4 != 0 || int(total) > len(pcap) || le.Uint32(pcap[total-4:]) != total {
+			t.Fatalf("block %d: bad length %d", len(types), total)
+		}
+		types = append(types, blockType)
+		if blockType == pcapngEnhancedPacket {
+			rec := want[packets]
+			ts := uint64(le.Uint32(pcap[12:]))<<32 | uint64(le.Uint32(pcap[16:]))
+			capLen := le.Uint32(pcap[20:])
+			body := pcap[28 : 28+capLen]
+			if ts != uint64(rec.Time.UnixMicro()) || !bytes.Equal(body, rec.Data) {
+				t.Fatalf("packet %d: ts %d data %x", packets, ts, body)
+			}
+			opts := pcap[28+int(capLen)+pad4(int(capLen)):]
+			wantFlags := uint32(1)
+			if rec.Dir == CaptureOut {
+				wantFlags = 2
+			}
+			if le.Uint16(opts) != pcapngOptEpbFlag || le.Uint32(opts[4:]) != wantFlags {
+				t.Fatalf("packet %d: options %x", packets, opts[:8])
+			}
+			packets++
+		}
+		pcap = pcap[total:]
+	}
+	wantTypes := []uint32{pcapngSectionHeader, pcapngInterfaceDesc, pcapngEnhancedPacket, pcapngEnhancedPacket, pcapngEnhancedPacket, pcapngEnhancedPacket}
+	if !reflect.DeepEqual(types, wantTypes) {
+		t.Fatalf("blocks %x", types)
+	}
+}
@@ -0,0 +1,299 @@
+package bot
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Nesting limit for CBOR embedded in world data; real payloads are shallow
+const maxCBORDepth = 32
+
+// CBORTag is a tagged CBOR value that has no natural Go representation
+type CBORTag struct {
+	Tag   uint64      `json:"tag"`
+	Value interface{} `json:"value"`
+}
+
+// decodeCBOR decodes exactly one CBOR data item from data (RFC 8949). Maps become
+// map[string]interface{} (non-string keys are formatted with %v) so the result
+// can be sent to the UI as JSON. It returns the number of bytes consumed.
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	d := &cborDecoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, d.pos, err
+	}
+	if _, ok := v.(breakMarker); ok {
+		return nil, d.pos, fmt.Errorf("cbor: unexpected break")
+	}
+	return v, d.pos, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// breakMarker is returned for the 0xFF "break" byte of indefinite-length items
+type breakMarker struct{}
+
+func (d *cborDecoder) byte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *cborDecoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	out := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return out, nil
+}
+
+// argument decodes the additional information of an initial byte. indefinite is
+// true for additional info 31.
+func (d *cborDecoder) argument(info byte) (arg uint64, indefinite bool, err error) {
+	switch {
+	case info < 24:
+		return uint64(info), false, nil
+	case info == 24:
+		b, err := d.take(1)
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(b[0]), false, nil
+	case info == 25:
+		b, err := d.take(2)
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(binary.BigEndian.Uint16(b)), false, nil
+	case info == 26:
+		b, err := d.take(4)
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(binary.BigEndian.Uint32(b)), false, nil
+	case info == 27:
+		b, err := d.take(8)
+		if err != nil {
+			return 0, false, err
+		}
+		return binary.BigEndian.Uint64(b), false, nil
+	case info == 31:
+		return 0, true, nil
+	}
+	return 0, false, fmt.Errorf("cbor: reserved additional info %d", info)
+}
+
+func (d *cborDecoder) value(depth int) (interface{}, error) {
+	if depth > maxCBORDepth {
+		return nil, fmt.Errorf("cbor: nesting deeper than %d", maxCBORDepth)
+	}
+	initial, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	major, info := initial>>5, initial&0x1F
+
+	if major == 7 {
+		return d.simple(info)
+	}
+
+	arg, indefinite, err := d.argument(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0: // Unsigned integer
+		if indefinite {
+			return nil, fmt.Errorf("cbor: indefinite integer")
+		}
+		return arg, nil
+
+	case 1: // Negative integer
+		if indefinite {
+			return nil, fmt.Errorf("cbor: indefinite integer")
+		}
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("cbor: negative integer overflow")
+		}
+		return -1 - int64(arg), nil
+
+	case 2, 3: // Byte string, text string
+		var buf []byte
+		if indefinite {
+			for {
+				chunk, err := d.value(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				if _, ok := chunk.(breakMarker); ok {
+					break
+				}
+				switch c := chunk.(type) {
+				case []byte:
+					buf = append(buf, c...)
+				case string:
+					buf = append(buf, c...)
+				default:
+					return nil, fmt.Errorf("cbor: invalid string chunk")
+				}
+			}
+		} else {
+			b, err := d.take(arg)
+			if err != nil {
+				return nil, err
+			}
+			buf = append([]byte(nil), b...)
+		}
+		if major == 3 {
+			return string(buf), nil
+		}
+		return buf, nil
+
+	case 4: // Array
+		var out []interface{}
+		for i := uint64(0); indefinite || i < arg; i++ {
+			// Every element takes at least one byte, so a bogus length fails fast
+			if !indefinite && arg-i > uint64(len(d.data)-d.pos) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := v.(breakMarker); ok {
+				if !indefinite {
+					return nil, fmt.Errorf("cbor: unexpected break")
+				}
+				break
+			}
+			out = append(out, v)
+		}
+		if out == nil {
+			out = []interface{}{}
+		}
+		return out, nil
+
+	case 5: // Map
+		out := make(map[string]interface{})
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if !indefinite && arg-i > uint64(len(d.data)-d.pos)/2 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			k, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := k.(breakMarker); ok {
+				if !indefinite {
+					return nil, fmt.Errorf("cbor: unexpected break")
+				}
+				break
+			}
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := v.(breakMarker); ok {
+				return nil, fmt.Errorf("cbor: map key without value")
+			}
+			key, ok := k.(string)
+			if !ok {
+				key = fmt.Sprintf("%v", k)
+			}
+			out[key] = v
+		}
+		return out, nil
+
+	case 6: // Tag
+		if indefinite {
+			return nil, fmt.Errorf("cbor: indefinite tag")
+		}
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return CBORTag{Tag: arg, Value: v}, nil
+	}
+	return nil, fmt.Errorf("cbor: invalid major type %d", major)
+}
+
+// simple decodes major type 7: booleans, null, floats and break
+func (d *cborDecoder) simple(info byte) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23: // null, undefined
+		return nil, nil
+	case 24:
+		b, err := d.take(1)
+		if err != nil {
+			return nil, err
+		}
+		return uint64(b[0]), nil
+	case 25:
+		b, err := d.take(2)
+		if err != nil {
+			return nil, err
+		}
+		return jsonFloat(float16ToFloat64(binary.BigEndian.Uint16(b))), nil
+	case 26:
+		b, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		return jsonFloat(float64(math.Float32frombits(binary.BigEndian.Uint32(b)))), nil
+	case 27:
+		b, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+		return jsonFloat(math.Float64frombits(binary.BigEndian.Uint64(b))), nil
+	case 31:
+		return breakMarker{}, nil
+	}
+	if info < 20 {
+		// Unassigned simple values
+		return uint64(info), nil
+	}
+	return nil, fmt.Errorf("cbor: invalid simple value %d", info)
+}
+
+func float16ToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h>>10) & 0x1F
+	frac := float64(h & 0x3FF)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(frac, -24)
+	case 0x1F:
+		if frac == 0 {
+			return sign * math.Inf(1)
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(frac+1024, exp-25)
+}
+
+// jsonFloat keeps NaN and infinities out of the result, encoding/json rejects them
+func jsonFloat(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Sprintf("%v", f)
+	}
+	return f
+}
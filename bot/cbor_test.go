@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// Vectors from RFC 8949 Appendix A
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want interface{}
+	}{
+		{"00", uint64(0)},
+		{"17", uint64(23)},
+		{"1818", uint64(24)},
+		{"1903e8", uint64(1000)},
+		{"1bffffffffffffffff", uint64(18446744073709551615)},
+		{"20", int64(-1)},
+		{"3903e7", int64(-1000)},
+		{"f90000", 0.0},
+		{"f93c00", 1.0},
+		{"f93e00", 1.5},
+		{"f97bff", 65504.0},
+		{"f90001", 5.960464477539063e-8},
+		{"f9c400", -4.0},
+		{"fa47c35000", 100000.0},
+		{"fb3ff199999999999a", 1.1},
+		{"f97c00", "+Inf"},
+		{"f97e00", "NaN"},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"f0", uint64(16)},
+		{"f8ff", uint64(255)},
+		{"c074323031332d30332d32315432303a30343a30305a", CBORTag{Tag: 0, Value: "2013-03-21T20:04:00Z"}},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"60", ""},
+		{"6449455446", "IETF"},
+		{"62c3bc", "ü"},
+		{"80", []interface{}{}},
+		{"83010203", []interface{}{uint64(1), uint64(2), uint64(3)}},
+		{"8301820203820405", []interface{}{uint64(1), []interface{}{uint64(2), uint64(3)}, []interface{}{uint64(4), uint64(5)}}},
+		{"a0", map[string]interface{}{}},
+		{"a201020304", map[string]interface{}{"1": uint64(2), "3": uint64(4)}},
+		{"a26161016162820203", map[string]interface{}{"a": uint64(1), "b": []interface{}{uint64(2), uint64(3)}}},
+		{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"9fff", []interface{}{}},
+		{"9f018202039f0405ffff", []interface{}{uint64(1), []interface{}{uint64(2), uint64(3)}, []interface{}{uint64(4), uint64(5)}}},
+		{"bf61610161629f0203ffff", map[string]interface{}{"a": uint64(1), "b": []interface{}{uint64(2), uint64(3)}}},
+	}
+	for _, tt := range tests {
+		data, _ := hex.DecodeString(tt.hex)
+		got, n, err := decodeCBOR(data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.hex, err)
+			continue
+		}
+		if n != len(data) {
+			t.Errorf("%s: consumed %d of %d bytes", tt.hex, n, len(data))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.hex, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	for _, h := range []string{
+		"",
+		"18",                 // Missing argument
+		"1c",                 // Reserved additional info
+		"62c3",               // Short string
+		"9bffffffffffffffff", // Array longer than the data
+		"baffffffff",         // Map longer than the data
+		"9f01",               // Unterminated indefinite array
+		"ff",                 // Lone break
+		"81ff",               // Break in a definite array
+		"a1ff",               // Break as a map key
+		"5f01ff",             // Integer inside an indefinite byte string
+		"1f",                 // Indefinite integer
+		"3bffffffffffffffff", // Negative integer overflow
+	} {
+		data, _ := hex.DecodeString(h)
+		if v, _, err := decodeCBOR(data); err == nil {
+			t.Errorf("%q decoded to %#v", h, v)
+		}
+	}
+
+	deep := make([]byte, maxCBORDepth+2)
+	for i := range deep {
+		deep[i] = 0x81
+	}
+	if _, _, err := decodeCBOR(deep); err == nil {
+		t.Error("nesting limit not enforced")
+	}
+}
+
+func FuzzDecodeCBOR(f *testing.F) {
+	for _, h := range []string{"a26161016162820203", "bf61610161629f0203ffff", "c074323031332d30332d32315432303a30343a30305a", "f97e00", "5f42010243030405ff"} {
+		data, _ := hex.DecodeString(h)
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, n, err := decodeCBOR(data)
+		if n > len(data) {
+			t.Fatalf("consumed %d of %d bytes", n, len(data))
+		}
+		if err != nil {
+			return
+		}
+		// The result goes to the UI as JSON
+		if _, err := json.Marshal(v); err != nil {
+			t.Fatalf("%#v: %v", v, err)
+		}
+	})
+}
@@ -0,0 +1,359 @@
+package bot
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Role is a bot's part in the work of a world shared with other bots
+type Role string
+
+const (
+	RoleBreaker   Role = "breaker"
+	RolePlanter   Role = "planter"
+	RoleCollector Role = "collector"
+	RoleGuard     Role = "guard" // Watches the world, works no tiles
+)
+
+// Roles are handed out in this order as bots arrive in a world, then again
+var roleOrder = []Role{RoleBreaker, RoleCollector, RolePlanter, RoleGuard}
+
+// Valid reports whether r is one of the roles above
+func (r Role) Valid() bool {
+	for _, role := range roleOrder {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	ErrNotCoordinated = errors.New("bot isn't in a coordinated world")
+	ErrOutsideRegion  = errors.New("tile is outside the bot's region")
+	ErrTileTaken      = errors.New("tile is worked by another bot")
+)
+
+// Region is a rectangle of tiles
+type Region struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// Contains reports whether tile (x, y) is in r
+func (r Region) Contains(x, y int) bool {
+	return x >= r.X && x < r.X+r.W && y >= r.Y && y < r.Y+r.H
+}
+
+// Assignment is what a bot does in its world
+type Assignment struct {
+	BotID  string `json:"bot_id"`
+	NetID  int    `json:"net_id"`
+	Role   Role   `json:"role"`
+	Region Region `json:"region"` // Empty for guards
+	Claims int    `json:"claims"` // Tiles it's working on
+}
+
+// Crew is our bots in one world
+type Crew struct {
+	World     string       `json:"world"`
+	Width     int          `json:"width"`
+	Height    int          `json:"height"`
+	Bots      []Assignment `json:"bots"`      // In order of arrival
+	Strangers []string     `json:"strangers"` // Players in the world that aren't ours
+}
+
+// Coordinator keeps bots sharing a world out of each other's way: each gets
+// a role and, unless it's a guard, a strip of columns of its own. Bots claim
+// tiles before working them and no two hold the same tile. Manager keeps it
+// up to date, see Manager.Coordinate.
+type Coordinator struct {
+	mu     sync.Mutex
+	pinned map[string]Role           // Bot ID -> role set by hand
+	crews  map[string]*Crew          // By world
+	member map[string]string         // Bot ID -> world
+	claims map[string]map[int]string // World -> tile index -> bot ID
+}
+
+func NewCoordinator() *Coordinator {
+	return &Coordinator{
+		pinned: make(map[string]Role),
+		crews:  make(map[string]*Crew),
+		member: make(map[string]string),
+		claims: make(map[string]map[int]string),
+	}
+}
+
+// crewMember is a bot in a world as Coordinate saw it
+type crewMember struct {
+	id, world     string
+	netID         int
+	width, height int
+	players       []Players
+}
+
+// crewMemberOf is b in its world, or false while it's in none or its local
+// player hasn't spawned yet
+func crewMemberOf(b *Bot) (crewMember, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	w := &b.Local.World
+	if b.World == "" || b.World == "EXIT" || w.Name != b.World {
+		return crewMember{}, false
+	}
+	m := crewMember{id: b.ID, world: b.World, netID: -1, width: int(w.Width), height: int(w.Height)}
+	for _, p := range b.Local.Players {
+		if p.IsLocal {
+			m.netID = p.NetID
+		}
+	}
+	if m.netID < 0 {
+		return crewMember{}, false
+	}
+	m.players = append([]Players(nil), b.Local.Players...)
+	return m, true
+}
+
+// SetRole pins a bot's role; an empty role lets the coordinator choose again
+func (c *Coordinator) SetRole(botID string, role Role) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if role == "" {
+		delete(c.pinned, botID)
+	} else {
+		c.pinned[botID] = role
+	}
+}
+
+// sync rebuilds the crews from members. Bots keep their place in the order
+// of arrival, so one arriving doesn't move the others; claims of bots that
+// left a world are dropped.
+func (c *Coordinator) sync(members []crewMember) []Crew {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	byWorld := make(map[string][]crewMember)
+	for _, m := range members {
+		byWorld[m.world] = append(byWorld[m.world], m)
+	}
+	for world := range c.crews {
+		if _, ok := byWorld[world]; !ok {
+			delete(c.crews, world)
+			delete(c.claims, world)
+		}
+	}
+
+	member := make(map[string]string, len(members))
+	for world, ms := range byWorld {
+		here := make(map[string]crewMember, len(ms))
+		for _, m := range ms {
+			here[m.id] = m
+			member[m.id] = world
+		}
+		var order []string
+		if old := c.crews[world]; old != nil {
+			for _, a := range old.Bots {
+				if _, ok := here[a.BotID]; ok {
+					order = append(order, a.BotID)
+					delete(here, a.BotID)
+				}
+			}
+		}
+		arrived := make([]string, 0, len(here))
+		for id := range here {
+			arrived = append(arrived, id)
+		}
+		sort.Strings(arrived)
+		order = append(order, arrived...)
+
+		crew := &Crew{World: world}
+		ours := make(map[int]bool)
+		for _, m := range ms {
+			crew.Width, crew.Height = max(crew.Width, m.width), max(crew.Height, m.height)
+			ours[m.netID] = true
+		}
+		seen := make(map[int]bool)
+		for _, m := range ms {
+			for _, p := range m.players {
+				if !ours[p.NetID] && !seen[p.NetID] {
+					seen[p.NetID] = true
+					crew.Strangers = append(crew.Strangers, p.Name)
+				}
+			}
+		}
+		sort.Strings(crew.Strangers)
+		c.assign(crew, order, ms)
+		c.crews[world] = crew
+
+		claims := c.claims[world]
+		for idx, id := range claims {
+			if member[id] != world {
+				delete(claims, idx)
+			}
+		}
+	}
+	c.member = member
+	return c.crewsLocked()
+}
+
+// assign hands out roles and regions to the bots in order
+func (c *Coordinator) assign(crew *Crew, order []string, ms []crewMember) {
+	netIDs := make(map[string]int, len(ms))
+	for _, m := range ms {
+		netIDs[m.id] = m.netID
+	}
+	crew.Bots = make([]Assignment, len(order))
+	workers, next := 0, 0
+	for i, id := range order {
+		role, ok := c.pinned[id]
+		if !ok {
+			role = roleOrder[next%len(roleOrder)]
+			next++
+		}
+		crew.Bots[i] = Assignment{BotID: id, NetID: netIDs[id], Role: role}
+		if role != RoleGuard {
+			workers++
+		}
+	}
+
+	// Strips of columns, widths at most one apart, left to right in order
+	strip := 0
+	for i := range crew.Bots {
+		a := &crew.Bots[i]
+		if a.Role == RoleGuard {
+			continue
+		}
+		x0, x1 := strip*crew.Width/workers, (strip+1)*crew.Width/workers
+		a.Region = Region{X: x0, W: x1 - x0, H: crew.Height}
+		strip++
+	}
+}
+
+// crewsLocked copies the crews, sorted by world, with claim counts
+func (c *Coordinator) crewsLocked() []Crew {
+	crews := make([]Crew, 0, len(c.crews))
+	for _, crew := range c.crews {
+		cp := *crew
+		cp.Bots = append([]Assignment(nil), crew.Bots...)
+		for i := range cp.Bots {
+			for _, id := range c.claims[crew.World] {
+				if id == cp.Bots[i].BotID {
+					cp.Bots[i].Claims++
+				}
+			}
+		}
+		crews = append(crews, cp)
+	}
+	sort.Slice(crews, func(i, j int) bool { return crews[i].World < crews[j].World })
+	return crews
+}
+
+// assignmentLocked is the bot's assignment and its crew
+func (c *Coordinator) assignmentLocked(botID string) (*Assignment, *Crew) {
+	crew := c.crews[c.member[botID]]
+	if crew == nil {
+		return nil, nil
+	}
+	for i := range crew.Bots {
+		if crew.Bots[i].BotID == botID {
+			return &crew.Bots[i], crew
+		}
+	}
+	return nil, nil
+}
+
+// claim gives tile (x, y) of the bot's world to the bot until it releases
+// it or leaves. Claiming a tile it holds again is fine.
+func (c *Coordinator) claim(botID string, x, y int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, crew := c.assignmentLocked(botID)
+	if a == nil {
+		return ErrNotCoordinated
+	}
+	if !a.Region.Contains(x, y) {
+		return ErrOutsideRegion
+	}
+	claims := c.claims[crew.World]
+	if claims == nil {
+		claims = make(map[int]string)
+		c.claims[crew.World] = claims
+	}
+	idx := x + y*crew.Width
+	if owner, ok := claims[idx]; ok && owner != botID {
+		return ErrTileTaken
+	}
+	claims[idx] = botID
+	return nil
+}
+
+// release gives back a tile the bot claimed
+func (c *Coordinator) release(botID string, x, y int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	crew := c.crews[c.member[botID]]
+	if crew == nil {
+		return
+	}
+	idx := x + y*crew.Width
+	if c.claims[crew.World][idx] == botID {
+		delete(c.claims[crew.World], idx)
+	}
+}
+
+// forget drops everything about a removed bot
+func (c *Coordinator) forget(botID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pinned, botID)
+	if world, ok := c.member[botID]; ok {
+		for idx, id := range c.claims[world] {
+			if id == botID {
+				delete(c.claims[world], idx)
+			}
+		}
+	}
+}
+
+// Coordinate brings the coordinator up to date with where the bots are and
+// returns the crews. It locks every bot in turn, so it must not be called
+// with one locked.
+func (m *Manager) Coordinate() []Crew {
+	var members []crewMember
+	for _, b := range m.GetAllBots() {
+		if cm, ok := crewMemberOf(b); ok {
+			members = append(members, cm)
+		}
+	}
+	return m.Coordinator.sync(members)
+}
+
+// Assignment is the bot's current role and region
+func (m *Manager) Assignment(botID string) (Assignment, bool) {
+	m.Coordinate()
+	c := m.Coordinator
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, _ := c.assignmentLocked(botID)
+	if a == nil {
+		return Assignment{}, false
+	}
+	return *a, true
+}
+
+// ClaimTile reserves tile (x, y) for the bot before it breaks, plants or
+// collects there. It fails with ErrOutsideRegion outside the bot's region and
+// ErrTileTaken while another bot holds the tile, such as one whose region
+// just moved. No bot action calls it yet.
+func (m *Manager) ClaimTile(botID string, x, y int) error {
+	m.Coordinate()
+	return m.Coordinator.claim(botID, x, y)
+}
+
+// ReleaseTile gives back a tile claimed with ClaimTile
+func (m *Manager) ReleaseTile(botID string, x, y int) {
+	m.Coordinator.release(botID, x, y)
+}
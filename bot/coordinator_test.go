@@ -0,0 +1,142 @@
+package bot
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// inWorld puts b in world w as the player with netID, among others
+func inWorld(b *Bot, world string, w, h, netID int, others ...Players) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.World = world
+	b.Local.World = World{Name: world, Width: uint32(w), Height: uint32(h)}
+	b.Local.NetID = netID
+	b.Local.Players = append([]Players{{Name: b.ID, NetID: netID, IsLocal: true}}, others...)
+}
+
+func testCrew(t *testing.T, n int) (*Manager, []*Bot) {
+	t.Helper()
+	m := NewManager()
+	var bots []*Bot
+	for i := 0; i < n; i++ {
+		b, err := m.AddBot(BotTypeLegacy, fmt.Sprint(i), "", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		bots = append(bots, b)
+	}
+	return m, bots
+}
+
+func TestCoordinateRoles(t *testing.T) {
+	m, bots := testCrew(t, 5)
+	for i, b := range bots[:4] {
+		inWorld(b, "FARM", 100, 60, i+1, Players{Name: "stranger", NetID: 50})
+	}
+	inWorld(bots[4], "OTHER", 10, 10, 1)
+
+	crews := m.Coordinate()
+	if len(crews) != 2 || crews[0].World != "FARM" || crews[1].World != "OTHER" {
+		t.Fatalf("crews %+v", crews)
+	}
+	farm := crews[0]
+	want := []Assignment{
+		{BotID: "bot_0", NetID: 1, Role: RoleBreaker, Region: Region{X: 0, W: 33, H: 60}},
+		{BotID: "bot_1", NetID: 2, Role: RoleCollector, Region: Region{X: 33, W: 33, H: 60}},
+		{BotID: "bot_2", NetID: 3, Role: RolePlanter, Region: Region{X: 66, W: 34, H: 60}},
+		{BotID: "bot_3", NetID: 4, Role: RoleGuard},
+	}
+	if !reflect.DeepEqual(farm.Bots, want) {
+		t.Errorf("farm crew\n%+v\nwant\n%+v", farm.Bots, want)
+	}
+	if !reflect.DeepEqual(farm.Strangers, []string{"stranger"}) {
+		t.Errorf("strangers %v", farm.Strangers)
+	}
+
+	// A bot leaving doesn't move the ones before it; a pinned role sticks
+	inWorld(bots[1], "EXIT", 0, 0, -1)
+	m.Coordinator.SetRole("bot_3", RoleBreaker)
+	crews = m.Coordinate()
+	roles := map[string]Role{}
+	for _, a := range crews[0].Bots {
+		roles[a.BotID] = a.Role
+	}
+	if !reflect.DeepEqual(roles, map[string]Role{"bot_0": RoleBreaker, "bot_2": RoleCollector, "bot_3": RoleBreaker}) {
+		t.Errorf("roles after a bot left %v", roles)
+	}
+	if _, ok := m.Assignment("bot_1"); ok {
+		t.Error("bot_1 still assigned after leaving")
+	}
+
+	// A bot whose player hasn't spawned isn't counted yet
+	inWorld(bots[1], "FARM", 100, 60, 2)
+	bots[1].Local.Players = nil
+	if a, ok := m.Assignment("bot_1"); ok {
+		t.Errorf("bot_1 assigned before it spawned: %+v", a)
+	}
+}
+
+func TestClaimTile(t *testing.T) {
+	m, bots := testCrew(t, 3)
+	for i, b := range bots[:2] {
+		inWorld(b, "FARM", 10, 10, i+1)
+	}
+
+	// bot_0 has columns 0-4, bot_1 columns 5-9
+	if err := m.ClaimTile("bot_0", 4, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ClaimTile("bot_0", 4, 3); err != nil {
+		t.Errorf("claiming a held tile again: %v", err)
+	}
+	if err := m.ClaimTile("bot_1", 4, 3); err != ErrOutsideRegion {
+		t.Errorf("claim outside the region: %v", err)
+	}
+	if err := m.ClaimTile("bot_2", 0, 0); err != ErrNotCoordinated {
+		t.Errorf("claim by a bot in no world: %v", err)
+	}
+
+	// bot_2 arrives and takes columns 6-9; bot_0 keeps its tile even
+	// though column 4 is bot_1's now
+	inWorld(bots[2], "FARM", 10, 10, 3)
+	if a, _ := m.Assignment("bot_1"); a.Region != (Region{X: 3, W: 3, H: 10}) {
+		t.Fatalf("bot_1 region %+v", a.Region)
+	}
+	if err := m.ClaimTile("bot_1", 4, 3); err != ErrTileTaken {
+		t.Errorf("claim of a tile held by another bot: %v", err)
+	}
+	if crews := m.Coordinate(); crews[0].Bots[0].Claims != 1 {
+		t.Errorf("claims %+v", crews[0].Bots)
+	}
+	m.ReleaseTile("bot_1", 4, 3) // Not its tile
+	if err := m.ClaimTile("bot_1", 4, 3); err != ErrTileTaken {
+		t.Errorf("release by another bot freed the tile: %v", err)
+	}
+	m.ReleaseTile("bot_0", 4, 3)
+	if err := m.ClaimTile("bot_1", 4, 3); err != nil {
+		t.Errorf("claim of a released tile: %v", err)
+	}
+
+	// Leaving the world gives everything back
+	inWorld(bots[1], "ELSEWHERE", 10, 10, 1)
+	m.Coordinate()
+	inWorld(bots[0], "FARM", 10, 10, 1)
+	if err := m.ClaimTile("bot_0", 4, 3); err != nil {
+		t.Errorf("tile of a bot that left: %v", err)
+	}
+}
+
+func TestRoleValid(t *testing.T) {
+	for _, r := range []Role{RoleBreaker, RolePlanter, RoleCollector, RoleGuard} {
+		if !r.Valid() {
+			t.Errorf("%q not valid", r)
+		}
+	}
+	for _, r := range []Role{"", "Breaker", "farmer"} {
+		if r.Valid() {
+			t.Errorf("%q valid", r)
+		}
+	}
+}
@@ -1,27 +1,37 @@
 package bot
 
 import (
-	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"log"
-	"strconv"
 	"strings"
-	"sync"
 	"time"
-	"vortenixgo/network/enet"
+	"vortenixgo/config"
+	"vortenixgo/network/transport"
 )
 
-var enetOnce sync.Once
+// NewTransport makes the connection a bot plays through. main sets it to the
+// cgo ENet backend; tests and simulations use a transport.Network.
+var NewTransport func() (transport.Transport, error)
 
+// How long EventListener waits in Service between its timeout checks. The
+// cgo host only sends while it's serviced, so it's polled; a transport.Waker
+// sends on its own and is woken when the bot stops.
 const (
-// Any remaining constants if any
+	pollInterval = 100 * time.Millisecond
+	wakeInterval = time.Second
 )
 
-func (b *Bot) EventListener(client *enet.Host) {
+func (b *Bot) EventListener(client transport.Transport) {
 	b.logENet("Starting EventListener...")
 	defer close(b.enetLoopDone)
+	defer b.RecoverPanic("EventListener", nil)
 
+	wait := pollInterval
+	if _, ok := client.(transport.Waker); ok {
+		wait = wakeInterval
+	}
 	for {
 		select {
 		case <-b.stop:
@@ -47,20 +57,22 @@ func (b *Bot) EventListener(client *enet.Host) {
 				b.Ping500StartedAt = nil
 			}
 
-			// Check Timeout Condition: Last Packet > 15s AND Ping 500 > 15s
+			// Check Timeout Condition: Last Packet AND Ping 500 both exceed the configured timeout
+			timeout := config.Get().Timing.ConnectionTimeout.D()
 			lastPacketDuration := now.Sub(b.LastPacketReceivedAt)
 			ping500Duration := time.Duration(0)
 			if b.Ping500StartedAt != nil {
 				ping500Duration = now.Sub(*b.Ping500StartedAt)
 			}
 
-			if lastPacketDuration > 15*time.Second && ping500Duration > 15*time.Second {
+			if lastPacketDuration > timeout && ping500Duration > timeout {
 				b.logENet(fmt.Sprintf("Connection Timeout: No packets for %.fs and Ping is %dms", lastPacketDuration.Seconds(), b.Ping))
 				b.mu.Unlock()
 
 				// Use a goroutine to avoid deadlock because StopENet waits for enetLoopDone
 				// which is closed when THIS function (EventListener) returns.
 				go func() {
+					defer b.RecoverPanic("Timeout", nil)
 					b.StopENet()
 					b.mu.Lock()
 					b.Status = "offline"
@@ -86,7 +98,7 @@ func (b *Bot) EventListener(client *enet.Host) {
 			return
 		}
 
-		event, err := client.Service(100)
+		event, err := client.Service(wait)
 		if err != nil {
 			b.mu.Lock()
 			stillConnected := b.Connected
@@ -109,10 +121,9 @@ func (b *Bot) EventListener(client *enet.Host) {
 
 		if event != nil {
 			switch event.Type {
-			case enet.EventConnect:
+			case transport.EventConnect:
 				b.GetPing()
-				ip := event.Peer.GetRemoteIP()
-				port := event.Peer.GetRemotePort()
+				ip, port := event.RemoteIP, event.RemotePort
 				msg := fmt.Sprintf("CONNECTED TO SERVER | IP: %s | PORT: %d", ip, port)
 				fmt.Println("\n[ENET] " + msg)
 				b.logENet(msg)
@@ -128,9 +139,8 @@ func (b *Bot) EventListener(client *enet.Host) {
 					b.OnUpdate()
 				}
 
-			case enet.EventDisconnect:
-				ip := event.Peer.GetRemoteIP()
-				port := event.Peer.GetRemotePort()
+			case transport.EventDisconnect:
+				ip, port := event.RemoteIP, event.RemotePort
 				msg := fmt.Sprintf("DISCONNECTED FROM SERVER | IP: %s | PORT: %d", ip, port)
 				fmt.Println("\n[ENET] " + msg)
 				b.logENet(msg)
@@ -152,13 +162,12 @@ func (b *Bot) EventListener(client *enet.Host) {
 				}
 				// Do NOT return/break here. Loop continues for next connection (Redirect).
 
-			case enet.EventReceive:
+			case transport.EventReceive:
 				b.mu.Lock()
 				b.LastPacketReceivedAt = time.Now()
 				b.mu.Unlock()
 				b.GetPing()
-				b.OnReceive(event)
-				event.Packet.Destroy()
+				b.HandlePacket(event.Data)
 			}
 		}
 	}
@@ -169,12 +178,11 @@ func (b *Bot) DisconnectPeer() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if b.Peer != nil {
-		b.Peer.Disconnect(0)
-		b.Peer.Reset() // Optional depending on wrapper
-		b.Peer = nil
+	if b.Client != nil {
+		b.Client.Disconnect()
 	}
 	b.Connected = false
+	b.SendQueue.flush() // Nothing queued is meant for the next connection
 }
 
 // StopENet fully destroys ENet Host (Hard Stop).
@@ -187,11 +195,7 @@ func (b *Bot) StopENet() {
 
 	b.logENet("Stopping ENet Client...")
 	b.Connected = false
-
-	if b.Peer != nil {
-		b.Peer.Disconnect(0)
-		b.Peer = nil
-	}
+	client := b.Client
 
 	b.mu.Unlock()
 
@@ -200,6 +204,9 @@ func (b *Bot) StopENet() {
 		defer func() { recover() }()
 		close(b.stop)
 	}()
+	if w, ok := client.(transport.Waker); ok {
+		w.Wake()
+	}
 
 	// 2. Wait for confirmation
 	b.logENet("Waiting for EventListener to exit...")
@@ -210,11 +217,10 @@ func (b *Bot) StopENet() {
 		b.logENet("EventListener exit timeout. Detaching and forcing destroy.")
 	}
 
-	// 3. Destroy Client
+	// 3. Destroy Client (says goodbye to the peer first)
 	b.mu.Lock()
 	if b.Client != nil {
-		b.Client.Flush()
-		b.Client.Destroy()
+		b.Client.Close()
 		b.Client = nil
 	}
 
@@ -223,6 +229,10 @@ func (b *Bot) StopENet() {
 	b.enetLoopDone = make(chan struct{})
 	close(b.enetLoopDone) // Mark it as "ready to start a new listener"
 
+	// 5. Drop packets still queued for the old server so they don't reach the
+	// next one
+	b.SendQueue.flush()
+
 	b.Status = "Idle"
 	b.mu.Unlock()
 	b.logENet("ENet resources fully cleared.")
@@ -243,8 +253,8 @@ func (b *Bot) GetPing() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if b.Peer != nil && !b.Peer.IsNil() {
-		b.Ping = b.Peer.GetRoundTripTime()
+	if b.Client != nil && b.Client.HasPeer() {
+		b.Ping = b.Client.RTT()
 	} else {
 		b.Status = "offline"
 		b.Ping = 500
@@ -253,6 +263,11 @@ func (b *Bot) GetPing() {
 
 func (b *Bot) ConnectClient() {
 	b.mu.Lock()
+	if b.replaying {
+		b.mu.Unlock()
+		b.logENet("Replaying a capture, not connecting")
+		return
+	}
 	targetIP := b.Server.Enet.NowConnectedIP
 	targetPort := b.Server.Enet.NowConnectedPort
 	b.logENet(fmt.Sprintf("Connecting to %s:%d...", targetIP, targetPort))
@@ -262,51 +277,25 @@ func (b *Bot) ConnectClient() {
 	b.Ping500StartedAt = nil
 
 	client := b.Client
-	var initErr error
 
 	if client == nil {
-		enetOnce.Do(func() {
-			if enet.Initialize() != 0 {
-				initErr = fmt.Errorf("failed to initialize enet")
-			}
-		})
-		if initErr != nil {
+		if NewTransport == nil {
 			b.mu.Unlock()
-			b.logENet("ENet init failed")
+			b.logENet("No transport configured")
 			return
 		}
-
-		client = enet.CreateHost(nil, 1, 2, 0, 0)
-		if client == nil {
+		var err error
+		client, err = NewTransport()
+		if err != nil {
 			b.mu.Unlock()
-			b.logENet("Host creation failed")
+			b.logENet(fmt.Sprintf("Transport creation failed: %v", err))
 			return
 		}
-
-		client.SetUsingNewPacket(true)
-		client.SetChecksum()
-		client.CompressWithRangeCoder()
-		client.SetMaxPacketLimits(16 * 1024 * 1024)
-		client.SetSocketBuffers(2048*1024, 2048*1024)
-
 		b.Client = client
 	}
 
 	// Always apply proxy settings (handles updates/re-connects)
-	proxyStr := b.Proxy
-	if proxyStr != "" {
-		parts := strings.Split(proxyStr, ":")
-		if len(parts) == 4 {
-			pPort, _ := strconv.Atoi(parts[1])
-			client.SetProxy(parts[0], pPort, parts[2], parts[3])
-		} else if len(parts) == 2 {
-			pPort, _ := strconv.Atoi(parts[1])
-			client.SetProxy(parts[0], pPort, "", "")
-		}
-	} else {
-		// Clear proxy if setting removed?
-		// client.SetProxy("", 0, "", "")
-	}
+	proxy := transport.ParseProxy(b.Proxy)
 
 	// Listener check: If it's dead or channel is closed, start a new one.
 	listenerDead := false
@@ -324,15 +313,8 @@ func (b *Bot) ConnectClient() {
 
 	b.mu.Unlock()
 
-	address, err := enet.NewAddress(targetIP, targetPort)
-	if err != nil {
-		b.logENet(fmt.Sprintf("Address failed: %v", err))
-		return
-	}
-
-	peer := client.Connect(address, 2, 0)
-	if peer == nil {
-		b.logENet("Connect() returned nil")
+	if err := client.Connect(targetIP, targetPort, proxy); err != nil {
+		b.logENet(fmt.Sprintf("Connect failed: %v", err))
 		b.mu.Lock()
 		b.Status = "No Peer"
 		b.mu.Unlock()
@@ -340,18 +322,18 @@ func (b *Bot) ConnectClient() {
 	}
 
 	b.mu.Lock()
-	b.Peer = peer
 	b.Connected = true
 
 	b.mu.Unlock()
 
 	go b.ProcessQueue()
+	go b.BehaviorLoop()
 
 	log.Printf("[SYSTEM]: CONNECTION INITIATED TO %s:%d", targetIP, targetPort)
 }
 
 func (b *Bot) logENet(msg string) {
-	if b.ShowENet {
+	if b.ShowENet.Load() {
 		if b.OnDebug != nil {
 			b.OnDebug("ENET", msg, false)
 		}
@@ -362,22 +344,11 @@ func (b *Bot) SendPacket(text string, mType uint32) {
 	b.SendPacketWithDelay(text, mType, 0)
 }
 
+// SendPacketWithDelay queues a text packet; delay holds back the packets of
+// its class queued after it
 func (b *Bot) SendPacketWithDelay(text string, mType uint32, delay time.Duration) {
-	b.mu.Lock()
-	if !b.Connected { // Check connection status
-		b.mu.Unlock()
-		return
-	}
-	b.mu.Unlock()
-
-	// Packet Structure: [4 bytes Type] + [Text Bytes]
-	data := make([]byte, 4+len(text))
-
-	binary.LittleEndian.PutUint32(data[:4], uint32(mType))
-	copy(data[4:], []byte(text))
-
-	// Send to queue
-	b.packetQueue <- QueuedPacket{Data: data, Delay: delay}
+	data := textPacket(text, mType)
+	b.Enqueue(context.Background(), QueuedPacket{Data: data, Priority: defaultPriority(data), Delay: delay})
 }
 
 func (b *Bot) SendPacketRaw(p *TankPacketStruct) {
@@ -385,28 +356,63 @@ func (b *Bot) SendPacketRaw(p *TankPacketStruct) {
 }
 
 func (b *Bot) SendPacketRawWithDelay(p *TankPacketStruct, delay time.Duration) {
+	data := gamePacketData(p)
+	b.Enqueue(context.Background(), QueuedPacket{Data: data, Priority: defaultPriority(data), Delay: delay})
+}
+
+// Enqueue hands a packet to the send queue. It fails with ErrNotConnected
+// when the bot isn't connected, and otherwise as the queue policy says when
+// the queue is full; with the block policy ctx bounds the wait.
+func (b *Bot) Enqueue(ctx context.Context, p QueuedPacket) error {
 	b.mu.Lock()
-	if !b.Connected {
+	if !b.Connected || b.replaying {
 		b.mu.Unlock()
-		return
+		return ErrNotConnected
 	}
 	b.mu.Unlock()
 
+	b.humanize(&p)
+	return b.SendQueue.push(ctx, p)
+}
+
+// sendDisconnect tells the current server the bot is leaving. It skips the send
+// queue, which StopENet flushes, so the packet is on the peer before it closes.
+func (b *Bot) sendDisconnect() {
+	var client transport.Transport
+	b.withLock(func() {
+		if b.Connected && !b.replaying {
+			client = b.Client
+		}
+	})
+	if client == nil {
+		return
+	}
+	b.sendQueued(client, &QueuedPacket{Data: gamePacketData(&TankPacketStruct{Type: uint8(NET_GAME_PACKET_DISCONNECT)})})
+}
+
+// gamePacketData is [4 bytes NET_MESSAGE_GAME_PACKET] + the serialized tank packet
+func gamePacketData(p *TankPacketStruct) []byte {
 	raw := p.Serialize()
 	data := make([]byte, 4+len(raw))
 	binary.LittleEndian.PutUint32(data[:4], uint32(NET_MESSAGE_GAME_PACKET))
 	copy(data[4:], raw)
+	return data
+}
 
-	b.packetQueue <- QueuedPacket{Data: data, Delay: delay}
+// textPacket is [4 bytes type] + text
+func textPacket(text string, mType uint32) []byte {
+	data := make([]byte, 4+len(text))
+	binary.LittleEndian.PutUint32(data[:4], mType)
+	copy(data[4:], text)
+	return data
 }
 
-func (b *Bot) OnReceive(event *enet.Event) {
-	packet := event.Packet
-	if packet == nil {
-		return
-	}
+// HandlePacket dispatches one ENet payload received from the server. The
+// EventListener and Replay call it.
+func (b *Bot) HandlePacket(data []byte) {
+	defer b.RecoverPanic("OnReceive", data)
+	b.capturePacket(CaptureIn, data)
 
-	data := packet.GetData()
 	length := len(data)
 
 	if length < 4 {
@@ -415,7 +421,7 @@ func (b *Bot) OnReceive(event *enet.Event) {
 	}
 
 	// Hex Debugging
-	if b.ShowENet {
+	if b.ShowENet.Load() {
 		maxLen := 500
 		hexStr := ""
 		if length > maxLen {
@@ -441,6 +447,7 @@ func (b *Bot) OnReceive(event *enet.Event) {
 		}
 	}
 
+	b.inspectPacket(CaptureIn, data)
 	packetId := binary.LittleEndian.Uint32(data[:4])
 
 	switch packetId {
@@ -457,70 +464,58 @@ func (b *Bot) OnReceive(event *enet.Event) {
 	}
 }
 
+// ProcessQueue sends what the scheduler hands out, sleeping only while
+// nothing may go
 func (b *Bot) ProcessQueue() {
 	b.logENet("Starting Packet Queue Processor...")
+	defer b.RecoverPanic("ProcessQueue", nil)
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
 	for {
 		b.mu.Lock()
 		connected := b.Connected
-		peer := b.Peer
+		client := b.Client
+		stop := b.stop
 		b.mu.Unlock()
 
-		if !connected || peer == nil || peer.IsNil() {
+		if !connected || client == nil || !client.HasPeer() {
 			return
 		}
 
-		select {
-		case <-b.stop:
-			return
-		case qPkt := <-b.packetQueue:
-			pkt := qPkt.Data
-			b.mu.Lock()
-			currentPeer := b.Peer
-			if currentPeer != nil && !currentPeer.IsNil() {
-				// Unified Debugging for sent packets
-				if b.ShowENet && len(pkt) >= 4 {
-					pktId := binary.LittleEndian.Uint32(pkt[:4])
-
-					if pktId == NET_MESSAGE_GAME_PACKET && len(pkt) >= 60 {
-						var p TankPacketStruct
-						binary.Read(bytes.NewReader(pkt[4:]), binary.LittleEndian, &p)
-
-						logMsg := fmt.Sprintf("[SYSTEM]: SENDING GAME PACKET (%d):\n\n", pktId)
-						logMsg += fmt.Sprintf("  TYPE: %s\n", b.convertTankPacketType(p.Type))
-						logMsg += fmt.Sprintf("  OBJECT_TYPE: %d\n", p.ObjectType)
-						logMsg += fmt.Sprintf("  JUMP_COUNT: %d\n", p.JumpCount)
-						logMsg += fmt.Sprintf("  ANIMATION_TYPE: %d\n", p.AnimationType)
-						logMsg += fmt.Sprintf("  NETID: %d\n", p.NetID)
-						logMsg += fmt.Sprintf("  TARGET_NETID: %d\n", p.TargetNetID)
-						logMsg += fmt.Sprintf("  FLOAT_VARIABLE: %f\n", p.FloatVariable)
-						logMsg += fmt.Sprintf("  VALUE: %d\n", p.Value)
-						logMsg += fmt.Sprintf("  VECTOR_X: %f\n", p.VectorX)
-						logMsg += fmt.Sprintf("  VECTOR_Y: %f\n", p.VectorY)
-						logMsg += fmt.Sprintf("  VECTOR_X2: %f\n", p.VectorX2)
-						logMsg += fmt.Sprintf("  VECTOR_Y2: %f\n", p.VectorY2)
-						logMsg += fmt.Sprintf("  INT_X: %d\n", p.IntX)
-						logMsg += fmt.Sprintf("  INT_Y: %d\n", p.IntY)
-						logMsg += fmt.Sprintf("  EXT_DATA_SIZE: %d\n", p.ExtendedDataLength)
-						b.logENet(logMsg)
-					} else if (pktId == NET_MESSAGE_GENERIC_TEXT || pktId == NET_MESSAGE_GAME_MESSAGE) && len(pkt) > 4 {
-						msg := string(pkt[4:])
-						b.logENet(fmt.Sprintf("[SYSTEM]: SENDING TEXT PACKET (%d):\n%s", pktId, msg))
-					} else {
-						b.logENet(fmt.Sprintf("[SYSTEM]: SENDING NET_MESSAGE TYPE: %d (Length: %d)", pktId, len(pkt)))
-					}
-				}
-
-				currentPeer.Send(0, pkt, 1) // 1 = ENET_PACKET_FLAG_RELIABLE
-			}
-			b.mu.Unlock()
+		pkt, wait := b.SendQueue.next(time.Now())
+		if pkt != nil {
+			b.sendQueued(client, pkt)
+			continue
+		}
 
-			// Delay after sending
-			if qPkt.Delay > 0 {
-				time.Sleep(qPkt.Delay)
+		var ready <-chan time.Time
+		if wait > 0 {
+			if timer == nil {
+				timer = time.NewTimer(wait)
 			} else {
-				// Small delay between packets to prevent spamming
-				time.Sleep(1 * time.Millisecond)
+				timer.Reset(wait)
 			}
+			ready = timer.C
+		}
+		select {
+		case <-stop:
+			return
+		case <-b.SendQueue.wake:
+		case <-ready:
 		}
 	}
 }
+
+// sendQueued sends one packet from the queue to the current peer, without
+// holding b.mu so a slow send never stalls the handlers
+func (b *Bot) sendQueued(client transport.Transport, pkt *QueuedPacket) {
+	defer b.RecoverPanic("ProcessQueue", pkt.Data)
+
+	b.inspectPacket(CaptureOut, pkt.Data)
+	b.capturePacket(CaptureOut, pkt.Data)
+	client.SendOn(pkt.Channel, pkt.Data, !pkt.Unreliable)
+}
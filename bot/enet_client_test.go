@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"vortenixgo/network/transport"
+)
+
+func TestConnectClientMemory(t *testing.T) {
+	n := transport.NewNetwork()
+	received := make(chan string, 10)
+	conns := make(chan *transport.Conn, 1)
+	n.Listen("127.0.0.1", 17091, func(c *transport.Conn) func([]byte) {
+		conns <- c
+		c.Send(binary.LittleEndian.AppendUint32(nil, NET_MESSAGE_SERVER_HELLO))
+		return func(data []byte) { received <- string(data[4:]) }
+	})
+	prev := NewTransport
+	NewTransport = n.NewTransport
+	t.Cleanup(func() { NewTransport = prev })
+
+	b := NewBot("bot_tester", BotTypeLegacy, "tester", "", "")
+	b.Server.HTTPS.LToken = "token-1"
+	b.Server.Enet.NowConnectedIP, b.Server.Enet.NowConnectedPort = "127.0.0.1", 17091
+	t.Cleanup(b.StopENet)
+
+	b.ConnectClient()
+	select {
+	case login := <-received:
+		if !strings.Contains(login, "ltoken|token-1") {
+			t.Errorf("login packet %q", login)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no login packet after HELLO")
+	}
+	b.Lock()
+	status, connected := b.Status, b.Connected
+	b.Unlock()
+	if status != "online" || !connected {
+		t.Errorf("status %q connected %v after HELLO", status, connected)
+	}
+
+	// The server hangs up
+	(<-conns).Close()
+	deadline := time.Now().Add(time.Second)
+	for {
+		b.Lock()
+		status, connected = b.Status, b.Connected
+		b.Unlock()
+		if status == "offline" && !connected {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status %q connected %v after the server left", status, connected)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
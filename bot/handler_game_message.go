@@ -1,7 +1,6 @@
 package bot
 
 import (
-	"fmt"
 	"regexp"
 	"strings"
 )
@@ -10,9 +9,6 @@ func (b *Bot) handleGameMessage(data []byte) {
 	message := string(data)
 	message = strings.TrimRight(message, "\x00")
 
-	b.logENet("[SYSTEM]: RECEIVED GAME MESSAGE:\n" + message)
-	fmt.Println(message)
-
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -21,6 +17,7 @@ func (b *Bot) handleGameMessage(data []byte) {
 		b.Server.Enet.NowConnectedIP = b.Server.Enet.ServerIP
 		b.Server.Enet.NowConnectedPort = b.Server.Enet.ServerPort
 		go func() {
+			defer b.RecoverPanic("Relogin", nil)
 			b.DisconnectClient()
 			b.Connect()
 		}()
@@ -52,6 +49,11 @@ func (b *Bot) handleGameMessage(data []byte) {
 		if len(match) > 1 {
 			newVersion := match[1]
 			b.logENet("[SYSTEM]: Detected update version: " + newVersion)
+			// Runs outside b.mu since it locks every bot
+			go func() {
+				defer b.RecoverPanic("VersionUpdate", nil)
+				Versions.Update(newVersion)
+			}()
 		}
 		b.Connected = false
 	}
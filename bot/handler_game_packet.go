@@ -7,7 +7,6 @@ import (
 	"log"
 	"strconv"
 	"strings"
-	"vortenixgo/database"
 )
 
 // SendPacketRaw is now defined in enet_client.go
@@ -19,22 +18,6 @@ func (b *Bot) handleGamePacket(ptr []byte) {
 	var p TankPacketStruct
 	binary.Read(bytes.NewReader(ptr), binary.LittleEndian, &p)
 
-	logMsg := "[SYSTEM]: [RECEIVED PACKET RAW]:\n\n"
-	logMsg += fmt.Sprintf("  TYPE: %s\n", b.convertTankPacketType(p.Type))
-	logMsg += fmt.Sprintf("  OBJECT_TYPE: %d\n", p.ObjectType)
-	logMsg += fmt.Sprintf("  NETID: %d\n", p.NetID)
-	logMsg += fmt.Sprintf("  TARGET_NETID: %d\n", p.TargetNetID)
-	logMsg += fmt.Sprintf("  FLOAT_VARIABLE: %f\n", p.FloatVariable)
-	logMsg += fmt.Sprintf("  VALUE: %d\n", p.Value)
-	logMsg += fmt.Sprintf("  VECTOR_X: %f\n", p.VectorX)
-	logMsg += fmt.Sprintf("  VECTOR_Y: %f\n", p.VectorY)
-	logMsg += fmt.Sprintf("  VECTOR_X2: %f\n", p.VectorX2)
-	logMsg += fmt.Sprintf("  VECTOR_Y2: %f\n", p.VectorY2)
-	logMsg += fmt.Sprintf("  INT_X: %d\n", p.IntX)
-	logMsg += fmt.Sprintf("  INT_Y: %d\n", p.IntY)
-	logMsg += fmt.Sprintf("  EXT_DATA_SIZE: %d\n", p.ExtendedDataLength)
-	b.logENet(logMsg)
-
 	switch ETankPacketType(p.Type) {
 	case NET_GAME_PACKET_STATE:
 		b.mu.Lock()
@@ -58,28 +41,27 @@ func (b *Bot) handleGamePacket(ptr []byte) {
 		}
 
 	case NET_GAME_PACKET_CALL_FUNCTION:
-		b.logENet("[SYSTEM]: RECEIVED GAME PACKET: " + b.convertTankPacketType(uint8(NET_GAME_PACKET_CALL_FUNCTION)))
 		b.handleGamePacket_CallFunction(ptr, &p)
 	case NET_GAME_PACKET_SET_CHARACTER_STATE:
-		b.logENet("[SYSTEM]: RECEIVED GAME PACKET: " + b.convertTankPacketType(uint8(NET_GAME_PACKET_SET_CHARACTER_STATE)))
-		b.mu.Lock()
-		b.Local.HackType = int32(p.Value)
-		b.Local.BuildLength = int(p.JumpCount) - 126
-		b.Local.PunchLength = int(p.AnimationType) - 126
-		b.Local.Gravity = p.VectorX2
-		b.Local.Velocity = p.VectorY2
-		b.mu.Unlock()
+		b.withLock(func() {
+			b.Local.HackType = int32(p.Value)
+			b.Local.BuildLength = int(p.JumpCount) - 126
+			b.Local.PunchLength = int(p.AnimationType) - 126
+			b.Local.Gravity = p.VectorX2
+			b.Local.Velocity = p.VectorY2
+		})
 
 	case NET_GAME_PACKET_PING_REQUEST:
-		b.mu.Lock()
-		b.Status = "online"
-		buildLen := b.Local.BuildLength
-		punchLen := b.Local.PunchLength
-		hackType := b.Local.HackType
-		velocity := b.Local.Velocity
-		gravity := b.Local.Gravity
-		worldName := b.World
-		b.mu.Unlock()
+		var buildLen, punchLen int
+		var hackType int32
+		var velocity, gravity float32
+		var worldName string
+		b.withLock(func() {
+			b.Status = "online"
+			buildLen, punchLen = b.Local.BuildLength, b.Local.PunchLength
+			hackType, velocity, gravity = b.Local.HackType, b.Local.Velocity, b.Local.Gravity
+			worldName = b.World
+		})
 
 		var tank TankPacketStruct
 		tank.Type = uint8(NET_GAME_PACKET_PING_REPLY)
@@ -105,8 +87,7 @@ func (b *Bot) handleGamePacket(ptr []byte) {
 		}
 		b.SendPacketRaw(&tank)
 	case NET_GAME_PACKET_SEND_ITEM_DATABASE_DATA:
-		// Manual update selected, ignoring automatic data
-		b.logENet("[SYSTEM]: Received items.dat packet, but ignoring due to manual update mode.")
+		b.handleItemDatabaseData(ptr, &p)
 	case NET_GAME_PACKET_SEND_INVENTORY_STATE:
 		b.handleInventoryState(ptr)
 	case NET_GAME_PACKET_MODIFY_ITEM_INVENTORY:
@@ -118,9 +99,11 @@ func (b *Bot) handleGamePacket(ptr []byte) {
 			if err := b.ParseWorld(mapData); err != nil {
 				b.logENet(fmt.Sprintf("Failed to parse world: %v", err))
 			} else {
-				b.mu.Lock()
-				b.Status = "In World"
-				b.mu.Unlock()
+				b.withLock(func() {
+					b.Status = "In World"
+					b.Local.Players = []Players{} // OnSpawn follows for everyone in the new world
+					b.joinedLocked(b.Local.World.Name)
+				})
 				if b.OnUpdate != nil {
 					b.OnUpdate()
 				}
@@ -139,7 +122,7 @@ func (b *Bot) handleGamePacket(ptr []byte) {
 	case NET_GAME_PACKET_PET_BATTLE:
 		// Silently ignore for now
 	default:
-		log.Printf("Unknown packet type: %s (%d)\n", b.convertTankPacketType(p.Type), p.Type)
+		log.Printf("Unknown packet type: %s (%d)\n", TankPacketTypeName(p.Type), p.Type)
 	}
 }
 
@@ -148,60 +131,62 @@ func (b *Bot) handleGamePacket_CallFunction(ptr []byte, p *TankPacketStruct) {
 		return
 	}
 	varList := &VariantList{}
-	varList.Parse(ptr[56:])
-
-	b.logENet("[SYSTEM]: RECEIVED VARIANT LIST:\n" + varList.String())
+	if err := varList.Parse(ptr[56:]); err != nil {
+		b.logENet(fmt.Sprintf("[SYSTEM]: Malformed variant list: %v", err))
+	}
 
 	if len(varList.Variants) > 0 {
-		headVar, _ := varList.Variants[0].(string)
+		headVar, _ := varList.Get(0).(string)
 		if headVar == "OnSendToServer" {
-			b.mu.Lock()
-			b.Login.User = varList.GetString(3)
-			if varList.GetString(2) != "-1" {
-				b.Login.UserToken = varList.GetString(2)
-			}
-			port := varList.GetInt(1)
-			b.Server.Enet.SubServerPort = int(port)
-			b.Login.TankIDName = varList.GetString(6)
-			b.Name = b.Login.TankIDName
-			b.Status = "Redirecting"
-
-			ipPortDoor, _ := varList.Variants[4].(string)
-			v := strings.Split(ipPortDoor, "|")
-			if len(v) >= 1 {
-				b.Server.Enet.SubServerIP = v[0]
-			}
-			if len(v) >= 2 {
-				b.Login.DoorID = v[1]
-			}
-			if len(v) >= 3 && v[2] != "-1" && v[2] != "0" && v[2] != "" {
-				if v[2] != "-1" {
-					b.Login.UUIDToken = v[2]
+			b.withLock(func() {
+				b.Login.User = varList.GetString(3)
+				if varList.GetString(2) != "-1" {
+					b.Login.UserToken = varList.GetString(2)
 				}
-			}
-			lmode := varList.GetInt(5)
-			b.Login.LMode = fmt.Sprintf("%d", lmode)
-
-			b.Server.Enet.NowConnectedIP = b.Server.Enet.SubServerIP
-			b.Server.Enet.NowConnectedPort = b.Server.Enet.SubServerPort
-			b.mu.Unlock()
+				port := varList.GetInt(1)
+				b.Server.Enet.SubServerPort = int(port)
+				b.Login.TankIDName = varList.GetString(6)
+				b.Name = b.Login.TankIDName
+				b.Status = "Redirecting"
+
+				ipPortDoor, _ := varList.Get(4).(string)
+				v := strings.Split(ipPortDoor, "|")
+				if len(v) >= 1 {
+					b.Server.Enet.SubServerIP = v[0]
+				}
+				if len(v) >= 2 {
+					b.Login.DoorID = v[1]
+				}
+				if len(v) >= 3 && v[2] != "-1" && v[2] != "0" && v[2] != "" {
+					if v[2] != "-1" {
+						b.Login.UUIDToken = v[2]
+					}
+				}
+				lmode := varList.GetInt(5)
+				b.Login.LMode = fmt.Sprintf("%d", lmode)
 
-			var tank TankPacketStruct
-			tank.Type = uint8(NET_GAME_PACKET_DISCONNECT)
-			tank.NetID = 0
-			b.SendPacketRaw(&tank)
+				b.Server.Enet.NowConnectedIP = b.Server.Enet.SubServerIP
+				b.Server.Enet.NowConnectedPort = b.Server.Enet.SubServerPort
+			})
 
 			generator := &GenerateLoginData{}
 			generator.CreateLoginPacket(b)
 			go func() {
+				defer b.RecoverPanic("Redirect", nil)
+				b.sendDisconnect()
 				b.DisconnectClient()
 				b.ConnectClient()
 			}()
 		} else if headVar == "OnSuperMainStartAcceptLogonHrdxs47254722215a" {
 			serverHash := varList.GetUint(1)
-			b.mu.Lock()
-			b.Local.ServerHash = int(serverHash)
-			b.mu.Unlock()
+			b.withLock(func() {
+				b.Local.ServerHash = int(serverHash)
+			})
+
+			if b.needsItemsUpdate(serverHash) {
+				b.logENet(fmt.Sprintf("[SYSTEM]: items.dat hash differs (server %d, local %d), requesting update", serverHash, b.localItemsDatHash()))
+				b.SendPacket("action|refresh_item_data\n", NET_MESSAGE_GENERIC_TEXT)
+			}
 
 			b.SendPacket("action|enter_game\n", NET_MESSAGE_GENERIC_TEXT)
 
@@ -223,15 +208,33 @@ func (b *Bot) handleGamePacket_CallFunction(ptr []byte, p *TankPacketStruct) {
 					favItems = append(favItems, v)
 				}
 			}
-			b.mu.Lock()
-			b.Local.FavoriteItems = favItems
-			b.Local.FavoriteItemsSlot = int(varList.GetInt(2))
-			b.mu.Unlock()
+			b.withLock(func() {
+				b.Local.FavoriteItems = favItems
+				b.Local.FavoriteItemsSlot = int(varList.GetInt(2))
+			})
 		} else if headVar == "SetHasGrowID" {
-			b.mu.Lock()
-			b.Local.Name = varList.GetString(2)
-			b.Local.Password = varList.GetString(3)
-			b.mu.Unlock()
+			b.withLock(func() {
+				b.Local.Name = varList.GetString(2)
+				b.Local.Password = varList.GetString(3)
+			})
+		} else if headVar == "OnConsoleMessage" {
+			b.withLock(func() {
+				b.joinConsoleLocked(varList.GetString(1))
+			})
+		} else if headVar == "OnFailedToEnterWorld" {
+			b.withLock(func() {
+				if b.join != nil && b.join.world != "EXIT" {
+					b.endJoinLocked(ErrJoinFailed)
+				}
+			})
+		} else if headVar == "OnRequestWorldSelectMenu" {
+			b.withLock(func() {
+				b.Local.World = World{Name: "EXIT"}
+				b.Local.Players = []Players{}
+				b.Local.NetID = -1
+				b.Status = "online"
+				b.joinedLocked("EXIT")
+			})
 		} else if headVar == "OnSpawn" {
 			raw := varList.GetString(1)
 			lines := strings.Split(raw, "\n")
@@ -285,18 +288,19 @@ func (b *Bot) handleGamePacket_CallFunction(ptr []byte, p *TankPacketStruct) {
 				b.logENet("[SYSTEM]: Player " + p.Name + " is modded.")
 				p.Mod = true
 			}
-			b.mu.Lock()
-			b.Local.Players = append(b.Local.Players, p)
-			if p.IsLocal {
-				b.Local.NetID = p.NetID
-				b.Local.UserID = p.UserID
-				b.Local.Name = p.Name
-				b.Local.PosX = p.PosX
-				b.Local.PosY = p.PosY
-			}
-			b.mu.Unlock()
+			b.withLock(func() {
+				b.Local.Players = append(b.Local.Players, p)
+				if p.IsLocal {
+					b.Local.NetID = p.NetID
+					b.Local.UserID = p.UserID
+					b.Local.Name = p.Name
+					b.Local.PosX = p.PosX
+					b.Local.PosY = p.PosY
+					b.teleportedLocked()
+				}
+			})
 		} else if headVar == "OnRemove" {
-			raw, _ := varList.Variants[1].(string)
+			raw, _ := varList.Get(1).(string)
 
 			// ambil netID
 			netIDRemove := -1
@@ -342,19 +346,22 @@ func (b *Bot) handleGamePacket_CallFunction(ptr []byte, p *TankPacketStruct) {
 					if player.NetID == b.Local.NetID {
 						b.Local.PosX = v.X
 						b.Local.PosY = v.Y
+						b.teleportedLocked()
 					}
 					break
 				}
 			}
 		} else if headVar == "OnSetBux" {
-			switch v := varList.Variants[1].(type) {
-			case int32:
-				b.Local.GemCount = int(v)
-			case uint32:
-				b.Local.GemCount = int(v)
-			case float32:
-				b.Local.GemCount = int(v)
-			}
+			b.withLock(func() {
+				switch v := varList.Get(1).(type) {
+				case int32:
+					b.Local.GemCount = int(v)
+				case uint32:
+					b.Local.GemCount = int(v)
+				case float32:
+					b.Local.GemCount = int(v)
+				}
+			})
 		} else if headVar == "OnSetClothing" {
 			b.mu.Lock()
 			defer b.mu.Unlock()
@@ -430,52 +437,57 @@ func (b *Bot) handleGamePacket_CallFunction(ptr []byte, p *TankPacketStruct) {
 	}
 }
 
+// inventoryEntry is one slot of NET_GAME_PACKET_SEND_INVENTORY_STATE
+type inventoryEntry struct {
+	ID    int16
+	Count uint8
+}
+
+// parseInventoryState decodes the extended data of an inventory state packet:
+// 5 unknown bytes, the slot count, then {flags u8, id u16, count u8} per item.
+// A truncated list returns the items read so far along with an error.
+func parseInventoryState(data []byte) (int, []inventoryEntry, error) {
+	r := newPacketReader(data)
+	r.skip(5)
+	size := int(r.u8())
+	if r.err != nil {
+		return 0, nil, fmt.Errorf("inventory state: %w", r.err)
+	}
+
+	entries := make([]inventoryEntry, 0, min(size, r.Remaining()/3))
+	for i := 0; i < size; i++ {
+		r.skip(1) // Flags
+		entry := inventoryEntry{ID: int16(r.u16()), Count: r.u8()}
+		if r.err != nil {
+			return size, entries, fmt.Errorf("inventory state: item %d of %d: %w", i, size, r.err)
+		}
+		entries = append(entries, entry)
+	}
+	return size, entries, nil
+}
+
 func (b *Bot) handleInventoryState(ptr []byte) {
-	if len(ptr) < 56+5+1 {
+	if len(ptr) < 56 {
 		return
 	}
+	size, entries, err := parseInventoryState(ptr[56:])
+	if err != nil {
+		b.logENet(fmt.Sprintf("[SYSTEM]: %v", err))
+		if entries == nil {
+			return
+		}
+	}
 
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
 	b.Local.Inventory = nil
+	db := b.itemDB()
 
-	offset := 56 + 5 // offset = 61
-
-	// Match C++: uint8_t size = *(ptr);
-	// Offset is at 61 (56 + 5)
-	size := int(ptr[offset])
-	// C++ loop uses ptr += 2 immediately, so we keep offset at 'size' position initially?
-	// C++: ptr points to size.
-	// Loop: ptr += 2 (skips size and next byte). item.id = ...
-	// So we DO NOT increment offset here if we want to mimic the relative jumps inside loop starting from 'size' pos.
-	// But to be cleaner in Go, let's track absolute:
-	// Ptr starts at 61.
-
-	// We allow the loop to handle the increments relative to 'current' pointer.
-	// So we keep offset = 61.
-
-	for i := 0; i < size; i++ {
-		// ptr += 2 (skips size byte and next byte)
-		offset += 2
-		if offset+2 > len(ptr) { // Need 2 bytes for itemID
-			break
-		}
-
-		// item.id = *(short*)(ptr)
-		itemID := int16(binary.LittleEndian.Uint16(ptr[offset : offset+2]))
-
-		// ptr += 2 (skips itemID)
-		offset += 2
-		if offset >= len(ptr) { // Need 1 byte for count
-			break
-		}
-
-		// item.count = *(ptr)
-		count := ptr[offset]
+	for _, entry := range entries {
+		itemID, count := entry.ID, entry.Count
 
 		var itemName string
-		db := database.GetGlobalItemDB()
 		if db != nil {
 			itemDef := db.GetItem(uint32(itemID))
 			if itemDef != nil {
@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInventoryState(t *testing.T) {
+	data := []byte{
+		0x01, 0x00, 0x00, 0x00, 0x00, // Unknown
+		3,                     // Size
+		0x00, 0x12, 0x00, 200, // Dirt x200
+		0x01, 0x02, 0x00, 1, // Fist
+		0x00, 0x10, 0x27, 5, // Item 10000 x5
+	}
+	size, entries, err := parseInventoryState(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []inventoryEntry{{ID: 18, Count: 200}, {ID: 2, Count: 1}, {ID: 10000, Count: 5}}
+	if size != 3 || !reflect.DeepEqual(entries, want) {
+		t.Fatalf("got %d %v, want 3 %v", size, entries, want)
+	}
+
+	// Cut the last item short: the first two are still returned
+	size, entries, err = parseInventoryState(data[:len(data)-1])
+	if err == nil || size != 3 || !reflect.DeepEqual(entries, want[:2]) {
+		t.Fatalf("truncated: got %d %v %v", size, entries, err)
+	}
+
+	if _, entries, err := parseInventoryState(data[:5]); err == nil || entries != nil {
+		t.Fatalf("missing size: got %v %v", entries, err)
+	}
+}
+
+func TestHandleInventoryStateShort(t *testing.T) {
+	b := NewBot("inv", BotTypeLegacy, "b", "", "")
+	for n := 0; n < 56+9; n++ {
+		b.handleInventoryState(make([]byte, n))
+	}
+	ptr := make([]byte, 56+6)
+	ptr[56+5] = 255 // Claims 255 items with no data behind the size
+	b.handleInventoryState(ptr)
+	if len(b.Local.Inventory) != 0 {
+		t.Fatalf("inventory = %v", b.Local.Inventory)
+	}
+}
+
+func FuzzParseInventoryState(f *testing.F) {
+	f.Add([]byte{1, 0, 0, 0, 0, 2, 0, 0x12, 0, 200, 1, 0x02, 0, 1})
+	f.Add([]byte{0, 0, 0, 0, 0, 0xFF})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		size, entries, err := parseInventoryState(data)
+		if len(entries) > size || len(entries)*4 > len(data) {
+			t.Fatalf("%d entries for size %d from %d bytes", len(entries), size, len(data))
+		}
+		if err == nil && len(entries) != size {
+			t.Fatalf("%d entries for size %d without error", len(entries), size)
+		}
+	})
+}
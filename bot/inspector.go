@@ -0,0 +1,329 @@
+package bot
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PacketEvent is one decoded packet as seen by the inspector. It marshals to
+// the JSON streamed to WS subscribers.
+type PacketEvent struct {
+	BotID  string    `json:"bot_id"`
+	Time   time.Time `json:"time"`
+	Dir    string    `json:"dir"`  // "in" or "out"
+	Type   string    `json:"type"` // NET_MESSAGE_* name, e.g. "GAME_PACKET"
+	Length int       `json:"length"`
+
+	Tank         *TankPacketView `json:"tank,omitempty"`
+	Variants     []interface{}   `json:"variants,omitempty"`
+	VariantError string          `json:"variant_error,omitempty"`
+	Text         []TextField     `json:"text,omitempty"`
+}
+
+// TankPacketView is TankPacketStruct with JSON names and safe floats
+type TankPacketView struct {
+	Type               string       `json:"type"` // NET_GAME_PACKET_* name, e.g. "CALL_FUNCTION"
+	ObjectType         uint8        `json:"object_type"`
+	JumpCount          uint8        `json:"jump_count"`
+	AnimationType      uint8        `json:"animation_type"`
+	NetID              int32        `json:"net_id"`
+	TargetNetID        int32        `json:"target_net_id"`
+	Flags              uint32       `json:"flags"`
+	FloatVariable      inspectFloat `json:"float_variable"`
+	Value              uint32       `json:"value"`
+	VectorX            inspectFloat `json:"vector_x"`
+	VectorY            inspectFloat `json:"vector_y"`
+	VectorX2           inspectFloat `json:"vector_x2"`
+	VectorY2           inspectFloat `json:"vector_y2"`
+	ParticleRotation   inspectFloat `json:"particle_rotation"`
+	IntX               int32        `json:"int_x"`
+	IntY               int32        `json:"int_y"`
+	ExtendedDataLength uint32       `json:"extended_data_length"`
+}
+
+// TextField is one "key|value" line of a text packet, in packet order
+type TextField struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// inspectFloat prints float32s at their own precision and keeps NaN and
+// infinities (which encoding/json rejects) as strings
+type inspectFloat float32
+
+func (f inspectFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return []byte(strconv.Quote(fmt.Sprintf("%v", v))), nil
+	}
+	return strconv.AppendFloat(nil, v, 'g', -1, 32), nil
+}
+
+// NewPacketEvent decodes one ENet payload (starting with the 4 byte message
+// type). Malformed packets still produce an event with whatever could be read.
+func NewPacketEvent(botID string, dir CaptureDirection, t time.Time, data []byte) *PacketEvent {
+	ev := &PacketEvent{BotID: botID, Time: t, Dir: dir.String(), Length: len(data)}
+	if len(data) < 4 {
+		ev.Type = MessageTypeName(NET_MESSAGE_UNKNOWN)
+		return ev
+	}
+	msgType := binary.LittleEndian.Uint32(data[:4])
+	ev.Type = MessageTypeName(msgType)
+	body := data[4:]
+
+	switch msgType {
+	case NET_MESSAGE_GENERIC_TEXT, NET_MESSAGE_GAME_MESSAGE:
+		ev.Text = parseTextFields(body)
+	case NET_MESSAGE_GAME_PACKET:
+		if len(body) < 56 {
+			break
+		}
+		var p TankPacketStruct
+		r := newPacketReader(body)
+		p.Type, p.ObjectType, p.JumpCount, p.AnimationType = r.u8(), r.u8(), r.u8(), r.u8()
+		p.NetID, p.TargetNetID = int32(r.u32()), int32(r.u32())
+		p.Flags, p.FloatVariable, p.Value = r.u32(), r.f32(), r.u32()
+		p.VectorX, p.VectorY, p.VectorX2, p.VectorY2, p.ParticleRotation = r.f32(), r.f32(), r.f32(), r.f32(), r.f32()
+		p.IntX, p.IntY = int32(r.u32()), int32(r.u32())
+		p.ExtendedDataLength = r.u32()
+		ev.Tank = newTankPacketView(&p)
+
+		if ETankPacketType(p.Type) == NET_GAME_PACKET_CALL_FUNCTION {
+			var vl VariantList
+			if err := vl.Parse(body[56:]); err != nil {
+				ev.VariantError = err.Error()
+			}
+			for _, v := range vl.Variants {
+				ev.Variants = append(ev.Variants, inspectVariant(v))
+			}
+		}
+	}
+	return ev
+}
+
+func newTankPacketView(p *TankPacketStruct) *TankPacketView {
+	return &TankPacketView{
+		Type:               TankPacketTypeName(p.Type),
+		ObjectType:         p.ObjectType,
+		JumpCount:          p.JumpCount,
+		AnimationType:      p.AnimationType,
+		NetID:              p.NetID,
+		TargetNetID:        p.TargetNetID,
+		Flags:              p.Flags,
+		FloatVariable:      inspectFloat(p.FloatVariable),
+		Value:              p.Value,
+		VectorX:            inspectFloat(p.VectorX),
+		VectorY:            inspectFloat(p.VectorY),
+		VectorX2:           inspectFloat(p.VectorX2),
+		VectorY2:           inspectFloat(p.VectorY2),
+		ParticleRotation:   inspectFloat(p.ParticleRotation),
+		IntX:               p.IntX,
+		IntY:               p.IntY,
+		ExtendedDataLength: p.ExtendedDataLength,
+	}
+}
+
+func inspectVariant(v interface{}) interface{} {
+	switch v := v.(type) {
+	case float32:
+		return inspectFloat(v)
+	case Vector2:
+		return map[string]inspectFloat{"x": inspectFloat(v.X), "y": inspectFloat(v.Y)}
+	case Vector3:
+		return map[string]inspectFloat{"x": inspectFloat(v.X), "y": inspectFloat(v.Y), "z": inspectFloat(v.Z)}
+	}
+	return v
+}
+
+// parseTextFields splits "key|value" lines. A line without a separator becomes
+// a key with an empty value.
+func parseTextFields(body []byte) []TextField {
+	var fields []TextField
+	for _, line := range strings.Split(strings.TrimRight(string(body), "\x00"), "\n") {
+		if line == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(line, "|")
+		fields = append(fields, TextField{Key: key, Value: value})
+	}
+	return fields
+}
+
+// String renders the event for the ENet debug console
+func (ev *PacketEvent) String() string {
+	var sb strings.Builder
+	verb := "RECEIVED"
+	if ev.Dir == CaptureOut.String() {
+		verb = "SENDING"
+	}
+	fmt.Fprintf(&sb, "[SYSTEM]: %s %s (%d bytes)\n", verb, ev.Type, ev.Length)
+
+	if p := ev.Tank; p != nil {
+		fmt.Fprintf(&sb, "  TYPE: %s\n", p.Type)
+		fmt.Fprintf(&sb, "  OBJECT_TYPE: %d  JUMP_COUNT: %d  ANIMATION_TYPE: %d\n", p.ObjectType, p.JumpCount, p.AnimationType)
+		fmt.Fprintf(&sb, "  NETID: %d  TARGET_NETID: %d  FLAGS: 0x%x\n", p.NetID, p.TargetNetID, p.Flags)
+		fmt.Fprintf(&sb, "  FLOAT_VARIABLE: %g  VALUE: %d\n", p.FloatVariable, p.Value)
+		fmt.Fprintf(&sb, "  VECTOR: (%g, %g)  VECTOR2: (%g, %g)\n", p.VectorX, p.VectorY, p.VectorX2, p.VectorY2)
+		fmt.Fprintf(&sb, "  INT: (%d, %d)  EXT_DATA_SIZE: %d\n", p.IntX, p.IntY, p.ExtendedDataLength)
+	}
+	for i, v := range ev.Variants {
+		fmt.Fprintf(&sb, "  [%d] %v\n", i, v)
+	}
+	if ev.VariantError != "" {
+		fmt.Fprintf(&sb, "  VARIANT ERROR: %s\n", ev.VariantError)
+	}
+	for _, f := range ev.Text {
+		fmt.Fprintf(&sb, "  %s|%s\n", f.Key, f.Value)
+	}
+	return sb.String()
+}
+
+// PacketFilter selects the packets a subscriber sees. Empty fields match
+// everything.
+type PacketFilter struct {
+	Bots []string `json:"bots"`
+	Dir  string   `json:"dir"` // "in", "out" or ""
+	// Message or tank packet type names, with or without the NET_MESSAGE_ /
+	// NET_GAME_PACKET_ prefix, e.g. "GAME_MESSAGE" or "CALL_FUNCTION"
+	Types []string `json:"types"`
+}
+
+func normalizePacketType(name string) string {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	name = strings.TrimPrefix(name, "NET_MESSAGE_")
+	return strings.TrimPrefix(name, "NET_GAME_PACKET_")
+}
+
+// match looks only at the packet header, so events are decoded only for
+// packets someone wants
+func (f *PacketFilter) match(botID string, dir CaptureDirection, data []byte) bool {
+	if f.Dir != "" && f.Dir != dir.String() {
+		return false
+	}
+	if len(f.Bots) > 0 {
+		found := false
+		for _, id := range f.Bots {
+			if id == botID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+
+	var msgType uint32
+	if len(data) >= 4 {
+		msgType = binary.LittleEndian.Uint32(data[:4])
+	}
+	tankType := ""
+	if msgType == NET_MESSAGE_GAME_PACKET && len(data) > 4 {
+		tankType = TankPacketTypeName(data[4])
+	}
+	for _, t := range f.Types {
+		if t == MessageTypeName(msgType) || (tankType != "" && t == tankType) {
+			return true
+		}
+	}
+	return false
+}
+
+type packetSubscriber struct {
+	filter PacketFilter
+	fn     func(*PacketEvent)
+}
+
+// PacketInspector fans decoded packet events out to subscribers. With no
+// subscribers a packet costs one atomic load.
+type PacketInspector struct {
+	mu     sync.RWMutex
+	subs   map[*packetSubscriber]struct{}
+	active atomic.Int32
+}
+
+// Inspector is the process wide packet inspector fed by every bot
+var Inspector = NewPacketInspector()
+
+func NewPacketInspector() *PacketInspector {
+	return &PacketInspector{subs: make(map[*packetSubscriber]struct{})}
+}
+
+// Subscribe calls fn for every packet matching filter until the returned
+// function is called. fn runs on the bot's network goroutine, possibly with
+// the bot locked: it must not block or call back into the bot.
+func (in *PacketInspector) Subscribe(filter PacketFilter, fn func(*PacketEvent)) (unsubscribe func()) {
+	types := make([]string, len(filter.Types))
+	for i, t := range filter.Types {
+		types[i] = normalizePacketType(t)
+	}
+	filter.Types = types
+	sub := &packetSubscriber{filter: filter, fn: fn}
+
+	in.mu.Lock()
+	in.subs[sub] = struct{}{}
+	in.active.Store(int32(len(in.subs)))
+	in.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			in.mu.Lock()
+			delete(in.subs, sub)
+			in.active.Store(int32(len(in.subs)))
+			in.mu.Unlock()
+		})
+	}
+}
+
+// Active reports whether anyone is subscribed
+func (in *PacketInspector) Active() bool {
+	return in.active.Load() > 0
+}
+
+// matching returns the subscribers that want this packet
+func (in *PacketInspector) matching(botID string, dir CaptureDirection, data []byte) []*packetSubscriber {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	var subs []*packetSubscriber
+	for sub := range in.subs {
+		if sub.filter.match(botID, dir, data) {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// inspectPacket publishes data to the inspector and, with ShowENet, to the
+// debug console. Nothing is decoded unless one of them wants it. Like
+// capturePacket it never takes b.mu.
+func (b *Bot) inspectPacket(dir CaptureDirection, data []byte) {
+	show := b.ShowENet.Load()
+	if !show && !Inspector.Active() {
+		return
+	}
+	var subs []*packetSubscriber
+	if Inspector.Active() {
+		subs = Inspector.matching(b.ID, dir, data)
+	}
+	if !show && len(subs) == 0 {
+		return
+	}
+
+	ev := NewPacketEvent(b.ID, dir, time.Now(), data)
+	if show {
+		b.logENet(ev.String())
+	}
+	for _, sub := range subs {
+		sub.fn(ev)
+	}
+}
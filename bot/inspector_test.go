@@ -0,0 +1,174 @@
+package bot
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPacketEventJSON(t *testing.T) {
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name string
+		dir  CaptureDirection
+		data []byte
+		want string
+	}{
+		{
+			name: "call function",
+			dir:  CaptureIn,
+			data: gamePacket(TankPacketStruct{Type: uint8(NET_GAME_PACKET_CALL_FUNCTION), NetID: -1, VectorX: 0.1},
+				(&variantWriter{}).str("OnSetPos").vec2(32, float32(math.NaN())).int(-5).bytes()),
+			want: `{"bot_id":"bot_a","time":"2025-01-02T03:04:05Z","dir":"in","type":"GAME_PACKET","length":91,` +
+				`"tank":{"type":"CALL_FUNCTION","object_type":0,"jump_count":0,"animation_type":0,"net_id":-1,"target_net_id":0,"flags":0,` +
+				`"float_variable":0,"value":0,"vector_x":0.1,"vector_y":0,"vector_x2":0,"vector_y2":0,"particle_rotation":0,` +
+				`"int_x":0,"int_y":0,"extended_data_length":31},` +
+				`"variants":["OnSetPos",{"x":32,"y":"NaN"},-5]}`,
+		},
+		{
+			name: "text",
+			dir:  CaptureOut,
+			data: append([]byte{2, 0, 0, 0}, "action|join_request\nname|START\ninvitedWorld\n\x00"...),
+			want: `{"bot_id":"bot_a","time":"2025-01-02T03:04:05Z","dir":"out","type":"GENERIC_TEXT","length":49,` +
+				`"text":[{"key":"action","value":"join_request"},{"key":"name","value":"START"},{"key":"invitedWorld","value":""}]}`,
+		},
+		{
+			name: "truncated variants",
+			dir:  CaptureIn,
+			data: gamePacket(TankPacketStruct{Type: uint8(NET_GAME_PACKET_CALL_FUNCTION)}, []byte{2, 0, 2, 9, 0, 0, 0, 'h'}),
+			want: `{"bot_id":"bot_a","time":"2025-01-02T03:04:05Z","dir":"in","type":"GAME_PACKET","length":68,` +
+				`"tank":{"type":"CALL_FUNCTION","object_type":0,"jump_count":0,"animation_type":0,"net_id":0,"target_net_id":0,"flags":0,` +
+				`"float_variable":0,"value":0,"vector_x":0,"vector_y":0,"vector_x2":0,"vector_y2":0,"particle_rotation":0,` +
+				`"int_x":0,"int_y":0,"extended_data_length":8},` +
+				`"variant_error":"variant 0 (type 2): unexpected EOF"}`,
+		},
+		{
+			name: "short tank packet",
+			dir:  CaptureIn,
+			data: []byte{4, 0, 0, 0, 1, 2, 3},
+			want: `{"bot_id":"bot_a","time":"2025-01-02T03:04:05Z","dir":"in","type":"GAME_PACKET","length":7}`,
+		},
+		{
+			name: "unknown message",
+			dir:  CaptureIn,
+			data: []byte{99, 0, 0, 0},
+			want: `{"bot_id":"bot_a","time":"2025-01-02T03:04:05Z","dir":"in","type":"UNKNOWN (99)","length":4}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(NewPacketEvent("bot_a", tt.dir, at, tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPacketFilter(t *testing.T) {
+	callFunction := gamePacket(TankPacketStruct{Type: uint8(NET_GAME_PACKET_CALL_FUNCTION)}, nil)
+	text := []byte{3, 0, 0, 0, 'a'}
+	tests := []struct {
+		name   string
+		filter PacketFilter
+		botID  string
+		dir    CaptureDirection
+		data   []byte
+		want   bool
+	}{
+		{"empty matches all", PacketFilter{}, "bot_a", CaptureOut, text, true},
+		{"bot", PacketFilter{Bots: []string{"bot_b", "bot_a"}}, "bot_a", CaptureIn, text, true},
+		{"other bot", PacketFilter{Bots: []string{"bot_b"}}, "bot_a", CaptureIn, text, false},
+		{"dir", PacketFilter{Dir: "out"}, "bot_a", CaptureIn, text, false},
+		{"message type", PacketFilter{Types: []string{"game_message"}}, "bot_a", CaptureIn, text, true},
+		{"prefixed tank type", PacketFilter{Types: []string{"NET_GAME_PACKET_CALL_FUNCTION"}}, "bot_a", CaptureIn, callFunction, true},
+		{"message type of tank packet", PacketFilter{Types: []string{"GAME_PACKET"}}, "bot_a", CaptureIn, callFunction, true},
+		{"other type", PacketFilter{Types: []string{"STATE"}}, "bot_a", CaptureIn, callFunction, false},
+		{"text is not a tank type", PacketFilter{Types: []string{"CALL_FUNCTION"}}, "bot_a", CaptureIn, text, false},
+		{"short packet", PacketFilter{Types: []string{"GAME_PACKET"}}, "bot_a", CaptureIn, []byte{4}, false},
+	}
+
+	in := NewPacketInspector()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsubscribe := in.Subscribe(tt.filter, func(*PacketEvent) {})
+			got := len(in.matching(tt.botID, tt.dir, tt.data)) == 1
+			unsubscribe()
+			if got != tt.want {
+				t.Errorf("match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if in.Active() {
+		t.Error("inspector still active after every subscriber left")
+	}
+}
+
+func TestInspectPacket(t *testing.T) {
+	b := NewBot("bot_a", "", "a", "", "")
+	pkt := gamePacket(TankPacketStruct{Type: uint8(NET_GAME_PACKET_CALL_FUNCTION)}, (&variantWriter{}).str("OnConsoleMessage").str("hi").bytes())
+
+	if allocs := testing.AllocsPerRun(100, func() { b.inspectPacket(CaptureIn, pkt) }); allocs != 0 {
+		t.Errorf("inspectPacket allocates %v times without subscribers", allocs)
+	}
+
+	var got []*PacketEvent
+	unsubscribe := Inspector.Subscribe(PacketFilter{Bots: []string{"bot_a"}, Types: []string{"CALL_FUNCTION"}}, func(ev *PacketEvent) {
+		got = append(got, ev)
+	})
+	b.inspectPacket(CaptureIn, pkt)
+	b.inspectPacket(CaptureOut, []byte{2, 0, 0, 0, 'x'})
+	unsubscribe()
+	unsubscribe() // Must be safe to call twice
+	b.inspectPacket(CaptureIn, pkt)
+
+	if len(got) != 1 {
+		t.Fatalf("got %d events, want 1", len(got))
+	}
+	if got[0].Dir != "in" || len(got[0].Variants) != 2 || got[0].Variants[0] != "OnConsoleMessage" {
+		t.Errorf("unexpected event %+v", got[0])
+	}
+}
+
+func TestShowENet(t *testing.T) {
+	b := NewBot("bot_show", "", "show", "", "")
+	var logged []string
+	b.OnDebug = func(category, message string, isError bool) { logged = append(logged, message) }
+	pkt := []byte{2, 0, 0, 0, 'x'}
+
+	// The WebSocket client toggles it under b.mu while packets keep coming
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			b.Lock()
+			b.ShowENet.Store(i%2 == 0)
+			b.Unlock()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		b.inspectPacket(CaptureOut, []byte{0, 0, 0, 0})
+	}
+	<-done
+	logged = nil
+
+	b.ShowENet.Store(true)
+	b.inspectPacket(CaptureOut, pkt)
+	if len(logged) != 1 {
+		t.Errorf("logged %q", logged)
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields struct {
+		ShowENet bool `json:"show_enet"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil || !fields.ShowENet {
+		t.Errorf("show_enet in %s", data)
+	}
+}
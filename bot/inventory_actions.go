@@ -2,6 +2,7 @@ package bot
 
 import (
 	"fmt"
+	"time"
 )
 
 // WearItem sends a packet to wear an item from inventory
@@ -32,3 +33,37 @@ func (b *Bot) TrashItem(itemID int32) {
 	b.SendPacket(fmt.Sprintf("action|trash\nitemID|%d", itemID), NET_MESSAGE_GENERIC_TEXT)
 	b.logENet(fmt.Sprintf("[SYSTEM]: [TRASH ITEM]: ID %d", itemID))
 }
+
+// SeedStack is a seed in the inventory together with what it grows into
+type SeedStack struct {
+	Inventory
+	BlockID  uint32 `json:"block_id"`
+	GrowTime uint32 `json:"grow_time"` // Tree grow time in seconds
+}
+
+// InventorySeeds returns the seeds currently in the inventory, for the
+// GET_SEEDS WebSocket message
+func (b *Bot) InventorySeeds() []SeedStack {
+	db := b.itemDB()
+	if db == nil || !db.Loaded {
+		return nil
+	}
+
+	b.mu.Lock()
+	inventory := append([]Inventory(nil), b.Local.Inventory...)
+	b.mu.Unlock()
+
+	seeds := []SeedStack{}
+	for _, inv := range inventory {
+		item := db.GetItem(uint32(inv.ID))
+		if item == nil || !item.IsSeed() {
+			continue
+		}
+		stack := SeedStack{Inventory: inv, GrowTime: uint32(db.TreeGrowTime(item.ID) / time.Second)}
+		if block := db.BlockOf(item.ID); block != nil {
+			stack.BlockID = block.ID
+		}
+		seeds = append(seeds, stack)
+	}
+	return seeds
+}
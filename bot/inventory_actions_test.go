@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"vortenixgo/database"
+)
+
+func TestInventorySeeds(t *testing.T) {
+	b := NewBot("bot_seeds", BotTypeLegacy, "seeds", "", "")
+	b.Items = database.NewProvider()
+	b.Local.Inventory = []Inventory{{ID: 3, Count: 10}, {ID: 2, Count: 200}, {ID: 1, Count: 1}}
+	if seeds := b.InventorySeeds(); seeds != nil {
+		t.Fatalf("seeds without an item database: %v", seeds)
+	}
+
+	// 0/1 Dirt, 2/3 Rock
+	src := database.NewItemDatabase()
+	src.Version = database.MaxItemsDatVersion
+	for id, name := range []string{"Dirt", "Dirt Seed", "Rock", "Rock Seed"} {
+		item := &database.Item{ID: uint32(id), Name: name}
+		if id%2 == 1 {
+			item.ActionType = database.ActionTypeSeed
+			item.GrowTime = uint32(id * 30)
+		}
+		src.Items[item.ID] = item
+	}
+	data, err := src.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Items.Update(data, filepath.Join(t.TempDir(), "items.dat")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := json.Marshal(b.InventorySeeds())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"name":"","id":3,"count":10,"is_favorite":false,"is_active":false,"block_id":2,"grow_time":90},` +
+		`{"name":"","id":1,"count":1,"is_favorite":false,"is_active":false,"block_id":0,"grow_time":30}]`
+	if string(got) != want {
+		t.Errorf("seeds %s, want %s", got, want)
+	}
+
+	b.Local.Inventory = []Inventory{{ID: 2, Count: 1}}
+	if seeds := b.InventorySeeds(); seeds == nil || len(seeds) != 0 {
+		t.Errorf("seeds %v, want none", seeds)
+	}
+}
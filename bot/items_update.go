@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sync"
+	"vortenixgo/config"
+	"vortenixgo/database"
+)
+
+// Upper bound for a decompressed items.dat, protects against a bogus packet
+const maxItemsDatSize = 64 * 1024 * 1024
+
+var itemsUpdateMu sync.Mutex
+
+// localItemsDatHash returns the hash of the loaded items.dat (0 if none)
+func (b *Bot) localItemsDatHash() uint32 {
+	db := b.itemDB()
+	if db == nil || !db.Loaded {
+		return 0
+	}
+	return db.Hash
+}
+
+// needsItemsUpdate reports whether the server's items.dat differs from ours and auto update is on
+func (b *Bot) needsItemsUpdate(serverHash uint32) bool {
+	return config.Get().Items.AutoUpdate && b.Items != nil && serverHash != 0 && serverHash != b.localItemsDatHash()
+}
+
+// handleItemDatabaseData verifies and installs items.dat sent by the server
+func (b *Bot) handleItemDatabaseData(ptr []byte, p *TankPacketStruct) {
+	if !config.Get().Items.AutoUpdate || b.Items == nil {
+		b.logENet("[SYSTEM]: Received items.dat packet, but ignoring due to manual update mode.")
+		return
+	}
+
+	b.mu.Lock()
+	serverHash := uint32(b.Local.ServerHash)
+	b.mu.Unlock()
+
+	itemsUpdateMu.Lock()
+	defer itemsUpdateMu.Unlock()
+
+	// Another bot may already have installed this version
+	if serverHash != 0 && serverHash == b.localItemsDatHash() {
+		b.logENet("[SYSTEM]: items.dat already up to date, skipping.")
+		return
+	}
+
+	if len(ptr) < 56 || uint64(len(ptr)-56) < uint64(p.ExtendedDataLength) {
+		b.logENet("[SYSTEM]: items.dat packet is truncated")
+		return
+	}
+	compressed := ptr[56 : 56+int(p.ExtendedDataLength)]
+
+	data, err := inflateItemsDat(compressed)
+	if err != nil {
+		b.logENet(fmt.Sprintf("[SYSTEM]: Failed to decompress items.dat: %v", err))
+		return
+	}
+
+	if hash := database.HashItemsDat(data); serverHash != 0 && hash != serverHash {
+		b.logENet(fmt.Sprintf("[SYSTEM]: items.dat hash mismatch (got %d, server %d), not installing", hash, serverHash))
+		return
+	}
+
+	// The provider swaps the database for every bot and WS handler sharing it
+	if err := b.Items.Update(data, config.Get().Server.ItemsDatPath); err != nil {
+		b.logENet(fmt.Sprintf("[SYSTEM]: Failed to install items.dat: %v", err))
+		return
+	}
+
+	db := b.Items.DB()
+	b.logENet(fmt.Sprintf("[SYSTEM]: items.dat updated to version %d (%d items)", db.Version, db.ItemCount))
+}
+
+func inflateItemsDat(compressed []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(io.LimitReader(r, maxItemsDatSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxItemsDatSize {
+		return nil, fmt.Errorf("items.dat exceeds %d bytes", maxItemsDatSize)
+	}
+	return data, nil
+}
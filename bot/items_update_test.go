@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"bytes"
+	"compress/zlib"
+	"os"
+	"path/filepath"
+	"testing"
+	"vortenixgo/config/configtest"
+	"vortenixgo/database"
+)
+
+// itemsDatPacket is the items.dat packet carrying compressed, as handleGamePacket
+// passes it on
+func itemsDatPacket(compressed []byte) ([]byte, *TankPacketStruct) {
+	return append(make([]byte, 56), compressed...), &TankPacketStruct{ExtendedDataLength: uint32(len(compressed))}
+}
+
+func deflate(data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestItemsDatUpdate(t *testing.T) {
+	itemsPath := filepath.Join(t.TempDir(), "items.dat")
+	configtest.Use(t, "server:\n  items_dat_path: "+itemsPath+"\nitems:\n  auto_update: true\n")
+
+	src := database.NewItemDatabase()
+	src.Version = database.MaxItemsDatVersion
+	for id, name := range []string{"Blank", "Dirt", "Dirt Seed"} {
+		src.Items[uint32(id)] = &database.Item{ID: uint32(id), Name: name}
+	}
+	data, err := src.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := database.HashItemsDat(data)
+	compressed := deflate(data)
+	old := []byte("the items.dat we had")
+	if err := os.WriteFile(itemsPath, old, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBot("bot_items", BotTypeLegacy, "items", "", "")
+	b.Items = database.NewProvider()
+	b.Local.ServerHash = int(hash)
+	if !b.needsItemsUpdate(hash) {
+		t.Fatal("no update needed without an items.dat")
+	}
+
+	rejected := []struct {
+		name       string
+		compressed []byte
+		length     uint32 // Claimed extended data length, 0 = real
+		serverHash uint32
+	}{
+		{"not zlib", []byte("definitely not zlib"), 0, hash},
+		{"cut short", compressed[:len(compressed)/2], 0, hash},
+		{"longer than the packet", compressed, uint32(len(compressed)) + 1, hash},
+		{"empty", nil, 0, hash},
+		{"hash mismatch", compressed, 0, hash + 1},
+		{"bogus items.dat", deflate([]byte("garbage")), 0, database.HashItemsDat([]byte("garbage"))},
+	}
+	for _, tt := range rejected {
+		ptr, p := itemsDatPacket(tt.compressed)
+		if tt.length != 0 {
+			p.ExtendedDataLength = tt.length
+		}
+		b.Local.ServerHash = int(tt.serverHash)
+		b.handleItemDatabaseData(ptr, p)
+		if b.Items.DB().Loaded {
+			t.Fatalf("%s: installed", tt.name)
+		}
+		if got, _ := os.ReadFile(itemsPath); !bytes.Equal(got, old) {
+			t.Fatalf("%s: items.dat replaced", tt.name)
+		}
+	}
+
+	b.Local.ServerHash = int(hash)
+	ptr, p := itemsDatPacket(compressed)
+	b.handleItemDatabaseData(ptr, p)
+	db := b.Items.DB()
+	if !db.Loaded || db.ItemCount != 3 || db.Hash != hash {
+		t.Fatalf("installed %d items, hash %d, loaded %v", db.ItemCount, db.Hash, db.Loaded)
+	}
+	if got, _ := os.ReadFile(itemsPath); !bytes.Equal(got, data) {
+		t.Error("items.dat not written")
+	}
+	if b.needsItemsUpdate(hash) {
+		t.Error("update still needed after installing")
+	}
+}
+
+func TestItemsDatUpdateOff(t *testing.T) {
+	configtest.Use(t, "")
+	b := NewBot("bot_items_off", BotTypeLegacy, "items_off", "", "")
+	b.Items = database.NewProvider()
+	if b.needsItemsUpdate(1234) {
+		t.Error("update requested with auto_update off")
+	}
+	ptr, p := itemsDatPacket(deflate([]byte("anything")))
+	b.handleItemDatabaseData(ptr, p)
+	if b.Items.DB().Loaded {
+		t.Error("installed with auto_update off")
+	}
+}
@@ -5,12 +5,16 @@ import (
 	"log"
 	"sort"
 	"sync"
+	"vortenixgo/database"
 )
 
 // Manager handles the lifecycle of multiple bots
 type Manager struct {
-	Bots map[string]*Bot
-	mu   sync.RWMutex
+	Bots  map[string]*Bot
+	Items *database.Provider // Shared item database, injected into every bot
+	// Coordinator keeps bots in the same world out of each other's way
+	Coordinator *Coordinator
+	mu          sync.RWMutex
 }
 
 // Global instance
@@ -22,7 +26,22 @@ func init() {
 
 func NewManager() *Manager {
 	return &Manager{
-		Bots: make(map[string]*Bot),
+		Bots:        make(map[string]*Bot),
+		Items:       database.NewProvider(),
+		Coordinator: NewCoordinator(),
+	}
+}
+
+// SetItemProvider replaces the item database provider for the manager and all its bots
+func (m *Manager) SetItemProvider(items *database.Provider) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Items = items
+	for _, b := range m.Bots {
+		b.mu.Lock()
+		b.Items = items
+		b.mu.Unlock()
 	}
 }
 
@@ -38,6 +57,7 @@ func (m *Manager) AddBot(botType BotType, name string, password string, glog str
 
 	bot := NewBot(id, botType, name, password, glog)
 	bot.Proxy = proxy
+	bot.Items = m.Items
 	m.Bots[id] = bot
 	log.Printf("[BotManager] Added bot ID: %s. Total bots: %d", id, len(m.Bots))
 	return bot, nil
@@ -55,6 +75,8 @@ func (m *Manager) RemoveBot(id string) error {
 
 	bot.DisconnectClient() // Stop ENet and EventListener
 	bot.Disconnect()       // Stop general bot loop
+	bot.StopCapture()
+	m.Coordinator.forget(id)
 	delete(m.Bots, id)
 	log.Printf("[BotManager] Removed bot ID: %s. Remaining: %d", id, len(m.Bots))
 	return nil
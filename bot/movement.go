@@ -0,0 +1,378 @@
+package bot
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Flags of a NET_GAME_PACKET_STATE
+const (
+	STATE_FLAG_FACING_LEFT uint32 = 0x10
+	STATE_FLAG_ON_SOLID    uint32 = 0x20
+	STATE_FLAG_ON_JUMP     uint32 = 0x80 // Set on the packet of the jump only
+)
+
+// Bits of SET_CHARACTER_STATE's Value (Local.HackType) the movement cares
+// about
+const (
+	HACK_WALK_IN_BLOCKS int32 = 1 << 0
+	HACK_DOUBLE_JUMP    int32 = 1 << 1
+	HACK_FROZEN         int32 = 1 << 11
+)
+
+// Collision types of items.dat, as in World.CollisionMap
+const (
+	COLLISION_NONE     uint8 = 0
+	COLLISION_SOLID    uint8 = 1
+	COLLISION_PLATFORM uint8 = 2   // Solid from above only
+	COLLISION_OUTSIDE  uint8 = 255 // Past the tiles sent
+)
+
+// Character physics, in pixels and seconds. A tile is 32 pixels; the
+// position is the top left corner of the player's 32x32 cell, the hitbox is
+// narrower and a bit shorter.
+const (
+	tileSize       = 32
+	hitboxLeft     = 6
+	hitboxRight    = 26
+	hitboxTop      = 2
+	hitboxBottom   = 32
+	defaultSpeed   = 250  // When the server sent no Velocity
+	defaultGravity = 1000 // When the server sent no Gravity
+	jumpSpeed      = 400
+	maxFallSpeed   = 600
+	accelTime      = 0.125 // Seconds from standing to full speed
+	physicsStep    = 1.0 / 60
+)
+
+// Timing of the movement loop and its state packets
+const (
+	moveTick      = 50 * time.Millisecond
+	stateInterval = 100 * time.Millisecond // Between state packets while moving
+)
+
+var ErrNotInWorld = errors.New("not in a world")
+
+// MoveInput is what the player holds down
+type MoveInput struct {
+	Left, Right, Jump bool
+}
+
+// Physics are the server's settings for the character, from
+// SET_CHARACTER_STATE
+type Physics struct {
+	Speed    float32 // Walking speed, pixels per second
+	Gravity  float32 // Pixels per second squared
+	HackType int32
+}
+
+func (l *Local) physics() Physics {
+	ph := Physics{Speed: l.Velocity, Gravity: l.Gravity, HackType: l.HackType}
+	if ph.Speed <= 0 {
+		ph.Speed = defaultSpeed
+	}
+	if ph.Gravity <= 0 {
+		ph.Gravity = defaultGravity
+	}
+	return ph
+}
+
+// Character is the simulated state of the bot's own player
+type Character struct {
+	X, Y           float32
+	SpeedX, SpeedY float32
+	FacingLeft     bool
+	OnGround       bool
+	Jumps          int  // Jumps since last on the ground
+	jumpHeld       bool // Jump was held on the last step, a new jump needs a new press
+}
+
+// Step advances the character by dt seconds. It returns true when it jumped.
+func (c *Character) Step(dt float32, in MoveInput, ph Physics, w *World) bool {
+	noclip := ph.HackType&HACK_WALK_IN_BLOCKS != 0
+	target := float32(0)
+	if ph.HackType&HACK_FROZEN == 0 {
+		switch {
+		case in.Left && !in.Right:
+			target = -ph.Speed
+			c.FacingLeft = true
+		case in.Right && !in.Left:
+			target = ph.Speed
+			c.FacingLeft = false
+		}
+	}
+	accel := ph.Speed / accelTime * dt
+	if c.SpeedX < target {
+		c.SpeedX = min(c.SpeedX+accel, target)
+	} else if c.SpeedX > target {
+		c.SpeedX = max(c.SpeedX-accel, target)
+	}
+
+	jumped := false
+	if in.Jump && !c.jumpHeld && ph.HackType&HACK_FROZEN == 0 {
+		maxJumps := 1
+		if ph.HackType&HACK_DOUBLE_JUMP != 0 {
+			maxJumps = 2
+		}
+		if c.OnGround || (c.Jumps > 0 && c.Jumps < maxJumps) {
+			c.SpeedY = -jumpSpeed
+			c.OnGround = false
+			c.Jumps++
+			jumped = true
+		}
+	}
+	c.jumpHeld = in.Jump
+
+	c.SpeedY = min(c.SpeedY+ph.Gravity*dt, maxFallSpeed)
+
+	// Horizontal, then vertical, each resolved against the tiles
+	c.X += c.SpeedX * dt
+	if !noclip {
+		if c.SpeedX > 0 {
+			if col := w.solidColumn(c.X+hitboxRight, c.Y+hitboxTop, c.Y+hitboxBottom); col >= 0 {
+				c.X = float32(col*tileSize) - hitboxRight
+				c.SpeedX = 0
+			}
+		} else if c.SpeedX < 0 {
+			if col := w.solidColumn(c.X+hitboxLeft, c.Y+hitboxTop, c.Y+hitboxBottom); col >= 0 {
+				c.X = float32((col+1)*tileSize) - hitboxLeft
+				c.SpeedX = 0
+			}
+		}
+	}
+
+	oldBottom := c.Y + hitboxBottom
+	c.Y += c.SpeedY * dt
+	c.OnGround = false
+	if noclip {
+		return jumped
+	}
+	if c.SpeedY >= 0 {
+		if row := w.floorRow(c.X+hitboxLeft, c.X+hitboxRight, oldBottom, c.Y+hitboxBottom); row >= 0 {
+			c.Y = float32(row*tileSize) - hitboxBottom
+			c.SpeedY = 0
+			c.OnGround = true
+			c.Jumps = 0
+		}
+	} else if row := w.solidRow(c.X+hitboxLeft, c.X+hitboxRight, c.Y+hitboxTop); row >= 0 {
+		c.Y = float32((row+1)*tileSize) - hitboxTop
+		c.SpeedY = 0
+	}
+	return jumped
+}
+
+// Flags are the state packet flags of the character
+func (c *Character) Flags() uint32 {
+	var flags uint32
+	if c.FacingLeft {
+		flags |= STATE_FLAG_FACING_LEFT
+	}
+	if c.OnGround {
+		flags |= STATE_FLAG_ON_SOLID
+	}
+	return flags
+}
+
+// StatePacket is the NET_GAME_PACKET_STATE the game client sends for c
+func (c *Character) StatePacket(netID int32, jumped bool) TankPacketStruct {
+	flags := c.Flags()
+	if jumped {
+		flags |= STATE_FLAG_ON_JUMP
+	}
+	return TankPacketStruct{
+		Type:     uint8(NET_GAME_PACKET_STATE),
+		NetID:    netID,
+		Flags:    flags,
+		VectorX:  c.X,
+		VectorY:  c.Y,
+		VectorX2: c.SpeedX,
+		VectorY2: c.SpeedY,
+		IntX:     -1, // No tile punched or placed
+		IntY:     -1,
+	}
+}
+
+// Resting reports whether the character stays put without input
+func (c *Character) Resting() bool {
+	return c.OnGround && c.SpeedX == 0 && c.SpeedY == 0
+}
+
+// collision is the collision type of tile (x, y); everything outside the
+// world is solid
+func (w *World) collision(x, y int) uint8 {
+	if w == nil || x < 0 || y < 0 || x >= int(w.Width) || y >= int(w.Height) {
+		return COLLISION_SOLID
+	}
+	idx := x + y*int(w.Width)
+	if idx >= len(w.CollisionMap) || w.CollisionMap[idx] == COLLISION_OUTSIDE {
+		return COLLISION_SOLID
+	}
+	return w.CollisionMap[idx]
+}
+
+func tileOf(px float32) int {
+	return int(math.Floor(float64(px) / tileSize))
+}
+
+// solidColumn is the column of the first solid tile at x between top and
+// bottom, or -1
+func (w *World) solidColumn(x, top, bottom float32) int {
+	col := tileOf(x)
+	for row := tileOf(top); row <= tileOf(bottom-0.01); row++ {
+		if w.collision(col, row) == COLLISION_SOLID {
+			return col
+		}
+	}
+	return -1
+}
+
+// solidRow is the row of the first solid tile at y between left and right,
+// or -1
+func (w *World) solidRow(left, right, y float32) int {
+	row := tileOf(y)
+	for col := tileOf(left); col <= tileOf(right-0.01); col++ {
+		if w.collision(col, row) == COLLISION_SOLID {
+			return row
+		}
+	}
+	return -1
+}
+
+// floorRow is the row of the tile the feet landed on moving down from
+// oldBottom to bottom, or -1. Platforms only count when crossed from above.
+// A step never crosses a whole row.
+func (w *World) floorRow(left, right, oldBottom, bottom float32) int {
+	row := tileOf(bottom)
+	for col := tileOf(left); col <= tileOf(right-0.01); col++ {
+		switch w.collision(col, row) {
+		case COLLISION_SOLID:
+			return row
+		case COLLISION_PLATFORM:
+			if oldBottom <= float32(row*tileSize)+0.01 {
+				return row
+			}
+		}
+	}
+	return -1
+}
+
+// movement is the bot's side of its character: held keys and the loop
+// stepping it. The position and speed live in Local, so OnSetPos and the
+// server's own state packets always win.
+type movement struct {
+	char      Character
+	input     MoveInput
+	running   bool
+	lastSent  time.Time
+	lastFlags uint32
+}
+
+// Move holds down in until the next Move; MoveInput{} lets go. The character
+// walks, jumps and falls, and state packets go to the server while it moves.
+func (b *Bot) Move(in MoveInput) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.inWorldLocked() {
+		return ErrNotInWorld
+	}
+	b.movement.input = in
+	if !b.movement.running {
+		b.movement.running = true
+		go b.movementLoop(b.stop)
+	}
+	return nil
+}
+
+// Nudge holds in for d, then lets go
+func (b *Bot) Nudge(in MoveInput, d time.Duration) error {
+	if err := b.Move(in); err != nil {
+		return err
+	}
+	time.AfterFunc(d, func() {
+		b.mu.Lock()
+		if b.movement.input == in {
+			b.movement.input = MoveInput{}
+		}
+		b.mu.Unlock()
+	})
+	return nil
+}
+
+func (b *Bot) inWorldLocked() bool {
+	w := &b.Local.World
+	return b.Connected && w.Name != "" && w.Name != "EXIT" && len(w.CollisionMap) > 0
+}
+
+// movementLoop steps the character until it rests with nothing held, the
+// bot leaves the world or stop closes
+func (b *Bot) movementLoop(stop chan struct{}) {
+	defer b.RecoverPanic("Movement", nil)
+	ticker := time.NewTicker(moveTick)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-stop:
+			b.mu.Lock()
+			b.movement.running = false
+			b.mu.Unlock()
+			return
+		case now := <-ticker.C:
+			pkt, done := b.stepMovement(now.Sub(last), now)
+			last = now
+			if pkt != nil {
+				b.SendPacketRaw(pkt)
+			}
+			if done {
+				return
+			}
+		}
+	}
+}
+
+// stepMovement runs the physics for elapsed and returns the state packet due,
+// if any, and whether the loop is done
+func (b *Bot) stepMovement(elapsed time.Duration, now time.Time) (*TankPacketStruct, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m := &b.movement
+	if !b.inWorldLocked() {
+		m.running = false
+		return nil, true
+	}
+
+	c := &m.char
+	c.X, c.Y = b.Local.PosX, b.Local.PosY
+	c.SpeedX, c.SpeedY = b.Local.SpeedX, b.Local.SpeedY
+	ph := b.Local.physics()
+	jumped := false
+	for left := float32(elapsed.Seconds()); left > 0; left -= physicsStep {
+		if c.Step(min(left, physicsStep), m.input, ph, &b.Local.World) {
+			jumped = true
+		}
+	}
+	b.Local.PosX, b.Local.PosY = c.X, c.Y
+	b.Local.SpeedX, b.Local.SpeedY = c.SpeedX, c.SpeedY
+
+	resting := c.Resting() && m.input == MoveInput{}
+	flags := c.Flags()
+	var pkt *TankPacketStruct
+	if jumped || resting || flags != m.lastFlags || now.Sub(m.lastSent) >= stateInterval {
+		p := c.StatePacket(int32(b.Local.NetID), jumped)
+		pkt = &p
+		m.lastSent, m.lastFlags = now, flags
+	}
+	if resting {
+		m.running = false
+	}
+	return pkt, resting
+}
+
+// teleportedLocked makes the character start over from the position the
+// server set
+func (b *Bot) teleportedLocked() {
+	b.Local.SpeedX, b.Local.SpeedY = 0, 0
+	b.movement.char.OnGround = false
+	b.movement.char.Jumps = 0
+}
@@ -0,0 +1,198 @@
+package bot
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// testWorld is w x h tiles of air with a solid floor on the last row
+func testWorld(w, h int) *World {
+	world := &World{Name: "TEST", Width: uint32(w), Height: uint32(h), CollisionMap: make([]uint8, w*h)}
+	for x := 0; x < w; x++ {
+		world.CollisionMap[x+(h-1)*w] = COLLISION_SOLID
+	}
+	return world
+}
+
+func (w *World) set(x, y int, collision uint8) {
+	w.CollisionMap[x+y*int(w.Width)] = collision
+}
+
+var testPhysics = Physics{Speed: 250, Gravity: 1000}
+
+// run steps c for d with in held and returns whether it jumped
+func run(c *Character, d time.Duration, in MoveInput, ph Physics, w *World) bool {
+	jumped := false
+	for left := float32(d.Seconds()); left > 0; left -= physicsStep {
+		jumped = c.Step(min(left, physicsStep), in, ph, w) || jumped
+	}
+	return jumped
+}
+
+func TestCharacterFalls(t *testing.T) {
+	w := testWorld(10, 10)
+	c := &Character{X: 64, Y: 0}
+	run(c, 2*time.Second, MoveInput{}, testPhysics, w)
+	if !c.Resting() || c.Y != 8*tileSize || c.X != 64 {
+		t.Fatalf("after falling %+v", c)
+	}
+	if c.Flags() != STATE_FLAG_ON_SOLID {
+		t.Errorf("flags %#x", c.Flags())
+	}
+}
+
+func TestCharacterWalk(t *testing.T) {
+	w := testWorld(20, 10)
+	w.set(10, 8, COLLISION_SOLID)
+	c := &Character{X: 64, Y: 8 * tileSize, OnGround: true}
+
+	run(c, 500*time.Millisecond, MoveInput{Right: true}, testPhysics, w)
+	// Full speed after accelTime, so half of it is lost, give or take the steps
+	if want := 64 + 250*(0.5-accelTime/2); math.Abs(float64(c.X)-want) > 3 || c.SpeedX != 250 || !c.OnGround {
+		t.Fatalf("after walking right %+v, want x %.1f", c, want)
+	}
+
+	// The block in column 10 stops it
+	run(c, 2*time.Second, MoveInput{Right: true}, testPhysics, w)
+	if c.X != 10*tileSize-hitboxRight || c.SpeedX != 0 {
+		t.Fatalf("against the wall %+v", c)
+	}
+
+	run(c, 100*time.Millisecond, MoveInput{Left: true}, testPhysics, w)
+	if !c.FacingLeft || c.Flags() != STATE_FLAG_FACING_LEFT|STATE_FLAG_ON_SOLID {
+		t.Errorf("walking left %+v, flags %#x", c, c.Flags())
+	}
+	run(c, time.Second, MoveInput{}, testPhysics, w)
+	if !c.Resting() || !c.FacingLeft {
+		t.Errorf("after letting go %+v", c)
+	}
+
+	frozen := testPhysics
+	frozen.HackType = HACK_FROZEN
+	x := c.X
+	run(c, time.Second, MoveInput{Right: true, Jump: true}, frozen, w)
+	if c.X != x || !c.Resting() {
+		t.Errorf("frozen character moved: %+v", c)
+	}
+}
+
+func TestCharacterJump(t *testing.T) {
+	w := testWorld(10, 20)
+	floor := float32(18 * tileSize)
+	c := &Character{X: 64, Y: floor, OnGround: true}
+
+	if !run(c, time.Second/60, MoveInput{Jump: true}, testPhysics, w) {
+		t.Fatal("no jump from the ground")
+	}
+	peak := c.Y
+	for !c.OnGround {
+		// Holding jump doesn't jump again, nor does a new press in the air
+		if run(c, 50*time.Millisecond, MoveInput{Jump: c.Y < floor-40}, testPhysics, w) {
+			t.Fatal("jumped in the air")
+		}
+		peak = min(peak, c.Y)
+	}
+	// v²/2g = 80 pixels, less a step's worth
+	if h := floor - peak; h < 75 || h > 80 {
+		t.Errorf("jumped %.1f pixels", h)
+	}
+	if c.Y != floor {
+		t.Errorf("landed at %.1f", c.Y)
+	}
+
+	// A second press in the air jumps again with a double jump, a third doesn't
+	double := testPhysics
+	double.HackType = HACK_DOUBLE_JUMP
+	tap := func() bool {
+		jumped := run(c, time.Second/60, MoveInput{Jump: true}, double, w)
+		run(c, 100*time.Millisecond, MoveInput{}, double, w)
+		return jumped
+	}
+	if !tap() || !tap() || tap() {
+		t.Error("double jump jumped more or less than twice")
+	}
+	if c.Jumps != 2 || c.OnGround {
+		t.Errorf("after a double jump %+v", c)
+	}
+}
+
+func TestCharacterPlatform(t *testing.T) {
+	w := testWorld(10, 20)
+	for x := 0; x < 10; x++ {
+		w.set(x, 16, COLLISION_PLATFORM)
+	}
+
+	// Jumps up through it from below and lands on it
+	c := &Character{X: 64, Y: 18 * tileSize, OnGround: true}
+	run(c, 20*time.Millisecond, MoveInput{Jump: true}, testPhysics, w)
+	run(c, 2*time.Second, MoveInput{}, testPhysics, w)
+	if c.Y != 18*tileSize {
+		t.Fatalf("jump too low to clear the platform ended at %.1f", c.Y)
+	}
+
+	high := testPhysics
+	high.Gravity = 400 // v²/2g = 200 pixels
+	run(c, 20*time.Millisecond, MoveInput{Jump: true}, high, w)
+	run(c, 3*time.Second, MoveInput{}, high, w)
+	if !c.OnGround || c.Y != 15*tileSize {
+		t.Fatalf("not on the platform: %+v", c)
+	}
+}
+
+func TestMovementStatePackets(t *testing.T) {
+	b := NewBot("bot_move", BotTypeLegacy, "mover", "", "")
+	b.Connected = true
+	b.Local.NetID = 7
+	b.Local.World = *testWorld(20, 10)
+	b.Local.PosX, b.Local.PosY = 64, 8*tileSize
+	b.movement.char.OnGround = true
+
+	now := time.Now()
+	b.movement.input = MoveInput{Left: true, Jump: true}
+	pkt, done := b.stepMovement(moveTick, now)
+	if pkt == nil || done {
+		t.Fatalf("first step: %+v, done %v", pkt, done)
+	}
+	if ETankPacketType(pkt.Type) != NET_GAME_PACKET_STATE || pkt.NetID != 7 || pkt.Flags != STATE_FLAG_FACING_LEFT|STATE_FLAG_ON_JUMP {
+		t.Errorf("jump packet %+v", pkt)
+	}
+	if pkt.VectorX != b.Local.PosX || pkt.VectorY != b.Local.PosY || pkt.VectorX2 >= 0 || pkt.VectorY2 >= 0 || pkt.IntX != -1 {
+		t.Errorf("jump packet position %+v, local %v,%v", pkt, b.Local.PosX, b.Local.PosY)
+	}
+
+	// Nothing new until the interval passes
+	now = now.Add(moveTick)
+	if pkt, _ := b.stepMovement(moveTick, now); pkt != nil {
+		t.Errorf("packet %+v before the interval", pkt)
+	}
+
+	// The server moves the bot: the simulation goes on from there
+	b.Local.PosX, b.Local.PosY = 320, 0
+	b.teleportedLocked()
+	b.movement.input = MoveInput{}
+	var last *TankPacketStruct
+	for i := 0; i < 100 && !done; i++ {
+		now = now.Add(moveTick)
+		if pkt, done = b.stepMovement(moveTick, now); pkt != nil {
+			last = pkt
+		}
+	}
+	if !done || b.Local.PosX != 320 || b.Local.PosY != 8*tileSize {
+		t.Fatalf("done %v at %v,%v", done, b.Local.PosX, b.Local.PosY)
+	}
+	if last == nil || last.Flags != STATE_FLAG_FACING_LEFT|STATE_FLAG_ON_SOLID || last.VectorX2 != 0 || last.VectorY2 != 0 {
+		t.Errorf("resting packet %+v", last)
+	}
+	if b.movement.running {
+		t.Error("still running after coming to rest")
+	}
+}
+
+func TestMoveNotInWorld(t *testing.T) {
+	b := NewBot("bot_move", BotTypeLegacy, "mover", "", "")
+	b.Connected = true
+	if err := b.Move(MoveInput{Right: true}); err != ErrNotInWorld {
+		t.Fatalf("Move outside a world: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// pcapng block types and options used by ExportPcapng
+const (
+	pcapngSectionHeader  = 0x0A0D0D0A
+	pcapngInterfaceDesc  = 0x00000001
+	pcapngEnhancedPacket = 0x00000006
+	pcapngByteOrderMagic = 0x1A2B3C4D
+
+	pcapngOptEnd     = 0
+	pcapngOptIfName  = 2 // Interface description
+	pcapngOptIfDesc  = 3
+	pcapngOptEpbFlag = 2 // Enhanced packet block
+
+	// LINKTYPE_USER0: the payload has no link layer. In Wireshark, map DLT 147
+	// to a dissector under Preferences > Protocols > DLT_USER.
+	pcapngLinkTypeUser0 = 147
+)
+
+// ExportPcapng converts a capture to pcapng for Wireshark. Each packet is the
+// ENet payload as captured (4 byte message type first); the direction is stored
+// in the packet flags, so Wireshark can filter on frame.p2p_dir.
+func ExportPcapng(w io.Writer, r *CaptureReader) (int, error) {
+	le := binary.LittleEndian
+
+	shb := le.AppendUint32(nil, pcapngByteOrderMagic)
+	shb = le.AppendUint16(shb, 1) // Version 1.0
+	shb = le.AppendUint16(shb, 0)
+	shb = le.AppendUint64(shb, 0xFFFFFFFFFFFFFFFF) // Section length not specified
+	if err := writePcapngBlock(w, pcapngSectionHeader, shb); err != nil {
+		return 0, err
+	}
+
+	idb := le.AppendUint16(nil, pcapngLinkTypeUser0)
+	idb = le.AppendUint16(idb, 0)
+	idb = le.AppendUint32(idb, 0) // No snap length
+	idb = appendPcapngOption(idb, pcapngOptIfName, []byte("vortenix:"+r.BotID))
+	idb = appendPcapngOption(idb, pcapngOptIfDesc, []byte("Growtopia ENet payloads"))
+	idb = appendPcapngOption(idb, pcapngOptEnd, nil)
+	if err := writePcapngBlock(w, pcapngInterfaceDesc, idb); err != nil {
+		return 0, err
+	}
+
+	for n := 0; ; n++ {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+
+		// Timestamps in microseconds, the default if_tsresol
+		ts := uint64(rec.Time.UnixMicro())
+		epb := le.AppendUint32(nil, 0) // Interface 0
+		epb = le.AppendUint32(epb, uint32(ts>>32))
+		epb = le.AppendUint32(epb, uint32(ts))
+		epb = le.AppendUint32(epb, uint32(len(rec.Data)))
+		epb = le.AppendUint32(epb, uint32(len(rec.Data)))
+		epb = append(epb, rec.Data...)
+		epb = append(epb, make([]byte, pad4(len(rec.Data)))...)
+
+		// epb_flags bits 0-1: 1 = inbound, 2 = outbound
+		flags := uint32(1)
+		if rec.Dir == CaptureOut {
+			flags = 2
+		}
+		epb = appendPcapngOption(epb, pcapngOptEpbFlag, le.AppendUint32(nil, flags))
+		epb = appendPcapngOption(epb, pcapngOptEnd, nil)
+		if err := writePcapngBlock(w, pcapngEnhancedPacket, epb); err != nil {
+			return n, err
+		}
+	}
+}
+
+// writePcapngBlock writes type, total length, body and the trailing length.
+// body must already be padded to 32 bits.
+func writePcapngBlock(w io.Writer, blockType uint32, body []byte) error {
+	total := uint32(12 + len(body))
+	block := binary.LittleEndian.AppendUint32(nil, blockType)
+	block = binary.LittleEndian.AppendUint32(block, total)
+	block = append(block, body...)
+	block = binary.LittleEndian.AppendUint32(block, total)
+	_, err := w.Write(block)
+	return err
+}
+
+func appendPcapngOption(b []byte, code uint16, value []byte) []byte {
+	b = binary.LittleEndian.AppendUint16(b, code)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	return append(b, make([]byte, pad4(len(value)))...)
+}
+
+func pad4(n int) int {
+	return (4 - n%4) % 4
+}
@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// packetReader reads little endian values from a byte slice. The first short read
+// sets err and every later read returns zero values, so parsers can read a whole
+// structure and check the error once. Nothing is ever allocated beyond what the
+// remaining data could actually hold.
+type packetReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func newPacketReader(data []byte) *packetReader {
+	return &packetReader{data: data}
+}
+
+// Remaining returns the number of unread bytes
+func (r *packetReader) Remaining() int {
+	return len(r.data) - r.pos
+}
+
+func (r *packetReader) need(n int) bool {
+	if r.err != nil {
+		return false
+	}
+	if n < 0 || n > r.Remaining() {
+		r.err = io.ErrUnexpectedEOF
+		return false
+	}
+	return true
+}
+
+func (r *packetReader) u8() uint8 {
+	if !r.need(1) {
+		return 0
+	}
+	v := r.data[r.pos]
+	r.pos++
+	return v
+}
+
+func (r *packetReader) u16() uint16 {
+	if !r.need(2) {
+		return 0
+	}
+	v := binary.LittleEndian.Uint16(r.data[r.pos:])
+	r.pos += 2
+	return v
+}
+
+func (r *packetReader) u32() uint32 {
+	if !r.need(4) {
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v
+}
+
+func (r *packetReader) f32() float32 {
+	return math.Float32frombits(r.u32())
+}
+
+// read decodes a fixed-size value (or struct of them) into v, like binary.Read
+func (r *packetReader) read(v interface{}) {
+	n := binary.Size(v)
+	if !r.need(n) {
+		return
+	}
+	if _, err := binary.Decode(r.data[r.pos:r.pos+n], binary.LittleEndian, v); err != nil {
+		r.err = err
+		return
+	}
+	r.pos += n
+}
+
+// bytes returns a copy of the next n bytes
+func (r *packetReader) bytes(n int) []byte {
+	if !r.need(n) {
+		return nil
+	}
+	out := make([]byte, n)
+	copy(out, r.data[r.pos:])
+	r.pos += n
+	return out
+}
+
+// str reads a uint16 length-prefixed string
+func (r *packetReader) str() string {
+	n := int(r.u16())
+	if !r.need(n) {
+		return ""
+	}
+	s := string(r.data[r.pos : r.pos+n])
+	r.pos += n
+	return s
+}
+
+func (r *packetReader) skip(n int) {
+	if r.need(n) {
+		r.pos += n
+	}
+}
+
+// count validates an element count read from the wire: count elements of
+// elemSize bytes must fit in the remaining data
+func (r *packetReader) count(n uint32, elemSize int) int {
+	if r.err != nil {
+		return 0
+	}
+	if elemSize > 0 && uint64(n)*uint64(elemSize) > uint64(r.Remaining()) {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	return int(n)
+}
+
+// u32s reads n uint32 values after checking they fit
+func (r *packetReader) u32s(n uint32) []uint32 {
+	count := r.count(n, 4)
+	out := make([]uint32, count)
+	for i := range out {
+		out[i] = r.u32()
+	}
+	return out
+}
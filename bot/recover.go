@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+	"vortenixgo/config"
+)
+
+// PanicReport describes a panic recovered in one of a bot's goroutines
+type PanicReport struct {
+	Time   time.Time `json:"time"`
+	Where  string    `json:"where"` // OnReceive, ProcessQueue, ...
+	Value  string    `json:"value"`
+	Stack  string    `json:"stack"`
+	Packet []byte    `json:"packet,omitempty"` // Packet being handled, if any
+	File   string    `json:"file,omitempty"`   // Crash report on disk, empty if not written
+}
+
+// RecoverPanic must be deferred directly: defer b.RecoverPanic("OnReceive", data).
+// A panic is logged, written to server.crash_dir with the packet that caused it,
+// and only this bot is disconnected; every other bot keeps running.
+func (b *Bot) RecoverPanic(where string, packet []byte) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	report := &PanicReport{
+		Time:   time.Now(),
+		Where:  where,
+		Value:  fmt.Sprint(r),
+		Stack:  string(debug.Stack()),
+		Packet: append([]byte(nil), packet...),
+	}
+	log.Printf("[Panic][%s] %s: %s\n%s", b.ID, where, report.Value, report.Stack)
+	b.panics.Add(1)
+
+	if dir := config.Get().Server.CrashDir; dir != "" {
+		path, err := writeCrashReport(dir, b.ID, report)
+		if err != nil {
+			log.Printf("[Panic][%s] Failed to write crash report: %v", b.ID, err)
+		} else {
+			report.File = path
+		}
+	}
+	if b.OnDebug != nil {
+		b.OnDebug("PANIC", fmt.Sprintf("%s: %s (bot disconnected)", where, report.Value), true)
+	}
+
+	// The panicking goroutine may be the EventListener, which StopENet waits for
+	go func() {
+		b.StopENet()
+		b.mu.Lock()
+		b.LastPanic = report
+		b.Status = "Crashed"
+		b.Connected = false
+		b.mu.Unlock()
+		if b.OnUpdate != nil {
+			b.OnUpdate()
+		}
+	}()
+}
+
+// writeCrashReport writes report to dir as <bot id>-<time>.txt
+func writeCrashReport(dir, botID string, report *PanicReport) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "bot: %s\ntime: %s\nwhere: %s\npanic: %s\n\n%s\n",
+		botID, report.Time.Format(time.RFC3339Nano), report.Where, report.Value, report.Stack)
+	if report.Packet != nil {
+		fmt.Fprintf(&sb, "packet (%d bytes):\n%s", len(report.Packet), hex.Dump(report.Packet))
+	}
+
+	name := fmt.Sprintf("%s-%s.txt", fileSafeID(botID), report.Time.Format("20060102-150405.000000"))
+	path := filepath.Join(dir, name)
+	return path, os.WriteFile(path, []byte(sb.String()), 0644)
+}
+
+// fileSafeID makes a bot ID (derived from user supplied names) usable in a file name
+func fileSafeID(id string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, id)
+}
@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"vortenixgo/config/configtest"
+)
+
+func TestRecoverPanic(t *testing.T) {
+	crashDir := filepath.Join(t.TempDir(), "crashes")
+	configtest.Use(t, "server:\n  crash_dir: "+crashDir+"\n")
+
+	b := NewBot("bot_a/b", BotTypeLegacy, "a/b", "", "")
+	other := NewBot("bot_other", BotTypeLegacy, "other", "", "")
+	b.Connected, other.Connected = true, true
+	updated := make(chan struct{})
+	b.OnUpdate = func() { close(updated) }
+
+	packet := []byte{4, 0, 0, 0, 1, 2, 3}
+	func() {
+		defer b.RecoverPanic("OnReceive", packet)
+		var vl VariantList
+		_ = vl.Variants[4].(string)
+	}()
+
+	select {
+	case <-updated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("bot was not disconnected")
+	}
+
+	b.mu.Lock()
+	status, connected, report := b.Status, b.Connected, b.LastPanic
+	b.mu.Unlock()
+	if status != "Crashed" || connected || report == nil {
+		t.Fatalf("status %q, connected %v, report %v", status, connected, report)
+	}
+	if !other.Connected {
+		t.Fatal("other bot was disconnected")
+	}
+	if report.Where != "OnReceive" || !strings.Contains(report.Value, "index out of range") || string(report.Packet) != string(packet) {
+		t.Fatalf("report = %+v", report)
+	}
+
+	if filepath.Dir(report.File) != crashDir { // The "/" in the ID must not become a directory
+		t.Fatalf("report written to %q", report.File)
+	}
+	dump, err := os.ReadFile(report.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"where: OnReceive", "index out of range", "recover_test.go", "packet (7 bytes)", "04 00 00 00 01 02 03"} {
+		if !strings.Contains(string(dump), want) {
+			t.Errorf("crash report is missing %q:\n%s", want, dump)
+		}
+	}
+}
+
+func TestRecoverPanicHoldingLock(t *testing.T) {
+	configtest.Use(t, "server:\n  crash_dir: "+t.TempDir()+"\n")
+
+	b := NewBot("bot_locked", BotTypeLegacy, "locked", "", "")
+	b.Connected = true
+	updated := make(chan struct{})
+	b.OnUpdate = func() { close(updated) }
+
+	func() {
+		defer b.RecoverPanic("OnReceive", nil)
+		b.withLock(func() {
+			var vl VariantList
+			b.Local.Name = vl.Variants[1].(string)
+		})
+	}()
+
+	select {
+	case <-updated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("recovery is stuck on the bot's lock")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Status != "Crashed" || b.Connected {
+		t.Fatalf("status %q, connected %v", b.Status, b.Connected)
+	}
+}
@@ -0,0 +1,312 @@
+package bot
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"vortenixgo/config"
+)
+
+// Priority is a send class of the scheduler. A class only waits for classes
+// above it, never for the delays of the ones below.
+type Priority int
+
+const (
+	PriorityNormal Priority = iota // Actions: tiles, items, dialogs
+	PriorityHigh                   // Ping replies, state and redirect packets
+	PriorityLow                    // Chat and warps, paced by their delays
+	priorityCount
+)
+
+// priorityOrder is the order the classes are served in
+var priorityOrder = [priorityCount]Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// QueuedPacket is one outgoing ENet payload and how to send it
+type QueuedPacket struct {
+	Data     []byte
+	Priority Priority
+	// Delay holds back the rest of the packet's class for this long after it
+	// is sent, e.g. the pause after a chat message. Other classes go on.
+	Delay time.Duration
+	// NotBefore is the earliest time the packet goes out. Packets queued after
+	// it in its class may go first.
+	NotBefore  time.Time
+	Channel    uint8
+	Unreliable bool
+}
+
+// packetKind names a packet for the send limits: the action of a text packet
+// ("input", "join_request") or the type of a tank packet ("STATE")
+func packetKind(data []byte) string {
+	if len(data) < 5 {
+		return ""
+	}
+	switch binary.LittleEndian.Uint32(data[:4]) {
+	case NET_MESSAGE_GAME_PACKET:
+		return TankPacketTypeName(data[4])
+	case NET_MESSAGE_GENERIC_TEXT, NET_MESSAGE_GAME_MESSAGE:
+		line, _, _ := strings.Cut(string(data[4:]), "\n")
+		if action, ok := strings.CutPrefix(line, "action|"); ok {
+			return action
+		}
+	}
+	return ""
+}
+
+// defaultPriority is the class of a packet queued without one
+func defaultPriority(data []byte) Priority {
+	if len(data) < 5 || binary.LittleEndian.Uint32(data[:4]) != NET_MESSAGE_GAME_PACKET {
+		return PriorityNormal
+	}
+	switch ETankPacketType(data[4]) {
+	case NET_GAME_PACKET_STATE, NET_GAME_PACKET_PING_REPLY, NET_GAME_PACKET_APP_CHECK_RESPONSE, NET_GAME_PACKET_DISCONNECT:
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
+
+// tokenBucket rate limits one packet kind. The limit is read on every use, so
+// config reloads apply at once.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens earned since the last call and returns how long
+// until one is available
+func (tb *tokenBucket) refill(l config.RateLimit, now time.Time) time.Duration {
+	if tb.last.IsZero() {
+		tb.tokens = float64(l.Burst)
+	} else {
+		tb.tokens = min(float64(l.Burst), tb.tokens+now.Sub(tb.last).Seconds()*l.Rate)
+	}
+	tb.last = now
+	if tb.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - tb.tokens) / l.Rate * float64(time.Second))
+}
+
+var (
+	ErrNotConnected = errors.New("not connected")
+	ErrQueueFull    = errors.New("send queue full")
+	ErrQueueFlushed = errors.New("send queue flushed")
+)
+
+// SendQueueStats counts what became of a bot's outgoing packets
+type SendQueueStats struct {
+	Queued   int    `json:"queued"` // Waiting now
+	Sent     uint64 `json:"sent"`
+	Dropped  uint64 `json:"dropped"`  // By the drop_oldest and drop_newest policies
+	Rejected uint64 `json:"rejected"` // Full under the error policy, or the caller gave up waiting
+	Flushed  uint64 `json:"flushed"`  // Thrown away on disconnect
+}
+
+// SendQueue is a bot's outgoing queue. ProcessQueue takes packets in
+// priority order, skipping a class while its Delay runs and packets whose
+// NotBefore or send limit isn't reached yet; within a kind the order is kept.
+// Its size and what it does when full come from the timing config.
+type SendQueue struct {
+	mu      sync.Mutex
+	queues  [priorityCount][]QueuedPacket
+	hold    [priorityCount]time.Time // End of the class' last Delay
+	buckets map[string]*tokenBucket
+	n       int
+	stats   SendQueueStats
+	flushes int           // Flushes so far, so blocked pushes notice one
+	waiting int           // Pushes blocked for room
+	room    chan struct{} // Closed when a packet leaves, for blocked pushes
+	wake    chan struct{} // Signalled on push
+	timing  func() *config.TimingConfig
+}
+
+func newSendQueue() *SendQueue {
+	return &SendQueue{
+		buckets: make(map[string]*tokenBucket),
+		room:    make(chan struct{}),
+		wake:    make(chan struct{}, 1),
+		timing:  func() *config.TimingConfig { return &config.Get().Timing },
+	}
+}
+
+// push queues p. When the queue is full the policy decides: block waits for
+// room until ctx ends or the queue is flushed, drop_oldest makes room by
+// dropping the oldest packet of the least important class (or p, if every
+// queued packet matters more), drop_newest drops p, and error refuses p with
+// ErrQueueFull.
+func (s *SendQueue) push(ctx context.Context, p QueuedPacket) error {
+	if p.Priority < 0 || p.Priority >= priorityCount {
+		p.Priority = PriorityNormal
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		if err := ctx.Err(); err != nil {
+			s.stats.Rejected++
+			return err
+		}
+		timing := s.timing()
+		if s.n < timing.SendQueueSize {
+			break
+		}
+
+		switch timing.SendQueuePolicy {
+		case "block":
+			flushes, room := s.flushes, s.room
+			s.waiting++
+			s.mu.Unlock()
+			select {
+			case <-ctx.Done():
+			case <-room:
+			}
+			s.mu.Lock()
+			s.waiting--
+			if s.flushes != flushes {
+				s.stats.Flushed++
+				return ErrQueueFlushed
+			}
+		case "drop_oldest":
+			if !s.dropOldest(p.Priority) {
+				s.stats.Dropped++
+				return nil
+			}
+		case "drop_newest":
+			s.stats.Dropped++
+			return nil
+		default:
+			s.stats.Rejected++
+			return ErrQueueFull
+		}
+	}
+
+	s.queues[p.Priority] = append(s.queues[p.Priority], p)
+	s.n++
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// dropOldest drops the oldest packet of the least important class that
+// isn't more important than prio
+func (s *SendQueue) dropOldest(prio Priority) bool {
+	for i := len(priorityOrder) - 1; i >= 0; i-- {
+		class := priorityOrder[i]
+		if len(s.queues[class]) > 0 {
+			s.queues[class] = s.queues[class][1:]
+			s.n--
+			s.stats.Dropped++
+			return true
+		}
+		if class == prio {
+			return false
+		}
+	}
+	return false
+}
+
+// taken frees the room of a packet that left the queue
+func (s *SendQueue) taken() {
+	s.n--
+	if s.waiting > 0 {
+		close(s.room)
+		s.room = make(chan struct{})
+	}
+}
+
+// next takes the packet to send now. With none, it returns how long until
+// one may be ready, 0 meaning not before the next push.
+func (s *SendQueue) next(now time.Time) (*QueuedPacket, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limits := s.timing().SendLimits
+	var wait time.Duration
+	later := func(d time.Duration) {
+		if wait == 0 || d < wait {
+			wait = d
+		}
+	}
+	for _, prio := range priorityOrder {
+		q := s.queues[prio]
+		if len(q) == 0 {
+			continue
+		}
+		if d := s.hold[prio].Sub(now); d > 0 {
+			later(d)
+			continue
+		}
+		blocked := make(map[string]bool) // Kinds that must keep their order
+		for i := range q {
+			p := q[i]
+			kind := packetKind(p.Data)
+			if blocked[kind] {
+				continue
+			}
+			if d := p.NotBefore.Sub(now); d > 0 {
+				later(d)
+				continue
+			}
+			var tb *tokenBucket
+			if l, ok := limits[kind]; ok && kind != "" {
+				if tb = s.buckets[kind]; tb == nil {
+					tb = &tokenBucket{}
+					s.buckets[kind] = tb
+				}
+				if d := tb.refill(l, now); d > 0 {
+					blocked[kind] = true
+					later(d)
+					continue
+				}
+			}
+
+			if tb != nil {
+				tb.tokens--
+			}
+			if p.Delay > 0 {
+				s.hold[prio] = now.Add(p.Delay)
+			}
+			s.queues[prio] = append(q[:i:i], q[i+1:]...)
+			s.taken()
+			s.stats.Sent++
+			return &p, 0
+		}
+	}
+	return nil, wait
+}
+
+// flush drops every queued packet and running delay, and fails the pushes
+// blocked for room
+func (s *SendQueue) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for prio := range s.queues {
+		s.stats.Flushed += uint64(len(s.queues[prio]))
+		s.queues[prio] = nil
+		s.hold[prio] = time.Time{}
+	}
+	s.n = 0
+	s.flushes++
+	close(s.room)
+	s.room = make(chan struct{})
+}
+
+// Stats returns the queue's counters
+func (s *SendQueue) Stats() SendQueueStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.Queued = s.n
+	return stats
+}
+
+func (s *SendQueue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Stats())
+}
@@ -0,0 +1,217 @@
+package bot
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"vortenixgo/config"
+)
+
+func tankPacket(t ETankPacketType) []byte {
+	p := TankPacketStruct{Type: uint8(t)}
+	return append(binary.LittleEndian.AppendUint32(nil, NET_MESSAGE_GAME_PACKET), p.Serialize()...)
+}
+
+// testQueue has the given send limits and queue settings instead of the
+// config's
+func testQueue(limits map[string]config.RateLimit, size int, policy string) *SendQueue {
+	s := newSendQueue()
+	timing := &config.TimingConfig{SendLimits: limits, SendQueueSize: size, SendQueuePolicy: policy}
+	s.timing = func() *config.TimingConfig { return timing }
+	return s
+}
+
+// push queues a text packet, failing the test if it's refused
+func push(t *testing.T, s *SendQueue, text string, prio Priority) {
+	t.Helper()
+	if err := s.push(context.Background(), QueuedPacket{Data: textPacket(text, NET_MESSAGE_GENERIC_TEXT), Priority: prio}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// take expects next to hand out want at now
+func take(t *testing.T, s *SendQueue, now time.Time, want string) {
+	t.Helper()
+	p, wait := s.next(now)
+	if p == nil {
+		t.Fatalf("nothing to send, wait %v, want %q", wait, want)
+	}
+	if got := string(p.Data[4:]); got != want {
+		t.Fatalf("sent %q, want %q", got, want)
+	}
+}
+
+// idle expects nothing at now and a wait of want
+func idle(t *testing.T, s *SendQueue, now time.Time, want time.Duration) {
+	t.Helper()
+	if p, wait := s.next(now); p != nil || wait != want {
+		t.Fatalf("got %v, wait %v, want nothing for %v", p, wait, want)
+	}
+}
+
+func TestPacketKind(t *testing.T) {
+	for _, tt := range []struct {
+		data []byte
+		want string
+	}{
+		{textPacket("action|input\n|text|hi\n", NET_MESSAGE_GENERIC_TEXT), "input"},
+		{textPacket("action|join_request\nname|START\n", NET_MESSAGE_GAME_MESSAGE), "join_request"},
+		{textPacket("protocol|225\nltoken|x\n", NET_MESSAGE_GENERIC_TEXT), ""},
+		{tankPacket(NET_GAME_PACKET_TILE_CHANGE_REQUEST), "TILE_CHANGE_REQUEST"},
+		{tankPacket(NET_GAME_PACKET_STATE), "STATE"},
+		{[]byte{2, 0, 0}, ""},
+	} {
+		if got := packetKind(tt.data); got != tt.want {
+			t.Errorf("packetKind(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestSendQueuePriorities(t *testing.T) {
+	s := testQueue(nil, 100, "error")
+	now := time.Now()
+	ctx := context.Background()
+	s.push(ctx, QueuedPacket{Data: textPacket("say 1", NET_MESSAGE_GENERIC_TEXT), Priority: PriorityLow, Delay: 2 * time.Second})
+	s.push(ctx, QueuedPacket{Data: textPacket("say 2", NET_MESSAGE_GENERIC_TEXT), Priority: PriorityLow, Delay: 2 * time.Second})
+	s.push(ctx, QueuedPacket{Data: textPacket("drop", NET_MESSAGE_GENERIC_TEXT)})
+	take(t, s, now, "drop") // Normal by default
+	take(t, s, now, "say 1")
+
+	// The chat delay holds back chat only
+	push(t, s, "ping", PriorityHigh)
+	push(t, s, "wear", PriorityNormal)
+	take(t, s, now, "ping")
+	take(t, s, now, "wear")
+	idle(t, s, now, 2*time.Second)
+	take(t, s, now.Add(2*time.Second), "say 2")
+	idle(t, s, now.Add(2*time.Second), 0)
+}
+
+func TestSendQueueNotBefore(t *testing.T) {
+	s := testQueue(nil, 100, "error")
+	now := time.Now()
+	ctx := context.Background()
+	s.push(ctx, QueuedPacket{Data: textPacket("later", NET_MESSAGE_GENERIC_TEXT), Priority: PriorityNormal, NotBefore: now.Add(time.Second)})
+	push(t, s, "now", PriorityNormal)
+	take(t, s, now, "now")
+	idle(t, s, now, time.Second)
+	take(t, s, now.Add(time.Second), "later")
+}
+
+func TestSendQueueSendLimits(t *testing.T) {
+	s := testQueue(map[string]config.RateLimit{"input": {Rate: 2, Burst: 2}}, 100, "error")
+	now := time.Now()
+	for _, text := range []string{"action|input\n1", "action|input\n2", "action|input\n3", "action|drop\n"} {
+		push(t, s, text, PriorityNormal)
+	}
+	take(t, s, now, "action|input\n1")
+	take(t, s, now, "action|input\n2")
+	take(t, s, now, "action|drop\n") // Another kind passes the empty bucket
+	idle(t, s, now, 500*time.Millisecond)
+	take(t, s, now.Add(500*time.Millisecond), "action|input\n3")
+}
+
+func TestSendQueueFlush(t *testing.T) {
+	s := testQueue(nil, 100, "error")
+	now := time.Now()
+	ctx := context.Background()
+	s.push(ctx, QueuedPacket{Data: textPacket("say", NET_MESSAGE_GENERIC_TEXT), Priority: PriorityLow, Delay: time.Minute})
+	push(t, s, "queued", PriorityLow)
+	take(t, s, now, "say")
+	s.flush()
+	if stats := s.Stats(); stats.Queued != 0 || stats.Flushed != 1 || stats.Sent != 1 {
+		t.Fatalf("stats after flush %+v", stats)
+	}
+	idle(t, s, now, 0)
+
+	// The delay went with the queue
+	push(t, s, "next", PriorityLow)
+	take(t, s, now, "next")
+}
+
+func TestSendQueuePolicies(t *testing.T) {
+	ctx := context.Background()
+	full := func(policy string) *SendQueue {
+		s := testQueue(nil, 2, policy)
+		push(t, s, "say", PriorityLow)
+		push(t, s, "wear", PriorityNormal)
+		return s
+	}
+
+	s := full("error")
+	if err := s.push(ctx, QueuedPacket{Data: textPacket("drop", NET_MESSAGE_GENERIC_TEXT)}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("error policy: %v", err)
+	}
+	if stats := s.Stats(); stats.Rejected != 1 || stats.Queued != 2 {
+		t.Errorf("error policy stats %+v", stats)
+	}
+
+	s = full("drop_newest")
+	push(t, s, "drop", PriorityNormal)
+	take(t, s, time.Now(), "wear")
+	take(t, s, time.Now(), "say")
+	if stats := s.Stats(); stats.Dropped != 1 || stats.Queued != 0 {
+		t.Errorf("drop_newest stats %+v", stats)
+	}
+
+	// The oldest chat goes first, but chat never pushes out an action
+	s = full("drop_oldest")
+	push(t, s, "ping", PriorityHigh)
+	push(t, s, "say 2", PriorityLow)
+	take(t, s, time.Now(), "ping")
+	take(t, s, time.Now(), "wear")
+	idle(t, s, time.Now(), 0)
+	if stats := s.Stats(); stats.Dropped != 2 {
+		t.Errorf("drop_oldest stats %+v", stats)
+	}
+}
+
+func TestSendQueueBlock(t *testing.T) {
+	s := testQueue(nil, 1, "block")
+	push(t, s, "first", PriorityNormal)
+
+	// Room made by a send lets a blocked push in
+	done := make(chan error, 1)
+	go func() {
+		done <- s.push(context.Background(), QueuedPacket{Data: textPacket("second", NET_MESSAGE_GENERIC_TEXT)})
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("push on a full queue returned %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	take(t, s, time.Now(), "first")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// The caller's context bounds the wait
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.push(ctx, QueuedPacket{Data: textPacket("late", NET_MESSAGE_GENERIC_TEXT)}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("push after the deadline returned %v", err)
+	}
+
+	// A flush fails the pushes waiting
+	go func() {
+		done <- s.push(context.Background(), QueuedPacket{Data: textPacket("flushed", NET_MESSAGE_GENERIC_TEXT)})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	s.flush()
+	if err := <-done; !errors.Is(err, ErrQueueFlushed) {
+		t.Fatalf("push during a flush returned %v", err)
+	}
+	if stats := s.Stats(); stats.Rejected != 1 || stats.Flushed != 2 || stats.Sent != 1 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestEnqueueDisconnected(t *testing.T) {
+	b := NewBot("bot_queue", BotTypeLegacy, "queue", "", "")
+	if err := b.Enqueue(context.Background(), QueuedPacket{Data: textPacket("hi", NET_MESSAGE_GENERIC_TEXT)}); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("Enqueue while offline returned %v", err)
+	}
+}
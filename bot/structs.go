@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	"math"
 	"strings"
 )
 
@@ -175,73 +174,52 @@ type VariantList struct {
 	Variants []interface{}
 }
 
-func (vl *VariantList) Parse(data []byte) {
-	if len(data) < 1 {
-		return
+// Parse decodes a serialized variant list. It stops at the first variant that
+// is truncated or of an unknown type and returns an error; the variants before
+// it are kept.
+func (vl *VariantList) Parse(data []byte) error {
+	r := newPacketReader(data)
+	count := int(r.u8())
+	if r.err != nil {
+		return fmt.Errorf("variant list: missing count: %w", r.err)
 	}
-	count := int(data[0])
-	offset := 1
 	for i := 0; i < count; i++ {
-		if offset+2 > len(data) {
-			break
-		}
-		// index := data[offset]
-		vType := data[offset+1]
-		offset += 2
+		r.u8() // index
+		vType := r.u8()
 
+		var v interface{}
 		switch vType {
 		case 1: // Float
-			if offset+4 <= len(data) {
-				bits := binary.LittleEndian.Uint32(data[offset : offset+4])
-				vl.Variants = append(vl.Variants, math.Float32frombits(bits))
-				offset += 4
-			}
+			v = r.f32()
+		case 2: // String
+			v = string(r.bytes(r.count(r.u32(), 1)))
 		case 3: // Vector2
-			if offset+8 <= len(data) {
-				xBits := binary.LittleEndian.Uint32(data[offset : offset+4])
-				yBits := binary.LittleEndian.Uint32(data[offset+4 : offset+8])
-				vl.Variants = append(vl.Variants, Vector2{
-					X: math.Float32frombits(xBits),
-					Y: math.Float32frombits(yBits),
-				})
-				offset += 8
-			}
+			v = Vector2{X: r.f32(), Y: r.f32()}
 		case 4: // Vector3
-			if offset+12 <= len(data) {
-				xBits := binary.LittleEndian.Uint32(data[offset : offset+4])
-				yBits := binary.LittleEndian.Uint32(data[offset+4 : offset+8])
-				zBits := binary.LittleEndian.Uint32(data[offset+8 : offset+12])
-				vl.Variants = append(vl.Variants, Vector3{
-					X: math.Float32frombits(xBits),
-					Y: math.Float32frombits(yBits),
-					Z: math.Float32frombits(zBits),
-				})
-				offset += 12
-			}
-		case 2: // String
-			if offset+4 <= len(data) {
-				strLen := int(binary.LittleEndian.Uint32(data[offset : offset+4]))
-				offset += 4
-				if offset+strLen <= len(data) {
-					vl.Variants = append(vl.Variants, string(data[offset:offset+strLen]))
-					offset += strLen
-				}
-			}
+			v = Vector3{X: r.f32(), Y: r.f32(), Z: r.f32()}
 		case 5: // uint32
-			if offset+4 <= len(data) {
-				vl.Variants = append(vl.Variants, binary.LittleEndian.Uint32(data[offset:offset+4]))
-				offset += 4
-			}
+			v = r.u32()
 		case 9: // int32
-			if offset+4 <= len(data) {
-				vl.Variants = append(vl.Variants, int32(binary.LittleEndian.Uint32(data[offset:offset+4])))
-				offset += 4
-			}
+			v = int32(r.u32())
 		default:
-			// Unhandled type
-			vl.Variants = append(vl.Variants, nil)
+			if r.err == nil {
+				return fmt.Errorf("variant %d: unknown type %d", i, vType)
+			}
+		}
+		if r.err != nil {
+			return fmt.Errorf("variant %d (type %d): %w", i, vType, r.err)
 		}
+		vl.Variants = append(vl.Variants, v)
+	}
+	return nil
+}
+
+// Get returns variant index, nil if the list is shorter
+func (vl *VariantList) Get(index int) interface{} {
+	if index < 0 || index >= len(vl.Variants) {
+		return nil
 	}
+	return vl.Variants[index]
 }
 
 func (vl *VariantList) String() string {
@@ -320,27 +298,82 @@ func ProtonHash(data []byte) int32 {
 	return int32(hash)
 }
 
-func (b *Bot) convertTankPacketType(t uint8) string {
-	switch ETankPacketType(t) {
-	case NET_GAME_PACKET_STATE:
-		return "STATE"
-	case NET_GAME_PACKET_CALL_FUNCTION:
-		return "CALL_FUNCTION"
-	case NET_GAME_PACKET_UPDATE_STATUS:
-		return "UPDATE_STATUS"
-	case NET_GAME_PACKET_TILE_CHANGE_REQUEST:
-		return "TILE_CHANGE_REQUEST"
-	case NET_GAME_PACKET_SEND_MAP_DATA:
-		return "SEND_MAP_DATA"
-	case NET_GAME_PACKET_PING_REQUEST:
-		return "PING_REQUEST"
-	case NET_GAME_PACKET_PING_REPLY:
-		return "PING_REPLY"
-	case NET_GAME_PACKET_DISCONNECT:
-		return "DISCONNECT"
-	default:
-		return fmt.Sprintf("UNKNOWN (%d)", t)
+var messageTypeNames = [...]string{
+	"UNKNOWN",
+	"SERVER_HELLO",
+	"GENERIC_TEXT",
+	"GAME_MESSAGE",
+	"GAME_PACKET",
+	"ERROR",
+	"TRACK",
+	"CLIENT_LOG_REQUEST",
+	"CLIENT_LOG_RESPONSE",
+}
+
+// MessageTypeName names a NET_MESSAGE_* type without its prefix, e.g. "GAME_PACKET"
+func MessageTypeName(t uint32) string {
+	if t < uint32(len(messageTypeNames)) {
+		return messageTypeNames[t]
 	}
+	return fmt.Sprintf("UNKNOWN (%d)", t)
+}
+
+var tankPacketTypeNames = [...]string{
+	"STATE",
+	"CALL_FUNCTION",
+	"UPDATE_STATUS",
+	"TILE_CHANGE_REQUEST",
+	"SEND_MAP_DATA",
+	"SEND_TILE_UPDATE_DATA",
+	"SEND_TILE_UPDATE_DATA_MULTIPLE",
+	"TILE_ACTIVATE_REQUEST",
+	"TILE_APPLY_DAMAGE",
+	"SEND_INVENTORY_STATE",
+	"ITEM_ACTIVATE_REQUEST",
+	"ITEM_ACTIVATE_OBJECT_REQUEST",
+	"SEND_TILE_TREE_STATE",
+	"MODIFY_ITEM_INVENTORY",
+	"ITEM_CHANGE_OBJECT",
+	"SEND_LOCK",
+	"SEND_ITEM_DATABASE_DATA",
+	"SEND_PARTICLE_EFFECT",
+	"SET_ICON_STATE",
+	"ITEM_EFFECT",
+	"SET_CHARACTER_STATE",
+	"PING_REPLY",
+	"PING_REQUEST",
+	"GOT_PUNCHED",
+	"APP_CHECK_RESPONSE",
+	"APP_INTEGRITY_FAIL",
+	"DISCONNECT",
+	"BATTLE_JOIN",
+	"BATTLE_EVENT",
+	"USE_DOOR",
+	"SEND_PARENTAL",
+	"GONE_FISHIN",
+	"STEAM",
+	"PET_BATTLE",
+	"NPC",
+	"SPECIAL",
+	"SEND_PARTICLE_EFFECT_V2",
+	"ACTIVATE_ARROW_TO_ITEM",
+	"SELECT_TILE_INDEX",
+	"SEND_PLAYER_TRIBUTE_DATA",
+	"FTUE_SET_ITEM_TO_QUICK_INVENTORY",
+	"PVE_NPC",
+	"PVP_CARD_BATTLE",
+	"PVE_APPLY_PLAYER_DAMAGE",
+	"PVE_NPC_POSITION_UPDATE",
+	"SET_EXTRA_MODS",
+	"ON_STEP_TILE_MOD",
+}
+
+// TankPacketTypeName names a NET_GAME_PACKET_* type without its prefix, e.g. "CALL_FUNCTION"
+func TankPacketTypeName(t uint8) string {
+	if int(t) < len(tankPacketTypeNames) {
+		return tankPacketTypeNames[t]
+	}
+	return fmt.Sprintf("UNKNOWN (%d)", t)
 }
 
 type Vector2 struct {
@@ -470,17 +503,18 @@ const (
 
 // World represents the game world/map
 type World struct {
-	Name           string        `json:"name"`
-	Width          uint32        `json:"width"`
-	Height         uint32        `json:"height"`
-	TileCount      uint32        `json:"tile_count"`
-	Tiles          []Tile        `json:"tiles"`
-	DroppedItems   []DroppedItem `json:"dropped_items"`
-	BaseWeather    WeatherType   `json:"base_weather"`
-	CurrentWeather WeatherType   `json:"current_weather"`
-	Version        uint16        `json:"version"`
-	Flags          uint32        `json:"flags"`
-	CollisionMap   []uint8       `json:"-"`
+	Name           string            `json:"name"`
+	Width          uint32            `json:"width"`
+	Height         uint32            `json:"height"`
+	TileCount      uint32            `json:"tile_count"`
+	Tiles          []Tile            `json:"tiles"`
+	DroppedItems   []DroppedItem     `json:"dropped_items"`
+	BaseWeather    WeatherType       `json:"base_weather"`
+	CurrentWeather WeatherType       `json:"current_weather"`
+	Version        uint16            `json:"version"`
+	Flags          uint32            `json:"flags"`
+	CollisionMap   []uint8           `json:"-"`
+	ParseReport    *WorldParseReport `json:"parse_report,omitempty"`
 }
 
 // Tile represents a single tile in the world
@@ -494,6 +528,7 @@ type Tile struct {
 	Y                uint32      `json:"y"`
 	TileType         uint8       `json:"tile_type"`
 	Extra            interface{} `json:"extra,omitempty"`
+	CBOR             interface{} `json:"cbor,omitempty"` // Trailing CBOR blob some items carry
 }
 
 // TileFlag constants
@@ -724,6 +759,7 @@ type TilePetBattleCage struct {
 	Label    string             `json:"label"`
 	Unknown1 []uint8            `json:"unknown_1"`
 	Extra    PetBattleCageExtra `json:"extra"`
+	CBOR     interface{}        `json:"cbor,omitempty"` // Decoded pet data
 }
 
 type TilePetTrainer struct {
@@ -886,8 +922,11 @@ type TileContainmentFieldPowerNode struct {
 
 type TileSpiritBoard struct {
 	Unknown1 uint32 `json:"unknown_1"`
+	Text1    string `json:"text_1"`
+	Text2    string `json:"text_2"`
 	Unknown2 uint32 `json:"unknown_2"`
 	Unknown3 uint32 `json:"unknown_3"`
+	Unknown4 uint32 `json:"unknown_4"`
 }
 
 type TileStormyCloud struct {
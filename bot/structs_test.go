@@ -0,0 +1,138 @@
+package bot
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// variantWriter builds serialized variant lists the way the server sends them
+type variantWriter struct {
+	buf   []byte
+	count int
+}
+
+func (w *variantWriter) header(vType byte) {
+	w.buf = append(w.buf, byte(w.count), vType)
+	w.count++
+}
+
+func (w *variantWriter) float(v float32) *variantWriter {
+	w.header(1)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, math.Float32bits(v))
+	return w
+}
+
+func (w *variantWriter) str(s string) *variantWriter {
+	w.header(2)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(len(s)))
+	w.buf = append(w.buf, s...)
+	return w
+}
+
+func (w *variantWriter) vec2(x, y float32) *variantWriter {
+	w.header(3)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, math.Float32bits(x))
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, math.Float32bits(y))
+	return w
+}
+
+func (w *variantWriter) uint(v uint32) *variantWriter {
+	w.header(5)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, v)
+	return w
+}
+
+func (w *variantWriter) int(v int32) *variantWriter {
+	w.header(9)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(v))
+	return w
+}
+
+func (w *variantWriter) bytes() []byte {
+	return append([]byte{byte(w.count)}, w.buf...)
+}
+
+func TestVariantListParse(t *testing.T) {
+	data := (&variantWriter{}).
+		str("OnSendToServer").
+		int(17091).
+		int(-1).
+		str("213.179.209.168|0|4a1f8c2d").
+		int(1).
+		str("GROWID").
+		vec2(1600, 928.5).
+		uint(0xFFFFFFFF).
+		float(-0.25).
+		bytes()
+
+	var vl VariantList
+	if err := vl.Parse(data); err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		"OnSendToServer", int32(17091), int32(-1), "213.179.209.168|0|4a1f8c2d",
+		int32(1), "GROWID", Vector2{X: 1600, Y: 928.5}, uint32(0xFFFFFFFF), float32(-0.25),
+	}
+	if !reflect.DeepEqual(vl.Variants, want) {
+		t.Fatalf("got %#v\nwant %#v", vl.Variants, want)
+	}
+	if vl.GetString(0) != "OnSendToServer" || vl.GetInt(1) != 17091 || vl.GetUint(7) != 0xFFFFFFFF || vl.Get(9) != nil {
+		t.Fatalf("accessors disagree with %v", vl.Variants)
+	}
+}
+
+func TestVariantListParseMalformed(t *testing.T) {
+	valid := (&variantWriter{}).str("OnConsoleMessage").str("hello").bytes()
+
+	tests := []struct {
+		name string
+		data []byte
+		kept int
+	}{
+		{"empty", nil, 0},
+		{"missing variants", []byte{3}, 0},
+		{"truncated string", valid[:len(valid)-2], 1},
+		{"huge string length", []byte{1, 0, 2, 0xFF, 0xFF, 0xFF, 0xFF, 'a'}, 0},
+		{"unknown type", []byte{2, 0, 5, 1, 0, 0, 0, 1, 7, 0, 0, 0, 0}, 1},
+		{"truncated vector", []byte{1, 0, 3, 0, 0, 0x80, 0x3F, 0}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var vl VariantList
+			if err := vl.Parse(tt.data); err == nil {
+				t.Fatal("parsed without error")
+			}
+			if len(vl.Variants) != tt.kept {
+				t.Fatalf("kept %d variants, want %d", len(vl.Variants), tt.kept)
+			}
+		})
+	}
+
+	// Trailing bytes after the declared count are ignored
+	var vl VariantList
+	if err := vl.Parse(append(valid, 0xAA, 0xBB)); err != nil || vl.GetString(1) != "hello" {
+		t.Fatalf("got %v, %v", vl.Variants, err)
+	}
+}
+
+func FuzzVariantListParse(f *testing.F) {
+	f.Add((&variantWriter{}).str("OnSetPos").vec2(3200, 1504).bytes())
+	f.Add((&variantWriter{}).str("OnSendToServer").int(17091).int(-1).str("host|0|uuid").int(1).str("NAME").bytes())
+	f.Add([]byte{1, 0, 2, 0xFF, 0xFF, 0xFF, 0x7F})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var vl VariantList
+		err := vl.Parse(data)
+		if len(data) > 0 && len(vl.Variants) > int(data[0]) {
+			t.Fatalf("%d variants from a count of %d", len(vl.Variants), data[0])
+		}
+		if err == nil && len(data) > 0 && len(vl.Variants) != int(data[0]) {
+			t.Fatalf("%d variants from a count of %d without error", len(vl.Variants), data[0])
+		}
+		for i := range vl.Variants {
+			_ = vl.GetString(i)
+		}
+	})
+}
@@ -0,0 +1,631 @@
+package bot
+
+import (
+	"encoding/binary"
+	"fmt"
+	"vortenixgo/database"
+)
+
+// tileExtraParser decodes the extra data of one tile type. Short reads are left
+// in r.err for the caller; the returned value becomes Tile.Extra (nil = none).
+type tileExtraParser func(r *packetReader, tile *Tile, ctx *tileContext) interface{}
+
+// tileContext is what a parser may need besides the bytes
+type tileContext struct {
+	items   *database.ItemDatabase // nil when items.dat is not loaded
+	cborErr error                  // Set by parsers whose embedded CBOR failed to decode
+}
+
+type tileExtraType struct {
+	name  string
+	parse tileExtraParser
+}
+
+// Extra data parsers by tile extra type, filled in init
+var tileExtraTypes = make(map[uint8]tileExtraType)
+
+func registerTileExtra(id uint8, name string, parse tileExtraParser) {
+	if _, dup := tileExtraTypes[id]; dup {
+		panic(fmt.Sprintf("tile extra type %d registered twice", id))
+	}
+	tileExtraTypes[id] = tileExtraType{name: name, parse: parse}
+}
+
+// TileExtraTypeName returns the name of a tile extra type, "Unknown(<id>)" if
+// no parser is registered for it
+func TileExtraTypeName(id uint8) string {
+	if t, ok := tileExtraTypes[id]; ok {
+		return t.name
+	}
+	return fmt.Sprintf("Unknown(%d)", id)
+}
+
+// cborFollows reports whether r is at a CBOR blob trailing a tile: a uint32
+// size that fits the data, then a map filling exactly that many bytes. Nothing
+// in items.dat says which items carry one, so the data is all there is to go
+// by. The next tile never looks like this: its parent index would have to start
+// a map. A blob missed here throws the following tiles off until resync.
+func cborFollows(r *packetReader) bool {
+	if r.err != nil || r.Remaining() < 5 {
+		return false
+	}
+	data := r.data[r.pos:]
+	size := binary.LittleEndian.Uint32(data)
+	if size == 0 || uint64(size) > uint64(len(data)-4) || data[4]>>5 != 5 {
+		return false
+	}
+	_, n, err := decodeCBOR(data[4 : 4+size])
+	return err == nil && n == int(size)
+}
+
+// readCBOR reads a uint32 size-prefixed CBOR blob. The blob is always consumed
+// so a bad payload does not desync the tiles after it.
+func readCBOR(r *packetReader) (interface{}, error) {
+	size := r.u32()
+	raw := r.bytes(r.count(size, 1))
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	v, n, err := decodeCBOR(raw)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(raw) {
+		return v, fmt.Errorf("cbor: %d trailing bytes", len(raw)-n)
+	}
+	return v, nil
+}
+
+// Messages shared by Mailbox, Bulletin and DonationBox
+func readThreeMessages(r *packetReader) (string, string, string) {
+	m1 := r.str()
+	m2 := r.str()
+	m3 := r.str()
+	return m1, m2, m3
+}
+
+func init() {
+	registerTileExtra(1, "Sign", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileSign{Text: r.str()}
+		r.read(&data.Flags)
+		return data
+	})
+
+	registerTileExtra(2, "Door", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileDoor{Text: r.str()}
+		r.read(&data.OwnerUID)
+		return data
+	})
+
+	registerTileExtra(3, "Lock", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileLock{}
+		r.read(&data.Settings)
+		r.read(&data.OwnerUID)
+		r.read(&data.AccessCount)
+		data.AccessUIDs = r.u32s(data.AccessCount)
+		r.read(&data.MinimumLevel)
+		r.skip(7) // unknown data
+		if tile.ForegroundItemID == 5814 {
+			r.skip(16)
+		}
+		return data
+	})
+
+	registerTileExtra(4, "Seed", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileSeed{}
+		r.read(&data.TimePassed)
+		r.read(&data.ItemOnTree)
+		if ctx.items != nil {
+			if item := ctx.items.GetItem(uint32(tile.ForegroundItemID)); item != nil {
+				data.ReadyToHarvest = data.TimePassed >= item.GrowTime
+			}
+		}
+		return data
+	})
+
+	registerTileExtra(6, "Mailbox", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileMailbox{}
+		data.Message1, data.Message2, data.Message3 = readThreeMessages(r)
+		r.read(&data.Unknown)
+		return data
+	})
+
+	registerTileExtra(7, "Bulletin", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileBulletin{}
+		data.Message1, data.Message2, data.Message3 = readThreeMessages(r)
+		r.read(&data.Unknown)
+		return data
+	})
+
+	registerTileExtra(8, "Dice", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileDice{}
+		r.read(&data.Symbol)
+		return data
+	})
+
+	registerTileExtra(9, "ChemicalSource", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileChemicalSource{}
+		r.read(&data.TimePassed)
+		return data
+	})
+
+	registerTileExtra(10, "AchievementBlock", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileAchievementBlock{}
+		r.read(&data.Unknown1)
+		r.read(&data.TileType)
+		return data
+	})
+
+	registerTileExtra(11, "HearthMonitor", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileHearthMonitor{}
+		r.read(&data.Unknown1)
+		data.PlayerName = r.str()
+		return data
+	})
+
+	registerTileExtra(12, "DonationBox", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileDonationBox{}
+		data.Message1, data.Message2, data.Message3 = readThreeMessages(r)
+		r.read(&data.Unknown)
+		return data
+	})
+
+	registerTileExtra(14, "Mannequin", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileMannequin{Text: r.str()}
+		r.read(&data.Unknown1)
+		for _, c := range []interface{}{
+			&data.Clothing1, &data.Clothing2, &data.Clothing3, &data.Clothing4, &data.Clothing5,
+			&data.Clothing6, &data.Clothing7, &data.Clothing8, &data.Clothing9, &data.Clothing10,
+		} {
+			r.read(c)
+		}
+		return data
+	})
+
+	registerTileExtra(15, "BunnyEgg", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileBunnyEgg{}
+		r.read(&data.EggPlaced)
+		return data
+	})
+
+	registerTileExtra(16, "GamePack", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileGamePack{}
+		r.read(&data.Team)
+		return data
+	})
+
+	registerTileExtra(17, "GameGenerator", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		return TileGameGenerator{}
+	})
+
+	registerTileExtra(18, "XenoniteCrystal", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileXenoniteCrystal{}
+		r.read(&data.Unknown1)
+		r.read(&data.Unknown2)
+		return data
+	})
+
+	registerTileExtra(19, "PhoneBooth", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TilePhoneBooth{}
+		for _, c := range []interface{}{
+			&data.Clothing1, &data.Clothing2, &data.Clothing3, &data.Clothing4, &data.Clothing5,
+			&data.Clothing6, &data.Clothing7, &data.Clothing8, &data.Clothing9,
+		} {
+			r.read(c)
+		}
+		return data
+	})
+
+	registerTileExtra(20, "Crystal", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		return TileCrystal{Message: r.str()}
+	})
+
+	registerTileExtra(21, "CrimeInProgress", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileCrimeInProgress{Message: r.str()}
+		r.read(&data.Unknown2)
+		r.read(&data.Unknown3)
+		return data
+	})
+
+	registerTileExtra(23, "DisplayBlock", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileDisplayBlock{}
+		r.read(&data.ItemID)
+		return data
+	})
+
+	registerTileExtra(24, "VendingMachine", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileVendingMachine{}
+		r.read(&data.ItemID)
+		r.read(&data.Price)
+		return data
+	})
+
+	registerTileExtra(25, "FishTankPort", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileFishTankPort{}
+		r.read(&data.Flags)
+		// The count is in fields, each fish is an (id, lbs) pair
+		fishCount := r.u32()
+		n := r.count(fishCount/2, 8)
+		for i := 0; i < n; i++ {
+			var f FishInfo
+			r.read(&f.FishItemID)
+			r.read(&f.Lbs)
+			data.Fishes = append(data.Fishes, f)
+		}
+		return data
+	})
+
+	registerTileExtra(26, "SolarCollector", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		return TileSolarCollector{Unknown1: r.bytes(5)}
+	})
+
+	registerTileExtra(27, "Forge", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileForge{}
+		r.read(&data.Temperature)
+		return data
+	})
+
+	registerTileExtra(28, "GivingTree", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileGivingTree{}
+		r.read(&data.Unknown1)
+		r.read(&data.Unknown2)
+		return data
+	})
+
+	registerTileExtra(30, "SteamOrgan", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileSteamOrgan{}
+		r.read(&data.InstrumentType)
+		r.read(&data.Note)
+		return data
+	})
+
+	registerTileExtra(31, "SilkWorm", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileSilkWorm{}
+		r.read(&data.Type)
+		data.Name = r.str()
+		r.read(&data.Age)
+		r.read(&data.Unknown1)
+		r.read(&data.Unknown2)
+		r.read(&data.CanBeFed)
+		r.read(&data.FoodSaturation)
+		r.read(&data.WaterSaturation)
+		color := r.u32()
+		data.Color = SilkWormColor{
+			A: uint8(color >> 24),
+			R: uint8(color >> 16),
+			G: uint8(color >> 8),
+			B: uint8(color),
+		}
+		r.read(&data.SickDuration)
+		return data
+	})
+
+	registerTileExtra(32, "SewingMachine", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		return TileSewingMachine{BoltIDList: r.u32s(uint32(r.u16()))}
+	})
+
+	registerTileExtra(33, "CountryFlag", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		return TileCountryFlag{Country: r.str()}
+	})
+
+	registerTileExtra(34, "LobsterTrap", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		return TileLobsterTrap{}
+	})
+
+	registerTileExtra(35, "PaintingEasel", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TilePaintingEasel{}
+		r.read(&data.ItemID)
+		data.Label = r.str()
+		return data
+	})
+
+	registerTileExtra(36, "PetBattleCage", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TilePetBattleCage{Label: r.str(), Unknown1: r.bytes(12)}
+		// A bad CBOR payload only loses the decoded pets, the blob is consumed
+		data.CBOR, ctx.cborErr = readCBOR(r)
+		return data
+	})
+
+	registerTileExtra(37, "PetTrainer", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TilePetTrainer{Name: r.str()}
+		r.read(&data.PetTotalCount)
+		r.read(&data.Unknown1)
+		data.PetsID = r.u32s(data.PetTotalCount)
+		return data
+	})
+
+	registerTileExtra(38, "SteamEngine", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileSteamEngine{}
+		r.read(&data.Temperature)
+		return data
+	})
+
+	registerTileExtra(39, "LockBot", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileLockBot{}
+		r.read(&data.TimePassed)
+		return data
+	})
+
+	registerTileExtra(40, "WeatherMachine", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileWeatherMachine{}
+		r.read(&data.Settings)
+		return data
+	})
+
+	registerTileExtra(41, "SpiritStorageUnit", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileSpiritStorageUnit{}
+		r.read(&data.GhostJarCount)
+		return data
+	})
+
+	registerTileExtra(42, "DataBedrock", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		r.skip(21)
+		return TileDataBedrock{}
+	})
+
+	registerTileExtra(43, "Shelf", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileShelf{}
+		r.read(&data.TopLeftItemID)
+		r.read(&data.TopRightItemID)
+		r.read(&data.BottomLeftItemID)
+		r.read(&data.BottomRightItemID)
+		return data
+	})
+
+	registerTileExtra(44, "VipEntrance", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileVipEntrance{}
+		r.read(&data.Unknown1)
+		r.read(&data.OwnerUID)
+		data.AccessUIDs = r.u32s(r.u32())
+		return data
+	})
+
+	registerTileExtra(45, "ChallengeTimer", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		return TileChallengeTimer{}
+	})
+
+	registerTileExtra(47, "FishWallMount", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileFishWallMount{Label: r.str()}
+		r.read(&data.ItemID)
+		r.read(&data.Lb)
+		return data
+	})
+
+	registerTileExtra(48, "Portrait", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TilePortrait{Label: r.str()}
+		r.read(&data.Unknown1)
+		r.read(&data.Unknown2)
+		r.read(&data.Unknown3)
+		r.read(&data.Unknown4)
+		r.read(&data.Face)
+		r.read(&data.Hat)
+		r.read(&data.Hair)
+		r.read(&data.Unknown5)
+		r.read(&data.Unknown6)
+		return data
+	})
+
+	registerTileExtra(49, "GuildWeatherMachine", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileGuildWeatherMachine{}
+		r.read(&data.Unknown1)
+		r.read(&data.Gravity)
+		r.read(&data.Flags)
+		return data
+	})
+
+	registerTileExtra(50, "FossilPrepStation", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileFossilPrepStation{}
+		r.read(&data.Unknown1)
+		return data
+	})
+
+	// No extra data
+	registerTileExtra(51, "DnaExtractor", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		return nil
+	})
+	registerTileExtra(52, "Howler", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		return nil
+	})
+
+	registerTileExtra(53, "ChemsynthTank", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileChemsynthTank{}
+		r.read(&data.CurrentChem)
+		r.read(&data.TargetChem)
+		return data
+	})
+
+	registerTileExtra(54, "StorageBlock", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileStorageBlock{}
+		// 13 bytes per item: 3 unknown, u32 id, 2 unknown, u32 amount
+		count := int(r.u16()) / 13
+		for i := 0; i < count && r.err == nil; i++ {
+			r.skip(3)
+			id := r.u32()
+			r.skip(2)
+			amount := r.u32()
+			data.Items = append(data.Items, StorageBlockItemInfo{ID: id, Amount: amount})
+		}
+		return data
+	})
+
+	registerTileExtra(55, "CookingOven", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileCookingOven{}
+		r.skip(4)
+		r.read(&data.TemperatureLevel)
+		n := r.count(r.u32(), 8)
+		for i := 0; i < n; i++ {
+			var ing CookingOvenIngredientInfo
+			r.read(&ing.ItemID)
+			r.read(&ing.TimeAdded)
+			data.Ingredients = append(data.Ingredients, ing)
+		}
+		r.read(&data.Unknown1)
+		r.read(&data.Unknown2)
+		r.read(&data.Unknown3)
+		return data
+	})
+
+	registerTileExtra(56, "AudioRack", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileAudioRack{Note: r.str()}
+		r.read(&data.Volume)
+		return data
+	})
+
+	registerTileExtra(57, "GeigerCharger", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileGeigerCharger{SecondsFromStart: r.u32()}
+		if data.SecondsFromStart > 3600 {
+			data.SecondsFromStart = 3600
+		}
+		data.SecondsToComplete = 3600 - data.SecondsFromStart
+		data.ChargingPercent = data.SecondsFromStart / 36
+		data.MinutesFromStart = data.SecondsFromStart / 60
+		data.MinutesToComplete = 60 - data.MinutesFromStart
+		return data
+	})
+
+	registerTileExtra(58, "AdventureBegins", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		return TileAdventureBegins{}
+	})
+
+	registerTileExtra(59, "TombRobber", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		return TileTombRobber{}
+	})
+
+	registerTileExtra(60, "BalloonOMatic", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileBalloonOMatic{}
+		r.read(&data.TotalRarity)
+		r.read(&data.TeamType)
+		return data
+	})
+
+	registerTileExtra(61, "TrainingPort", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileTrainingPort{}
+		r.read(&data.FishLb)
+		r.read(&data.FishStatus)
+		r.read(&data.FishID)
+		r.read(&data.FishTotalExp)
+		r.read(&data.FishLevel)
+		r.read(&data.Unknown2)
+		return data
+	})
+
+	registerTileExtra(62, "ItemSucker", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileItemSucker{}
+		r.read(&data.ItemIDToSuck)
+		r.read(&data.ItemAmount)
+		r.read(&data.Flags)
+		r.read(&data.Limit)
+		return data
+	})
+
+	registerTileExtra(63, "CyBot", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileCyBot{}
+		r.read(&data.SyncTimer)
+		r.read(&data.Activated)
+		n := r.count(r.u32(), 15)
+		for i := 0; i < n; i++ {
+			var cmd CyBotCommandData
+			r.read(&cmd.CommandID)
+			r.read(&cmd.IsCommandUsed)
+			r.skip(7)
+			data.CommandDatas = append(data.CommandDatas, cmd)
+		}
+		return data
+	})
+
+	registerTileExtra(65, "GuildItem", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		r.skip(17)
+		return TileGuildItem{}
+	})
+
+	registerTileExtra(66, "Growscan", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileGrowscan{}
+		r.read(&data.Unknown1)
+		return data
+	})
+
+	registerTileExtra(67, "ContainmentFieldPowerNode", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileContainmentFieldPowerNode{}
+		r.read(&data.GhostJarCount)
+		data.Unknown1 = r.u32s(r.u32())
+		return data
+	})
+
+	registerTileExtra(68, "SpiritBoard", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileSpiritBoard{}
+		r.read(&data.Unknown1)
+		data.Text1 = r.str()
+		data.Text2 = r.str()
+		r.read(&data.Unknown2)
+		r.read(&data.Unknown3)
+		r.read(&data.Unknown4)
+		return data
+	})
+
+	registerTileExtra(69, "TesseractManipulator", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileTesseractManipulator{}
+		r.read(&data.Gems)
+		r.read(&data.Unknown2)
+		r.read(&data.ItemID)
+		r.read(&data.Unknown4)
+		return data
+	})
+
+	registerTileExtra(72, "StormyCloud", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileStormyCloud{}
+		r.read(&data.StingDuration)
+		r.read(&data.IsSolid)
+		r.read(&data.NonSolidDuration)
+		return data
+	})
+
+	registerTileExtra(73, "TemporaryPlatform", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileTemporaryPlatform{}
+		r.read(&data.Unknown1)
+		return data
+	})
+
+	registerTileExtra(74, "SafeVault", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		return TileSafeVault{}
+	})
+
+	registerTileExtra(75, "AngelicCountingCloud", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileAngelicCountingCloud{}
+		r.read(&data.IsRaffling)
+		r.read(&data.Unknown1)
+		r.read(&data.AsciiCode)
+		return data
+	})
+
+	registerTileExtra(77, "InfinityWeatherMachine", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileInfinityWeatherMachine{}
+		r.read(&data.IntervalMinutes)
+		data.WeatherMachineList = r.u32s(r.u32())
+		return data
+	})
+
+	registerTileExtra(79, "PineappleGuzzler", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		return TilePineappleGuzzler{}
+	})
+
+	registerTileExtra(80, "KrakenGalaticBlock", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileKrakenGalaticBlock{}
+		r.read(&data.PatternIndex)
+		r.read(&data.Unknown1)
+		r.read(&data.R)
+		r.read(&data.G)
+		r.read(&data.B)
+		return data
+	})
+
+	registerTileExtra(81, "FriendsEntrance", func(r *packetReader, tile *Tile, ctx *tileContext) interface{} {
+		data := TileFriendsEntrance{}
+		r.read(&data.OwnerUserID)
+		r.read(&data.Unknown1)
+		r.read(&data.Unknown2)
+		return data
+	})
+}
@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"log"
+	"sync"
+	"time"
+	"vortenixgo/config"
+)
+
+// VersionManager keeps track of the game version used in the login packet.
+// When the server answers with "UPDATE REQUIRED" the detected version is applied
+// to every bot, persisted in config and the affected bots are reconnected.
+type VersionManager struct {
+	mu      sync.Mutex
+	current string
+
+	// ReconnectDelay staggers reconnects so the whole fleet doesn't log in at once
+	ReconnectDelay time.Duration
+
+	// OnReconnect is set by the orchestrator to run the full connect flow
+	OnReconnect func(*Bot)
+}
+
+// Versions is the global version manager
+var Versions = NewVersionManager()
+
+func NewVersionManager() *VersionManager {
+	return &VersionManager{
+		ReconnectDelay: 500 * time.Millisecond,
+	}
+}
+
+// Current returns the game version new login packets should use
+func (vm *VersionManager) Current() string {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if vm.current == "" {
+		return config.Get().Login.GameVersion
+	}
+	return vm.current
+}
+
+// Update stores the detected version and applies it to all bots.
+// Bots that were stopped with status "UPDATE" are reconnected.
+func (vm *VersionManager) Update(version string) {
+	if version == "" {
+		return
+	}
+
+	vm.mu.Lock()
+	changed := vm.current != version && config.Get().Login.GameVersion != version
+	vm.current = version
+	onReconnect := vm.OnReconnect
+	delay := vm.ReconnectDelay
+	vm.mu.Unlock()
+
+	if changed {
+		log.Printf("[Version] Game version updated to %s", version)
+		if err := config.Set("login.game_version", version); err != nil {
+			log.Printf("[Version] Failed to persist game version: %v", err)
+		}
+	}
+
+	generator := &GenerateLoginData{}
+	var affected []*Bot
+	for _, b := range BotManager.GetAllBots() {
+		b.mu.Lock()
+		if b.Login.GameVersion != version {
+			b.Login.GameVersion = version
+			if b.Login.Rid != "" {
+				b.Login.Klv = generator.GenerateKlv(b.Login.Protocol, version, b.Login.Rid)
+			}
+		}
+		if b.Status == "UPDATE" {
+			affected = append(affected, b)
+		}
+		b.mu.Unlock()
+	}
+
+	if onReconnect == nil || len(affected) == 0 {
+		return
+	}
+	go func() {
+		for i, b := range affected {
+			if i > 0 {
+				time.Sleep(delay)
+			}
+			b.logENet("[SYSTEM]: Reconnecting with game version " + version)
+			func() {
+				defer b.RecoverPanic("Reconnect", nil)
+				onReconnect(b)
+			}()
+		}
+	}()
+}
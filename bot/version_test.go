@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"vortenixgo/config"
+	"vortenixgo/config/configtest"
+)
+
+func TestVersionUpdate(t *testing.T) {
+	file := "# Comment\nlogin:\n  game_version: \"5.39\"       # VORTENIX_GAME_VERSION\n  country: us\n"
+	cfgPath := configtest.Use(t, file)
+
+	vm := NewVersionManager()
+	vm.ReconnectDelay = 0
+	reconnected := make(chan string, 2)
+	vm.OnReconnect = func(b *Bot) { reconnected <- b.ID }
+	old := Versions
+	Versions = vm
+	t.Cleanup(func() { Versions = old })
+
+	stopped, err := BotManager.AddBot(BotTypeLegacy, "version_stopped", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	online, err := BotManager.AddBot(BotTypeLegacy, "version_online", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		BotManager.RemoveBot(stopped.ID)
+		BotManager.RemoveBot(online.ID)
+	})
+	online.Status = "online"
+
+	stopped.handleGameMessage([]byte("action|log\nmsg|`4UPDATE REQUIRED!`` Get version $V5.40 now\x00"))
+	select {
+	case id := <-reconnected:
+		if id != stopped.ID {
+			t.Fatalf("reconnected %s", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stopped bot wasn't reconnected")
+	}
+	select {
+	case id := <-reconnected:
+		t.Fatalf("reconnected %s, which wasn't stopped for the update", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if v := vm.Current(); v != "5.40" {
+		t.Errorf("current version %q", v)
+	}
+	for _, b := range []*Bot{stopped, online} {
+		b.mu.Lock()
+		v := b.Login.GameVersion
+		b.mu.Unlock()
+		if v != "5.40" {
+			t.Errorf("%s logs in with %q", b.ID, v)
+		}
+	}
+	if v := config.Get().Login.GameVersion; v != "5.40" {
+		t.Errorf("config has %q", v)
+	}
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Replace(file, "5.39", "5.40", 1); string(data) != want {
+		t.Errorf("config file\n%s\nwant\n%s", data, want)
+	}
+}
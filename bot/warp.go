@@ -0,0 +1,220 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"vortenixgo/config"
+)
+
+// Why a join failed. Warp wraps them in a *WarpError.
+var (
+	ErrInvalidWorld = errors.New("invalid world name")
+	ErrWorldLocked  = errors.New("world is locked")
+	ErrWorldFull    = errors.New("too many people in world")
+	ErrWorldBanned  = errors.New("banned from world")
+	ErrJoinFailed   = errors.New("failed to enter world")
+	ErrWarpTimeout  = errors.New("no answer to join request")
+	ErrWarpReplaced = errors.New("replaced by another warp")
+)
+
+// Console messages telling why a join failed, lowercase without color codes
+var joinFailures = []struct {
+	text string
+	err  error
+}{
+	{"world is locked", ErrWorldLocked},
+	{"too many people", ErrWorldFull},
+	{"banned from", ErrWorldBanned},
+}
+
+// WarpError is a Warp that gave up
+type WarpError struct {
+	World    string // As asked, with the door
+	Attempts int
+	Message  string // The server's console message, if any
+	Err      error
+}
+
+func (e *WarpError) Error() string {
+	msg := fmt.Sprintf("warp to %s failed after %d attempt(s): %v", e.World, e.Attempts, e.Err)
+	if e.Message != "" {
+		msg += " (" + e.Message + ")"
+	}
+	return msg
+}
+
+func (e *WarpError) Unwrap() error {
+	return e.Err
+}
+
+// retryable reports whether a join that failed with err may work next time
+func retryable(err error) bool {
+	return errors.Is(err, ErrWarpTimeout) || errors.Is(err, ErrWorldFull) || errors.Is(err, ErrJoinFailed)
+}
+
+// pendingJoin is a Warp waiting for the server's answer
+type pendingJoin struct {
+	world   string // EXIT when leaving
+	message string // Console message that explained a failure
+	done    chan error
+}
+
+// ParseWarpTarget splits "NAME" or "NAME|DOORID" into an upper-case world
+// name and a door ID. World names are 1 to 24 letters and digits.
+func ParseWarpTarget(target string) (world, door string, err error) {
+	world, door, _ = strings.Cut(strings.TrimSpace(target), "|")
+	world = strings.ToUpper(world)
+	if len(world) == 0 || len(world) > 24 {
+		return "", "", fmt.Errorf("%w: %q", ErrInvalidWorld, target)
+	}
+	for _, r := range world {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return "", "", fmt.Errorf("%w: %q", ErrInvalidWorld, target)
+		}
+	}
+	if strings.ContainsAny(door, "|\n") {
+		return "", "", fmt.Errorf("%w: door %q", ErrInvalidWorld, door)
+	}
+	return world, door, nil
+}
+
+// Warp joins the world target, "NAME" or "NAME|DOORID", and waits until the
+// bot is in it. "EXIT" leaves the current world. A join that times out, finds
+// the world full or fails for no given reason is tried again as
+// timing.warp_retries says; any failure returns a *WarpError. ctx bounds the
+// whole call.
+func (b *Bot) Warp(ctx context.Context, target string) error {
+	world, door, err := ParseWarpTarget(target)
+	if err != nil {
+		return err
+	}
+	if world == "EXIT" {
+		return b.leaveWorld(ctx)
+	}
+
+	t := config.Get().Timing
+	werr := &WarpError{World: target}
+	for werr.Attempts < t.WarpRetries+1 {
+		if werr.Attempts > 0 {
+			b.logENet(fmt.Sprintf("[SYSTEM]: Joining %s failed (%v), retrying in %v", target, werr.Err, t.WarpRetryDelay.D()))
+			select {
+			case <-ctx.Done():
+				werr.Err = ctx.Err()
+				return werr
+			case <-time.After(t.WarpRetryDelay.D()):
+			}
+		}
+		werr.Attempts++
+		pkt := fmt.Sprintf("action|join_request\nname|%s\ninvitedWorld|0\n", joinName(world, door))
+		werr.Message, werr.Err = b.await(ctx, world, pkt, t)
+		if werr.Err == nil {
+			return nil
+		}
+		if !retryable(werr.Err) {
+			break
+		}
+	}
+	return werr
+}
+
+func joinName(world, door string) string {
+	if door == "" {
+		return world
+	}
+	return world + "|" + door
+}
+
+// leaveWorld goes back to EXIT, the world select menu
+func (b *Bot) leaveWorld(ctx context.Context) error {
+	b.mu.Lock()
+	inWorld := b.Local.World.Name != "" && b.Local.World.Name != "EXIT"
+	b.mu.Unlock()
+	if !inWorld {
+		return nil
+	}
+	message, err := b.await(ctx, "EXIT", "action|quit_to_exit\n", config.Get().Timing)
+	if err != nil {
+		return &WarpError{World: "EXIT", Attempts: 1, Message: message, Err: err}
+	}
+	return nil
+}
+
+// await sends pkt and waits for the answer about world: the server's
+// console message and why it failed, or nil
+func (b *Bot) await(ctx context.Context, world, pkt string, t config.TimingConfig) (string, error) {
+	j := &pendingJoin{world: world, done: make(chan error, 1)}
+	b.mu.Lock()
+	b.endJoinLocked(ErrWarpReplaced)
+	b.join = j
+	b.mu.Unlock()
+
+	timer := time.NewTimer(t.WarpTimeout.D())
+	defer timer.Stop()
+	var err error
+	if err = b.Enqueue(ctx, QueuedPacket{Data: textPacket(pkt, NET_MESSAGE_GAME_MESSAGE), Priority: PriorityLow, Delay: t.WarpDelay.D()}); err == nil {
+		select {
+		case err = <-j.done:
+		case <-timer.C:
+			err = ErrWarpTimeout
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.join == j {
+		b.join = nil
+	}
+	return j.message, err
+}
+
+// endJoinLocked answers the pending join, if any, with err
+func (b *Bot) endJoinLocked(err error) {
+	if b.join != nil {
+		b.join.done <- err
+		b.join = nil
+	}
+}
+
+// joinConsoleLocked checks a console message for a reason the pending join
+// failed
+func (b *Bot) joinConsoleLocked(msg string) {
+	if b.join == nil || b.join.world == "EXIT" || strings.HasPrefix(msg, "CP:") { // CP: is chat
+		return
+	}
+	plain := strings.ToLower(stripColors(msg))
+	for _, f := range joinFailures {
+		if strings.Contains(plain, f.text) {
+			b.join.message = stripColors(msg)
+			b.endJoinLocked(f.err)
+			return
+		}
+	}
+}
+
+// joinedLocked records that the bot is in world, after map data or the
+// world select menu
+func (b *Bot) joinedLocked(world string) {
+	b.World = world
+	if b.join != nil && (b.join.world == "EXIT") == (world == "EXIT") {
+		b.endJoinLocked(nil)
+	}
+}
+
+// stripColors removes the game's `x color codes
+func stripColors(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '`' {
+			i++
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
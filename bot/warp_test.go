@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseWarpTarget(t *testing.T) {
+	tests := []struct {
+		target, world, door string
+		ok                  bool
+	}{
+		{"start", "START", "", true},
+		{" Buy|SHOP1 ", "BUY", "SHOP1", true},
+		{"exit", "EXIT", "", true},
+		{"WORLD123|", "WORLD123", "", true},
+		{"", "", "", false},
+		{"|door", "", "", false},
+		{"no spaces", "", "", false},
+		{"ÄPFEL", "", "", false},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXY", "", "", false},
+		{"a|b|c", "", "", false},
+	}
+	for _, tt := range tests {
+		world, door, err := ParseWarpTarget(tt.target)
+		if (err == nil) != tt.ok || world != tt.world || door != tt.door {
+			t.Errorf("ParseWarpTarget(%q) = %q, %q, %v", tt.target, world, door, err)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidWorld) {
+			t.Errorf("ParseWarpTarget(%q): %v isn't ErrInvalidWorld", tt.target, err)
+		}
+	}
+}
+
+func TestJoinConsole(t *testing.T) {
+	b := NewBot("bot_warp", BotTypeLegacy, "warper", "", "")
+	for msg, want := range map[string]error{
+		"`4That world is locked.``":                            ErrWorldLocked,
+		"`4Oops:`` Too many people in that world!":             ErrWorldFull,
+		"`4You are banned from that world.``":                  ErrWorldBanned,
+		"World `wSTART`` entered.":                             nil,
+		"CP:0_PL:4_OID:_CT:[W]_ <`wjoe``> world is locked lol": nil,
+	} {
+		j := &pendingJoin{world: "START", done: make(chan error, 1)}
+		b.join = j
+		b.joinConsoleLocked(msg)
+		select {
+		case err := <-j.done:
+			if err != want {
+				t.Errorf("%q: %v, want %v", msg, err, want)
+			}
+			if j.message != stripColors(msg) {
+				t.Errorf("%q: message %q", msg, j.message)
+			}
+		default:
+			if want != nil {
+				t.Errorf("%q: join still pending, want %v", msg, want)
+			}
+		}
+	}
+}
@@ -1,192 +1,347 @@
 package bot
 
 import (
-	"bytes"
-	"encoding/binary"
+	"errors"
 	"fmt"
-	"io"
 	"os"
+	"sort"
+	"strings"
+	"vortenixgo/database"
 )
 
-// ParseWorld parses world data from NET_GAME_PACKET_SEND_MAP_DATA
-func (b *Bot) ParseWorld(data []byte) error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+// Resync limits after a tile with an unknown extra type: how far ahead to look
+// for the next tile, how many whole tiles a parsing offset may still be off by,
+// and how many tiles probing may parse per world
+const (
+	maxResyncScan     = 4096
+	resyncTileWindow  = 8
+	resyncProbeBudget = 1 << 18
+)
 
-	reader := bytes.NewReader(data)
-	world := &b.Local.World
+// Issues kept per WorldParseReport
+const maxParseIssues = 100
 
-	// Reset world
-	world.Name = "EXIT"
-	world.Width = 0
-	world.Height = 0
-	world.TileCount = 0
-	world.Tiles = nil
-	world.DroppedItems = nil
-	world.BaseWeather = 0
-	world.CurrentWeather = 0
+// WorldParseReport records what ParseWorld could not fully decode
+type WorldParseReport struct {
+	UnknownTypes   map[uint8]int `json:"unknown_types,omitempty"` // Extra type -> number of tiles
+	RecoveredTiles int           `json:"recovered_tiles"`         // Tiles skipped over by resyncing
+	CBORTiles      int           `json:"cbor_tiles"`
+	CBORErrors     int           `json:"cbor_errors"`
+	Issues         []TileIssue   `json:"issues,omitempty"` // First maxParseIssues problems
+}
 
-	// Read version
-	var version uint16
-	if err := binary.Read(reader, binary.LittleEndian, &version); err != nil {
-		return fmt.Errorf("failed to read version: %w", err)
-	}
-	world.Version = version
+// TileIssue is one tile ParseWorld had trouble with
+type TileIssue struct {
+	Index     uint32 `json:"index"`
+	X         uint32 `json:"x"`
+	Y         uint32 `json:"y"`
+	ItemID    uint16 `json:"item_id"`
+	ExtraType uint8  `json:"extra_type"`
+	Message   string `json:"message"`
+}
 
-	if version < 0x19 {
-		return fmt.Errorf("unsupported world version: %d", version)
-	}
+// Clean reports whether every tile was decoded
+func (r *WorldParseReport) Clean() bool {
+	return len(r.UnknownTypes) == 0 && r.CBORErrors == 0
+}
 
-	// Read flags
-	if err := binary.Read(reader, binary.LittleEndian, &world.Flags); err != nil {
-		return fmt.Errorf("failed to read flags: %w", err)
+func (r *WorldParseReport) String() string {
+	if r.Clean() {
+		return fmt.Sprintf("all tiles decoded (%d with CBOR)", r.CBORTiles)
 	}
-
-	// Read world name
-	var nameLen uint16
-	if err := binary.Read(reader, binary.LittleEndian, &nameLen); err != nil {
-		return fmt.Errorf("failed to read name length: %w", err)
+	types := make([]int, 0, len(r.UnknownTypes))
+	for t := range r.UnknownTypes {
+		types = append(types, int(t))
 	}
-	nameBytes := make([]byte, nameLen)
-	if _, err := io.ReadFull(reader, nameBytes); err != nil {
-		return fmt.Errorf("failed to read name: %w", err)
+	sort.Ints(types)
+	parts := make([]string, len(types))
+	for i, t := range types {
+		parts[i] = fmt.Sprintf("%d x%d", t, r.UnknownTypes[uint8(t)])
 	}
-	world.Name = string(nameBytes)
+	return fmt.Sprintf("unparsed extra types [%s], %d tiles recovered, %d/%d CBOR errors",
+		strings.Join(parts, ", "), r.RecoveredTiles, r.CBORErrors, r.CBORTiles)
+}
 
-	// Read dimensions
-	if err := binary.Read(reader, binary.LittleEndian, &world.Width); err != nil {
-		return fmt.Errorf("failed to read width: %w", err)
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &world.Height); err != nil {
-		return fmt.Errorf("failed to read height: %w", err)
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &world.TileCount); err != nil {
-		return fmt.Errorf("failed to read tile count: %w", err)
+func (r *WorldParseReport) issue(i uint32, tile *Tile, format string, args ...interface{}) {
+	if len(r.Issues) >= maxParseIssues {
+		return
 	}
+	r.Issues = append(r.Issues, TileIssue{
+		Index:     i,
+		X:         tile.X,
+		Y:         tile.Y,
+		ItemID:    tile.ForegroundItemID,
+		ExtraType: tile.TileType,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
 
-	// Skip 5 bytes (debug flag)
-	reader.Seek(5, io.SeekCurrent)
+// TileUnknown is the extra data of a tile type with no registered parser
+type TileUnknown struct {
+	Type uint8  `json:"type"`
+	Raw  []byte `json:"raw"`
+}
 
-	if world.TileCount > 0xFE01 {
-		return fmt.Errorf("tile count too large: %d", world.TileCount)
-	}
+// errUnknownExtra is returned by worldParser.tile for an unregistered extra type
+var errUnknownExtra = errors.New("unknown tile extra type")
 
-	// Parse tiles
-	world.Tiles = make([]Tile, 0, world.TileCount)
-	for i := uint32(0); i < world.TileCount; i++ {
-		x := i % world.Width
-		y := i / world.Width
+// worldParser holds the state shared by the tile loop and resync probes
+type worldParser struct {
+	r      *packetReader
+	width  uint32
+	count  uint32
+	items  *database.ItemDatabase
+	report *WorldParseReport // nil while probing
 
-		tile := Tile{
-			X: x,
-			Y: y,
-		}
+	probeBudget int
+}
+
+// tile reads tile i. For an unknown extra type it returns errUnknownExtra with
+// the reader right after the type byte.
+func (p *worldParser) tile(i uint32) (Tile, error) {
+	r := p.r
+	tile := Tile{X: i % p.width, Y: i / p.width}
+	r.read(&tile.ForegroundItemID)
+	r.read(&tile.BackgroundItemID)
+	r.read(&tile.ParentBlockIndex)
+	r.read(&tile.Flags)
+	tile.TileFlags = tile.Flags
+
+	if tile.Flags&TileFlagHasParent != 0 {
+		r.skip(2)
+	}
 
-		// Read tile data
-		if err := binary.Read(reader, binary.LittleEndian, &tile.ForegroundItemID); err != nil {
-			return fmt.Errorf("failed to read fg item id at tile %d: %w", i, err)
+	ctx := tileContext{items: p.items}
+	if tile.Flags&TileFlagHasExtraData != 0 {
+		tile.TileType = r.u8()
+		if r.err != nil {
+			return tile, fmt.Errorf("tile %d: %w", i, r.err)
 		}
-		if err := binary.Read(reader, binary.LittleEndian, &tile.BackgroundItemID); err != nil {
-			return fmt.Errorf("failed to read bg item id at tile %d: %w", i, err)
+		t, ok := tileExtraTypes[tile.TileType]
+		if !ok {
+			return tile, errUnknownExtra
 		}
-		if err := binary.Read(reader, binary.LittleEndian, &tile.ParentBlockIndex); err != nil {
-			return fmt.Errorf("failed to read parent index at tile %d: %w", i, err)
+		tile.Extra = t.parse(r, &tile, &ctx)
+	}
+
+	hasCBOR := cborFollows(r)
+	if hasCBOR {
+		var err error
+		tile.CBOR, err = readCBOR(r)
+		if ctx.cborErr == nil {
+			ctx.cborErr = err
+		}
+	}
+	if r.err != nil {
+		return tile, fmt.Errorf("tile %d (extra %s): %w", i, TileExtraTypeName(tile.TileType), r.err)
+	}
+
+	if p.report != nil {
+		if hasCBOR {
+			p.report.CBORTiles++
 		}
-		if err := binary.Read(reader, binary.LittleEndian, &tile.Flags); err != nil {
-			return fmt.Errorf("failed to read flags at tile %d: %w", i, err)
+		if ctx.cborErr != nil {
+			p.report.CBORErrors++
+			p.report.issue(i, &tile, "%v", ctx.cborErr)
 		}
-		tile.TileFlags = tile.Flags
+	}
+	return tile, nil
+}
 
-		// Check for parent data
-		if tile.Flags&0x02 != 0 { // HAS_PARENT
-			var parentData uint16
-			binary.Read(reader, binary.LittleEndian, &parentData)
+// plausible rejects tiles that can only come from reading at the wrong offset
+func (p *worldParser) plausible(tile *Tile) bool {
+	// Only tiles inside a lock's area point at a parent
+	if tile.ParentBlockIndex != 0 && (tile.Flags&TileFlagHasParent == 0 || uint32(tile.ParentBlockIndex) >= p.count) {
+		return false
+	}
+	if p.items == nil || !p.items.Loaded {
+		return tile.ForegroundItemID < 0x8000 && tile.BackgroundItemID < 0x8000
+	}
+	for _, id := range []uint16{tile.ForegroundItemID, tile.BackgroundItemID} {
+		if id != 0 && p.items.GetItem(uint32(id)) == nil {
+			return false
 		}
+	}
+	return true
+}
 
-		// Check for extra data
-		if tile.Flags&0x01 != 0 { // HAS_EXTRA_DATA
-			var extraType uint8
-			if err := binary.Read(reader, binary.LittleEndian, &extraType); err != nil {
-				return fmt.Errorf("failed to read extra type at tile %d: %w", i, err)
+// resync finds where tile next starts after extra data nobody can parse. The
+// earliest offset that parses plausibly either through the remaining tiles and
+// the trailer, or up to another tile of an unknown type seen before, is taken.
+// Being off by whole tiles also parses, so when the rest of the world parses,
+// the offset within a few tiles leaving the fewest trailing bytes wins: that is
+// the one that found the dropped item list where it really is.
+func (p *worldParser) resync(next uint32, seen map[uint8]int) (int, bool) {
+	start := p.r.pos
+	best, bestTrailing, limit := -1, -1, start+maxResyncScan
+	for off := start; off <= len(p.r.data) && off <= limit && p.probeBudget > 0; off++ {
+		stop, trailing := p.probe(off, next)
+		switch {
+		case trailing >= 0:
+			if best < 0 {
+				limit = off + 8*resyncTileWindow
 			}
-
-			if err := b.parseExtraTileData(reader, &tile, extraType); err != nil {
-				return fmt.Errorf("failed to parse extra data at tile %d: %w", i, err)
+			if bestTrailing < 0 || trailing < bestTrailing {
+				best, bestTrailing = off, trailing
 			}
+		case best < 0 && stop >= 0 && seen[uint8(stop)] > 0:
+			return off, true
 		}
+	}
+	return best, best >= 0
+}
 
-		// Check for CBOR data (some special tiles)
-		if b.ItemDatabase != nil {
-			if item := b.ItemDatabase.GetItem(uint32(tile.ForegroundItemID)); item != nil {
-				// Tiles with CBOR data
-				specialTiles := []uint32{15376, 8642, 15546}
-				isCBOR := false
-				for _, id := range specialTiles {
-					if uint32(tile.ForegroundItemID) == id {
-						isCBOR = true
-						break
-					}
-				}
-
-				if isCBOR || len(item.FileName) > 4 && item.FileName[len(item.FileName)-4:] == ".xml" {
-					var cborSize uint32
-					if err := binary.Read(reader, binary.LittleEndian, &cborSize); err == nil {
-						// Skip CBOR data for now
-						reader.Seek(int64(cborSize), io.SeekCurrent)
-					}
-				}
-			}
+// probe parses tiles from off. trailing is the number of bytes left after the
+// trailer if everything parsed, -1 otherwise. stop is the unknown extra type of
+// a plausible tile that ended the probe, -1 if it failed any other way.
+func (p *worldParser) probe(off int, next uint32) (stop, trailing int) {
+	q := &worldParser{r: &packetReader{data: p.r.data, pos: off}, width: p.width, count: p.count, items: p.items}
+	for i := next; i < p.count; i++ {
+		if p.probeBudget--; p.probeBudget < 0 {
+			return -1, -1
+		}
+		tile, err := q.tile(i)
+		if errors.Is(err, errUnknownExtra) && q.plausible(&tile) {
+			return int(tile.TileType), -1
 		}
+		if err != nil || !q.plausible(&tile) {
+			return -1, -1
+		}
+	}
+	var scratch World
+	if q.trailer(&scratch) != nil {
+		return -1, -1
+	}
+	return -1, q.r.Remaining()
+}
 
-		world.Tiles = append(world.Tiles, tile)
+// parseWorld decodes world data without touching any bot state. Malformed input
+// returns an error; nothing is allocated beyond what data can hold.
+func parseWorld(data []byte, itemDB *database.ItemDatabase) (*World, error) {
+	r := newPacketReader(data)
+	world := &World{Name: "EXIT"}
+
+	world.Version = r.u16()
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to read version: %w", r.err)
+	}
+	if world.Version < 0x19 {
+		return nil, fmt.Errorf("unsupported world version: %d", world.Version)
 	}
 
-	// Skip 12 bytes
-	reader.Seek(12, io.SeekCurrent)
+	world.Flags = r.u32()
+	name := r.str()
+	world.Width = r.u32()
+	world.Height = r.u32()
+	world.TileCount = r.u32()
+	r.skip(5) // debug flag
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to read world header: %w", r.err)
+	}
+	world.Name = name
 
-	// Parse dropped items
-	var droppedCount uint32
-	var lastDroppedUID uint32
-	if err := binary.Read(reader, binary.LittleEndian, &droppedCount); err != nil {
-		return fmt.Errorf("failed to read dropped count: %w", err)
+	if world.TileCount > 0xFE01 {
+		return nil, fmt.Errorf("tile count too large: %d", world.TileCount)
+	}
+	if uint64(world.Width)*uint64(world.Height) > 0xFE01 || (world.Width == 0 && world.TileCount > 0) {
+		return nil, fmt.Errorf("invalid world size: %dx%d", world.Width, world.Height)
 	}
-	if err := binary.Read(reader, binary.LittleEndian, &lastDroppedUID); err != nil {
-		return fmt.Errorf("failed to read last dropped uid: %w", err)
+	// Every tile takes at least 8 bytes
+	if uint64(world.TileCount)*8 > uint64(r.Remaining()) {
+		return nil, fmt.Errorf("%d tiles do not fit in %d bytes", world.TileCount, r.Remaining())
 	}
 
-	world.DroppedItems = make([]DroppedItem, 0, droppedCount)
-	for i := uint32(0); i < droppedCount; i++ {
-		var item DroppedItem
-		if err := binary.Read(reader, binary.LittleEndian, &item.ID); err != nil {
-			return fmt.Errorf("failed to read dropped item id: %w", err)
-		}
-		if err := binary.Read(reader, binary.LittleEndian, &item.X); err != nil {
-			return fmt.Errorf("failed to read dropped item x: %w", err)
-		}
-		if err := binary.Read(reader, binary.LittleEndian, &item.Y); err != nil {
-			return fmt.Errorf("failed to read dropped item y: %w", err)
-		}
-		if err := binary.Read(reader, binary.LittleEndian, &item.Count); err != nil {
-			return fmt.Errorf("failed to read dropped item count: %w", err)
-		}
-		if err := binary.Read(reader, binary.LittleEndian, &item.Flags); err != nil {
-			return fmt.Errorf("failed to read dropped item flags: %w", err)
+	report := &WorldParseReport{}
+	p := &worldParser{r: r, width: world.Width, count: world.TileCount, items: itemDB, report: report,
+		probeBudget: resyncProbeBudget}
+
+	// Parse tiles
+	world.Tiles = make([]Tile, 0, world.TileCount)
+	for i := uint32(0); i < world.TileCount; i++ {
+		tile, err := p.tile(i)
+		if errors.Is(err, errUnknownExtra) {
+			if report.UnknownTypes == nil {
+				report.UnknownTypes = make(map[uint8]int)
+			}
+			report.UnknownTypes[tile.TileType]++
+
+			start := r.pos
+			off, ok := p.resync(i+1, report.UnknownTypes)
+			if !ok {
+				return nil, fmt.Errorf("unknown tile extra type %d at tile %d (fg_item=%d), could not resync",
+					tile.TileType, i, tile.ForegroundItemID)
+			}
+			r.pos = off
+			tile.Extra = TileUnknown{Type: tile.TileType, Raw: append([]byte(nil), data[start:off]...)}
+			report.RecoveredTiles++
+			report.issue(i, &tile, "unknown extra type %d, skipped %d bytes", tile.TileType, off-start)
+			err = nil
 		}
-		if err := binary.Read(reader, binary.LittleEndian, &item.UID); err != nil {
-			return fmt.Errorf("failed to read dropped item uid: %w", err)
+		if err != nil {
+			return nil, err
 		}
+		world.Tiles = append(world.Tiles, tile)
+	}
+
+	if err := p.trailer(world); err != nil {
+		return nil, err
+	}
+	world.ParseReport = report
+	return world, nil
+}
+
+// trailer reads what follows the tiles: dropped items and weather
+func (p *worldParser) trailer(world *World) error {
+	r := p.r
+	r.skip(12)
+	droppedCount := r.u32()
+	r.u32() // last dropped uid
+	if r.err != nil {
+		return fmt.Errorf("failed to read dropped items header: %w", r.err)
+	}
+
+	// id u16, x f32, y f32, count u8, flags u8, uid u32
+	n := r.count(droppedCount, 16)
+	world.DroppedItems = make([]DroppedItem, 0, n)
+	for i := 0; i < n; i++ {
+		var item DroppedItem
+		r.read(&item.ID)
+		r.read(&item.X)
+		r.read(&item.Y)
+		r.read(&item.Count)
+		r.read(&item.Flags)
+		r.read(&item.UID)
 		world.DroppedItems = append(world.DroppedItems, item)
 	}
+	if r.err != nil {
+		return fmt.Errorf("failed to read dropped items: %w", r.err)
+	}
 
-	// Parse weather
-	if err := binary.Read(reader, binary.LittleEndian, &world.BaseWeather); err != nil {
-		return fmt.Errorf("failed to read base weather: %w", err)
+	r.read(&world.BaseWeather)
+	r.u16() // unknown
+	r.read(&world.CurrentWeather)
+	if r.err != nil {
+		return fmt.Errorf("failed to read weather: %w", r.err)
 	}
-	var unknownWeather uint16
-	binary.Read(reader, binary.LittleEndian, &unknownWeather)
-	if err := binary.Read(reader, binary.LittleEndian, &world.CurrentWeather); err != nil {
-		return fmt.Errorf("failed to read current weather: %w", err)
+	return nil
+}
+
+// ParseWorld parses world data from NET_GAME_PACKET_SEND_MAP_DATA
+func (b *Bot) ParseWorld(data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	world := &b.Local.World
+	itemDB := b.itemDB()
+
+	parsed, err := parseWorld(data, itemDB)
+	if err != nil {
+		// Reset world
+		*world = World{Name: "EXIT"}
+		return err
 	}
+	*world = *parsed
+	report := world.ParseReport
 
 	// Save raw world data (matching Rust: fs::write("world.dat", world_data))
 	if err := os.WriteFile("world.dat", data, 0644); err != nil {
@@ -213,8 +368,8 @@ func (b *Bot) ParseWorld(data []byte) error {
 				if idx < len(world.Tiles) {
 					tile := &world.Tiles[idx]
 					var itemID uint32 = uint32(tile.ForegroundItemID)
-					if b.ItemDatabase != nil {
-						if item := b.ItemDatabase.GetItem(itemID); item != nil {
+					if itemDB != nil {
+						if item := itemDB.GetItem(itemID); item != nil {
 							collisionType = item.CollisionType
 						}
 					}
@@ -228,6 +383,9 @@ func (b *Bot) ParseWorld(data []byte) error {
 	}
 
 	b.logENet(fmt.Sprintf("World loaded: %s (%dx%d, %d tiles)", world.Name, world.Width, world.Height, world.TileCount))
+	if !report.Clean() {
+		b.logENet(fmt.Sprintf("World %s parse report: %s", world.Name, report))
+	}
 
 	// Dump parsed map to text file
 	b.dumpMapToTxt(world)
@@ -293,683 +451,3 @@ func (b *Bot) DumpMapToFile() {
 	}
 	b.logENet("Map dumped to map.txt successfully")
 }
-
-func (b *Bot) parseExtraTileData(reader *bytes.Reader, tile *Tile, extraType uint8) error {
-	tile.TileType = extraType
-
-	switch extraType {
-	case 1: // Sign
-		var textLen uint16
-		if err := binary.Read(reader, binary.LittleEndian, &textLen); err != nil {
-			return err
-		}
-		textBytes := make([]byte, textLen)
-		io.ReadFull(reader, textBytes)
-		var flags uint8
-		binary.Read(reader, binary.LittleEndian, &flags)
-
-		tile.Extra = TileSign{
-			Text:  string(textBytes),
-			Flags: flags,
-		}
-
-	case 2: // Door
-		var textLen uint16
-		if err := binary.Read(reader, binary.LittleEndian, &textLen); err != nil {
-			return err
-		}
-		textBytes := make([]byte, textLen)
-		io.ReadFull(reader, textBytes)
-		var ownerUID uint32
-		binary.Read(reader, binary.LittleEndian, &ownerUID)
-
-		tile.Extra = TileDoor{
-			Text:     string(textBytes),
-			OwnerUID: ownerUID,
-		}
-
-	case 3: // Lock
-		data := TileLock{}
-		binary.Read(reader, binary.LittleEndian, &data.Settings)
-		binary.Read(reader, binary.LittleEndian, &data.OwnerUID)
-		binary.Read(reader, binary.LittleEndian, &data.AccessCount)
-		data.AccessUIDs = make([]uint32, data.AccessCount)
-		for i := uint32(0); i < data.AccessCount; i++ {
-			binary.Read(reader, binary.LittleEndian, &data.AccessUIDs[i])
-		}
-		binary.Read(reader, binary.LittleEndian, &data.MinimumLevel)
-		reader.Seek(7, io.SeekCurrent) // unknown data
-		if tile.ForegroundItemID == 5814 {
-			reader.Seek(16, io.SeekCurrent)
-		}
-		tile.Extra = data
-
-	case 4: // Seed
-		data := TileSeed{}
-		binary.Read(reader, binary.LittleEndian, &data.TimePassed)
-		binary.Read(reader, binary.LittleEndian, &data.ItemOnTree)
-
-		// Check if ready to harvest
-		if b.ItemDatabase != nil {
-			if item := b.ItemDatabase.GetItem(uint32(tile.ForegroundItemID)); item != nil {
-				data.ReadyToHarvest = data.TimePassed >= item.GrowTime
-			}
-		}
-
-		tile.Extra = data
-
-	case 6: // Mailbox
-		data := TileMailbox{}
-		var strLen uint16
-
-		binary.Read(reader, binary.LittleEndian, &strLen)
-		buf := make([]byte, strLen)
-		io.ReadFull(reader, buf)
-		data.Message1 = string(buf)
-
-		binary.Read(reader, binary.LittleEndian, &strLen)
-		buf = make([]byte, strLen)
-		io.ReadFull(reader, buf)
-		data.Message2 = string(buf)
-
-		binary.Read(reader, binary.LittleEndian, &strLen)
-		buf = make([]byte, strLen)
-		io.ReadFull(reader, buf)
-		data.Message3 = string(buf)
-
-		binary.Read(reader, binary.LittleEndian, &data.Unknown)
-		tile.Extra = data
-
-	case 7: // Bulletin
-		data := TileBulletin{}
-		var strLen uint16
-
-		binary.Read(reader, binary.LittleEndian, &strLen)
-		buf := make([]byte, strLen)
-		io.ReadFull(reader, buf)
-		data.Message1 = string(buf)
-
-		binary.Read(reader, binary.LittleEndian, &strLen)
-		buf = make([]byte, strLen)
-		io.ReadFull(reader, buf)
-		data.Message2 = string(buf)
-
-		binary.Read(reader, binary.LittleEndian, &strLen)
-		buf = make([]byte, strLen)
-		io.ReadFull(reader, buf)
-		data.Message3 = string(buf)
-
-		binary.Read(reader, binary.LittleEndian, &data.Unknown)
-		tile.Extra = data
-
-	case 8: // Dice
-		data := TileDice{}
-		binary.Read(reader, binary.LittleEndian, &data.Symbol)
-		tile.Extra = data
-
-	case 9: // ChemicalSource
-		data := TileChemicalSource{}
-		binary.Read(reader, binary.LittleEndian, &data.TimePassed)
-		tile.Extra = data
-
-	case 10: // AchievementBlock
-		data := TileAchievementBlock{}
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		binary.Read(reader, binary.LittleEndian, &data.TileType)
-		tile.Extra = data
-
-	case 11: // HearthMonitor
-		data := TileHearthMonitor{}
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		var strLen uint16
-		binary.Read(reader, binary.LittleEndian, &strLen)
-		buf := make([]byte, strLen)
-		io.ReadFull(reader, buf)
-		data.PlayerName = string(buf)
-		tile.Extra = data
-
-	case 12: // DonationBox
-		data := TileDonationBox{}
-		var strLen uint16
-
-		binary.Read(reader, binary.LittleEndian, &strLen)
-		buf := make([]byte, strLen)
-		io.ReadFull(reader, buf)
-		data.Message1 = string(buf)
-
-		binary.Read(reader, binary.LittleEndian, &strLen)
-		buf = make([]byte, strLen)
-		io.ReadFull(reader, buf)
-		data.Message2 = string(buf)
-
-		binary.Read(reader, binary.LittleEndian, &strLen)
-		buf = make([]byte, strLen)
-		io.ReadFull(reader, buf)
-		data.Message3 = string(buf)
-
-		binary.Read(reader, binary.LittleEndian, &data.Unknown)
-		tile.Extra = data
-
-	case 14: // Mannequin
-		data := TileMannequin{}
-		var strLen uint16
-		binary.Read(reader, binary.LittleEndian, &strLen)
-		buf := make([]byte, strLen)
-		io.ReadFull(reader, buf)
-		data.Text = string(buf)
-
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing1)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing2)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing3)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing4)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing5)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing6)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing7)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing8)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing9)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing10)
-		tile.Extra = data
-
-	case 15: // BunnyEgg
-		data := TileBunnyEgg{}
-		binary.Read(reader, binary.LittleEndian, &data.EggPlaced)
-		tile.Extra = data
-
-	case 16: // GamePack
-		data := TileGamePack{}
-		binary.Read(reader, binary.LittleEndian, &data.Team)
-		tile.Extra = data
-
-	case 17: // GameGenerator
-		tile.Extra = TileGameGenerator{}
-
-	case 18: // XenoniteCrystal
-		data := TileXenoniteCrystal{}
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown2)
-		tile.Extra = data
-
-	case 19: // PhoneBooth
-		data := TilePhoneBooth{}
-		binary.Read(reader, binary.LittleEndian, &data.Clothing1)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing2)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing3)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing4)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing5)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing6)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing7)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing8)
-		binary.Read(reader, binary.LittleEndian, &data.Clothing9)
-		tile.Extra = data
-
-	case 20: // Crystal
-		data := TileCrystal{}
-		var strLen uint16
-		binary.Read(reader, binary.LittleEndian, &strLen)
-		buf := make([]byte, strLen)
-		io.ReadFull(reader, buf)
-		data.Message = string(buf)
-		tile.Extra = data
-
-	case 21: // CrimeInProgress
-		data := TileCrimeInProgress{}
-		var strLen uint16
-		binary.Read(reader, binary.LittleEndian, &strLen)
-		buf := make([]byte, strLen)
-		io.ReadFull(reader, buf)
-		data.Message = string(buf)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown2)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown3)
-		tile.Extra = data
-
-	case 23: // DisplayBlock
-		data := TileDisplayBlock{}
-		binary.Read(reader, binary.LittleEndian, &data.ItemID)
-		tile.Extra = data
-
-	case 24: // VendingMachine
-		data := TileVendingMachine{}
-		binary.Read(reader, binary.LittleEndian, &data.ItemID)
-		binary.Read(reader, binary.LittleEndian, &data.Price)
-		tile.Extra = data
-
-	case 25: // FishTankPort
-		data := TileFishTankPort{}
-		binary.Read(reader, binary.LittleEndian, &data.Flags)
-		var fishCount uint32
-		binary.Read(reader, binary.LittleEndian, &fishCount)
-		// fishCount is number of fields, each fish has 2 fields (id, lbs) -> fishCount/2 ?
-		// Rust: for _ in 0..(fish_count / 2)
-		for i := uint32(0); i < fishCount/2; i++ {
-			var f FishInfo
-			binary.Read(reader, binary.LittleEndian, &f.FishItemID)
-			binary.Read(reader, binary.LittleEndian, &f.Lbs)
-			data.Fishes = append(data.Fishes, f)
-		}
-		tile.Extra = data
-
-	case 26: // SolarCollector
-		data := TileSolarCollector{Unknown1: make([]byte, 5)}
-		io.ReadFull(reader, data.Unknown1)
-		tile.Extra = data
-
-	case 27: // Forge
-		data := TileForge{}
-		binary.Read(reader, binary.LittleEndian, &data.Temperature)
-		tile.Extra = data
-
-	case 28: // GivingTree
-		data := TileGivingTree{}
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown2)
-		tile.Extra = data
-
-	case 30: // SteamOrgan
-		data := TileSteamOrgan{}
-		binary.Read(reader, binary.LittleEndian, &data.InstrumentType)
-		binary.Read(reader, binary.LittleEndian, &data.Note)
-		tile.Extra = data
-
-	case 31: // SilkWorm
-		data := TileSilkWorm{}
-		binary.Read(reader, binary.LittleEndian, &data.Type)
-		var nameLen uint16
-		binary.Read(reader, binary.LittleEndian, &nameLen)
-		buf := make([]byte, nameLen)
-		io.ReadFull(reader, buf)
-		data.Name = string(buf)
-		binary.Read(reader, binary.LittleEndian, &data.Age)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown2)
-		binary.Read(reader, binary.LittleEndian, &data.CanBeFed)
-		binary.Read(reader, binary.LittleEndian, &data.FoodSaturation)
-		binary.Read(reader, binary.LittleEndian, &data.WaterSaturation)
-
-		var color uint32
-		binary.Read(reader, binary.LittleEndian, &color)
-		data.Color = SilkWormColor{
-			A: uint8(color >> 24),
-			R: uint8(color >> 16),
-			G: uint8(color >> 8),
-			B: uint8(color),
-		}
-		binary.Read(reader, binary.LittleEndian, &data.SickDuration)
-		tile.Extra = data
-
-	case 32: // SewingMachine
-		data := TileSewingMachine{}
-		var boltLen uint16
-		binary.Read(reader, binary.LittleEndian, &boltLen)
-		data.BoltIDList = make([]uint32, boltLen)
-		for i := 0; i < int(boltLen); i++ {
-			binary.Read(reader, binary.LittleEndian, &data.BoltIDList[i])
-		}
-		tile.Extra = data
-
-	case 33: // CountryFlag
-		data := TileCountryFlag{}
-		var countryLen uint16
-		binary.Read(reader, binary.LittleEndian, &countryLen)
-		buf := make([]byte, countryLen)
-		io.ReadFull(reader, buf)
-		data.Country = string(buf)
-		tile.Extra = data
-
-	case 34: // LobsterTrap
-		tile.Extra = TileLobsterTrap{}
-
-	case 35: // PaintingEasel
-		data := TilePaintingEasel{}
-		binary.Read(reader, binary.LittleEndian, &data.ItemID)
-		var labelLen uint16
-		binary.Read(reader, binary.LittleEndian, &labelLen)
-		buf := make([]byte, labelLen)
-		io.ReadFull(reader, buf)
-		data.Label = string(buf)
-		tile.Extra = data
-
-	case 36: // PetBattleCage
-		data := TilePetBattleCage{}
-		var labelLen uint16
-		binary.Read(reader, binary.LittleEndian, &labelLen)
-		buf := make([]byte, labelLen)
-		io.ReadFull(reader, buf)
-		data.Label = string(buf)
-
-		data.Unknown1 = make([]byte, 12)
-		io.ReadFull(reader, data.Unknown1)
-
-		var cborSize uint32
-		binary.Read(reader, binary.LittleEndian, &cborSize)
-		// CBOR parsing skipped for now, but bytes consumed
-		reader.Seek(int64(cborSize), io.SeekCurrent)
-		tile.Extra = data
-
-	case 37: // PetTrainer
-		data := TilePetTrainer{}
-		var nameLen uint16
-		binary.Read(reader, binary.LittleEndian, &nameLen)
-		buf := make([]byte, nameLen)
-		io.ReadFull(reader, buf)
-		data.Name = string(buf)
-
-		binary.Read(reader, binary.LittleEndian, &data.PetTotalCount)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		data.PetsID = make([]uint32, data.PetTotalCount)
-		for i := uint32(0); i < data.PetTotalCount; i++ {
-			binary.Read(reader, binary.LittleEndian, &data.PetsID[i])
-		}
-		tile.Extra = data
-
-	case 38: // SteamEngine
-		data := TileSteamEngine{}
-		binary.Read(reader, binary.LittleEndian, &data.Temperature)
-		tile.Extra = data
-
-	case 39: // LockBot
-		data := TileLockBot{}
-		binary.Read(reader, binary.LittleEndian, &data.TimePassed)
-		tile.Extra = data
-
-	case 40: // WeatherMachine
-		data := TileWeatherMachine{}
-		binary.Read(reader, binary.LittleEndian, &data.Settings)
-		tile.Extra = data
-
-	case 41: // SpiritStorageUnit
-		data := TileSpiritStorageUnit{}
-		binary.Read(reader, binary.LittleEndian, &data.GhostJarCount)
-		tile.Extra = data
-
-	case 42: // DataBedrock
-		reader.Seek(21, io.SeekCurrent)
-		tile.Extra = TileDataBedrock{}
-
-	case 43: // Shelf
-		data := TileShelf{}
-		binary.Read(reader, binary.LittleEndian, &data.TopLeftItemID)
-		binary.Read(reader, binary.LittleEndian, &data.TopRightItemID)
-		binary.Read(reader, binary.LittleEndian, &data.BottomLeftItemID)
-		binary.Read(reader, binary.LittleEndian, &data.BottomRightItemID)
-		tile.Extra = data
-
-	case 44: // VipEntrance
-		data := TileVipEntrance{}
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		binary.Read(reader, binary.LittleEndian, &data.OwnerUID)
-		var accessCount uint32
-		binary.Read(reader, binary.LittleEndian, &accessCount)
-		data.AccessUIDs = make([]uint32, accessCount)
-		for i := uint32(0); i < accessCount; i++ {
-			binary.Read(reader, binary.LittleEndian, &data.AccessUIDs[i])
-		}
-		tile.Extra = data
-
-	case 45: // ChallengeTimer
-		tile.Extra = TileChallengeTimer{}
-
-	case 47: // FishWallMount
-		data := TileFishWallMount{}
-		var labelLen uint16
-		binary.Read(reader, binary.LittleEndian, &labelLen)
-		buf := make([]byte, labelLen)
-		io.ReadFull(reader, buf)
-		data.Label = string(buf)
-		binary.Read(reader, binary.LittleEndian, &data.ItemID)
-		binary.Read(reader, binary.LittleEndian, &data.Lb)
-		tile.Extra = data
-
-	case 48: // Portrait
-		data := TilePortrait{}
-		var labelLen uint16
-		binary.Read(reader, binary.LittleEndian, &labelLen)
-		buf := make([]byte, labelLen)
-		io.ReadFull(reader, buf)
-		data.Label = string(buf)
-
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown2)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown3)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown4)
-		binary.Read(reader, binary.LittleEndian, &data.Face)
-		binary.Read(reader, binary.LittleEndian, &data.Hat)
-		binary.Read(reader, binary.LittleEndian, &data.Hair)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown5)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown6)
-		tile.Extra = data
-
-	case 49: // GuildWeatherMachine
-		data := TileGuildWeatherMachine{}
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		binary.Read(reader, binary.LittleEndian, &data.Gravity)
-		binary.Read(reader, binary.LittleEndian, &data.Flags)
-		tile.Extra = data
-
-	case 50: // FossilPrepStation
-		data := TileFossilPrepStation{}
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		tile.Extra = data
-
-	case 51, 52: // DnaExtractor, Howler
-		// No extra data
-
-	case 53: // ChemsynthTank
-		data := TileChemsynthTank{}
-		binary.Read(reader, binary.LittleEndian, &data.CurrentChem)
-		binary.Read(reader, binary.LittleEndian, &data.TargetChem)
-		tile.Extra = data
-
-	case 54: // StorageBlock
-		data := TileStorageBlock{}
-		var dataLen uint16
-		binary.Read(reader, binary.LittleEndian, &dataLen)
-		// dataLen / 13 items ?
-		// Rust: for _ in 0..(data_len / 13)
-		count := int(dataLen) / 13
-		for i := 0; i < count; i++ {
-			// Rust: skip 3, read u32, skip 2, read u32
-			// The wrapper code says:
-			// data.set_position(data.position() + 3);
-			// let id = data.read_u32::<LittleEndian>().unwrap();
-			// data.set_position(data.position() + 2);
-			// let amount = data.read_u32::<LittleEndian>().unwrap();
-			reader.Seek(3, io.SeekCurrent)
-			var id uint32
-			binary.Read(reader, binary.LittleEndian, &id)
-			reader.Seek(2, io.SeekCurrent)
-			var amount uint32
-			binary.Read(reader, binary.LittleEndian, &amount)
-			data.Items = append(data.Items, StorageBlockItemInfo{ID: id, Amount: amount})
-		}
-		tile.Extra = data
-
-	case 55: // CookingOven
-		data := TileCookingOven{}
-		reader.Seek(4, io.SeekCurrent) // temperature_level (rust says read it, wait)
-		// Rust: let temperature_level = data.read_u32::<LittleEndian>().unwrap();
-		// I must rewind/not skip if I want to read.
-		// My logic below handles it correctly by NOT skipping.
-
-		binary.Read(reader, binary.LittleEndian, &data.TemperatureLevel)
-		var ingredientCount uint32
-		binary.Read(reader, binary.LittleEndian, &ingredientCount)
-		for i := uint32(0); i < ingredientCount; i++ {
-			var ing CookingOvenIngredientInfo
-			binary.Read(reader, binary.LittleEndian, &ing.ItemID)
-			binary.Read(reader, binary.LittleEndian, &ing.TimeAdded)
-			data.Ingredients = append(data.Ingredients, ing)
-		}
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown2)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown3)
-		tile.Extra = data
-
-	case 56: // AudioRack
-		data := TileAudioRack{}
-		var noteLen uint16
-		binary.Read(reader, binary.LittleEndian, &noteLen)
-		buf := make([]byte, noteLen)
-		io.ReadFull(reader, buf)
-		data.Note = string(buf)
-		binary.Read(reader, binary.LittleEndian, &data.Volume)
-		tile.Extra = data
-
-	case 57: // GeigerCharger
-		data := TileGeigerCharger{}
-		var raw uint32
-		binary.Read(reader, binary.LittleEndian, &raw)
-
-		data.SecondsFromStart = raw
-		if data.SecondsFromStart > 3600 {
-			data.SecondsFromStart = 3600
-		}
-		data.SecondsToComplete = 3600 - data.SecondsFromStart
-		data.ChargingPercent = data.SecondsFromStart / 36
-		data.MinutesFromStart = data.SecondsFromStart / 60
-		data.MinutesToComplete = 60
-		if data.MinutesFromStart < 60 {
-			data.MinutesToComplete = 60 - data.MinutesFromStart
-		} else {
-			data.MinutesToComplete = 0
-		}
-		tile.Extra = data
-
-	case 58: // AdventureBegins
-		tile.Extra = TileAdventureBegins{}
-	case 59: // TombRobber
-		tile.Extra = TileTombRobber{}
-
-	case 60: // BalloonOMatic
-		data := TileBalloonOMatic{}
-		binary.Read(reader, binary.LittleEndian, &data.TotalRarity)
-		binary.Read(reader, binary.LittleEndian, &data.TeamType)
-		tile.Extra = data
-
-	case 61: // TrainingPort
-		data := TileTrainingPort{}
-		binary.Read(reader, binary.LittleEndian, &data.FishLb)
-		binary.Read(reader, binary.LittleEndian, &data.FishStatus)
-		binary.Read(reader, binary.LittleEndian, &data.FishID)
-		binary.Read(reader, binary.LittleEndian, &data.FishTotalExp)
-		binary.Read(reader, binary.LittleEndian, &data.FishLevel)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown2)
-		tile.Extra = data
-
-	case 62: // ItemSucker
-		data := TileItemSucker{}
-		binary.Read(reader, binary.LittleEndian, &data.ItemIDToSuck)
-		binary.Read(reader, binary.LittleEndian, &data.ItemAmount)
-		binary.Read(reader, binary.LittleEndian, &data.Flags)
-		binary.Read(reader, binary.LittleEndian, &data.Limit)
-		tile.Extra = data
-
-	case 63: // CyBot
-		data := TileCyBot{}
-		binary.Read(reader, binary.LittleEndian, &data.SyncTimer)
-		binary.Read(reader, binary.LittleEndian, &data.Activated)
-		var commandCount uint32
-		binary.Read(reader, binary.LittleEndian, &commandCount)
-		for i := uint32(0); i < commandCount; i++ {
-			var cmd CyBotCommandData
-			binary.Read(reader, binary.LittleEndian, &cmd.CommandID)
-			binary.Read(reader, binary.LittleEndian, &cmd.IsCommandUsed)
-			reader.Seek(7, io.SeekCurrent)
-			data.CommandDatas = append(data.CommandDatas, cmd)
-		}
-		tile.Extra = data
-
-	case 65: // GuildItem
-		reader.Seek(17, io.SeekCurrent)
-		tile.Extra = TileGuildItem{}
-
-	case 66: // Growscan
-		data := TileGrowscan{}
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		tile.Extra = data
-
-	case 67: // ContainmentFieldPowerNode
-		data := TileContainmentFieldPowerNode{}
-		binary.Read(reader, binary.LittleEndian, &data.GhostJarCount)
-		var unknown1Size uint32
-		binary.Read(reader, binary.LittleEndian, &unknown1Size)
-		data.Unknown1 = make([]uint32, unknown1Size)
-		for i := uint32(0); i < unknown1Size; i++ {
-			binary.Read(reader, binary.LittleEndian, &data.Unknown1[i])
-		}
-		tile.Extra = data
-
-	case 68: // SpiritBoard
-		data := TileSpiritBoard{}
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown2)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown3)
-		tile.Extra = data
-
-	case 72: // StormyCloud
-		data := TileStormyCloud{}
-		binary.Read(reader, binary.LittleEndian, &data.StingDuration)
-		binary.Read(reader, binary.LittleEndian, &data.IsSolid)
-		binary.Read(reader, binary.LittleEndian, &data.NonSolidDuration)
-		tile.Extra = data
-
-	case 73: // TemporaryPlatform
-		data := TileTemporaryPlatform{}
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		tile.Extra = data
-
-	case 74: // SafeVault
-		tile.Extra = TileSafeVault{}
-
-	case 75: // AngelicCountingCloud
-		data := TileAngelicCountingCloud{}
-		binary.Read(reader, binary.LittleEndian, &data.IsRaffling)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		binary.Read(reader, binary.LittleEndian, &data.AsciiCode)
-		tile.Extra = data
-
-	case 77: // InfinityWeatherMachine
-		data := TileInfinityWeatherMachine{}
-		binary.Read(reader, binary.LittleEndian, &data.IntervalMinutes)
-		var listSize uint32
-		binary.Read(reader, binary.LittleEndian, &listSize)
-		data.WeatherMachineList = make([]uint32, listSize)
-		for i := uint32(0); i < listSize; i++ {
-			binary.Read(reader, binary.LittleEndian, &data.WeatherMachineList[i])
-		}
-		tile.Extra = data
-
-	case 79: // PineappleGuzzler
-		tile.Extra = TilePineappleGuzzler{}
-
-	case 80: // KrakenGalaticBlock
-		data := TileKrakenGalaticBlock{}
-		binary.Read(reader, binary.LittleEndian, &data.PatternIndex)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		binary.Read(reader, binary.LittleEndian, &data.R)
-		binary.Read(reader, binary.LittleEndian, &data.G)
-		binary.Read(reader, binary.LittleEndian, &data.B)
-		tile.Extra = data
-
-	case 81: // FriendsEntrance
-		data := TileFriendsEntrance{}
-		binary.Read(reader, binary.LittleEndian, &data.OwnerUserID)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown1)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown2)
-		tile.Extra = data
-
-	case 69: // TesseractManipulator
-		data := TileTesseractManipulator{}
-		binary.Read(reader, binary.LittleEndian, &data.Gems)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown2)
-		binary.Read(reader, binary.LittleEndian, &data.ItemID)
-		binary.Read(reader, binary.LittleEndian, &data.Unknown4)
-		tile.Extra = data
-
-	default:
-		// Unknown tile type, skip carefully
-		b.logENet(fmt.Sprintf("WARNING: Unknown tile extra type %d at fg_item=%d", extraType, tile.ForegroundItemID))
-	}
-
-	return nil
-}
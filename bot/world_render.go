@@ -0,0 +1,217 @@
+package bot
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"vortenixgo/database"
+)
+
+// Pixels per tile in the game; player and dropped item positions use this unit
+const worldTilePixels = 32
+
+// TileTextures supplies item sprites to the renderer (e.g. *assets.Store)
+type TileTextures interface {
+	ItemIconImage(id uint32) (image.Image, error)
+}
+
+// RenderOptions controls World.Render
+type RenderOptions struct {
+	TileSize int                    // Output pixels per tile, 8 if zero
+	Items    *database.ItemDatabase // Used for tile colors, optional
+	Textures TileTextures           // Sprites instead of flat colors, optional
+	Players  []Players              // Drawn as markers
+	Self     *Players               // The bot itself, highlighted
+}
+
+var (
+	renderSkyColor     = color.NRGBA{R: 0x60, G: 0xB8, B: 0xF0, A: 0xFF}
+	renderLockColor    = color.NRGBA{R: 0xFF, G: 0xD7, B: 0x00, A: 0xFF}
+	renderDroppedColor = color.NRGBA{R: 0xFF, G: 0x8C, B: 0x00, A: 0xFF}
+	renderPlayerColor  = color.NRGBA{R: 0xE0, G: 0x30, B: 0x30, A: 0xFF}
+	renderSelfColor    = color.NRGBA{R: 0x20, G: 0xE0, B: 0x40, A: 0xFF}
+	renderOutlineColor = color.NRGBA{A: 0xFF}
+)
+
+// Render draws the world: background and foreground tiles, then locks, dropped
+// items, players and the bot on top
+func (w *World) Render(opts RenderOptions) *image.NRGBA {
+	size := opts.TileSize
+	if size <= 0 {
+		size = 8
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, int(w.Width)*size, int(w.Height)*size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(renderSkyColor), image.Point{}, draw.Src)
+
+	r := &worldRenderer{img: img, size: size, opts: opts, sprites: make(map[uint16]image.Image)}
+	for i := range w.Tiles {
+		tile := &w.Tiles[i]
+		rect := r.tileRect(float32(tile.X*worldTilePixels), float32(tile.Y*worldTilePixels))
+		if tile.BackgroundItemID != 0 {
+			r.drawItem(rect, tile.BackgroundItemID, true)
+		}
+		if tile.ForegroundItemID != 0 {
+			r.drawItem(rect, tile.ForegroundItemID, false)
+		}
+	}
+
+	for i := range w.Tiles {
+		if _, ok := w.Tiles[i].Extra.(TileLock); ok {
+			tile := &w.Tiles[i]
+			r.outline(r.tileRect(float32(tile.X*worldTilePixels), float32(tile.Y*worldTilePixels)), renderLockColor)
+		}
+	}
+
+	for _, drop := range w.DroppedItems {
+		rect := r.tileRect(drop.X, drop.Y)
+		r.fill(shrink(rect, size/4), renderDroppedColor)
+	}
+
+	for _, p := range opts.Players {
+		if p.IsLocal {
+			continue
+		}
+		r.marker(p.PosX, p.PosY, renderPlayerColor)
+	}
+	if opts.Self != nil {
+		r.marker(opts.Self.PosX, opts.Self.PosY, renderSelfColor)
+	}
+	return img
+}
+
+// RenderWorld renders the bot's current world with every overlay
+func (b *Bot) RenderWorld(tileSize int, textures TileTextures) *image.NRGBA {
+	b.mu.Lock()
+	world := b.Local.World
+	world.Tiles = append([]Tile(nil), b.Local.World.Tiles...)
+	world.DroppedItems = append([]DroppedItem(nil), b.Local.World.DroppedItems...)
+	players := append([]Players(nil), b.Local.Players...)
+	self := Players{Name: b.Name, NetID: b.Local.NetID, PosX: b.Local.PosX, PosY: b.Local.PosY, IsLocal: true}
+	b.mu.Unlock()
+
+	return world.Render(RenderOptions{
+		TileSize: tileSize,
+		Items:    b.itemDB(),
+		Textures: textures,
+		Players:  players,
+		Self:     &self,
+	})
+}
+
+type worldRenderer struct {
+	img     *image.NRGBA
+	size    int
+	opts    RenderOptions
+	sprites map[uint16]image.Image // nil entry = no sprite, use the flat color
+}
+
+// tileRect maps a position in game pixels to the output tile rectangle
+func (r *worldRenderer) tileRect(x, y float32) image.Rectangle {
+	px := int(x) * r.size / worldTilePixels
+	py := int(y) * r.size / worldTilePixels
+	return image.Rect(px, py, px+r.size, py+r.size)
+}
+
+func (r *worldRenderer) drawItem(rect image.Rectangle, id uint16, background bool) {
+	if sprite := r.sprite(id); sprite != nil {
+		drawScaled(r.img, rect, sprite, background)
+		return
+	}
+	c := itemColor(r.opts.Items, id)
+	if background {
+		c = darken(c)
+	}
+	r.fill(rect, c)
+}
+
+func (r *worldRenderer) sprite(id uint16) image.Image {
+	if r.opts.Textures == nil {
+		return nil
+	}
+	sprite, ok := r.sprites[id]
+	if !ok {
+		sprite, _ = r.opts.Textures.ItemIconImage(uint32(id))
+		r.sprites[id] = sprite
+	}
+	return sprite
+}
+
+func (r *worldRenderer) fill(rect image.Rectangle, c color.NRGBA) {
+	draw.Draw(r.img, rect.Intersect(r.img.Bounds()), image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func (r *worldRenderer) outline(rect image.Rectangle, c color.NRGBA) {
+	rect = rect.Intersect(r.img.Bounds())
+	for x := rect.Min.X; x < rect.Max.X; x++ {
+		r.img.SetNRGBA(x, rect.Min.Y, c)
+		r.img.SetNRGBA(x, rect.Max.Y-1, c)
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		r.img.SetNRGBA(rect.Min.X, y, c)
+		r.img.SetNRGBA(rect.Max.X-1, y, c)
+	}
+}
+
+// marker draws a player as an outlined square the size of a tile
+func (r *worldRenderer) marker(x, y float32, c color.NRGBA) {
+	rect := r.tileRect(x, y)
+	r.fill(rect, c)
+	r.outline(rect, renderOutlineColor)
+}
+
+func shrink(rect image.Rectangle, n int) image.Rectangle {
+	if rect.Dx() <= 2*n || rect.Dy() <= 2*n {
+		return rect
+	}
+	return rect.Inset(n)
+}
+
+// drawScaled copies src into dst rect with nearest-neighbor scaling
+func drawScaled(dst *image.NRGBA, rect image.Rectangle, src image.Image, background bool) {
+	sb := src.Bounds()
+	clip := rect.Intersect(dst.Bounds())
+	for y := clip.Min.Y; y < clip.Max.Y; y++ {
+		sy := sb.Min.Y + (y-rect.Min.Y)*sb.Dy()/rect.Dy()
+		for x := clip.Min.X; x < clip.Max.X; x++ {
+			sx := sb.Min.X + (x-rect.Min.X)*sb.Dx()/rect.Dx()
+			c := color.NRGBAModel.Convert(src.At(sx, sy)).(color.NRGBA)
+			if c.A == 0 {
+				continue
+			}
+			if background {
+				c = darken(c)
+			}
+			if c.A == 0xFF {
+				dst.SetNRGBA(x, y, c)
+				continue
+			}
+			// Alpha blend over what is already there
+			under := dst.NRGBAAt(x, y)
+			a := uint32(c.A)
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8((uint32(c.R)*a + uint32(under.R)*(255-a)) / 255),
+				G: uint8((uint32(c.G)*a + uint32(under.G)*(255-a)) / 255),
+				B: uint8((uint32(c.B)*a + uint32(under.B)*(255-a)) / 255),
+				A: 0xFF,
+			})
+		}
+	}
+}
+
+// itemColor picks a flat color for an item: its seed color from items.dat when
+// known, otherwise a stable color derived from the ID
+func itemColor(db *database.ItemDatabase, id uint16) color.NRGBA {
+	if db != nil {
+		if seed := db.SeedOf(uint32(id)); seed != nil && seed.BaseColor != 0 {
+			// Stored as 0xAARRGGBB
+			c := seed.BaseColor
+			return color.NRGBA{R: uint8(c >> 16), G: uint8(c >> 8), B: uint8(c), A: 0xFF}
+		}
+	}
+	h := uint32(id) * 2654435761
+	return color.NRGBA{R: uint8(h>>24)/2 + 64, G: uint8(h>>16)/2 + 64, B: uint8(h>>8)/2 + 64, A: 0xFF}
+}
+
+func darken(c color.NRGBA) color.NRGBA {
+	return color.NRGBA{R: c.R / 2, G: c.G / 2, B: c.B / 2, A: c.A}
+}
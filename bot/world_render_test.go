@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// oneColor serves a 2x2 sprite of c for id, nothing for the rest
+type oneColor struct {
+	id uint32
+	c  color.NRGBA
+}
+
+func (o oneColor) ItemIconImage(id uint32) (image.Image, error) {
+	if id != o.id {
+		return nil, nil
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	for i := 0; i < 4; i++ {
+		img.SetNRGBA(i%2, i/2, o.c)
+	}
+	return img, nil
+}
+
+// renderTestWorld is 3x2 tiles: a foreground at (0,0), a background at
+// (1,0), a lock at (2,1) and an item dropped on (1,1)
+func renderTestWorld() *World {
+	w := &World{Name: "RENDER", Width: 3, Height: 2}
+	for y := uint32(0); y < 2; y++ {
+		for x := uint32(0); x < 3; x++ {
+			w.Tiles = append(w.Tiles, Tile{X: x, Y: y})
+		}
+	}
+	w.Tiles[0].ForegroundItemID = 2
+	w.Tiles[1].BackgroundItemID = 4
+	w.Tiles[5].ForegroundItemID = 242
+	w.Tiles[5].Extra = TileLock{}
+	w.DroppedItems = []DroppedItem{{ID: 112, X: 32, Y: 32}}
+	return w
+}
+
+func TestWorldRender(t *testing.T) {
+	w := renderTestWorld()
+	img := w.Render(RenderOptions{
+		TileSize: 4,
+		Players:  []Players{{Name: "other", PosX: 64, PosY: 0}, {Name: "me", IsLocal: true, PosX: 32, PosY: 0}},
+		Self:     &Players{PosX: 0, PosY: 32},
+	})
+	if b := img.Bounds(); b.Dx() != 12 || b.Dy() != 8 {
+		t.Fatalf("image is %v, want 12x8", b)
+	}
+
+	tests := []struct {
+		name string
+		x, y int
+		want color.NRGBA
+	}{
+		{"foreground", 1, 1, itemColor(nil, 2)},
+		{"background", 5, 1, darken(itemColor(nil, 4))},
+		{"other player", 9, 1, renderPlayerColor},
+		{"player outline", 8, 0, renderOutlineColor},
+		{"self", 1, 5, renderSelfColor},
+		{"dropped item", 5, 5, renderDroppedColor},
+		{"around the dropped item", 4, 4, renderSkyColor},
+		{"lock outline", 8, 4, renderLockColor},
+		{"locked tile", 9, 5, itemColor(nil, 242)},
+	}
+	for _, tt := range tests {
+		if got := img.NRGBAAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("%s at %d,%d: %v, want %v", tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestWorldRenderTextures(t *testing.T) {
+	red := color.NRGBA{R: 0xFF, A: 0xFF}
+	img := renderTestWorld().Render(RenderOptions{TileSize: 32, Textures: oneColor{id: 2, c: red}})
+	if b := img.Bounds(); b.Dx() != 96 || b.Dy() != 64 {
+		t.Fatalf("image is %v, want 96x64", b)
+	}
+	// The 2x2 sprite fills the whole tile, items without one get their color
+	for _, p := range []image.Point{{0, 0}, {31, 31}, {16, 8}} {
+		if got := img.NRGBAAt(p.X, p.Y); got != red {
+			t.Errorf("sprite at %v: %v", p, got)
+		}
+	}
+	if got := img.NRGBAAt(40, 8); got != darken(itemColor(nil, 4)) {
+		t.Errorf("background without a sprite: %v", got)
+	}
+}
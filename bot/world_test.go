@@ -0,0 +1,252 @@
+package bot
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// checkGolden compares got with testdata/name, or rewrites it with -update
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if got != string(want) {
+		gotLines, wantLines := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+		for i := 0; i < len(gotLines) && i < len(wantLines); i++ {
+			if gotLines[i] != wantLines[i] {
+				t.Fatalf("%s differs at line %d:\n got: %s\nwant: %s", name, i+1, gotLines[i], wantLines[i])
+			}
+		}
+		t.Fatalf("%s differs: %d lines, want %d", name, len(gotLines), len(wantLines))
+	}
+}
+
+// worldSummary prints a parsed world compactly: the header, every tile with
+// extra data, a checksum over all tiles and the dropped items
+func worldSummary(w *World) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "name %s\nsize %dx%d tiles %d version %d flags %d\n", w.Name, w.Width, w.Height, w.TileCount, w.Version, w.Flags)
+	fmt.Fprintf(&sb, "weather base %d current %d\nreport %s\n", w.BaseWeather, w.CurrentWeather, w.ParseReport)
+
+	h := fnv.New64a()
+	for i, tile := range w.Tiles {
+		fmt.Fprintf(h, "%d %d %d %d\n", tile.ForegroundItemID, tile.BackgroundItemID, tile.ParentBlockIndex, tile.Flags)
+		if tile.Flags&TileFlagHasExtraData != 0 || tile.CBOR != nil {
+			fmt.Fprintf(&sb, "tile %d (%d,%d) fg %d bg %d %s %+v\n", i, tile.X, tile.Y,
+				tile.ForegroundItemID, tile.BackgroundItemID, TileExtraTypeName(tile.TileType), tile.Extra)
+		}
+	}
+	fmt.Fprintf(&sb, "tiles checksum %016x\n", h.Sum64())
+
+	for _, drop := range w.DroppedItems {
+		fmt.Fprintf(&sb, "drop %+v\n", drop)
+	}
+	return sb.String()
+}
+
+func TestParseWorldGolden(t *testing.T) {
+	data, err := os.ReadFile("testdata/world.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := parseWorld(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !w.ParseReport.Clean() {
+		t.Errorf("report: %s", w.ParseReport)
+	}
+	checkGolden(t, "world.golden", worldSummary(w))
+}
+
+func TestParseWorldTruncated(t *testing.T) {
+	data, err := os.ReadFile("testdata/world.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < len(data); n += 97 {
+		if _, err := parseWorld(data[:n], nil); err == nil {
+			t.Fatalf("%d of %d bytes parsed without error", n, len(data))
+		}
+	}
+}
+
+// worldHeader is the start of a version 0x19 world named "A"
+func worldHeader(width, height, tiles uint32) []byte {
+	r := []byte{0x19, 0, 0, 0, 0, 0, 1, 0, 'A'}
+	for _, v := range []uint32{width, height, tiles} {
+		r = binary.LittleEndian.AppendUint32(r, v)
+	}
+	return append(r, 0, 0, 0, 0, 0)
+}
+
+// smallWorld is a 4x1 world with a sign, a door and a lock, one dropped item
+// and rain
+func smallWorld() []byte {
+	le := binary.LittleEndian
+	tile := func(r []byte, fg, bg, flags uint16) []byte {
+		r = le.AppendUint16(r, fg)
+		r = le.AppendUint16(r, bg)
+		r = le.AppendUint16(r, 0)
+		return le.AppendUint16(r, flags)
+	}
+	str := func(r []byte, s string) []byte {
+		return append(le.AppendUint16(r, uint16(len(s))), s...)
+	}
+
+	data := worldHeader(4, 1, 4)
+	data = tile(data, 2, 14, 0)
+	data = tile(data, 20, 14, TileFlagHasExtraData)
+	data = append(str(append(data, 1), "hello"), 0)
+	data = tile(data, 12, 14, TileFlagHasExtraData)
+	data = le.AppendUint32(str(append(data, 2), "EXIT"), 0xFFFFFFFF)
+	data = tile(data, 242, 0, TileFlagHasExtraData)
+	data = append(data, 3, 0)                    // Lock, settings
+	data = le.AppendUint32(data, 1234)           // Owner
+	data = le.AppendUint32(data, 1)              // Access count
+	data = le.AppendUint32(data, 5678)           // Access UID
+	data = append(data, 10, 0, 0, 0, 0, 0, 0, 0) // Minimum level, unknown
+
+	data = append(data, make([]byte, 12)...)
+	data = le.AppendUint32(data, 1) // Dropped items
+	data = le.AppendUint32(data, 7) // Last UID
+	data = le.AppendUint16(data, 112)
+	data = le.AppendUint32(data, math.Float32bits(32))
+	data = le.AppendUint32(data, math.Float32bits(0))
+	data = append(data, 3, 0)
+	data = le.AppendUint32(data, 7)
+
+	data = le.AppendUint16(data, 5) // Base weather
+	data = le.AppendUint16(data, 0)
+	return le.AppendUint16(data, 5) // Current weather
+}
+
+func TestParseWorldSmall(t *testing.T) {
+	w, err := parseWorld(smallWorld(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "small_world.golden", worldSummary(w))
+}
+
+func TestParseWorldCBOR(t *testing.T) {
+	le := binary.LittleEndian
+	blob := []byte{0xA1, 0x61, 'a', 0x01} // {"a": 1}
+	data := worldHeader(2, 1, 2)
+	data = le.AppendUint16(le.AppendUint16(data, 9999), 14)
+	data = append(data, 0, 0, 0, 0)
+	data = append(le.AppendUint32(data, uint32(len(blob))), blob...)
+	data = append(data, 2, 0, 14, 0, 0, 0, 0, 0)
+	data = append(data, make([]byte, 20)...) // No dropped items
+	data = append(data, 0, 0, 0, 0, 0, 0)
+
+	w, err := parseWorld(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := w.Tiles[0].CBOR.(map[string]interface{}); !ok || m["a"] != uint64(1) {
+		t.Errorf("tile 0 CBOR %#v", w.Tiles[0].CBOR)
+	}
+	if w.Tiles[1].CBOR != nil || w.Tiles[1].ForegroundItemID != 2 {
+		t.Errorf("tile 1 %+v", w.Tiles[1])
+	}
+	if w.ParseReport.CBORTiles != 1 || !w.ParseReport.Clean() {
+		t.Errorf("report: %s", w.ParseReport)
+	}
+}
+
+func TestParseWorldHugeCounts(t *testing.T) {
+	header := worldHeader
+	for _, data := range [][]byte{
+		header(100, 60, 0xFFFFFFFF),
+		header(0xFFFF, 0xFFFF, 6000),
+		header(0, 60, 6000),
+		header(100, 60, 6000), // No room for the tiles
+	} {
+		if _, err := parseWorld(data, nil); err == nil {
+			t.Fatalf("%x parsed without error", data)
+		}
+	}
+
+	// A single empty tile followed by a dropped item count far beyond the data
+	data := header(1, 1, 1)
+	data = append(data, make([]byte, 8+12)...)
+	data = append(data, 0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0)
+	if _, err := parseWorld(data, nil); err == nil {
+		t.Fatal("bogus dropped item count parsed without error")
+	}
+}
+
+// TestParseWorldUnknownType drops a registered parser and checks that the rest
+// of the world still decodes the same
+func TestParseWorldUnknownType(t *testing.T) {
+	data, err := os.ReadFile("testdata/world.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := parseWorld(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const dataBedrock = 42
+	saved := tileExtraTypes[dataBedrock]
+	delete(tileExtraTypes, dataBedrock)
+	defer func() { tileExtraTypes[dataBedrock] = saved }()
+
+	got, err := parseWorld(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ParseReport.UnknownTypes[dataBedrock] != 1 || got.ParseReport.RecoveredTiles != 1 {
+		t.Fatalf("report: %s", got.ParseReport)
+	}
+	for i := range want.Tiles {
+		if want.Tiles[i].TileType == dataBedrock {
+			if u, ok := got.Tiles[i].Extra.(TileUnknown); !ok || len(u.Raw) != 21 {
+				t.Fatalf("tile %d extra = %+v", i, got.Tiles[i].Extra)
+			}
+			continue
+		}
+		if fmt.Sprint(want.Tiles[i]) != fmt.Sprint(got.Tiles[i]) {
+			t.Fatalf("tile %d differs:\n got %+v\nwant %+v", i, got.Tiles[i], want.Tiles[i])
+		}
+	}
+	if len(got.DroppedItems) != len(want.DroppedItems) {
+		t.Fatalf("%d dropped items, want %d", len(got.DroppedItems), len(want.DroppedItems))
+	}
+}
+
+func FuzzParseWorld(f *testing.F) {
+	// testdata/world.dat is covered by the golden test; the fuzzer stalls on inputs that large
+	f.Add(smallWorld())
+	f.Add([]byte{0x19, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		w, err := parseWorld(data, nil)
+		if err != nil {
+			return
+		}
+		if uint32(len(w.Tiles)) != w.TileCount || len(w.Tiles)*8 > len(data) || len(w.DroppedItems)*16 > len(data) {
+			t.Fatalf("%d tiles (%d declared), %d dropped items from %d bytes", len(w.Tiles), w.TileCount, len(w.DroppedItems), len(data))
+		}
+	})
+}
@@ -14,6 +14,8 @@ func HandleBotConnect(b *bot.Bot, hub *ws.Hub) {
 	b.OnUpdate = hub.BroadcastBotUpdate
 
 	go func() {
+		defer b.RecoverPanic("Orchestrator", nil)
+
 		b.Lock()
 		b.Status = "Connecting..."
 		b.Connected = true
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"vortenixgo/bot"
+	"vortenixgo/config/configtest"
+	"vortenixgo/database"
+	"vortenixgo/network/genet"
+	"vortenixgo/network/gttest"
+	"vortenixgo/network/transport"
+	"vortenixgo/network/ws"
+)
+
+// loginServer starts a mock login server sending bots to the game server at
+// 127.0.0.1:port and points the config at it, with more yaml from extra
+func loginServer(t *testing.T, port int, extra string) *gttest.LoginServer {
+	t.Helper()
+	srv := gttest.NewLoginServer("127.0.0.1", port)
+	t.Cleanup(srv.Close)
+
+	configtest.Use(t, "server:\n  server_data_url: "+srv.ServerDataURL()+"\n  login_url: "+srv.URL+"\n"+extra)
+	return srv
+}
+
+// gameServer serves a mock game server at 127.0.0.1:17091 on an in-memory
+// network and makes it the bots' transport
+func gameServer(t *testing.T) *gttest.GameServer {
+	t.Helper()
+	game := gttest.NewGameServer("127.0.0.1", 17091)
+	n := transport.NewNetwork()
+	gttest.ServeMemory(game, n, "127.0.0.1", 17091)
+
+	prev := bot.NewTransport
+	bot.NewTransport = n.NewTransport
+	t.Cleanup(func() { bot.NewTransport = prev })
+	return game
+}
+
+// waitFor polls until done reports true with the bot locked
+func waitFor(t *testing.T, b *bot.Bot, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b.Lock()
+		ok, status := done(), b.Status
+		b.Unlock()
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out, status %q", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHandleBotConnect(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string // Stored ltoken, empty for a GrowID login
+		password string
+		setup    func(srv *gttest.LoginServer)
+		status   string // Expected final status, empty when the bot should get into the game
+		hits     map[string]int
+	}{
+		{
+			name:     "growid login",
+			password: "secret",
+			hits: map[string]int{
+				gttest.EndpointServerData: 1, gttest.EndpointCheckToken: 0,
+				gttest.EndpointDashboard: 1, gttest.EndpointLoginForm: 1, gttest.EndpointValidate: 1,
+			},
+		},
+		{
+			name:  "valid token",
+			token: "saved-token",
+			setup: func(srv *gttest.LoginServer) { srv.IssueToken("saved-token") },
+			hits:  map[string]int{gttest.EndpointCheckToken: 1, gttest.EndpointDashboard: 0, gttest.EndpointValidate: 0},
+		},
+		{
+			name:     "invalid token falls back to growid",
+			token:    "expired-token",
+			password: "secret",
+			hits:     map[string]int{gttest.EndpointCheckToken: 1, gttest.EndpointDashboard: 1, gttest.EndpointValidate: 1},
+		},
+		{
+			name: "server data bad gateway",
+			setup: func(srv *gttest.LoginServer) {
+				srv.Inject(gttest.EndpointServerData, gttest.Fault{Status: http.StatusBadGateway})
+			},
+			status: "HTTP_BLOCK",
+			hits:   map[string]int{gttest.EndpointDashboard: 0},
+		},
+		{
+			name: "server data without marker",
+			setup: func(srv *gttest.LoginServer) {
+				srv.Inject(gttest.EndpointServerData, gttest.Fault{Body: gttest.BodyNoMarker})
+			},
+			status: "HTTP_BLOCK",
+		},
+		{
+			name: "dashboard bad gateway",
+			setup: func(srv *gttest.LoginServer) {
+				srv.Inject(gttest.EndpointDashboard, gttest.Fault{Status: http.StatusBadGateway})
+			},
+			status: "Bad Gateway",
+			hits:   map[string]int{gttest.EndpointValidate: 0},
+		},
+		{
+			name:  "too many people",
+			token: "saved-token",
+			setup: func(srv *gttest.LoginServer) {
+				srv.Inject(gttest.EndpointCheckToken, gttest.Fault{Body: gttest.BodyTooManyPeople})
+			},
+			status: "Too Many People",
+			hits:   map[string]int{gttest.EndpointDashboard: 0},
+		},
+		{
+			name:     "wrong password",
+			password: "wrong",
+			status:   "failed",
+		},
+	}
+
+	hub := ws.NewHub(database.NewProvider())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := loginServer(t, 17091, "")
+			game := gameServer(t)
+			srv.AddAccount("tester", "secret")
+			if tt.setup != nil {
+				tt.setup(srv)
+			}
+
+			b := bot.NewBot("bot_tester", bot.BotTypeLegacy, "tester", tt.password, "")
+			b.Server.HTTPS.LToken = tt.token
+			t.Cleanup(func() { HandleBotDisconnect(b, hub) })
+
+			HandleBotConnect(b, hub)
+			if tt.status != "" {
+				waitFor(t, b, func() bool { return b.Status == tt.status })
+			} else {
+				waitFor(t, b, func() bool { return b.Local.ServerHash == int(game.ServerHash) && len(b.Local.Inventory) > 0 })
+				b.Lock()
+				ip, port, token := b.Server.Enet.NowConnectedIP, b.Server.Enet.NowConnectedPort, b.Server.HTTPS.LToken
+				b.Unlock()
+				if ip != "127.0.0.1" || port != 17091 {
+					t.Errorf("connected to %s:%d, want 127.0.0.1:17091", ip, port)
+				}
+				want := "ltoken-tester"
+				if tt.token == "saved-token" {
+					want = tt.token
+				}
+				if token != want {
+					t.Errorf("ltoken %q, want %q", token, want)
+				}
+				if got := game.Received(); !contains(got, "GENERIC_TEXT action|enter_game") {
+					t.Errorf("game server received %v", got)
+				}
+			}
+			for endpoint, want := range tt.hits {
+				if got := srv.Hits(endpoint); got != want {
+					t.Errorf("%s: %d hits, want %d", endpoint, got, want)
+				}
+			}
+		})
+	}
+}
+
+// The whole login over real UDP, with the pure Go ENet on both ends
+func TestHandleBotConnectUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	game := gttest.NewGameServer("127.0.0.1", port)
+	srv, err := gttest.ServeENet(game, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	prev := bot.NewTransport
+	bot.NewTransport = genet.NewTransport
+	t.Cleanup(func() { bot.NewTransport = prev })
+
+	loginServer(t, port, "").AddAccount("tester", "secret")
+
+	hub := ws.NewHub(database.NewProvider())
+	b := bot.NewBot("bot_tester", bot.BotTypeLegacy, "tester", "secret", "")
+	t.Cleanup(func() { HandleBotDisconnect(b, hub) })
+	HandleBotConnect(b, hub)
+	waitFor(t, b, func() bool { return b.Local.ServerHash == int(game.ServerHash) && len(b.Local.Inventory) > 0 })
+	// The DISCONNECT before the redirect must beat the peer being closed
+	if got := game.Received(); !contains(got, "GAME_PACKET DISCONNECT") || !contains(got, "GENERIC_TEXT action|enter_game") {
+		t.Errorf("game server received %v", got)
+	}
+}
+
+func TestWarp(t *testing.T) {
+	t.Chdir(t.TempDir()) // ParseWorld dumps every world it gets to the working directory
+	srv := loginServer(t, 17091, "timing:\n  warp_delay: 0s\n  warp_timeout: 200ms\n  warp_retries: 2\n  warp_retry_delay: 10ms\n")
+	game := gameServer(t)
+	srv.AddAccount("tester", "secret")
+
+	hub := ws.NewHub(database.NewProvider())
+	b := bot.NewBot("bot_tester", bot.BotTypeLegacy, "tester", "secret", "")
+	t.Cleanup(func() { HandleBotDisconnect(b, hub) })
+	HandleBotConnect(b, hub)
+	waitFor(t, b, func() bool { return len(b.Local.Inventory) > 0 })
+
+	tests := []struct {
+		name   string
+		target string
+		faults []gttest.JoinFault
+		err    error    // Wrapped by the *WarpError, nil when the bot gets in
+		joins  []string // Join requests the server gets
+		world  string   // Where the bot is afterwards
+	}{
+		{"join", "start", nil, nil, []string{"START"}, "START"},
+		{"door", "buy|SHOP1", nil, nil, []string{"BUY|SHOP1"}, "BUY"},
+		{"locked", "vault", []gttest.JoinFault{gttest.JoinFaultLocked}, bot.ErrWorldLocked, []string{"VAULT"}, "BUY"},
+		{"banned", "club", []gttest.JoinFault{gttest.JoinFaultBanned}, bot.ErrWorldBanned, []string{"CLUB"}, "BUY"},
+		{"full, then in", "party", []gttest.JoinFault{gttest.JoinFaultFull, gttest.JoinFaultFull}, nil, []string{"PARTY", "PARTY", "PARTY"}, "PARTY"},
+		{"always full", "crowd", []gttest.JoinFault{gttest.JoinFaultFull, gttest.JoinFaultFull, gttest.JoinFaultFull}, bot.ErrWorldFull, []string{"CROWD", "CROWD", "CROWD"}, "PARTY"},
+		{"no reason", "odd", []gttest.JoinFault{gttest.JoinFaultUnexplained, gttest.JoinFaultLocked}, bot.ErrWorldLocked, []string{"ODD", "ODD"}, "PARTY"},
+		{"no answer", "quiet", []gttest.JoinFault{gttest.JoinFaultSilent, gttest.JoinFaultSilent, gttest.JoinFaultSilent}, bot.ErrWarpTimeout, []string{"QUIET", "QUIET", "QUIET"}, "PARTY"},
+		{"invalid", "no spaces", nil, bot.ErrInvalidWorld, nil, "PARTY"},
+		{"exit", "EXIT", nil, nil, nil, "EXIT"},
+		{"exit again", "exit", nil, nil, nil, "EXIT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			game.FailJoins(tt.target, tt.faults...)
+			before := len(game.Joins())
+			err := b.Warp(context.Background(), tt.target)
+			if !errors.Is(err, tt.err) || (err == nil) != (tt.err == nil) {
+				t.Fatalf("Warp(%q): %v, want %v", tt.target, err, tt.err)
+			}
+			var werr *bot.WarpError
+			if errors.As(err, &werr) && werr.Attempts != len(tt.joins) {
+				t.Errorf("%d attempts, want %d", werr.Attempts, len(tt.joins))
+			}
+			if joins := game.Joins()[before:]; strings.Join(joins, ",") != strings.Join(tt.joins, ",") {
+				t.Errorf("server got joins %v, want %v", joins, tt.joins)
+			}
+			b.Lock()
+			world, local := b.World, b.Local.World.Name
+			b.Unlock()
+			if world != tt.world || local != tt.world {
+				t.Errorf("in %q (local %q), want %q", world, local, tt.world)
+			}
+		})
+	}
+	if got := game.Received(); !contains(got, "GAME_MESSAGE action|quit_to_exit") {
+		t.Errorf("game server received %v", got)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
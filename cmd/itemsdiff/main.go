@@ -0,0 +1,70 @@
+// Command itemsdiff compares two items.dat files and lists added, removed and changed items.
+//
+//	go run ./cmd/itemsdiff [-json] old/items.dat new/items.dat
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"vortenixgo/database"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: itemsdiff [-json] <old items.dat> <new items.dat>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	oldDB := load(flag.Arg(0))
+	newDB := load(flag.Arg(1))
+	report := database.Diff(oldDB, newDB)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("[itemsdiff] %v", err)
+		}
+		return
+	}
+
+	fmt.Printf("Version %d -> %d: %d added, %d removed, %d changed\n",
+		report.OldVersion, report.NewVersion, len(report.Added), len(report.Removed), len(report.Changed))
+	for _, item := range report.Added {
+		fmt.Printf("+ %d %s\n", item.ID, item.Name)
+	}
+	for _, item := range report.Removed {
+		fmt.Printf("- %d %s\n", item.ID, item.Name)
+	}
+	for _, change := range report.Changed {
+		fmt.Printf("~ %d %s\n", change.ID, change.Name)
+		for _, field := range change.Fields {
+			fmt.Printf("    %s\n", field)
+		}
+	}
+}
+
+// load parses leniently so a newer, partly unknown items.dat can still be compared
+func load(path string) *database.ItemDatabase {
+	db := database.NewItemDatabase()
+	report, err := db.LoadFromFile(path)
+	if err != nil {
+		log.Fatalf("[itemsdiff] %s: %v", path, err)
+	}
+	if !report.Clean() {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, report)
+		for _, w := range report.Warnings {
+			fmt.Fprintf(os.Stderr, "  %s\n", w)
+		}
+	}
+	return db
+}
@@ -0,0 +1,589 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is used when VORTENIX_CONFIG is not set
+const DefaultPath = "config.yaml"
+
+// Duration wraps time.Duration so it reads and writes as "15s" in YAML and JSON
+type Duration time.Duration
+
+func (d Duration) D() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", value.Value, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// ServerConfig holds the web server and startup settings.
+// These are connection settings: changing them requires a restart.
+type ServerConfig struct {
+	Port          int    `yaml:"port" json:"port" env:"VORTENIX_PORT"`
+	StaticDir     string `yaml:"static_dir" json:"static_dir" env:"VORTENIX_STATIC_DIR"`
+	ItemsDatPath  string `yaml:"items_dat_path" json:"items_dat_path" env:"VORTENIX_ITEMS_DAT"`
+	ServerDataURL string `yaml:"server_data_url" json:"server_data_url" env:"VORTENIX_SERVER_DATA_URL"`
+	LoginURL      string `yaml:"login_url" json:"login_url" env:"VORTENIX_LOGIN_URL"`          // Base of the checktoken, dashboard and GrowID login pages
+	GameDir       string `yaml:"game_dir" json:"game_dir" env:"VORTENIX_GAME_DIR"`             // Growtopia install, for RTTEX textures
+	CrashDir      string `yaml:"crash_dir" json:"crash_dir" env:"VORTENIX_CRASH_DIR"`          // Reports of recovered panics, empty = log only
+	CaptureDir    string `yaml:"capture_dir" json:"capture_dir" env:"VORTENIX_CAPTURE_DIR"`    // Packet captures (.vxcap) started from the UI
+	ENetBackend   string `yaml:"enet_backend" json:"enet_backend" env:"VORTENIX_ENET_BACKEND"` // "cgo" (the C ENet) or "go" (network/genet)
+	ENetShared    int    `yaml:"enet_shared" json:"enet_shared" env:"VORTENIX_ENET_SHARED"`    // Bots per shared ENet host, 0 = a host per bot (go backend only)
+}
+
+// LoginConfig holds the LoginPacket defaults applied in NewBot
+type LoginConfig struct {
+	Protocol      string `yaml:"protocol" json:"protocol" env:"VORTENIX_PROTOCOL"`
+	GameVersion   string `yaml:"game_version" json:"game_version" env:"VORTENIX_GAME_VERSION"`
+	FHash         string `yaml:"fhash" json:"fhash" env:"VORTENIX_FHASH"`
+	Zf            string `yaml:"zf" json:"zf" env:"VORTENIX_ZF"`
+	Fz            string `yaml:"fz" json:"fz" env:"VORTENIX_FZ"`
+	Cbits         string `yaml:"cbits" json:"cbits"`
+	PlayerAge     string `yaml:"player_age" json:"player_age"`
+	GDPR          string `yaml:"gdpr" json:"gdpr"`
+	Category      string `yaml:"category" json:"category"`
+	PlatformID    string `yaml:"platform_id" json:"platform_id" env:"VORTENIX_PLATFORM_ID"`
+	DeviceVersion string `yaml:"device_version" json:"device_version"`
+	Country       string `yaml:"country" json:"country" env:"VORTENIX_COUNTRY"`
+	LMode         string `yaml:"lmode" json:"lmode"`
+	Aat           string `yaml:"aat" json:"aat"`
+}
+
+// TimingConfig holds timeouts and action delays
+type TimingConfig struct {
+	ConnectionTimeout Duration `yaml:"connection_timeout" json:"connection_timeout" env:"VORTENIX_CONNECTION_TIMEOUT"`
+	SayDelay          Duration `yaml:"say_delay" json:"say_delay" env:"VORTENIX_SAY_DELAY"`
+	WarpDelay         Duration `yaml:"warp_delay" json:"warp_delay" env:"VORTENIX_WARP_DELAY"`
+	// A join waits WarpTimeout for the world; a timeout, a full world or an
+	// unexplained failure is tried again WarpRetries times, WarpRetryDelay apart
+	WarpTimeout    Duration `yaml:"warp_timeout" json:"warp_timeout" env:"VORTENIX_WARP_TIMEOUT"`
+	WarpRetries    int      `yaml:"warp_retries" json:"warp_retries" env:"VORTENIX_WARP_RETRIES"`
+	WarpRetryDelay Duration `yaml:"warp_retry_delay" json:"warp_retry_delay" env:"VORTENIX_WARP_RETRY_DELAY"`
+	// SendLimits are token buckets for outgoing packets, keyed by the text
+	// action ("input", "join_request") or tank packet type ("TILE_CHANGE_REQUEST")
+	SendLimits map[string]RateLimit `yaml:"send_limits" json:"send_limits"`
+	// SendQueueSize bounds each bot's outgoing queue; SendQueuePolicy says
+	// what a full queue does with one more packet: block, drop_oldest,
+	// drop_newest or error
+	SendQueueSize   int    `yaml:"send_queue_size" json:"send_queue_size" env:"VORTENIX_SEND_QUEUE_SIZE"`
+	SendQueuePolicy string `yaml:"send_queue_policy" json:"send_queue_policy" env:"VORTENIX_SEND_QUEUE_POLICY"`
+}
+
+// RateLimit lets Burst packets through at once, then Rate per second
+type RateLimit struct {
+	Rate  float64 `yaml:"rate" json:"rate"`
+	Burst int     `yaml:"burst" json:"burst"`
+}
+
+// ItemsConfig controls how items.dat is kept up to date
+type ItemsConfig struct {
+	// AutoUpdate requests items.dat from the game server when its hash differs from ours
+	AutoUpdate bool `yaml:"auto_update" json:"auto_update" env:"VORTENIX_ITEMS_AUTO_UPDATE"`
+}
+
+// BehaviorConfig makes bots look less like bots: anti-AFK moves, some chat
+// and jittered delays, shaped by each bot's seeded personality
+type BehaviorConfig struct {
+	Enabled      bool     `yaml:"enabled" json:"enabled" env:"VORTENIX_BEHAVIOR"`
+	IdleInterval Duration `yaml:"idle_interval" json:"idle_interval" env:"VORTENIX_IDLE_INTERVAL"` // Average time between anti-AFK moves
+	ActionGap    Duration `yaml:"action_gap" json:"action_gap" env:"VORTENIX_ACTION_GAP"`          // Average pause after an action
+	Jitter       float64  `yaml:"jitter" json:"jitter"`                                            // Spread of intervals and delays, 0.3 = ±30%
+	ChatChance   float64  `yaml:"chat_chance" json:"chat_chance"`                                  // Chance an anti-AFK move comes with a phrase or emote
+	Phrases      []string `yaml:"phrases" json:"phrases"`
+	Emotes       []string `yaml:"emotes" json:"emotes"`
+	Seed         int      `yaml:"seed" json:"seed" env:"VORTENIX_BEHAVIOR_SEED"` // Personality seed, 0 = from the bot ID
+}
+
+// Config is the root of config.yaml
+type Config struct {
+	Server   ServerConfig   `yaml:"server" json:"server"`
+	Login    LoginConfig    `yaml:"login" json:"login"`
+	Timing   TimingConfig   `yaml:"timing" json:"timing"`
+	Items    ItemsConfig    `yaml:"items" json:"items"`
+	Behavior BehaviorConfig `yaml:"behavior" json:"behavior"`
+}
+
+// Default returns the built-in configuration used when no file is present
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:          8080,
+			StaticDir:     "./public",
+			ItemsDatPath:  "items.dat",
+			ServerDataURL: "https://www.growtopia1.com/growtopia/server_data.php",
+			LoginURL:      "https://login.growtopiagame.com",
+			CrashDir:      "crashes",
+			CaptureDir:    "captures",
+			ENetBackend:   "cgo",
+		},
+		Login: LoginConfig{
+			Protocol:      "225",
+			GameVersion:   "5.39",
+			FHash:         "-716928004",
+			Zf:            "-1986240656",
+			Fz:            "22647480",
+			Cbits:         "1024",
+			PlayerAge:     "17",
+			GDPR:          "1",
+			Category:      "_-5100",
+			PlatformID:    "0,1,1",
+			DeviceVersion: "0",
+			Country:       "us",
+			LMode:         "1",
+			Aat:           "2",
+		},
+		Timing: TimingConfig{
+			ConnectionTimeout: Duration(15 * time.Second),
+			SayDelay:          Duration(2 * time.Second),
+			WarpDelay:         Duration(4 * time.Second),
+			WarpTimeout:       Duration(15 * time.Second),
+			WarpRetries:       2,
+			WarpRetryDelay:    Duration(5 * time.Second),
+			SendLimits: map[string]RateLimit{
+				"input":               {Rate: 1, Burst: 3},
+				"TILE_CHANGE_REQUEST": {Rate: 10, Burst: 5},
+			},
+			SendQueueSize:   100,
+			SendQueuePolicy: "drop_oldest",
+		},
+		Behavior: BehaviorConfig{
+			IdleInterval: Duration(45 * time.Second),
+			ActionGap:    Duration(150 * time.Millisecond),
+			Jitter:       0.3,
+			ChatChance:   0.1,
+			Phrases:      []string{"hi", "brb", "lol", "nice world", "anyone selling wl?"},
+			Emotes:       []string{"/wave", "/dance", "/laugh", "/yes"},
+		},
+	}
+}
+
+var (
+	mu      sync.RWMutex
+	path    string
+	current = Default()
+	modTime time.Time
+)
+
+// Load reads the config file at p (falls back to defaults if it does not exist),
+// applies environment overrides and makes it the current config.
+func Load(p string) error {
+	if p == "" {
+		p = os.Getenv("VORTENIX_CONFIG")
+	}
+	if p == "" {
+		p = DefaultPath
+	}
+
+	effective, mtime, err := readFile(p)
+	if err != nil {
+		return err
+	}
+	if err := applyEnv(effective); err != nil {
+		return err
+	}
+	if err := effective.Validate(); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	path = p
+	current = effective
+	modTime = mtime
+	mu.Unlock()
+	return nil
+}
+
+// Get returns the current effective config. The returned value must not be modified.
+func Get() *Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Path returns the file the config was loaded from
+func Path() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return path
+}
+
+// Watch polls the config file and hot-reloads it when it changes.
+// Server settings are kept from the running config since they need a restart.
+// Nothing is notified: everything reads Get when it needs a setting.
+func Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := reloadIfChanged(); err != nil {
+				log.Printf("[Config] Reload failed: %v", err)
+			}
+		}
+	}
+}
+
+func reloadIfChanged() error {
+	mu.RLock()
+	p := path
+	last := modTime
+	mu.RUnlock()
+
+	info, err := os.Stat(p)
+	if err != nil || !info.ModTime().After(last) {
+		return nil
+	}
+
+	effective, mtime, err := readFile(p)
+	if err != nil {
+		return err
+	}
+	if err := applyEnv(effective); err != nil {
+		return err
+	}
+	if err := effective.Validate(); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	old := current
+	if effective.Server != old.Server {
+		log.Printf("[Config] Server settings changed; restart required to apply them")
+		effective.Server = old.Server
+	}
+	current = effective
+	modTime = mtime
+	mu.Unlock()
+
+	log.Printf("[Config] Reloaded %s", p)
+	return nil
+}
+
+// Set changes one string setting, key as in the file ("login.game_version"),
+// in the file and the current config. Only the value in the file is rewritten, so
+// its comments and layout stay as they are; an environment override of the
+// setting still wins over the new value.
+func Set(key, value string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	p := path
+	if p == "" {
+		p = DefaultPath
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	keys := strings.Split(key, ".")
+	data, err = setValue(data, keys, value)
+	if err != nil {
+		return fmt.Errorf("failed to set %s in %s: %w", key, p, err)
+	}
+
+	next := current.clone()
+	if err := patchNode(keys, value).Decode(next); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	if err := applyEnv(next); err != nil {
+		return err
+	}
+	if err := next.Validate(); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(p, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if info, err := os.Stat(p); err == nil {
+		modTime = info.ModTime()
+	}
+	path = p
+	current = next
+	return nil
+}
+
+// setValue returns the YAML document data with the string at keys set to
+// value. An existing value is replaced in place; a missing one is added by
+// encoding the document again, which keeps comments but not their alignment.
+func setValue(data []byte, keys []string, value string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	node := doc.Content[0]
+	for i, k := range keys {
+		if node.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s is not a mapping", strings.Join(keys[:i], "."))
+		}
+		var next *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == k {
+				next = node.Content[j+1]
+			}
+		}
+		if next == nil {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: k}, patchNode(keys[i+1:], value))
+			return encode(&doc)
+		}
+		node = next
+	}
+	if node.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("not a single value")
+	}
+
+	// Splice the new value over the old one
+	start := offset(data, node.Line, node.Column)
+	n := scalarLen(data[start:], node)
+	if n < 0 {
+		node.Value = value
+		return encode(&doc)
+	}
+	repl, err := yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Style: node.Style, Value: value})
+	if err != nil {
+		return nil, err
+	}
+	out := append([]byte(nil), data[:start]...)
+	out = append(out, bytes.TrimSuffix(repl, []byte("\n"))...)
+	return append(out, data[start+n:]...), nil
+}
+
+// patchNode is value nested in mappings under keys
+func patchNode(keys []string, value string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	for i := len(keys) - 1; i >= 0; i-- {
+		node = &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: keys[i]}, node}}
+	}
+	return node
+}
+
+func encode(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+// offset is the byte offset of a 1-based line and column (in characters)
+func offset(data []byte, line, column int) int {
+	i := 0
+	for ; line > 1 && i < len(data); i++ {
+		if data[i] == '\n' {
+			line--
+		}
+	}
+	for ; column > 1 && i < len(data); column-- {
+		_, size := utf8.DecodeRune(data[i:])
+		i += size
+	}
+	return i
+}
+
+// scalarLen is the length of the source of scalar n at the start of s, or -1
+// when it can't be told
+func scalarLen(s []byte, n *yaml.Node) int {
+	switch n.Style {
+	case yaml.DoubleQuotedStyle:
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+			} else if s[i] == '"' {
+				return i + 1
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+	case 0:
+		if !strings.Contains(n.Value, "\n") && bytes.HasPrefix(s, []byte(n.Value)) {
+			return len(n.Value)
+		}
+	}
+	return -1
+}
+
+// Validate checks the config for values that would break startup
+func (c *Config) Validate() error {
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		return fmt.Errorf("invalid server.port: %d", c.Server.Port)
+	}
+	if c.Server.ServerDataURL == "" {
+		return fmt.Errorf("server.server_data_url is empty")
+	}
+	if c.Server.LoginURL == "" {
+		return fmt.Errorf("server.login_url is empty")
+	}
+	if c.Server.ENetBackend != "cgo" && c.Server.ENetBackend != "go" {
+		return fmt.Errorf("server.enet_backend must be cgo or go, not %q", c.Server.ENetBackend)
+	}
+	if c.Server.ENetShared < 0 {
+		return fmt.Errorf("server.enet_shared can't be negative")
+	}
+	if c.Server.ENetShared > 0 && c.Server.ENetBackend != "go" {
+		return fmt.Errorf("server.enet_shared needs server.enet_backend: go")
+	}
+	if c.Login.Protocol == "" || c.Login.GameVersion == "" {
+		return fmt.Errorf("login.protocol and login.game_version are required")
+	}
+	if c.Timing.ConnectionTimeout <= 0 {
+		return fmt.Errorf("timing.connection_timeout must be positive")
+	}
+	if c.Timing.WarpTimeout <= 0 {
+		return fmt.Errorf("timing.warp_timeout must be positive")
+	}
+	if c.Timing.WarpRetries < 0 || c.Timing.WarpRetryDelay < 0 {
+		return fmt.Errorf("timing.warp_retries and timing.warp_retry_delay can't be negative")
+	}
+	if c.Timing.SendQueueSize < 1 {
+		return fmt.Errorf("timing.send_queue_size must be at least 1")
+	}
+	switch c.Timing.SendQueuePolicy {
+	case "block", "drop_oldest", "drop_newest", "error":
+	default:
+		return fmt.Errorf("timing.send_queue_policy must be block, drop_oldest, drop_newest or error, not %q", c.Timing.SendQueuePolicy)
+	}
+	if c.Behavior.IdleInterval <= 0 || c.Behavior.ActionGap < 0 {
+		return fmt.Errorf("behavior.idle_interval must be positive and behavior.action_gap not negative")
+	}
+	if c.Behavior.Jitter < 0 || c.Behavior.Jitter >= 1 {
+		return fmt.Errorf("behavior.jitter must be at least 0 and below 1")
+	}
+	if c.Behavior.ChatChance < 0 || c.Behavior.ChatChance > 1 {
+		return fmt.Errorf("behavior.chat_chance must be between 0 and 1")
+	}
+	for kind, l := range c.Timing.SendLimits {
+		if l.Rate <= 0 || l.Burst < 1 {
+			return fmt.Errorf("timing.send_limits.%s needs a positive rate and a burst of at least 1", kind)
+		}
+	}
+	return nil
+}
+
+func (c *Config) clone() *Config {
+	cp := *c
+	cp.Timing.SendLimits = make(map[string]RateLimit, len(c.Timing.SendLimits))
+	for kind, l := range c.Timing.SendLimits {
+		cp.Timing.SendLimits[kind] = l
+	}
+	cp.Behavior.Phrases = append([]string(nil), c.Behavior.Phrases...)
+	cp.Behavior.Emotes = append([]string(nil), c.Behavior.Emotes...)
+	return &cp
+}
+
+func readFile(p string) (*Config, time.Time, error) {
+	cfg := Default()
+	info, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		log.Printf("[Config] %s not found, using defaults", p)
+		return cfg, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to parse %s: %w", p, err)
+	}
+	return cfg, info.ModTime(), nil
+}
+
+// applyEnv overrides fields tagged with `env` from the environment
+func applyEnv(c *Config) error {
+	return applyEnvValue(reflect.ValueOf(c).Elem())
+}
+
+func applyEnvValue(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := applyEnvValue(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		key := t.Field(i).Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+
+		switch field.Interface().(type) {
+		case Duration:
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			field.SetInt(int64(d))
+		case string:
+			field.SetString(raw)
+		case int:
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			field.SetInt(int64(n))
+		case bool:
+			bv, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			field.SetBool(bv)
+		}
+	}
+	return nil
+}
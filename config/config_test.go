@@ -0,0 +1,248 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// load writes data (unless empty) to a config file in a temp dir and loads it
+func load(t *testing.T, data string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "config.yaml")
+	if data != "" {
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Load(p); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Load(filepath.Join(filepath.Dir(p), "missing.yaml")) })
+	return p
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name, file, key, value, want string
+	}{
+		{"double quoted", "# Top\nlogin:\n  game_version: \"5.39\"   # Kept\n  country: us\n", "login.game_version", "5.40",
+			"# Top\nlogin:\n  game_version: \"5.40\"   # Kept\n  country: us\n"},
+		{"single quoted", "login:\n  game_version: '5.39' # Kept\n", "login.game_version", "5.40",
+			"login:\n  game_version: '5.40' # Kept\n"},
+		{"plain", "login:\n  country: us   # Kept\n  lmode: \"1\"\n", "login.country", "de",
+			"login:\n  country: de   # Kept\n  lmode: \"1\"\n"},
+		{"plain to quoted", "login:\n  country: us\n", "login.country", "a: b",
+			"login:\n  country: 'a: b'\n"},
+		{"missing key", "# Top\nlogin:\n  country: us # Kept\n", "login.game_version", "5.40",
+			"# Top\nlogin:\n  country: us # Kept\n  game_version: \"5.40\"\n"},
+		{"missing section", "server:\n  port: 8080\n", "login.game_version", "5.40",
+			"server:\n  port: 8080\nlogin:\n  game_version: \"5.40\"\n"},
+		{"no file", "", "login.game_version", "5.40",
+			"login:\n  game_version: \"5.40\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := load(t, tt.file)
+			if err := Set(tt.key, tt.value); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("file\n%s\nwant\n%s", data, tt.want)
+			}
+			if err := Load(p); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSetCurrent(t *testing.T) {
+	t.Setenv("VORTENIX_COUNTRY", "fr")
+	p := load(t, "login:\n  game_version: \"5.39\"\n  country: us\n")
+
+	if err := Set("login.game_version", "5.40"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set("login.country", "de"); err != nil {
+		t.Fatal(err)
+	}
+	if c := Get().Login; c.GameVersion != "5.40" || c.Country != "fr" {
+		t.Errorf("game version %q, country %q", c.GameVersion, c.Country)
+	}
+	data, _ := os.ReadFile(p)
+	if !strings.Contains(string(data), "country: de") {
+		t.Errorf("the file doesn't have the new country\n%s", data)
+	}
+
+	// Nothing is written when the result is invalid
+	for _, key := range []string{"login.protocol", "login", "login.game_version.major", "server.port"} {
+		if err := Set(key, ""); err == nil {
+			t.Errorf("setting %s succeeded", key)
+		}
+	}
+	if after, _ := os.ReadFile(p); string(after) != string(data) {
+		t.Errorf("file changed by failed sets\n%s", after)
+	}
+	if Get().Login.Protocol == "" {
+		t.Error("protocol emptied")
+	}
+}
+
+func TestShippedConfig(t *testing.T) {
+	load(t, "")
+	if err := Load("../config.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if got := Get(); !reflect.DeepEqual(got, Default()) {
+		t.Errorf("config.yaml differs from the defaults:\n%+v\n%+v", got, Default())
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	load(t, "server:\n  port: 9000\ntiming:\n  warp_timeout: 3s\n  send_limits:\n    input: {rate: 2, burst: 1}\n")
+	want := Default()
+	want.Server.Port = 9000
+	want.Timing.WarpTimeout = Duration(3 * time.Second)
+	want.Timing.SendLimits["input"] = RateLimit{Rate: 2, Burst: 1} // Other kinds keep their defaults
+	if got := Get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got, want)
+	}
+
+	p := filepath.Join(t.TempDir(), "config.yaml")
+	for _, data := range []string{"server: [", "timing:\n  warp_timeout: soon\n", "server:\n  port: 0\n"} {
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := Load(p); err == nil {
+			t.Errorf("loaded %q", data)
+		}
+	}
+	if Get().Server.Port != 9000 {
+		t.Error("a failed load replaced the config")
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	tests := []struct {
+		env, value string
+		check      func(c *Config) bool
+	}{
+		{"VORTENIX_PORT", "9090", func(c *Config) bool { return c.Server.Port == 9090 }},
+		{"VORTENIX_GAME_VERSION", "6.00", func(c *Config) bool { return c.Login.GameVersion == "6.00" }},
+		{"VORTENIX_WARP_TIMEOUT", "1m30s", func(c *Config) bool { return c.Timing.WarpTimeout == Duration(90*time.Second) }},
+		{"VORTENIX_BEHAVIOR", "true", func(c *Config) bool { return c.Behavior.Enabled }},
+		{"VORTENIX_PORT", "abc", nil},
+		{"VORTENIX_WARP_TIMEOUT", "soon", nil},
+		{"VORTENIX_BEHAVIOR", "maybe", nil},
+		{"VORTENIX_PORT", "70000", nil}, // Fails Validate
+	}
+	for _, tt := range tests {
+		t.Run(tt.env+"="+tt.value, func(t *testing.T) {
+			p := load(t, "login:\n  game_version: \"5.39\"\n")
+			t.Setenv(tt.env, tt.value)
+			err := Load(p)
+			if tt.check == nil {
+				if err == nil {
+					t.Error("no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(Get()) {
+				t.Errorf("not applied: %+v", Get())
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+	}{
+		{"port", func(c *Config) { c.Server.Port = 0 }},
+		{"server_data_url", func(c *Config) { c.Server.ServerDataURL = "" }},
+		{"login_url", func(c *Config) { c.Server.LoginURL = "" }},
+		{"enet_backend", func(c *Config) { c.Server.ENetBackend = "rust" }},
+		{"enet_shared", func(c *Config) { c.Server.ENetShared = -1 }},
+		{"enet_shared", func(c *Config) { c.Server.ENetShared = 4 }}, // Needs the go backend
+		{"game_version", func(c *Config) { c.Login.GameVersion = "" }},
+		{"connection_timeout", func(c *Config) { c.Timing.ConnectionTimeout = 0 }},
+		{"warp_timeout", func(c *Config) { c.Timing.WarpTimeout = -1 }},
+		{"warp_retries", func(c *Config) { c.Timing.WarpRetries = -1 }},
+		{"send_queue_size", func(c *Config) { c.Timing.SendQueueSize = 0 }},
+		{"send_queue_policy", func(c *Config) { c.Timing.SendQueuePolicy = "drop_all" }},
+		{"idle_interval", func(c *Config) { c.Behavior.IdleInterval = 0 }},
+		{"jitter", func(c *Config) { c.Behavior.Jitter = 1 }},
+		{"chat_chance", func(c *Config) { c.Behavior.ChatChance = 1.5 }},
+		{"send_limits", func(c *Config) { c.Timing.SendLimits["input"] = RateLimit{Rate: 1} }},
+	}
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+	for _, tt := range tests {
+		c := Default()
+		tt.change(c)
+		if err := c.Validate(); err == nil || !strings.Contains(err.Error(), tt.name) {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+
+	c := Default()
+	c.Server.ENetBackend, c.Server.ENetShared = "go", 4
+	if err := c.Validate(); err != nil {
+		t.Errorf("shared go hosts: %v", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	p := load(t, "# Settings\nlogin:\n  game_version: \"5.39\"  # Bumped on UPDATE REQUIRED\n")
+	if err := Set("login.game_version", "5.41"); err != nil {
+		t.Fatal(err)
+	}
+	want := Get()
+	if err := Load(p); err != nil {
+		t.Fatal(err)
+	}
+	if got := Get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after reloading\n%+v\nwant\n%+v", got, want)
+	}
+	if got := Get().Login.GameVersion; got != "5.41" {
+		t.Errorf("game version %q", got)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	p := load(t, "server:\n  port: 8080\ntiming:\n  say_delay: 2s\n")
+	stop := make(chan struct{})
+	defer close(stop)
+	go Watch(5*time.Millisecond, stop)
+
+	if err := os.WriteFile(p, []byte("server:\n  port: 9999\ntiming:\n  say_delay: 5s\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute) // The file system's clock may be coarse
+	if err := os.Chtimes(p, later, later); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for Get().Timing.SayDelay != Duration(5*time.Second) {
+		if time.Now().After(deadline) {
+			t.Fatal("not reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if port := Get().Server.Port; port != 8080 {
+		t.Errorf("port %d after reload, want it kept until a restart", port)
+	}
+}
@@ -0,0 +1,26 @@
+// Package configtest swaps the global config for the length of a test
+package configtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vortenixgo/config"
+)
+
+// Use writes yaml to config.yaml in a temp dir and loads it. When the test
+// ends the built-in defaults are loaded again. It returns the file's path.
+func Use(t testing.TB, yaml string) string {
+	t.Helper()
+	dir := t.TempDir()
+	p := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(p, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Load(p); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { config.Load(filepath.Join(dir, "missing.yaml")) })
+	return p
+}
@@ -1,32 +1,105 @@
 package database
 
 import (
+	"fmt"
 	"log"
+	"os"
 	"sync"
 )
 
-var (
-	// GlobalItemDB is the global item database instance
-	GlobalItemDB *ItemDatabase
-	once         sync.Once
-)
+// Provider owns the active item database. Consumers hold the Provider and call DB()
+// on use, so a reload never leaves them with a stale *ItemDatabase.
+type Provider struct {
+	Mode        ParseMode // Parse mode for every load, lenient by default
+	mu          sync.RWMutex
+	db          *ItemDatabase
+	subscribers []func(*ItemDatabase)
+}
 
-// InitializeItemDatabase loads the items.dat file and initializes the global database
-func InitializeItemDatabase(path string) error {
-	var err error
-	once.Do(func() {
-		GlobalItemDB = NewItemDatabase()
-		err = GlobalItemDB.LoadFromFile(path)
-		if err != nil {
-			log.Printf("[Database] Failed to load items.dat: %v", err)
-			return
-		}
-		log.Printf("[Database] Loaded %d items (version %d)", GlobalItemDB.ItemCount, GlobalItemDB.Version)
-	})
-	return err
+// NewProvider creates a provider holding an empty (not loaded) database
+func NewProvider() *Provider {
+	return &Provider{
+		db: NewItemDatabase(),
+	}
+}
+
+// DB returns the current item database. It is never nil.
+func (p *Provider) DB() *ItemDatabase {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.db
+}
+
+// Subscribe registers fn to be called with the new database after every reload
+func (p *Provider) Subscribe(fn func(*ItemDatabase)) {
+	p.mu.Lock()
+	p.subscribers = append(p.subscribers, fn)
+	p.mu.Unlock()
+}
+
+// LoadFile loads items.dat from path and makes it the active database
+func (p *Provider) LoadFile(path string) error {
+	next := NewItemDatabase()
+	next.Mode = p.Mode
+	report, err := next.LoadFromFile(path)
+	if err != nil {
+		log.Printf("[Database] Failed to load items.dat: %v", err)
+		return err
+	}
+	logParseReport(report)
+	p.swap(next)
+	log.Printf("[Database] Loaded %d items (version %d)", next.ItemCount, next.Version)
+	return nil
 }
 
-// GetGlobalItemDB returns the global item database instance
-func GetGlobalItemDB() *ItemDatabase {
-	return GlobalItemDB
+// Update parses a new items.dat, writes it to path and makes it the active database
+func (p *Provider) Update(data []byte, path string) error {
+	next := NewItemDatabase()
+	next.Mode = p.Mode
+	report, err := next.LoadFromMemory(data)
+	if err != nil {
+		return fmt.Errorf("invalid items.dat: %w", err)
+	}
+	logParseReport(report)
+
+	// Write to a temp file first so a crash never leaves a half-written items.dat
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write items.dat: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace items.dat: %w", err)
+	}
+
+	p.swap(next)
+	log.Printf("[Database] Updated items.dat: %d items (version %d, hash %d)", next.ItemCount, next.Version, next.Hash)
+	return nil
+}
+
+func (p *Provider) swap(next *ItemDatabase) {
+	p.mu.Lock()
+	p.db = next
+	subs := append([]func(*ItemDatabase){}, p.subscribers...)
+	p.mu.Unlock()
+
+	for _, fn := range subs {
+		fn(next)
+	}
+}
+
+// maxLoggedWarnings keeps a badly broken file from flooding the log
+const maxLoggedWarnings = 10
+
+func logParseReport(report *ParseReport) {
+	if report.Clean() {
+		return
+	}
+	log.Printf("[Database] items.dat parsed with warnings: %s", report)
+	for i, w := range report.Warnings {
+		if i == maxLoggedWarnings {
+			log.Printf("[Database]   ... and %d more", len(report.Warnings)-i)
+			break
+		}
+		log.Printf("[Database]   %s", w)
+	}
 }
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// FieldChange is one field that differs between two versions of an item
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// ItemChange lists the changed fields of an item present in both databases
+type ItemChange struct {
+	ID     uint32        `json:"id"`
+	Name   string        `json:"name"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// DiffReport is the result of comparing two item databases
+type DiffReport struct {
+	OldVersion uint16       `json:"old_version"`
+	NewVersion uint16       `json:"new_version"`
+	Added      []*Item      `json:"added"`
+	Removed    []*Item      `json:"removed"`
+	Changed    []ItemChange `json:"changed"`
+}
+
+// Empty reports whether the two databases hold identical items
+func (r *DiffReport) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Changed) == 0
+}
+
+// Diff compares two item databases. Results are ordered by item ID.
+func Diff(oldDB, newDB *ItemDatabase) *DiffReport {
+	oldDB.mu.RLock()
+	defer oldDB.mu.RUnlock()
+	newDB.mu.RLock()
+	defer newDB.mu.RUnlock()
+
+	report := &DiffReport{OldVersion: oldDB.Version, NewVersion: newDB.Version}
+
+	for id, item := range newDB.Items {
+		old, ok := oldDB.Items[id]
+		if !ok {
+			report.Added = append(report.Added, item)
+			continue
+		}
+		if fields := diffItem(old, item); len(fields) > 0 {
+			report.Changed = append(report.Changed, ItemChange{ID: id, Name: item.Name, Fields: fields})
+		}
+	}
+	for id, item := range oldDB.Items {
+		if _, ok := newDB.Items[id]; !ok {
+			report.Removed = append(report.Removed, item)
+		}
+	}
+
+	sort.Slice(report.Added, func(i, j int) bool { return report.Added[i].ID < report.Added[j].ID })
+	sort.Slice(report.Removed, func(i, j int) bool { return report.Removed[i].ID < report.Removed[j].ID })
+	sort.Slice(report.Changed, func(i, j int) bool { return report.Changed[i].ID < report.Changed[j].ID })
+	return report
+}
+
+// diffItem compares every field of two items, nested structs (Flags) as "Flags.Seedless"
+func diffItem(a, b *Item) []FieldChange {
+	var changes []FieldChange
+	diffStruct("", reflect.ValueOf(*a), reflect.ValueOf(*b), &changes)
+	return changes
+}
+
+func diffStruct(prefix string, a, b reflect.Value, changes *[]FieldChange) {
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := prefix + field.Name
+		fa, fb := a.Field(i), b.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			diffStruct(name+".", fa, fb, changes)
+			continue
+		}
+		if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			*changes = append(*changes, FieldChange{Field: name, Old: fa.Interface(), New: fb.Interface()})
+		}
+	}
+}
+
+// String formats a change for the diff tool's text output
+func (c FieldChange) String() string {
+	return fmt.Sprintf("%s: %v -> %v", c.Field, c.Old, c.New)
+}
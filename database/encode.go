@@ -0,0 +1,84 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+)
+
+// Encode serializes the database back to the items.dat format of db.Version
+func (db *ItemDatabase) Encode() ([]byte, error) {
+	return db.EncodeVersion(db.Version)
+}
+
+// EncodeVersion serializes the database using the layout of the given version.
+// Fields the version doesn't have are dropped, fields it adds are written as stored
+// (zero for items that came from an older file).
+func (db *ItemDatabase) EncodeVersion(version uint16) ([]byte, error) {
+	if !IsKnownItemsDatVersion(version) {
+		return nil, fmt.Errorf("unsupported items.dat version %d (known %d-%d)", version, MinItemsDatVersion, MaxItemsDatVersion)
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	count := uint32(len(db.Items))
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, version)
+	binary.Write(buf, binary.LittleEndian, count)
+
+	// Items are stored in ID order and the reader expects IDs without gaps
+	for id := uint32(0); id < count; id++ {
+		item, ok := db.Items[id]
+		if !ok {
+			return nil, fmt.Errorf("item %d is missing, IDs must be contiguous from 0", id)
+		}
+		if err := writeItem(buf, item, version); err != nil {
+			return nil, fmt.Errorf("failed to write item %d: %w", id, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// SaveToFile encodes the database and writes it to path
+func (db *ItemDatabase) SaveToFile(path string) error {
+	data, err := db.Encode()
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write items.dat: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace items.dat: %w", err)
+	}
+	return nil
+}
+
+func writeString(buf *bytes.Buffer, s string) error {
+	if len(s) > math.MaxUint16 {
+		return fmt.Errorf("string too long (%d bytes)", len(s))
+	}
+	binary.Write(buf, binary.LittleEndian, uint16(len(s)))
+	buf.WriteString(s)
+	return nil
+}
+
+// cipherItemName is the inverse of decipherItemName (XOR is symmetric)
+func cipherItemName(buf *bytes.Buffer, name string, itemID uint32) error {
+	if len(name) > math.MaxUint16 {
+		return fmt.Errorf("name too long (%d bytes)", len(name))
+	}
+	binary.Write(buf, binary.LittleEndian, uint16(len(name)))
+
+	secretBytes := []byte(SECRET)
+	for i := 0; i < len(name); i++ {
+		charPos := (uint32(i) + itemID) % uint32(len(secretBytes))
+		buf.WriteByte(name[i] ^ secretBytes[charPos])
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package database
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// sampleDatabase builds a database that exercises every field the given version stores
+func sampleDatabase(version uint16, count int) *ItemDatabase {
+	db := NewItemDatabase()
+	db.Version = version
+	db.ItemCount = uint32(count)
+	for i := 0; i < count; i++ {
+		id := uint32(i)
+		item := &Item{
+			ID:                 id,
+			ActionType:         uint8(i % 120),
+			Material:           uint8(i % 7),
+			Name:               []string{"Blank", "Dirt", "Dirt Seed", "World Lock", "Golden Heartbow"}[i%5],
+			TextureFileName:    "tiles_page1.rttex",
+			TextureHash:        0xDEADBEEF ^ id,
+			CookingIngredient:  id * 3,
+			VisualEffect:       uint8(i),
+			TextureX:           uint8(i % 32),
+			TextureY:           uint8(i / 32),
+			RenderType:         2,
+			IsStripeyWallpaper: uint8(i & 1),
+			CollisionType:      1,
+			BlockHealth:        uint8(i % 255),
+			DropChance:         id * 7,
+			ClothingType:       uint8(i % 10),
+			Rarity:             uint16(i % 1000),
+			MaxItem:            200,
+			FileName:           "audio/punch.wav",
+			FileHash:           id,
+			AudioVolume:        100,
+			SeedBaseSprite:     uint8(i % 5),
+			SeedOverlaySprite:  uint8(i % 6),
+			TreeBaseSprite:     uint8(i % 7),
+			TreeOverlaySprite:  uint8(i % 8),
+			BaseColor:          0xFF00FF00,
+			OverlayColor:       0x00FF00FF,
+			Ingredient:         id<<16 | id,
+			GrowTime:           id * 31,
+			Unknown1:           uint16(i),
+			IsRayman:           uint16(i & 1),
+		}
+		item.Flags.FromBits(uint16(i * 2654435761))
+		item.Reserved[0], item.Reserved[79] = byte(i), byte(i>>8)
+		if i%3 == 0 {
+			item.PetName, item.PetPrefix, item.PetSuffix, item.PetAbility = "Pet", "Pre", "Suf", "Fire"
+			item.ExtraOptions, item.TexturePath2, item.ExtraOption2 = "opt", "game/tex.rttex", "opt2"
+		}
+		if version >= 11 {
+			item.PunchOption = "punch"
+		}
+		if version >= 12 {
+			item.Unknown12[12] = byte(i)
+		}
+		if version >= 13 {
+			item.Unknown13 = id + 13
+		}
+		if version >= 14 {
+			item.Unknown14 = id + 14
+		}
+		if version >= 15 {
+			item.Unknown15[24] = byte(i)
+			item.Unknown15Str = "v15"
+		}
+		if version >= 16 {
+			item.Unknown16Str = "v16"
+		}
+		if version >= 17 {
+			item.Unknown17 = id + 17
+		}
+		if version >= 18 {
+			item.Unknown18 = id + 18
+		}
+		if version >= 19 {
+			item.Unknown19[8] = byte(i)
+		}
+		if version >= 21 {
+			item.Unknown21 = uint16(i + 21)
+		}
+		if version >= 22 {
+			item.Description = "This is item " + item.Name
+		}
+		if version >= 23 {
+			item.Unknown23 = id + 23
+		}
+		if version >= 24 {
+			item.Unknown24 = uint8(i + 24)
+		}
+		db.Items[id] = item
+	}
+	return db
+}
+
+func TestRoundTripAllVersions(t *testing.T) {
+	for version := uint16(MinItemsDatVersion); version <= MaxItemsDatVersion; version++ {
+		src := sampleDatabase(version, 40)
+
+		encoded, err := src.Encode()
+		if err != nil {
+			t.Fatalf("v%d: encode: %v", version, err)
+		}
+
+		decoded := NewItemDatabase()
+		if _, err := decoded.LoadFromMemory(encoded); err != nil {
+			t.Fatalf("v%d: decode: %v", version, err)
+		}
+		if decoded.Version != version || decoded.ItemCount != 40 {
+			t.Fatalf("v%d: header mismatch: version %d, count %d", version, decoded.Version, decoded.ItemCount)
+		}
+		for id, item := range src.Items {
+			if !reflect.DeepEqual(item, decoded.Items[id]) {
+				t.Fatalf("v%d: item %d differs after decode:\n%+v\n%+v", version, id, item, decoded.Items[id])
+			}
+		}
+
+		reencoded, err := decoded.Encode()
+		if err != nil {
+			t.Fatalf("v%d: re-encode: %v", version, err)
+		}
+		if !bytes.Equal(encoded, reencoded) {
+			t.Fatalf("v%d: re-encoded bytes differ", version)
+		}
+		if decoded.Hash != HashItemsDat(reencoded) {
+			t.Fatalf("v%d: hash mismatch", version)
+		}
+	}
+}
+
+func TestNameCipher(t *testing.T) {
+	// v21 has no description, which would repeat the name in plain text
+	db := sampleDatabase(21, 5)
+	encoded, err := db.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The plain name must not appear in the file
+	if bytes.Contains(encoded, []byte("Golden Heartbow")) {
+		t.Fatal("item name was written without ciphering")
+	}
+
+	decoded := NewItemDatabase()
+	if _, err := decoded.LoadFromMemory(encoded); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.GetItem(4).Name; got != "Golden Heartbow" {
+		t.Fatalf("name = %q", got)
+	}
+}
+
+func TestEncodeRejectsGaps(t *testing.T) {
+	db := sampleDatabase(24, 5)
+	delete(db.Items, 2)
+	if _, err := db.Encode(); err == nil {
+		t.Fatal("expected an error for a missing item ID")
+	}
+	if _, err := sampleDatabase(24, 1).EncodeVersion(MaxItemsDatVersion + 1); err == nil {
+		t.Fatal("expected an error for an unknown version")
+	}
+}
+
+// TestRoundTripFile checks a real items.dat when one is available, e.g.
+// ITEMS_DAT=../items.dat go test ./database
+func TestRoundTripFile(t *testing.T) {
+	path := os.Getenv("ITEMS_DAT")
+	if path == "" {
+		path = "../items.dat"
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Skipf("no items.dat at %s", path)
+	}
+
+	db := NewItemDatabase()
+	if _, err := db.LoadFromMemory(data); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := db.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, encoded) {
+		for i := range data {
+			if i >= len(encoded) || data[i] != encoded[i] {
+				t.Fatalf("bytes differ at offset %d (original %d bytes, encoded %d)", i, len(data), len(encoded))
+			}
+		}
+		t.Fatalf("encoded file is longer (%d vs %d bytes)", len(encoded), len(data))
+	}
+}
+
+func TestDiff(t *testing.T) {
+	oldDB := sampleDatabase(23, 10)
+	newDB := sampleDatabase(24, 12)
+	delete(newDB.Items, 3)
+	newDB.Items[5].Rarity = 999
+	newDB.Items[5].Flags.Untradeable = !oldDB.Items[5].Flags.Untradeable
+
+	report := Diff(oldDB, newDB)
+	if len(report.Added) != 2 || report.Added[0].ID != 10 || report.Added[1].ID != 11 {
+		t.Fatalf("added = %v", report.Added)
+	}
+	if len(report.Removed) != 1 || report.Removed[0].ID != 3 {
+		t.Fatalf("removed = %v", report.Removed)
+	}
+
+	var item5 *ItemChange
+	for i := range report.Changed {
+		if report.Changed[i].ID == 5 {
+			item5 = &report.Changed[i]
+		}
+	}
+	if item5 == nil {
+		t.Fatal("item 5 not reported as changed")
+	}
+	fields := map[string]bool{}
+	for _, f := range item5.Fields {
+		fields[f.Field] = true
+	}
+	for _, want := range []string{"Rarity", "Flags.Untradeable", "Unknown24"} {
+		if !fields[want] {
+			t.Errorf("item 5 missing change %s, got %v", want, item5.Fields)
+		}
+	}
+
+	if !Diff(oldDB, oldDB).Empty() {
+		t.Fatal("diff of a database with itself should be empty")
+	}
+}
@@ -52,6 +52,22 @@ func (f *ItemFlag) FromBits(bits uint16) {
 	f.Untradeable = bits&0x8000 != 0
 }
 
+// ToBits packs the flags back into their 16-bit form
+func (f ItemFlag) ToBits() uint16 {
+	var bits uint16
+	for i, set := range []bool{
+		f.Flippable, f.Editable, f.Seedless, f.Permanent,
+		f.Dropless, f.NoSelf, f.NoShadow, f.WorldLocked,
+		f.Beta, f.AutoPickup, f.ModFlag, f.RandomGrow,
+		f.Public, f.Foreground, f.Holiday, f.Untradeable,
+	} {
+		if set {
+			bits |= 1 << i
+		}
+	}
+	return bits
+}
+
 // Item represents a single Growtopia item
 type Item struct {
 	ID                 uint32
@@ -94,14 +110,35 @@ type Item struct {
 	ExtraOption2       string
 	PunchOption        string
 	Description        string
+
+	// Bytes the parser does not interpret yet, kept so items.dat re-encodes byte for byte
+	Unknown1     uint16   `json:"-"` // Between GrowTime and IsRayman
+	Reserved     [80]byte `json:"-"` // After ExtraOption2
+	Unknown12    [13]byte `json:"-"` // version >= 12
+	Unknown13    uint32   `json:"-"` // version >= 13
+	Unknown14    uint32   `json:"-"` // version >= 14
+	Unknown15    [25]byte `json:"-"` // version >= 15
+	Unknown15Str string   `json:"-"` // version >= 15
+	Unknown16Str string   `json:"-"` // version >= 16
+	Unknown17    uint32   `json:"-"` // version >= 17
+	Unknown18    uint32   `json:"-"` // version >= 18
+	Unknown19    [9]byte  `json:"-"` // version >= 19
+	Unknown21    uint16   `json:"-"` // version >= 21
+	Unknown23    uint32   `json:"-"` // version >= 23
+	Unknown24    uint8    `json:"-"` // version >= 24
 }
 
 // ItemDatabase holds all items from items.dat
 type ItemDatabase struct {
 	Version   uint16
 	ItemCount uint32
+	Hash      uint32 // Hash of the raw items.dat, compared against the server's
 	Items     map[uint32]*Item
 	Loaded    bool
+	Mode      ParseMode    // Set before loading, lenient by default
+	Report    *ParseReport // Result of the last load
+	index     *searchIndex
+	meta      *itemMeta
 	mu        sync.RWMutex
 }
 
@@ -113,48 +150,41 @@ func NewItemDatabase() *ItemDatabase {
 	}
 }
 
-// LoadFromFile loads items.dat from a file path
-func (db *ItemDatabase) LoadFromFile(path string) error {
+// LoadFromFile loads items.dat from a file path using db.Mode
+func (db *ItemDatabase) LoadFromFile(path string) (*ParseReport, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 	return db.LoadFromMemory(data)
 }
 
-// LoadFromMemory loads items.dat from byte slice
-func (db *ItemDatabase) LoadFromMemory(data []byte) error {
+// LoadFromMemory loads items.dat from byte slice using db.Mode. The report is
+// returned even when parsing fails.
+func (db *ItemDatabase) LoadFromMemory(data []byte) (*ParseReport, error) {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	reader := bytes.NewReader(data)
-
-	// Read version
-	if err := binary.Read(reader, binary.LittleEndian, &db.Version); err != nil {
-		return fmt.Errorf("failed to read version: %w", err)
-	}
-
-	// Read item count
-	if err := binary.Read(reader, binary.LittleEndian, &db.ItemCount); err != nil {
-		return fmt.Errorf("failed to read item count: %w", err)
+	report, err := db.parse(data)
+	db.Report = report
+	if err != nil {
+		return report, err
 	}
 
-	// Read all items
-	for i := uint32(0); i < db.ItemCount; i++ {
-		item, err := readItem(reader, db.Version)
-		if err != nil {
-			return fmt.Errorf("failed to read item %d: %w", i, err)
-		}
-
-		if item.ID != i {
-			return fmt.Errorf("item ID mismatch: expected %d, got %d", i, item.ID)
-		}
+	db.Hash = HashItemsDat(data)
+	db.buildIndex()
+	db.buildMetadata()
+	db.Loaded = true
+	return report, nil
+}
 
-		db.Items[item.ID] = item
+// HashItemsDat computes the items.dat hash the same way the game does (Proton hash)
+func HashItemsDat(data []byte) uint32 {
+	var hash uint32 = 0x55555555
+	for _, b := range data {
+		hash = (hash >> 27) + (hash << 5) + uint32(b)
 	}
-
-	db.Loaded = true
-	return nil
+	return hash
 }
 
 // GetItem retrieves an item by ID
@@ -169,22 +199,22 @@ func (db *ItemDatabase) GetItemByName(name string) *Item {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	nameLower := strings.ToLower(name)
-	for _, item := range db.Items {
-		if strings.ToLower(item.Name) == nameLower {
-			return item
-		}
+	if db.index == nil {
+		return nil
 	}
-	return nil
+	return db.index.byName[strings.ToLower(name)]
 }
 
-// GetItemsByRarity retrieves all items with a specific rarity
+// GetItemsByRarity retrieves all items with a specific rarity, ordered by ID
 func (db *ItemDatabase) GetItemsByRarity(rarity uint16) []*Item {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	var items []*Item
-	for _, item := range db.Items {
+	if db.index == nil {
+		return items
+	}
+	for _, item := range db.index.sorted {
 		if item.Rarity == rarity {
 			items = append(items, item)
 		}
@@ -192,13 +222,16 @@ func (db *ItemDatabase) GetItemsByRarity(rarity uint16) []*Item {
 	return items
 }
 
-// GetItemsByClothingType retrieves all items with a specific clothing type
+// GetItemsByClothingType retrieves all items with a specific clothing type, ordered by ID
 func (db *ItemDatabase) GetItemsByClothingType(clothingType uint8) []*Item {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	var items []*Item
-	for _, item := range db.Items {
+	if db.index == nil {
+		return items
+	}
+	for _, item := range db.index.sorted {
 		if item.ClothingType == clothingType {
 			items = append(items, item)
 		}
@@ -206,186 +239,21 @@ func (db *ItemDatabase) GetItemsByClothingType(clothingType uint8) []*Item {
 	return items
 }
 
-// SearchItems searches for items by partial name match
+// SearchItems returns every item whose name contains query, best matches first
 func (db *ItemDatabase) SearchItems(query string) []*Item {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-
-	queryLower := strings.ToLower(query)
+	q := SearchQuery{Query: query, PageSize: MaxSearchPageSize}
 	var items []*Item
-	for _, item := range db.Items {
-		if strings.Contains(strings.ToLower(item.Name), queryLower) {
-			items = append(items, item)
+	for q.Page = 1; ; q.Page++ {
+		res := db.Search(q)
+		items = append(items, res.Items...)
+		if len(res.Items) < q.PageSize || len(items) >= res.Total {
+			return items
 		}
 	}
-	return items
 }
 
 // Helper functions
 
-func readItem(reader *bytes.Reader, version uint16) (*Item, error) {
-	item := &Item{}
-
-	// Read ID
-	if err := binary.Read(reader, binary.LittleEndian, &item.ID); err != nil {
-		return nil, err
-	}
-
-	// Read flags
-	var flags uint16
-	if err := binary.Read(reader, binary.LittleEndian, &flags); err != nil {
-		return nil, err
-	}
-	item.Flags.FromBits(flags)
-
-	// Read basic properties
-	if err := binary.Read(reader, binary.LittleEndian, &item.ActionType); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &item.Material); err != nil {
-		return nil, err
-	}
-
-	// Read encrypted name
-	var err error
-	item.Name, err = decipherItemName(reader, item.ID)
-	if err != nil {
-		return nil, err
-	}
-
-	// Read texture file name
-	item.TextureFileName, err = readString(reader)
-	if err != nil {
-		return nil, err
-	}
-
-	// Continue reading properties
-	if err := binary.Read(reader, binary.LittleEndian, &item.TextureHash); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &item.VisualEffect); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &item.CookingIngredient); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &item.TextureX); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &item.TextureY); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &item.RenderType); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &item.IsStripeyWallpaper); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &item.CollisionType); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &item.BlockHealth); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &item.DropChance); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &item.ClothingType); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &item.Rarity); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &item.MaxItem); err != nil {
-		return nil, err
-	}
-
-	// Read file name
-	item.FileName, err = readString(reader)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := binary.Read(reader, binary.LittleEndian, &item.FileHash); err != nil {
-		return nil, err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &item.AudioVolume); err != nil {
-		return nil, err
-	}
-
-	// Read pet strings
-	item.PetName, _ = readString(reader)
-	item.PetPrefix, _ = readString(reader)
-	item.PetSuffix, _ = readString(reader)
-	item.PetAbility, _ = readString(reader)
-
-	// Read sprite data
-	binary.Read(reader, binary.LittleEndian, &item.SeedBaseSprite)
-	binary.Read(reader, binary.LittleEndian, &item.SeedOverlaySprite)
-	binary.Read(reader, binary.LittleEndian, &item.TreeBaseSprite)
-	binary.Read(reader, binary.LittleEndian, &item.TreeOverlaySprite)
-	binary.Read(reader, binary.LittleEndian, &item.BaseColor)
-	binary.Read(reader, binary.LittleEndian, &item.OverlayColor)
-	binary.Read(reader, binary.LittleEndian, &item.Ingredient)
-	binary.Read(reader, binary.LittleEndian, &item.GrowTime)
-
-	// Skip 2 bytes
-	reader.Seek(2, io.SeekCurrent)
-	binary.Read(reader, binary.LittleEndian, &item.IsRayman)
-
-	// Read extra options
-	item.ExtraOptions, _ = readString(reader)
-	item.TexturePath2, _ = readString(reader)
-	item.ExtraOption2, _ = readString(reader)
-
-	// Skip 80 bytes
-	reader.Seek(80, io.SeekCurrent)
-
-	// Version-specific fields
-	if version >= 11 {
-		item.PunchOption, _ = readString(reader)
-	}
-	if version >= 12 {
-		reader.Seek(13, io.SeekCurrent)
-	}
-	if version >= 13 {
-		reader.Seek(4, io.SeekCurrent)
-	}
-	if version >= 14 {
-		reader.Seek(4, io.SeekCurrent)
-	}
-	if version >= 15 {
-		reader.Seek(25, io.SeekCurrent)
-		readString(reader) // Discard
-	}
-	if version >= 16 {
-		readString(reader) // Discard
-	}
-	if version >= 17 {
-		reader.Seek(4, io.SeekCurrent)
-	}
-	if version >= 18 {
-		reader.Seek(4, io.SeekCurrent)
-	}
-	if version >= 19 {
-		reader.Seek(9, io.SeekCurrent)
-	}
-	if version >= 21 {
-		reader.Seek(2, io.SeekCurrent)
-	}
-	if version >= 22 {
-		item.Description, _ = readString(reader)
-	}
-	if version >= 23 {
-		reader.Seek(4, io.SeekCurrent)
-	}
-	if version >= 24 {
-		reader.Seek(1, io.SeekCurrent)
-	}
-
-	return item, nil
-}
-
 func readString(reader *bytes.Reader) (string, error) {
 	var length uint16
 	if err := binary.Read(reader, binary.LittleEndian, &length); err != nil {
@@ -396,8 +264,11 @@ func readString(reader *bytes.Reader) (string, error) {
 		return "", nil
 	}
 
+	if int(length) > reader.Len() {
+		return "", io.ErrUnexpectedEOF
+	}
 	buf := make([]byte, length)
-	if _, err := reader.Read(buf); err != nil {
+	if _, err := io.ReadFull(reader, buf); err != nil {
 		return "", err
 	}
 
@@ -414,8 +285,11 @@ func decipherItemName(reader *bytes.Reader, itemID uint32) (string, error) {
 		return "", nil
 	}
 
+	if int(length) > reader.Len() {
+		return "", io.ErrUnexpectedEOF
+	}
 	buf := make([]byte, length)
-	if _, err := reader.Read(buf); err != nil {
+	if _, err := io.ReadFull(reader, buf); err != nil {
 		return "", err
 	}
 
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Oldest and newest items.dat versions with a known layout
+const (
+	MinItemsDatVersion = 11
+	MaxItemsDatVersion = 24
+)
+
+// itemField is one entry of the items.dat item layout. The same table drives
+// readItem and writeItem, so the two can't drift apart.
+type itemField struct {
+	name  string
+	since uint16 // First version that has the field, 0 = all
+	read  func(r *bytes.Reader, item *Item) error
+	write func(buf *bytes.Buffer, item *Item) error
+}
+
+// itemFields lists every field in file order. Versions below MinItemsDatVersion
+// are read with the base layout (since 0).
+var itemFields = []itemField{
+	fixedField("ID", 0, func(it *Item) interface{} { return &it.ID }),
+	{
+		name: "Flags",
+		read: func(r *bytes.Reader, it *Item) error {
+			var bits uint16
+			if err := binary.Read(r, binary.LittleEndian, &bits); err != nil {
+				return err
+			}
+			it.Flags.FromBits(bits)
+			return nil
+		},
+		write: func(buf *bytes.Buffer, it *Item) error {
+			return binary.Write(buf, binary.LittleEndian, it.Flags.ToBits())
+		},
+	},
+	fixedField("ActionType", 0, func(it *Item) interface{} { return &it.ActionType }),
+	fixedField("Material", 0, func(it *Item) interface{} { return &it.Material }),
+	{
+		name: "Name",
+		read: func(r *bytes.Reader, it *Item) (err error) {
+			it.Name, err = decipherItemName(r, it.ID)
+			return err
+		},
+		write: func(buf *bytes.Buffer, it *Item) error {
+			return cipherItemName(buf, it.Name, it.ID)
+		},
+	},
+	stringField("TextureFileName", 0, func(it *Item) *string { return &it.TextureFileName }),
+	fixedField("TextureHash", 0, func(it *Item) interface{} { return &it.TextureHash }),
+	fixedField("VisualEffect", 0, func(it *Item) interface{} { return &it.VisualEffect }),
+	fixedField("CookingIngredient", 0, func(it *Item) interface{} { return &it.CookingIngredient }),
+	fixedField("TextureX", 0, func(it *Item) interface{} { return &it.TextureX }),
+	fixedField("TextureY", 0, func(it *Item) interface{} { return &it.TextureY }),
+	fixedField("RenderType", 0, func(it *Item) interface{} { return &it.RenderType }),
+	fixedField("IsStripeyWallpaper", 0, func(it *Item) interface{} { return &it.IsStripeyWallpaper }),
+	fixedField("CollisionType", 0, func(it *Item) interface{} { return &it.CollisionType }),
+	fixedField("BlockHealth", 0, func(it *Item) interface{} { return &it.BlockHealth }),
+	fixedField("DropChance", 0, func(it *Item) interface{} { return &it.DropChance }),
+	fixedField("ClothingType", 0, func(it *Item) interface{} { return &it.ClothingType }),
+	fixedField("Rarity", 0, func(it *Item) interface{} { return &it.Rarity }),
+	fixedField("MaxItem", 0, func(it *Item) interface{} { return &it.MaxItem }),
+	stringField("FileName", 0, func(it *Item) *string { return &it.FileName }),
+	fixedField("FileHash", 0, func(it *Item) interface{} { return &it.FileHash }),
+	fixedField("AudioVolume", 0, func(it *Item) interface{} { return &it.AudioVolume }),
+	stringField("PetName", 0, func(it *Item) *string { return &it.PetName }),
+	stringField("PetPrefix", 0, func(it *Item) *string { return &it.PetPrefix }),
+	stringField("PetSuffix", 0, func(it *Item) *string { return &it.PetSuffix }),
+	stringField("PetAbility", 0, func(it *Item) *string { return &it.PetAbility }),
+	fixedField("SeedBaseSprite", 0, func(it *Item) interface{} { return &it.SeedBaseSprite }),
+	fixedField("SeedOverlaySprite", 0, func(it *Item) interface{} { return &it.SeedOverlaySprite }),
+	fixedField("TreeBaseSprite", 0, func(it *Item) interface{} { return &it.TreeBaseSprite }),
+	fixedField("TreeOverlaySprite", 0, func(it *Item) interface{} { return &it.TreeOverlaySprite }),
+	fixedField("BaseColor", 0, func(it *Item) interface{} { return &it.BaseColor }),
+	fixedField("OverlayColor", 0, func(it *Item) interface{} { return &it.OverlayColor }),
+	fixedField("Ingredient", 0, func(it *Item) interface{} { return &it.Ingredient }),
+	fixedField("GrowTime", 0, func(it *Item) interface{} { return &it.GrowTime }),
+	fixedField("Unknown1", 0, func(it *Item) interface{} { return &it.Unknown1 }),
+	fixedField("IsRayman", 0, func(it *Item) interface{} { return &it.IsRayman }),
+	stringField("ExtraOptions", 0, func(it *Item) *string { return &it.ExtraOptions }),
+	stringField("TexturePath2", 0, func(it *Item) *string { return &it.TexturePath2 }),
+	stringField("ExtraOption2", 0, func(it *Item) *string { return &it.ExtraOption2 }),
+	fixedField("Reserved", 0, func(it *Item) interface{} { return &it.Reserved }),
+	stringField("PunchOption", 11, func(it *Item) *string { return &it.PunchOption }),
+	fixedField("Unknown12", 12, func(it *Item) interface{} { return &it.Unknown12 }),
+	fixedField("Unknown13", 13, func(it *Item) interface{} { return &it.Unknown13 }),
+	fixedField("Unknown14", 14, func(it *Item) interface{} { return &it.Unknown14 }),
+	fixedField("Unknown15", 15, func(it *Item) interface{} { return &it.Unknown15 }),
+	stringField("Unknown15Str", 15, func(it *Item) *string { return &it.Unknown15Str }),
+	stringField("Unknown16Str", 16, func(it *Item) *string { return &it.Unknown16Str }),
+	fixedField("Unknown17", 17, func(it *Item) interface{} { return &it.Unknown17 }),
+	fixedField("Unknown18", 18, func(it *Item) interface{} { return &it.Unknown18 }),
+	fixedField("Unknown19", 19, func(it *Item) interface{} { return &it.Unknown19 }),
+	fixedField("Unknown21", 21, func(it *Item) interface{} { return &it.Unknown21 }),
+	stringField("Description", 22, func(it *Item) *string { return &it.Description }),
+	fixedField("Unknown23", 23, func(it *Item) interface{} { return &it.Unknown23 }),
+	fixedField("Unknown24", 24, func(it *Item) interface{} { return &it.Unknown24 }),
+}
+
+// itemLayouts holds the field table of every known version
+var itemLayouts = func() map[uint16][]*itemField {
+	layouts := make(map[uint16][]*itemField)
+	for v := uint16(MinItemsDatVersion); v <= MaxItemsDatVersion; v++ {
+		layouts[v] = buildLayout(v)
+	}
+	return layouts
+}()
+
+func buildLayout(version uint16) []*itemField {
+	var fields []*itemField
+	for i := range itemFields {
+		if itemFields[i].since <= version {
+			fields = append(fields, &itemFields[i])
+		}
+	}
+	return fields
+}
+
+// IsKnownItemsDatVersion reports whether the layout of version is known
+func IsKnownItemsDatVersion(version uint16) bool {
+	_, ok := itemLayouts[version]
+	return ok
+}
+
+// layoutFor returns the field table for version. Unknown versions fall back to
+// the closest known layout.
+func layoutFor(version uint16) []*itemField {
+	if fields, ok := itemLayouts[version]; ok {
+		return fields
+	}
+	if version > MaxItemsDatVersion {
+		return itemLayouts[MaxItemsDatVersion]
+	}
+	return buildLayout(version)
+}
+
+// ItemLayout returns the field names of an item in the given version, in file order
+func ItemLayout(version uint16) []string {
+	fields := layoutFor(version)
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.name
+	}
+	return names
+}
+
+// readItem reads one item. On failure it returns the partially read item and
+// the name of the field that could not be read.
+func readItem(reader *bytes.Reader, version uint16) (*Item, string, error) {
+	item := &Item{}
+	for _, f := range layoutFor(version) {
+		if err := f.read(reader, item); err != nil {
+			return item, f.name, err
+		}
+	}
+	return item, "", nil
+}
+
+// writeItem is the mirror of readItem, field for field
+func writeItem(buf *bytes.Buffer, item *Item, version uint16) error {
+	for _, f := range layoutFor(version) {
+		if err := f.write(buf, item); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// fixedField is a fixed-size little endian value (integer or byte array)
+func fixedField(name string, since uint16, ptr func(*Item) interface{}) itemField {
+	return itemField{
+		name:  name,
+		since: since,
+		read: func(r *bytes.Reader, it *Item) error {
+			return binary.Read(r, binary.LittleEndian, ptr(it))
+		},
+		write: func(buf *bytes.Buffer, it *Item) error {
+			return binary.Write(buf, binary.LittleEndian, ptr(it))
+		},
+	}
+}
+
+// stringField is a uint16 length-prefixed string
+func stringField(name string, since uint16, ptr func(*Item) *string) itemField {
+	return itemField{
+		name:  name,
+		since: since,
+		read: func(r *bytes.Reader, it *Item) (err error) {
+			*ptr(it), err = readString(r)
+			return err
+		},
+		write: func(buf *bytes.Buffer, it *Item) error {
+			return writeString(buf, *ptr(it))
+		},
+	}
+}
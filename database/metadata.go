@@ -0,0 +1,174 @@
+package database
+
+import (
+	"sort"
+	"time"
+)
+
+// ActionTypeSeed is the action type of every seed item
+const ActionTypeSeed uint8 = 19
+
+// SpliceRecipe is a pair of seeds that splice into Result (a seed ID)
+type SpliceRecipe struct {
+	Result uint32    `json:"result"`
+	Seeds  [2]uint32 `json:"seeds"`
+}
+
+// ItemMeta is the derived information about an item, used by farming logic and the UI tooltip
+type ItemMeta struct {
+	ID       uint32         `json:"id"`
+	IsSeed   bool           `json:"is_seed"`
+	SeedID   uint32         `json:"seed_id,omitempty"`  // Seed of a block (ID + 1)
+	BlockID  uint32         `json:"block_id,omitempty"` // Block a seed grows into (ID - 1)
+	GrowTime uint32         `json:"grow_time"`          // Tree grow time in seconds
+	Recipe   *SpliceRecipe  `json:"recipe,omitempty"`   // What splices into this item
+	UsedIn   []SpliceRecipe `json:"used_in,omitempty"`  // What this item's seed splices into
+}
+
+// itemMeta holds the relationships derived at load time
+type itemMeta struct {
+	recipes map[uint32]SpliceRecipe   // Seed ID -> recipe producing it
+	usedIn  map[uint32][]SpliceRecipe // Seed ID -> recipes that consume it
+}
+
+// IsSeed reports whether the item is a seed
+func (item *Item) IsSeed() bool {
+	return item.ActionType == ActionTypeSeed
+}
+
+// SpliceSeeds decodes Ingredient: two 16-bit seed IDs, zero when the seed can't be spliced
+func (item *Item) SpliceSeeds() (uint32, uint32) {
+	return item.Ingredient & 0xFFFF, item.Ingredient >> 16
+}
+
+// buildMetadata must be called with db.mu held for writing
+func (db *ItemDatabase) buildMetadata() {
+	meta := &itemMeta{
+		recipes: make(map[uint32]SpliceRecipe),
+		usedIn:  make(map[uint32][]SpliceRecipe),
+	}
+	for id, item := range db.Items {
+		if !item.IsSeed() {
+			continue
+		}
+		a, b := item.SpliceSeeds()
+		if a == 0 || b == 0 {
+			continue
+		}
+		recipe := SpliceRecipe{Result: id, Seeds: [2]uint32{a, b}}
+		meta.recipes[id] = recipe
+		meta.usedIn[a] = append(meta.usedIn[a], recipe)
+		if b != a {
+			meta.usedIn[b] = append(meta.usedIn[b], recipe)
+		}
+	}
+	for _, list := range meta.usedIn {
+		sort.Slice(list, func(i, j int) bool { return list[i].Result < list[j].Result })
+	}
+	db.meta = meta
+}
+
+// SeedOf returns the seed of a block or seed item, nil if there is none
+func (db *ItemDatabase) SeedOf(id uint32) *Item {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.seedOf(id)
+}
+
+// BlockOf returns the block a seed (or the block itself) grows into, nil if there is none
+func (db *ItemDatabase) BlockOf(id uint32) *Item {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.blockOf(id)
+}
+
+func (db *ItemDatabase) seedOf(id uint32) *Item {
+	item := db.Items[id]
+	if item == nil {
+		return nil
+	}
+	if item.IsSeed() {
+		return item
+	}
+	if seed := db.Items[id+1]; seed != nil && seed.IsSeed() {
+		return seed
+	}
+	return nil
+}
+
+func (db *ItemDatabase) blockOf(id uint32) *Item {
+	item := db.Items[id]
+	if item == nil {
+		return nil
+	}
+	if !item.IsSeed() {
+		return item
+	}
+	if id == 0 {
+		return nil
+	}
+	if block := db.Items[id-1]; block != nil && !block.IsSeed() {
+		return block
+	}
+	return nil
+}
+
+// TreeGrowTime returns how long the tree of an item (block or seed) takes to grow
+func (db *ItemDatabase) TreeGrowTime(id uint32) time.Duration {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	seed := db.seedOf(id)
+	if seed == nil {
+		return 0
+	}
+	return time.Duration(seed.GrowTime) * time.Second
+}
+
+// SplicesInto returns the recipe that produces the item (block or seed), nil if it can't be spliced
+func (db *ItemDatabase) SplicesInto(id uint32) *SpliceRecipe {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	seed := db.seedOf(id)
+	if seed == nil || db.meta == nil {
+		return nil
+	}
+	recipe, ok := db.meta.recipes[seed.ID]
+	if !ok {
+		return nil
+	}
+	return &recipe
+}
+
+// UsedInSplices returns every recipe the item's seed is an ingredient of, ordered by result
+func (db *ItemDatabase) UsedInSplices(id uint32) []SpliceRecipe {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	seed := db.seedOf(id)
+	if seed == nil || db.meta == nil {
+		return nil
+	}
+	return append([]SpliceRecipe(nil), db.meta.usedIn[seed.ID]...)
+}
+
+// Meta collects the derived information about an item, nil if the item doesn't exist
+func (db *ItemDatabase) Meta(id uint32) *ItemMeta {
+	item := db.GetItem(id)
+	if item == nil {
+		return nil
+	}
+
+	meta := &ItemMeta{ID: id, IsSeed: item.IsSeed()}
+	if seed := db.SeedOf(id); seed != nil && seed.ID != id {
+		meta.SeedID = seed.ID
+	}
+	if block := db.BlockOf(id); block != nil && block.ID != id {
+		meta.BlockID = block.ID
+	}
+	meta.GrowTime = uint32(db.TreeGrowTime(id) / time.Second)
+	meta.Recipe = db.SplicesInto(id)
+	meta.UsedIn = db.UsedInSplices(id)
+	return meta
+}
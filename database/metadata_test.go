@@ -0,0 +1,56 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestItemMetadata(t *testing.T) {
+	src := NewItemDatabase()
+	src.Version = MaxItemsDatVersion
+	// 0/1 Dirt, 2/3 Rock, 4/5 Grass (Dirt Seed + Rock Seed)
+	for id, name := range []string{"Dirt", "Dirt Seed", "Rock", "Rock Seed", "Grass", "Grass Seed"} {
+		item := &Item{ID: uint32(id), Name: name}
+		if id%2 == 1 {
+			item.ActionType = ActionTypeSeed
+			item.GrowTime = uint32(id * 30)
+		}
+		src.Items[item.ID] = item
+	}
+	src.Items[5].Ingredient = 1 | 3<<16
+
+	data, err := src.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := NewItemDatabase()
+	if _, err := db.LoadFromMemory(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if seed := db.SeedOf(4); seed == nil || seed.ID != 5 {
+		t.Fatalf("SeedOf(4) = %v", seed)
+	}
+	if block := db.BlockOf(5); block == nil || block.ID != 4 {
+		t.Fatalf("BlockOf(5) = %v", block)
+	}
+	if got := db.TreeGrowTime(4); got != 150*time.Second {
+		t.Fatalf("TreeGrowTime(4) = %v", got)
+	}
+
+	recipe := db.SplicesInto(4)
+	if recipe == nil || recipe.Result != 5 || recipe.Seeds != [2]uint32{1, 3} {
+		t.Fatalf("SplicesInto(4) = %+v", recipe)
+	}
+	if db.SplicesInto(0) != nil {
+		t.Fatal("Dirt should not have a splice recipe")
+	}
+	if used := db.UsedInSplices(0); len(used) != 1 || used[0].Result != 5 {
+		t.Fatalf("UsedInSplices(0) = %+v", used)
+	}
+
+	meta := db.Meta(3)
+	if meta == nil || !meta.IsSeed || meta.BlockID != 2 || meta.GrowTime != 90 || len(meta.UsedIn) != 1 {
+		t.Fatalf("Meta(3) = %+v", meta)
+	}
+}
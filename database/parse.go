@@ -0,0 +1,152 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// ParseMode controls how items.dat parsing reacts to malformed data
+type ParseMode int
+
+const (
+	// ParseLenient keeps every item it can read and records the problems as warnings
+	ParseLenient ParseMode = iota
+	// ParseStrict fails on the first short read, ID mismatch, unknown version or leftover bytes
+	ParseStrict
+)
+
+func (m ParseMode) String() string {
+	if m == ParseStrict {
+		return "strict"
+	}
+	return "lenient"
+}
+
+func (m ParseMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// ParseWarning is a problem found with one item (or the file as a whole when Index is -1)
+type ParseWarning struct {
+	Index   int    `json:"index"`
+	ItemID  uint32 `json:"item_id"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (w ParseWarning) String() string {
+	if w.Index < 0 {
+		return w.Message
+	}
+	if w.Field != "" {
+		return fmt.Sprintf("item #%d (ID %d) %s: %s", w.Index, w.ItemID, w.Field, w.Message)
+	}
+	return fmt.Sprintf("item #%d (ID %d): %s", w.Index, w.ItemID, w.Message)
+}
+
+// ParseReport describes how an items.dat load went. A new game version that
+// adds fields shows up as KnownVersion=false and usually TrailingBytes > 0.
+type ParseReport struct {
+	Mode          ParseMode      `json:"mode"`
+	Version       uint16         `json:"version"`
+	KnownVersion  bool           `json:"known_version"`
+	ItemCount     uint32         `json:"item_count"` // Declared in the header
+	ItemsParsed   int            `json:"items_parsed"`
+	Truncated     bool           `json:"truncated"`
+	TrailingBytes int            `json:"trailing_bytes"`
+	Warnings      []ParseWarning `json:"warnings"`
+}
+
+// Clean reports whether the file parsed without any warnings
+func (r *ParseReport) Clean() bool {
+	return len(r.Warnings) == 0
+}
+
+func (r *ParseReport) String() string {
+	known := "known"
+	if !r.KnownVersion {
+		known = "unknown"
+	}
+	return fmt.Sprintf("v%d (%s), %d/%d items, %d warnings, %d trailing bytes (%s)",
+		r.Version, known, r.ItemsParsed, r.ItemCount, len(r.Warnings), r.TrailingBytes, r.Mode)
+}
+
+func (r *ParseReport) warn(index int, itemID uint32, field, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, ParseWarning{
+		Index:   index,
+		ItemID:  itemID,
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// parse must be called with db.mu held for writing
+func (db *ItemDatabase) parse(data []byte) (*ParseReport, error) {
+	report := &ParseReport{Mode: db.Mode}
+	strict := db.Mode == ParseStrict
+	reader := bytes.NewReader(data)
+
+	// Read version
+	if err := binary.Read(reader, binary.LittleEndian, &db.Version); err != nil {
+		return report, fmt.Errorf("failed to read version: %w", err)
+	}
+
+	// Read item count
+	if err := binary.Read(reader, binary.LittleEndian, &db.ItemCount); err != nil {
+		return report, fmt.Errorf("failed to read item count: %w", err)
+	}
+
+	report.Version = db.Version
+	report.ItemCount = db.ItemCount
+	report.KnownVersion = IsKnownItemsDatVersion(db.Version)
+	if !report.KnownVersion {
+		if strict {
+			return report, fmt.Errorf("unknown items.dat version %d (known %d-%d)", db.Version, MinItemsDatVersion, MaxItemsDatVersion)
+		}
+		report.warn(-1, 0, "", "unknown items.dat version %d, parsed with the closest known layout", db.Version)
+	}
+
+	// Read all items
+	for i := uint32(0); i < db.ItemCount; i++ {
+		item, field, err := readItem(reader, db.Version)
+		if err != nil {
+			if strict {
+				return report, fmt.Errorf("failed to read item %d (%s): %w", i, field, err)
+			}
+			// Nothing after a short read can be trusted, keep what we have
+			report.warn(int(i), item.ID, field, "short read: %v", err)
+			report.Truncated = true
+			break
+		}
+
+		if item.ID != i && strict {
+			return report, fmt.Errorf("item ID mismatch: expected %d, got %d", i, item.ID)
+		}
+		// An earlier out-of-place item may already hold this ID, even when the
+		// ID matches the position
+		if _, exists := db.Items[item.ID]; exists {
+			report.warn(int(i), item.ID, "ID", "duplicate ID, item skipped")
+			continue
+		}
+		if item.ID != i {
+			report.warn(int(i), item.ID, "ID", "expected ID %d", i)
+		}
+
+		db.Items[item.ID] = item
+		report.ItemsParsed++
+	}
+
+	if report.ItemsParsed == 0 && db.ItemCount > 0 {
+		return report, fmt.Errorf("none of the %d items could be parsed", db.ItemCount)
+	}
+
+	report.TrailingBytes = reader.Len()
+	if report.TrailingBytes > 0 {
+		if strict {
+			return report, fmt.Errorf("%d bytes left after the last item, the v%d layout is incomplete", report.TrailingBytes, db.Version)
+		}
+		report.warn(-1, 0, "", "%d bytes left after the last item, the v%d layout may be missing fields", report.TrailingBytes, db.Version)
+	}
+	return report, nil
+}
@@ -0,0 +1,197 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestItemLayoutPerVersion(t *testing.T) {
+	for version := uint16(MinItemsDatVersion); version <= MaxItemsDatVersion; version++ {
+		fields := ItemLayout(version)
+		if fields[0] != "ID" || fields[len(fields)-1] == "" {
+			t.Fatalf("v%d: bad layout %v", version, fields)
+		}
+		if version > MinItemsDatVersion && len(fields) < len(ItemLayout(version-1)) {
+			t.Fatalf("v%d has fewer fields than v%d", version, version-1)
+		}
+	}
+	last := ItemLayout(MaxItemsDatVersion)
+	if last[len(last)-1] != "Unknown24" {
+		t.Fatalf("v%d should end with Unknown24, got %v", MaxItemsDatVersion, last)
+	}
+	if !reflect.DeepEqual(ItemLayout(20), ItemLayout(19)) {
+		t.Fatal("v20 adds no fields over v19")
+	}
+}
+
+func encodeSample(t *testing.T, version uint16, count int) []byte {
+	t.Helper()
+	data, err := sampleDatabase(version, count).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseTruncated(t *testing.T) {
+	data := encodeSample(t, 24, 10)
+	data = data[:len(data)-1]
+
+	strict := NewItemDatabase()
+	strict.Mode = ParseStrict
+	if _, err := strict.LoadFromMemory(data); err == nil {
+		t.Fatal("strict mode should fail on a short read")
+	}
+
+	lenient := NewItemDatabase()
+	report, err := lenient.LoadFromMemory(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Truncated || report.ItemsParsed != 9 || len(lenient.Items) != 9 {
+		t.Fatalf("report = %s", report)
+	}
+	if w := report.Warnings[0]; w.Index != 9 || w.Field != "Unknown24" {
+		t.Fatalf("warning = %+v", w)
+	}
+}
+
+func TestParseIDMismatch(t *testing.T) {
+	db := sampleDatabase(24, 5)
+	// Item at position 3 claims ID 7
+	moved := *db.Items[3]
+	moved.ID = 7
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, uint16(24))
+	binary.Write(buf, binary.LittleEndian, uint32(5))
+	for i := uint32(0); i < 5; i++ {
+		item := db.Items[i]
+		if i == 3 {
+			item = &moved
+		}
+		if err := writeItem(buf, item, 24); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	strict := NewItemDatabase()
+	strict.Mode = ParseStrict
+	if _, err := strict.LoadFromMemory(buf.Bytes()); err == nil {
+		t.Fatal("strict mode should fail on an ID mismatch")
+	}
+
+	lenient := NewItemDatabase()
+	report, err := lenient.LoadFromMemory(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ItemsParsed != 5 || len(report.Warnings) != 1 || lenient.GetItem(7) == nil || lenient.GetItem(4) == nil {
+		t.Fatalf("report = %s, warnings %v", report, report.Warnings)
+	}
+}
+
+func TestParseUnknownVersion(t *testing.T) {
+	data := encodeSample(t, 24, 3)
+	binary.LittleEndian.PutUint16(data, MaxItemsDatVersion+1)
+
+	strict := NewItemDatabase()
+	strict.Mode = ParseStrict
+	if _, err := strict.LoadFromMemory(data); err == nil {
+		t.Fatal("strict mode should reject an unknown version")
+	}
+
+	lenient := NewItemDatabase()
+	report, err := lenient.LoadFromMemory(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.KnownVersion || report.ItemsParsed != 3 || report.Clean() {
+		t.Fatalf("report = %s", report)
+	}
+}
+
+func TestParseTrailingBytes(t *testing.T) {
+	data := append(encodeSample(t, 23, 3), 0xAA, 0xBB)
+
+	strict := NewItemDatabase()
+	strict.Mode = ParseStrict
+	if _, err := strict.LoadFromMemory(data); err == nil {
+		t.Fatal("strict mode should reject leftover bytes")
+	}
+
+	lenient := NewItemDatabase()
+	report, err := lenient.LoadFromMemory(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TrailingBytes != 2 || report.Clean() {
+		t.Fatalf("report = %s", report)
+	}
+}
+
+// FuzzLoadFromMemory feeds arbitrary items.dat bytes to both parse modes. Nothing
+// may panic, and whatever strict mode accepts must encode back byte for byte.
+func FuzzLoadFromMemory(f *testing.F) {
+	for _, version := range []uint16{MinItemsDatVersion, 16, 21, MaxItemsDatVersion} {
+		data, err := sampleDatabase(version, 3).Encode()
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Add([]byte{24, 0, 0xFF, 0xFF, 0xFF, 0xFF})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		lenient := NewItemDatabase()
+		if report, err := lenient.LoadFromMemory(data); err == nil && report.ItemsParsed != len(lenient.Items) {
+			t.Fatalf("report says %d items, database has %d", report.ItemsParsed, len(lenient.Items))
+		}
+
+		strict := NewItemDatabase()
+		strict.Mode = ParseStrict
+		if _, err := strict.LoadFromMemory(data); err != nil {
+			return
+		}
+		out, err := strict.Encode()
+		if err != nil {
+			t.Fatalf("strict parse succeeded but encode failed: %v", err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Fatalf("round trip differs: %d bytes in, %d out", len(data), len(out))
+		}
+	})
+}
+
+func FuzzReadItem(f *testing.F) {
+	db := sampleDatabase(MaxItemsDatVersion, 2)
+	for _, version := range []uint16{MinItemsDatVersion, 16, MaxItemsDatVersion} {
+		var buf bytes.Buffer
+		if err := writeItem(&buf, db.Items[1], version); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(version, buf.Bytes())
+	}
+
+	f.Fuzz(func(t *testing.T, version uint16, data []byte) {
+		reader := bytes.NewReader(data)
+		item, field, err := readItem(reader, version)
+		if err != nil {
+			if field == "" {
+				t.Fatalf("error without a field name: %v", err)
+			}
+			return
+		}
+
+		// A complete item must encode back to exactly the bytes it was read from
+		var buf bytes.Buffer
+		if err := writeItem(&buf, item, version); err != nil {
+			t.Fatal(err)
+		}
+		if consumed := data[:len(data)-reader.Len()]; !bytes.Equal(buf.Bytes(), consumed) {
+			t.Fatalf("round trip differs: %d bytes read, %d written", len(consumed), buf.Len())
+		}
+	})
+}
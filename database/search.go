@@ -0,0 +1,336 @@
+package database
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultSearchPageSize = 50
+	MaxSearchPageSize     = 500
+)
+
+// SearchQuery describes an item search. Zero values mean "no filter".
+type SearchQuery struct {
+	Query        string   // Name query, matched case-insensitively
+	Fuzzy        bool     // Also match by trigram similarity (typos, missing letters)
+	MinRarity    *uint16  // Inclusive
+	MaxRarity    *uint16  // Inclusive
+	ClothingType *uint8   // Exact match
+	ActionType   *uint8   // Exact match
+	Flags        []string // Flag names that must be set, e.g. "untradeable", "seedless"
+	Sort         string   // "relevance" (default), "id", "name" or "rarity"
+	Page         int      // 1-based
+	PageSize     int
+}
+
+// SearchResult is one page of search results
+type SearchResult struct {
+	Items    []*Item `json:"items"`
+	Total    int     `json:"total"`
+	Page     int     `json:"page"`
+	PageSize int     `json:"page_size"`
+}
+
+// searchIndex is built once per load; the database is read-only afterwards
+type searchIndex struct {
+	sorted   []*Item          // All items ordered by ID
+	lower    []string         // Lowercase names, aligned with sorted
+	byName   map[string]*Item // Exact lowercase name
+	trigrams map[string][]int // Trigram -> positions in sorted
+}
+
+// itemFlagGetters maps lowercase flag names accepted in SearchQuery.Flags
+var itemFlagGetters = map[string]func(ItemFlag) bool{
+	"flippable":   func(f ItemFlag) bool { return f.Flippable },
+	"editable":    func(f ItemFlag) bool { return f.Editable },
+	"seedless":    func(f ItemFlag) bool { return f.Seedless },
+	"permanent":   func(f ItemFlag) bool { return f.Permanent },
+	"dropless":    func(f ItemFlag) bool { return f.Dropless },
+	"noself":      func(f ItemFlag) bool { return f.NoSelf },
+	"noshadow":    func(f ItemFlag) bool { return f.NoShadow },
+	"worldlocked": func(f ItemFlag) bool { return f.WorldLocked },
+	"beta":        func(f ItemFlag) bool { return f.Beta },
+	"autopickup":  func(f ItemFlag) bool { return f.AutoPickup },
+	"modflag":     func(f ItemFlag) bool { return f.ModFlag },
+	"randomgrow":  func(f ItemFlag) bool { return f.RandomGrow },
+	"public":      func(f ItemFlag) bool { return f.Public },
+	"foreground":  func(f ItemFlag) bool { return f.Foreground },
+	"holiday":     func(f ItemFlag) bool { return f.Holiday },
+	"untradeable": func(f ItemFlag) bool { return f.Untradeable },
+}
+
+// buildIndex must be called with db.mu held for writing
+func (db *ItemDatabase) buildIndex() {
+	idx := &searchIndex{
+		sorted:   make([]*Item, 0, len(db.Items)),
+		byName:   make(map[string]*Item, len(db.Items)),
+		trigrams: make(map[string][]int),
+	}
+	for _, item := range db.Items {
+		idx.sorted = append(idx.sorted, item)
+	}
+	sort.Slice(idx.sorted, func(i, j int) bool {
+		return idx.sorted[i].ID < idx.sorted[j].ID
+	})
+
+	idx.lower = make([]string, len(idx.sorted))
+	for pos, item := range idx.sorted {
+		name := strings.ToLower(item.Name)
+		idx.lower[pos] = name
+		// Keep the lowest ID when names collide
+		if _, exists := idx.byName[name]; !exists {
+			idx.byName[name] = item
+		}
+		for _, tg := range trigramsOf(name) {
+			list := idx.trigrams[tg]
+			if len(list) == 0 || list[len(list)-1] != pos {
+				idx.trigrams[tg] = append(list, pos)
+			}
+		}
+	}
+	db.index = idx
+}
+
+// Search runs an indexed search with filters, ranking and pagination
+func (db *ItemDatabase) Search(q SearchQuery) SearchResult {
+	db.mu.RLock()
+	idx := db.index
+	db.mu.RUnlock()
+
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultSearchPageSize
+	}
+	if q.PageSize > MaxSearchPageSize {
+		q.PageSize = MaxSearchPageSize
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	result := SearchResult{Page: q.Page, PageSize: q.PageSize, Items: []*Item{}}
+	if idx == nil {
+		return result
+	}
+
+	flagChecks := make([]func(ItemFlag) bool, 0, len(q.Flags))
+	for _, name := range q.Flags {
+		getter, ok := itemFlagGetters[strings.ToLower(name)]
+		if !ok {
+			// Unknown flag can never match
+			return result
+		}
+		flagChecks = append(flagChecks, getter)
+	}
+
+	type scored struct {
+		pos   int
+		score float64
+	}
+	var matches []scored
+
+	query := strings.ToLower(strings.TrimSpace(q.Query))
+	for _, pos := range idx.candidates(query, q.Fuzzy) {
+		item := idx.sorted[pos]
+		if !matchesFilters(item, &q, flagChecks) {
+			continue
+		}
+		score := 1.0
+		if query != "" {
+			score = rankName(idx.lower[pos], query, q.Fuzzy)
+			if score <= 0 {
+				continue
+			}
+		}
+		matches = append(matches, scored{pos: pos, score: score})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := idx.sorted[matches[i].pos], idx.sorted[matches[j].pos]
+		switch q.Sort {
+		case "id":
+			return a.ID < b.ID
+		case "name":
+			if idx.lower[matches[i].pos] != idx.lower[matches[j].pos] {
+				return idx.lower[matches[i].pos] < idx.lower[matches[j].pos]
+			}
+			return a.ID < b.ID
+		case "rarity":
+			if a.Rarity != b.Rarity {
+				return a.Rarity < b.Rarity
+			}
+			return a.ID < b.ID
+		default:
+			if matches[i].score != matches[j].score {
+				return matches[i].score > matches[j].score
+			}
+			return a.ID < b.ID
+		}
+	})
+
+	result.Total = len(matches)
+	// Pages past the last come from clients as is: bail out before the
+	// multiplication can overflow
+	if q.Page > len(matches)/q.PageSize+1 {
+		return result
+	}
+	start := (q.Page - 1) * q.PageSize
+	if start >= len(matches) {
+		return result
+	}
+	end := min(start+q.PageSize, len(matches))
+	for _, m := range matches[start:end] {
+		result.Items = append(result.Items, idx.sorted[m.pos])
+	}
+	return result
+}
+
+// candidates narrows the search space using the trigram index
+func (idx *searchIndex) candidates(query string, fuzzy bool) []int {
+	grams := innerTrigrams(query)
+	if fuzzy {
+		grams = trigramsOf(query)
+	}
+	if len(grams) == 0 {
+		// Query too short for the index, rank every item
+		all := make([]int, len(idx.sorted))
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}
+
+	if !fuzzy {
+		// Substring match: every trigram inside the query must be present
+		var result []int
+		for i, tg := range grams {
+			list := idx.trigrams[tg]
+			if i == 0 {
+				result = append([]int(nil), list...)
+				continue
+			}
+			result = intersectSorted(result, list)
+			if len(result) == 0 {
+				break
+			}
+		}
+		return result
+	}
+
+	// Fuzzy: any shared trigram makes an item a candidate
+	seen := make(map[int]struct{})
+	for _, tg := range grams {
+		for _, pos := range idx.trigrams[tg] {
+			seen[pos] = struct{}{}
+		}
+	}
+	result := make([]int, 0, len(seen))
+	for pos := range seen {
+		result = append(result, pos)
+	}
+	sort.Ints(result)
+	return result
+}
+
+func matchesFilters(item *Item, q *SearchQuery, flagChecks []func(ItemFlag) bool) bool {
+	if q.MinRarity != nil && item.Rarity < *q.MinRarity {
+		return false
+	}
+	if q.MaxRarity != nil && item.Rarity > *q.MaxRarity {
+		return false
+	}
+	if q.ClothingType != nil && item.ClothingType != *q.ClothingType {
+		return false
+	}
+	if q.ActionType != nil && item.ActionType != *q.ActionType {
+		return false
+	}
+	for _, check := range flagChecks {
+		if !check(item.Flags) {
+			return false
+		}
+	}
+	return true
+}
+
+// rankName scores how well name matches query; 0 means no match
+func rankName(name, query string, fuzzy bool) float64 {
+	switch {
+	case name == query:
+		return 100
+	case strings.HasPrefix(name, query):
+		// Shorter names are closer to what was typed
+		return 80 + 10*float64(len(query))/float64(len(name))
+	case strings.Contains(name, " "+query):
+		return 60
+	case strings.Contains(name, query):
+		return 40
+	}
+	if !fuzzy {
+		return 0
+	}
+	similarity := trigramSimilarity(name, query)
+	if similarity < 0.2 {
+		return 0
+	}
+	return 30 * similarity
+}
+
+// trigramsOf returns the distinct trigrams of s padded with spaces, so word
+// boundaries count towards similarity
+func trigramsOf(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return innerTrigrams(" " + s + " ")
+}
+
+// innerTrigrams returns the distinct trigrams of s without padding
+func innerTrigrams(s string) []string {
+	seen := make(map[string]struct{})
+	var grams []string
+	for i := 0; i+3 <= len(s); i++ {
+		tg := s[i : i+3]
+		if _, ok := seen[tg]; ok {
+			continue
+		}
+		seen[tg] = struct{}{}
+		grams = append(grams, tg)
+	}
+	return grams
+}
+
+func trigramSimilarity(a, b string) float64 {
+	ga, gb := trigramsOf(a), trigramsOf(b)
+	if len(ga) == 0 || len(gb) == 0 {
+		return 0
+	}
+	set := make(map[string]struct{}, len(ga))
+	for _, g := range ga {
+		set[g] = struct{}{}
+	}
+	shared := 0
+	for _, g := range gb {
+		if _, ok := set[g]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ga)+len(gb)-shared)
+}
+
+func intersectSorted(a, b []int) []int {
+	out := a[:0]
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, a[i])
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return out
+}
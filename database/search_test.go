@@ -0,0 +1,141 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// searchDatabase indexes items with the given names, IDs in order
+func searchDatabase(names ...string) *ItemDatabase {
+	db := NewItemDatabase()
+	for id, name := range names {
+		db.Items[uint32(id)] = &Item{ID: uint32(id), Name: name}
+	}
+	db.buildIndex()
+	return db
+}
+
+func names(items []*Item) []string {
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = item.Name
+	}
+	return out
+}
+
+func TestSearchRanking(t *testing.T) {
+	db := searchDatabase("Cave Dirt", "Dirty Bench", "Dirt Seed", "Dirt", "Mud", "World Lock", "Small Lock")
+	tests := []struct {
+		query string
+		fuzzy bool
+		sort  string
+		want  []string
+	}{
+		// Exact, then prefixes (shorter names first), then word starts
+		{"dirt", false, "", []string{"Dirt", "Dirt Seed", "Dirty Bench", "Cave Dirt"}},
+		{"DIRT", false, "", []string{"Dirt", "Dirt Seed", "Dirty Bench", "Cave Dirt"}},
+		{"irt", false, "", []string{"Cave Dirt", "Dirty Bench", "Dirt Seed", "Dirt"}}, // Substrings only, by ID
+		{"dirt", false, "name", []string{"Cave Dirt", "Dirt", "Dirt Seed", "Dirty Bench"}},
+		{"dirt", false, "id", []string{"Cave Dirt", "Dirty Bench", "Dirt Seed", "Dirt"}},
+		{"lock", false, "", []string{"World Lock", "Small Lock"}}, // Same score, by ID
+		{"wrld lock", false, "", []string{}},
+		{"wrld lock", true, "", []string{"World Lock", "Small Lock"}},
+		{"zzz", true, "", []string{}},
+	}
+	for _, tt := range tests {
+		got := names(db.Search(SearchQuery{Query: tt.query, Fuzzy: tt.fuzzy, Sort: tt.sort}).Items)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%q fuzzy %v sort %q: %v, want %v", tt.query, tt.fuzzy, tt.sort, got, tt.want)
+		}
+	}
+}
+
+func TestSearchCandidates(t *testing.T) {
+	db := searchDatabase("Dirt", "Dirt Seed", "Mud", "Dirty Bench")
+	idx := db.index
+	if got := idx.candidates("dirt", false); fmt.Sprint(got) != "[0 1 3]" {
+		t.Errorf("substring candidates %v", got)
+	}
+	if got := idx.candidates("di", false); len(got) != 4 {
+		t.Errorf("a query shorter than a trigram has %d candidates, want all", len(got))
+	}
+	if got := idx.candidates("mux", true); fmt.Sprint(got) != "[2]" { // Shares " mu"
+		t.Errorf("fuzzy candidates %v", got)
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	db := NewItemDatabase()
+	for id, rarity := range []uint16{1, 5, 10, 50, 999} {
+		item := &Item{ID: uint32(id), Name: fmt.Sprint("Block ", id), Rarity: rarity, ClothingType: uint8(id % 2), ActionType: uint8(id % 3)}
+		item.Flags.Untradeable = id >= 3
+		db.Items[item.ID] = item
+	}
+	db.buildIndex()
+
+	u8 := func(v uint8) *uint8 { return &v }
+	u16 := func(v uint16) *uint16 { return &v }
+	tests := []struct {
+		name string
+		q    SearchQuery
+		want []string
+	}{
+		{"rarity range", SearchQuery{MinRarity: u16(5), MaxRarity: u16(50)}, []string{"Block 1", "Block 2", "Block 3"}},
+		{"clothing type", SearchQuery{ClothingType: u8(1)}, []string{"Block 1", "Block 3"}},
+		{"action type", SearchQuery{ActionType: u8(0)}, []string{"Block 0", "Block 3"}},
+		{"flag", SearchQuery{Flags: []string{"Untradeable"}}, []string{"Block 3", "Block 4"}},
+		{"unknown flag", SearchQuery{Flags: []string{"shiny"}}, []string{}},
+		{"query and filters", SearchQuery{Query: "block 4", Flags: []string{"untradeable"}, MinRarity: u16(999)}, []string{"Block 4"}},
+		{"by rarity", SearchQuery{Sort: "rarity", MinRarity: u16(10)}, []string{"Block 2", "Block 3", "Block 4"}},
+	}
+	for _, tt := range tests {
+		if got := names(db.Search(tt.q).Items); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSearchPagination(t *testing.T) {
+	list := make([]string, 7)
+	for i := range list {
+		list[i] = fmt.Sprint("Seed ", i)
+	}
+	db := searchDatabase(list...)
+
+	tests := []struct {
+		page, size int
+		want       []string
+		wantPage   int
+		wantSize   int
+	}{
+		{1, 3, []string{"Seed 0", "Seed 1", "Seed 2"}, 1, 3},
+		{3, 3, []string{"Seed 6"}, 3, 3},
+		{4, 3, []string{}, 4, 3},
+		{0, 3, []string{"Seed 0", "Seed 1", "Seed 2"}, 1, 3},
+		{-5, 0, list, 1, DefaultSearchPageSize},
+		{1, 10000, list, 1, MaxSearchPageSize},
+		{3e16, MaxSearchPageSize, []string{}, 3e16, MaxSearchPageSize}, // Would overflow the offset
+		{math.MaxInt, 7, []string{}, math.MaxInt, 7},
+	}
+	for _, tt := range tests {
+		res := db.Search(SearchQuery{Query: "seed", Sort: "id", Page: tt.page, PageSize: tt.size})
+		if fmt.Sprint(names(res.Items)) != fmt.Sprint(tt.want) || res.Total != 7 || res.Page != tt.wantPage || res.PageSize != tt.wantSize {
+			t.Errorf("page %d of %d: %v, total %d, page %d of %d", tt.page, tt.size, names(res.Items), res.Total, res.Page, res.PageSize)
+		}
+	}
+}
+
+func TestSearchItemsAll(t *testing.T) {
+	list := make([]string, 2*MaxSearchPageSize+1)
+	for i := range list {
+		list[i] = fmt.Sprint("Block ", i)
+	}
+	db := searchDatabase(list...)
+	if got := db.SearchItems("block"); len(got) != len(list) {
+		t.Errorf("%d items, want all %d", len(got), len(list))
+	}
+	if got := db.SearchItems("nothing"); len(got) != 0 {
+		t.Errorf("%d items for no match", len(got))
+	}
+}
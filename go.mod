@@ -5,4 +5,5 @@ go 1.24.0
 require (
 	github.com/gorilla/websocket v1.5.3
 	golang.org/x/net v0.48.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -4,41 +4,72 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
+	"vortenixgo/assets"
 	"vortenixgo/bot"
+	"vortenixgo/config"
 	"vortenixgo/database"
+	"vortenixgo/network/enet"
+	"vortenixgo/network/genet"
 	"vortenixgo/network/ws"
 )
 
 func main() {
+	// Load Config (config.yaml or $VORTENIX_CONFIG, with env overrides)
+	if err := config.Load(""); err != nil {
+		log.Fatalf("[Startup] Failed to load config: %v", err)
+	}
+	cfg := config.Get()
+	go config.Watch(2*time.Second, nil)
+
 	// Initialize Bot Manager
 	_ = bot.BotManager // Ensures init() runs
+	bot.NewTransport = enet.NewTransport
+	if cfg.Server.ENetBackend == "go" {
+		bot.NewTransport = genet.NewTransport
+		if n := cfg.Server.ENetShared; n > 0 {
+			bot.NewTransport = genet.NewShared(genet.Growtopia, n).NewTransport
+			log.Printf("[Startup] Bots share ENet hosts, %d per host", n)
+		}
+	}
+	log.Printf("[Startup] ENet backend: %s", cfg.Server.ENetBackend)
 
-	// Initialize Item Database
+	// Initialize Item Database (shared by all bots and the WS handlers)
 	log.Println("[Startup] Loading item database...")
-	if err := database.InitializeItemDatabase("items.dat"); err != nil {
+	items := database.NewProvider()
+	if err := items.LoadFile(cfg.Server.ItemsDatPath); err != nil {
 		log.Printf("[Startup] Warning: Failed to load items.dat: %v", err)
 		log.Println("[Startup] Database features will be disabled")
 	} else {
-		db := database.GetGlobalItemDB()
+		db := items.DB()
 		log.Printf("[Startup] Item database loaded successfully: %d items (version %d)", db.ItemCount, db.Version)
 	}
+	bot.BotManager.SetItemProvider(items)
 
 	// Initialize WS Hub
-	hub := ws.NewHub()
+	hub := ws.NewHub(items)
 	hub.OnConnect = HandleBotConnect
 	hub.OnDisconnect = HandleBotDisconnect
+	bot.Versions.OnReconnect = func(b *bot.Bot) {
+		HandleBotConnect(b, hub)
+	}
 	go hub.Run()
 
 	// Serve Static Files
-	fs := http.FileServer(http.Dir("./public"))
+	fs := http.FileServer(http.Dir(cfg.Server.StaticDir))
 	http.Handle("/", fs)
 
+	// Item icons cropped from the game's RTTEX atlases
+	icons := assets.NewStore(cfg.Server.GameDir, items)
+	http.HandleFunc("GET /items/{id}/icon.png", icons.ServeItemIcon)
+	http.HandleFunc("GET /api/bots/{id}/world.png", HandleWorldPNG(icons))
+
 	// WebSocket Endpoint
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		ws.ServeWs(hub, w, r)
 	})
 
-	port := "8080"
+	port := fmt.Sprintf("%d", cfg.Server.Port)
 	fmt.Printf("VortenixGO Server started at http://localhost:%s\n", port)
 
 	// Start Server
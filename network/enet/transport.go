@@ -0,0 +1,145 @@
+package enet
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"vortenixgo/network/transport"
+)
+
+var initOnce sync.Once
+
+// Transport is the transport.Transport backed by this wrapper: one client
+// host set up the way the game client does (CRC32 checksum, range coder, new
+// packet header) and its current peer.
+type Transport struct {
+	host *Host
+
+	mu   sync.Mutex
+	peer *Peer
+}
+
+// NewTransport creates a client host. It's the bot's default transport
+// factory.
+func NewTransport() (transport.Transport, error) {
+	initOnce.Do(func() { Initialize() })
+
+	host := CreateHost(nil, 1, 2, 0, 0)
+	if host == nil {
+		return nil, fmt.Errorf("host creation failed")
+	}
+	host.SetUsingNewPacket(true)
+	host.SetChecksum()
+	host.CompressWithRangeCoder()
+	host.SetMaxPacketLimits(16 * 1024 * 1024)
+	host.SetSocketBuffers(2048*1024, 2048*1024)
+	return &Transport{host: host}, nil
+}
+
+func (t *Transport) Connect(ip string, port int, proxy *transport.Proxy) error {
+	if proxy != nil {
+		t.host.SetProxy(proxy.Host, proxy.Port, proxy.User, proxy.Password)
+	}
+	address, err := NewAddress(ip, port)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.peer != nil {
+		t.peer.Disconnect(0)
+		t.peer.Reset()
+	}
+	t.peer = t.host.Connect(address, 2, 0)
+	if t.peer == nil {
+		return fmt.Errorf("no peer available")
+	}
+	return nil
+}
+
+func (t *Transport) Send(data []byte) error {
+	return t.SendOn(0, data, true)
+}
+
+func (t *Transport) SendOn(channel uint8, data []byte, reliable bool) error {
+	flags := uint32(0)
+	if reliable {
+		flags = 1 // ENET_PACKET_FLAG_RELIABLE
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.peer == nil || t.peer.IsNil() {
+		return fmt.Errorf("not connected")
+	}
+	if t.peer.Send(channel, data, flags) < 0 {
+		return fmt.Errorf("send failed")
+	}
+	return nil
+}
+
+func (t *Transport) Service(timeout time.Duration) (*transport.Event, error) {
+	event, err := t.host.Service(int(timeout.Milliseconds()))
+	if err != nil || event == nil {
+		return nil, err
+	}
+
+	ev := &transport.Event{}
+	if event.Peer != nil {
+		ev.RemoteIP = event.Peer.GetRemoteIP()
+		ev.RemotePort = event.Peer.GetRemotePort()
+	}
+	switch event.Type {
+	case EventConnect:
+		ev.Type = transport.EventConnect
+	case EventDisconnect:
+		ev.Type = transport.EventDisconnect
+	case EventReceive:
+		ev.Type = transport.EventReceive
+		if event.Packet != nil {
+			ev.Data = event.Packet.GetData() // A copy, the packet can go
+			event.Packet.Destroy()
+		}
+	default:
+		return nil, nil
+	}
+	return ev, nil
+}
+
+func (t *Transport) Disconnect() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.peer != nil {
+		t.peer.Disconnect(0)
+		t.peer.Reset() // No EventDisconnect for a peer we dropped ourselves
+		t.peer = nil
+	}
+}
+
+func (t *Transport) HasPeer() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.peer != nil && !t.peer.IsNil()
+}
+
+func (t *Transport) RTT() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.peer == nil {
+		return 0
+	}
+	return t.peer.GetRoundTripTime()
+}
+
+func (t *Transport) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.peer != nil {
+		t.host.Flush() // Disconnect drops what is still queued
+		t.peer.Disconnect(0)
+		t.peer = nil
+	}
+	t.host.Flush()
+	t.host.Destroy()
+}
@@ -0,0 +1,86 @@
+package genet
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// eventQueue holds the events of one or more hosts until Service takes them
+type eventQueue struct {
+	mu     sync.Mutex
+	events []*Event
+	notify chan struct{} // Signalled when events is appended to
+	woken  chan struct{} // Signalled by wake
+	closed bool
+}
+
+func newEventQueue() *eventQueue {
+	return &eventQueue{notify: make(chan struct{}, 1), woken: make(chan struct{}, 1)}
+}
+
+func (q *eventQueue) push(ev *Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.events = append(q.events, ev)
+	q.signal()
+}
+
+func (q *eventQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// wake makes a waiting wait return early, without an event
+func (q *eventQueue) wake() {
+	select {
+	case q.woken <- struct{}{}:
+	default:
+	}
+}
+
+// wait returns the next event, nil after timeout or when woken, or an error
+// once closed
+func (q *eventQueue) wait(timeout time.Duration) (*Event, error) {
+	var timer *time.Timer
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return nil, fmt.Errorf("host closed")
+		}
+		if len(q.events) > 0 {
+			ev := q.events[0]
+			q.events[0] = nil
+			q.events = q.events[1:]
+			q.mu.Unlock()
+			return ev, nil
+		}
+		q.mu.Unlock()
+
+		if timer == nil {
+			timer = time.NewTimer(timeout)
+			defer timer.Stop()
+		}
+		select {
+		case <-q.notify:
+		case <-q.woken:
+			return nil, nil
+		case <-timer.C:
+			return nil, nil
+		}
+	}
+}
+
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.events = nil
+	q.signal() // Wake a waiting Service
+}
@@ -0,0 +1,606 @@
+package genet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"vortenixgo/network/transport"
+)
+
+// EventType tells what an Event reports
+type EventType int
+
+const (
+	EventNone EventType = iota
+	EventConnect
+	EventDisconnect
+	EventReceive
+)
+
+// Event is what Service returns
+type Event struct {
+	Type    EventType
+	Peer    *Peer
+	Channel uint8
+	Data    []byte // EventReceive only
+}
+
+// Config holds the host settings the C wrapper sets with SetUsingNewPacket,
+// SetMaxPacketLimits, the channel and the peer limits. The CRC32 checksum and
+// the range coder are always on, every host in this repo uses them.
+type Config struct {
+	NewPacket     bool // Client to server packets carry the game client's header
+	Channels      int  // Channels asked for when connecting, and the server's limit
+	MaxPacketSize int  // Largest packet sent or accepted
+	Peers         int  // Most peers at once, 0 for the protocol's 4095
+}
+
+// Growtopia is the configuration of the game client
+var Growtopia = Config{NewPacket: true, Channels: 2, MaxPacketSize: 16 * 1024 * 1024}
+
+// Host is a UDP socket and its peers, the pure Go counterpart of an ENetHost.
+// A background goroutine sends, resends and pings; Service only collects the
+// events.
+type Host struct {
+	cfg    Config
+	server bool
+	start  time.Time
+	events *eventQueue
+
+	mu      sync.Mutex
+	conn    net.PacketConn
+	peers   []*Peer                 // By peer ID, nil slots are free
+	relays  map[string]*socks5Relay // SOCKS5 associations in use, by relay address
+	proxies map[string]bool         // Proxy endpoints of the peers, see proxyKey
+	coder   rangeCoder
+	closed  bool
+	wake    chan struct{}
+	done    chan struct{}
+	scratch []byte
+}
+
+func newHost(cfg Config, server bool, conn net.PacketConn) *Host {
+	if cfg.Channels < minChannelCount {
+		cfg.Channels = Growtopia.Channels
+	}
+	if cfg.MaxPacketSize <= 0 {
+		cfg.MaxPacketSize = Growtopia.MaxPacketSize
+	}
+	if cfg.Peers <= 0 || cfg.Peers > maxPeerID {
+		cfg.Peers = maxPeerID
+	}
+	h := &Host{
+		cfg:     cfg,
+		server:  server,
+		start:   time.Now(),
+		events:  newEventQueue(),
+		conn:    conn,
+		relays:  make(map[string]*socks5Relay),
+		proxies: make(map[string]bool),
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go h.loop()
+	go h.read(conn)
+	return h
+}
+
+// Listen serves addr ("ip:port") as a game server would: it accepts
+// connections and, with cfg.NewPacket, expects the client header
+func Listen(addr string, cfg Config) (*Host, error) {
+	conn, err := net.ListenPacket("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	return newHost(cfg, true, conn), nil
+}
+
+// NewClient creates a client host on a port of its own. Its peers can each
+// connect somewhere else, through a proxy of their own.
+func NewClient(cfg Config) (*Host, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	return newHost(cfg, false, conn), nil
+}
+
+// Dial creates a client host and starts connecting it to ip:port, through
+// proxy when it's not nil. The EventConnect (or EventDisconnect) comes from
+// Service.
+func Dial(ip string, port int, proxy *transport.Proxy, cfg Config) (*Host, *Peer, error) {
+	h, err := NewClient(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := h.Connect(ip, port, proxy)
+	if err != nil {
+		h.Close()
+		return nil, nil, err
+	}
+	return h, p, nil
+}
+
+// Connect starts connecting a new peer of a client host to ip:port, through
+// proxy when it's not nil. It fails when the host is full or already has a
+// peer behind that proxy.
+func (h *Host) Connect(ip string, port int, proxy *transport.Proxy) (*Peer, error) {
+	return h.connect(ip, port, proxy, nil)
+}
+
+// connect is Connect with the peer's events going to events instead of the
+// host's queue, when it's not nil
+func (h *Host) connect(ip string, port int, proxy *transport.Proxy, events *eventQueue) (*Peer, error) {
+	addr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(ip, fmt.Sprint(port)))
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch {
+	case h.server:
+		return nil, fmt.Errorf("a server host doesn't connect")
+	case h.closed:
+		return nil, fmt.Errorf("host closed")
+	case !h.hasRoom(proxy):
+		return nil, fmt.Errorf("no room for another peer")
+	}
+	id := h.freeSlot()
+	p := newPeer(h, uint16(id), addr)
+	p.state = stateConnecting
+	p.connectID = rand.Uint32()
+	p.channels = make([]channel, h.cfg.Channels)
+	p.events = events
+	h.peers[id] = p
+
+	cmd := command(cmdConnect|flagAcknowledge, 0xFF)
+	binary.BigEndian.PutUint16(cmd[4:], p.id)
+	cmd[6] = p.incomingSessionID
+	cmd[7] = p.outgoingSessionID
+	binary.BigEndian.PutUint32(cmd[8:], p.mtu)
+	binary.BigEndian.PutUint32(cmd[12:], p.windowSize)
+	binary.BigEndian.PutUint32(cmd[16:], uint32(len(p.channels)))
+	binary.BigEndian.PutUint32(cmd[28:], throttleInterval)
+	binary.BigEndian.PutUint32(cmd[32:], throttleAccelerate)
+	binary.BigEndian.PutUint32(cmd[36:], throttleDecelerate)
+	binary.LittleEndian.PutUint32(cmd[40:], p.connectID) // Copied raw by ENet, never byte swapped
+	p.queueOutgoing(&outgoingCommand{cmd: cmd})
+
+	if proxy == nil {
+		h.wakeLoop()
+	} else {
+		p.proxy = proxyKey(proxy)
+		h.proxies[p.proxy] = true
+		go h.associate(proxy, p)
+	}
+	return p, nil
+}
+
+// hasRoom tells whether a peer behind proxy (nil for none) can be added
+func (h *Host) hasRoom(proxy *transport.Proxy) bool {
+	if proxy != nil && h.proxies[proxyKey(proxy)] {
+		return false
+	}
+	return h.peerCount() < h.cfg.Peers
+}
+
+func (h *Host) peerCount() int {
+	n := 0
+	for _, p := range h.peers {
+		if p != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// freeSlot returns a free peer ID, growing peers when needed. The caller
+// checks the limit first.
+func (h *Host) freeSlot() int {
+	for i, p := range h.peers {
+		if p == nil {
+			return i
+		}
+	}
+	h.peers = append(h.peers, nil)
+	return len(h.peers) - 1
+}
+
+// associate sets up p's SOCKS5 UDP association; the connection attempt
+// waits for it like the C host does
+func (h *Host) associate(proxy *transport.Proxy, p *Peer) {
+	relay, err := dialSOCKS5(proxy)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		log.Printf("[genet] SOCKS5 proxy %s:%d: %v", proxy.Host, proxy.Port, err)
+		if p.state != stateDisconnected {
+			h.removePeer(p)
+			h.pushEvent(&Event{Type: EventDisconnect, Peer: p})
+		}
+		return
+	}
+	if h.closed || p.state == stateDisconnected {
+		relay.close()
+		return
+	}
+	p.relay = relay
+	h.relays[relay.addr.String()] = relay
+	h.wakeLoop()
+}
+
+// Service waits up to timeout for the next event of the peers that don't
+// have a queue of their own. It returns nil, nil when there is none and an
+// error once the host is closed.
+func (h *Host) Service(timeout time.Duration) (*Event, error) {
+	return h.events.wait(timeout)
+}
+
+// Close drops every peer at once, telling them with an unsequenced
+// DISCONNECT, and closes the socket
+func (h *Host) Close() {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return
+	}
+	for _, p := range h.peers {
+		if p != nil {
+			p.disconnectNow()
+		}
+	}
+	h.closed = true
+	h.conn.Close()
+	h.mu.Unlock()
+
+	close(h.done)
+	h.events.close()
+}
+
+// Addr is the local address of the socket
+func (h *Host) Addr() net.Addr {
+	return h.conn.LocalAddr()
+}
+
+// Peers is the number of peers connected or connecting
+func (h *Host) Peers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.peerCount()
+}
+
+// now is the host clock in milliseconds, never 0 since 0 means unset
+func (h *Host) now() uint32 {
+	return uint32(time.Since(h.start).Milliseconds()) + 1
+}
+
+func (h *Host) wakeLoop() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pushEvent hands ev to the queue of its peer, or to the host's
+func (h *Host) pushEvent(ev *Event) {
+	if h.closed {
+		return
+	}
+	if ev.Peer != nil && ev.Peer.events != nil {
+		ev.Peer.events.push(ev)
+		return
+	}
+	h.events.push(ev)
+}
+
+func (h *Host) removePeer(p *Peer) {
+	p.state = stateDisconnected
+	p.resetQueues()
+	if int(p.id) < len(h.peers) && h.peers[p.id] == p {
+		h.peers[p.id] = nil
+	}
+	if p.relay != nil {
+		delete(h.relays, p.relay.addr.String())
+		p.relay.close()
+		p.relay = nil
+	}
+	if p.proxy != "" {
+		delete(h.proxies, p.proxy)
+		p.proxy = ""
+	}
+}
+
+// loop sends whatever is due and sleeps until the next resend or ping, or
+// until a Send or a received datagram wakes it
+func (h *Host) loop() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		select {
+		case <-h.done:
+			return
+		case <-h.wake:
+		case <-timer.C:
+		}
+		h.mu.Lock()
+		wait := h.flush()
+		h.mu.Unlock()
+		timer.Reset(wait)
+	}
+}
+
+// flush services every peer and returns how long the loop may sleep
+func (h *Host) flush() time.Duration {
+	if h.closed {
+		return time.Second
+	}
+	now := h.now()
+	wait := uint32(1000)
+	for _, p := range h.peers {
+		if p == nil {
+			continue
+		}
+		h.flushPeer(p, now, true)
+		if p.state == stateDisconnected {
+			continue
+		}
+		if len(p.sentReliable) > 0 {
+			wait = min(wait, p.nextTimeout-now)
+			if timeLess(p.nextTimeout, now) {
+				wait = 1
+			}
+		} else if p.state == stateConnected {
+			due := p.lastReceiveTime + pingInterval
+			if timeLess(now, due) {
+				wait = min(wait, due-now)
+			} else {
+				wait = 1
+			}
+		}
+	}
+	return time.Duration(max(wait, 1)) * time.Millisecond
+}
+
+func (h *Host) outgoingHeaderSize() int {
+	if h.cfg.NewPacket && !h.server {
+		return integritySize + 4
+	}
+	return 4
+}
+
+// flushPeer packs p's acknowledgements and queued commands into datagrams
+// and sends them, enet_protocol_send_outgoing_commands for one peer
+func (h *Host) flushPeer(p *Peer, now uint32, checkTimeouts bool) {
+	if p.proxy != "" && p.relay == nil {
+		return // Waiting for the SOCKS5 association
+	}
+	zombie := false
+	for more := true; more && !zombie && p.state != stateDisconnected && p.state != stateZombie; {
+		more = false
+		var cmds [][]byte
+		flags := uint16(0)
+		size := h.outgoingHeaderSize()
+		room := func(n int) bool {
+			return len(cmds) < maxPacketCommands && int(p.mtu)-size >= n
+		}
+		add := func(b ...[]byte) {
+			for _, part := range b {
+				cmds = append(cmds, part)
+				size += len(part)
+			}
+		}
+
+		// Acknowledgements first
+		for len(p.acks) > 0 {
+			if !room(commandSizes[cmdAcknowledge]) {
+				more = true
+				break
+			}
+			a := p.acks[0]
+			p.acks = p.acks[1:]
+			cmd := command(cmdAcknowledge, a.channel)
+			binary.BigEndian.PutUint16(cmd[2:], a.seq)
+			binary.BigEndian.PutUint16(cmd[4:], a.seq)
+			binary.BigEndian.PutUint16(cmd[6:], a.sentTime)
+			add(cmd)
+			if a.cmd&cmdMask == cmdDisconnect {
+				zombie = true // Gone once this acknowledgement is out
+			}
+		}
+
+		if checkTimeouts && len(p.sentReliable) > 0 && !timeLess(now, p.nextTimeout) && p.checkTimeouts(now) {
+			state := p.state
+			h.removePeer(p)
+			if state == stateConnecting || state >= stateConnected {
+				h.pushEvent(&Event{Type: EventDisconnect, Peer: p})
+			}
+			return
+		}
+
+		canPing := true
+		if len(p.outgoingReliable) > 0 {
+			canPing = h.packReliable(p, now, room, add, &flags, &more)
+		}
+		if canPing && len(p.sentReliable) == 0 && timeDifference(now, p.lastReceiveTime) >= pingInterval &&
+			room(commandSizes[cmdPing]) {
+			p.ping()
+			h.packReliable(p, now, room, add, &flags, &more)
+		}
+
+		// Unreliable commands go if they fit, or wait for the next datagram
+		for len(p.outgoingUnreliable) > 0 {
+			c := p.outgoingUnreliable[0]
+			if !room(len(c.cmd) + len(c.data)) {
+				more = true
+				break
+			}
+			p.outgoingUnreliable = p.outgoingUnreliable[1:]
+			add(c.cmd)
+			if len(c.data) > 0 {
+				add(c.data)
+			}
+		}
+		if p.state == stateDisconnectLater && p.idle() {
+			p.disconnect()
+		}
+
+		if len(cmds) == 0 {
+			break
+		}
+		h.send(p, now, flags, cmds)
+	}
+	if zombie || p.state == stateZombie {
+		h.removePeer(p)
+		h.pushEvent(&Event{Type: EventDisconnect, Peer: p})
+	}
+}
+
+// packReliable adds the reliable commands the windows allow. It returns
+// whether a ping may be added, i.e. nothing was sent.
+func (h *Host) packReliable(p *Peer, now uint32, room func(int) bool, add func(...[]byte), flags *uint16, more *bool) bool {
+	canPing := true
+	windowExceeded, windowWrap := false, false
+	kept := make([]*outgoingCommand, 0, len(p.outgoingReliable))
+	for i, c := range p.outgoingReliable {
+		ch := c.channel()
+		var channel *channel
+		if int(ch) < len(p.channels) {
+			channel = &p.channels[ch]
+		}
+		window := c.reliableSeq / reliableWindowSize
+		if channel != nil {
+			if !windowWrap && c.sendAttempts < 1 && c.reliableSeq%reliableWindowSize == 0 &&
+				(channel.reliableWindows[(window+reliableWindows-1)%reliableWindows] >= reliableWindowSize ||
+					channel.usedReliableWindows&((((1<<freeReliableWindows)-1)<<window)|
+						(((1<<freeReliableWindows)-1)>>(reliableWindows-window))) != 0) {
+				windowWrap = true
+			}
+			if windowWrap {
+				kept = append(kept, c)
+				continue
+			}
+		}
+		if c.data != nil {
+			if !windowExceeded && p.reliableInTransit+uint32(len(c.data)) > max(p.windowSize, p.mtu) {
+				windowExceeded = true
+			}
+			if windowExceeded {
+				kept = append(kept, c)
+				continue
+			}
+		}
+		canPing = false
+
+		if !room(len(c.cmd)) || (c.data != nil && !room(len(c.cmd)+len(c.data))) {
+			*more = true
+			kept = append(kept, p.outgoingReliable[i:]...)
+			break
+		}
+		if channel != nil && c.sendAttempts < 1 {
+			channel.usedReliableWindows |= 1 << window
+			channel.reliableWindows[window]++
+		}
+		c.sendAttempts++
+		if c.rtTimeout == 0 {
+			c.rtTimeout = p.roundTripTime + 4*p.roundTripVar
+			c.rtTimeoutMax = timeoutLimit * c.rtTimeout
+		}
+		if len(p.sentReliable) == 0 {
+			p.nextTimeout = now + c.rtTimeout
+		}
+		p.sentReliable = append(p.sentReliable, c)
+		c.sentTime = now
+		*flags |= headerFlagSentTime
+		add(c.cmd)
+		if c.data != nil {
+			add(c.data)
+			p.reliableInTransit += uint32(len(c.data))
+		}
+	}
+	p.outgoingReliable = kept
+	return canPing
+}
+
+// send writes one datagram: header, checksum, then the commands, range coded
+// when that makes them smaller
+func (h *Host) send(p *Peer, now uint32, flags uint16, cmds [][]byte) {
+	body := h.scratch[:0]
+	for _, c := range cmds {
+		body = append(body, c...)
+	}
+	h.scratch = body
+
+	var compressed []byte
+	if c := h.coder.compress(body, len(body)); c != nil && len(c) < len(body) {
+		compressed = c
+		flags |= headerFlagCompressed
+	}
+
+	header := make([]byte, 0, integritySize+4+checksumSize)
+	if h.cfg.NewPacket && !h.server {
+		header = header[:integritySize]
+		integrity(header, uint16(p.addr.Port))
+	}
+	peerID := p.outgoingPeerID | flags
+	if p.outgoingPeerID < maxPeerID {
+		peerID |= uint16(p.outgoingSessionID) << headerSessionShift
+	}
+	header = binary.BigEndian.AppendUint16(header, peerID)
+	if flags&headerFlagSentTime != 0 {
+		header = binary.BigEndian.AppendUint16(header, uint16(now))
+	}
+	var seed uint32
+	if p.outgoingPeerID < maxPeerID {
+		seed = p.connectID
+	}
+	header = binary.LittleEndian.AppendUint32(header, seed)
+	binary.BigEndian.PutUint32(header[len(header)-checksumSize:], checksum(header, body))
+
+	if compressed != nil {
+		body = compressed
+	}
+	datagram, to := append(header, body...), p.addr
+	if p.relay != nil {
+		datagram, to = p.relay.wrap(datagram, p.addr), p.relay.addr
+	}
+	if _, err := h.conn.WriteTo(datagram, to); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Printf("[genet] Send to %s failed: %v", to, err)
+	}
+}
+
+// read feeds the datagrams of conn to the host until conn is closed.
+// Datagrams from a SOCKS5 relay are unwrapped first.
+func (h *Host) read(conn net.PacketConn) {
+	buf := make([]byte, maxMTU+socks5HeaderSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue // ICMP errors and the like, ENet ignores them too
+		}
+		from, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		data := buf[:n]
+		h.mu.Lock()
+		relay := h.relays[from.String()]
+		if relay != nil {
+			data, from, ok = unwrapSOCKS5(data)
+		}
+		if ok {
+			h.receive(data, from, relay)
+		}
+		h.mu.Unlock()
+		h.wakeLoop()
+	}
+}
@@ -0,0 +1,205 @@
+package genet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// next waits for the next event of h, failing the test if none comes
+func next(t *testing.T, h *Host) *Event {
+	t.Helper()
+	ev, err := h.Service(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev == nil {
+		t.Fatal("no event")
+	}
+	return ev
+}
+
+// pair connects a client to a new server and returns both ends
+func pair(t *testing.T, cfg Config) (server, client *Host, sp, cp *Peer) {
+	t.Helper()
+	server, err := Listen("127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	port := server.Addr().(*net.UDPAddr).Port
+	client, cp, err = Dial("127.0.0.1", port, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+
+	if ev := next(t, client); ev.Type != EventConnect || ev.Peer != cp {
+		t.Fatalf("client event %+v", ev)
+	}
+	ev := next(t, server)
+	if ev.Type != EventConnect {
+		t.Fatalf("server event %+v", ev)
+	}
+	return server, client, ev.Peer, cp
+}
+
+func TestHostExchange(t *testing.T) {
+	for _, cfg := range []Config{Growtopia, {Channels: 2}} {
+		t.Run(map[bool]string{true: "NewPacket", false: "Plain"}[cfg.NewPacket], func(t *testing.T) {
+			server, client, sp, cp := pair(t, cfg)
+
+			big := make([]byte, 100_000) // About 75 fragments
+			for i := range big {
+				big[i] = byte(i * 7 / 3)
+			}
+			text := []byte(strings.Repeat("action|join_request\nname|START\n", 20)) // Compresses
+			sent := [][]byte{[]byte("hello"), big, text, {}}
+			for _, data := range sent {
+				if err := cp.Send(0, data, true); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i, want := range sent {
+				ev := next(t, server)
+				if ev.Type != EventReceive || ev.Peer != sp || !bytes.Equal(ev.Data, want) {
+					t.Fatalf("packet %d: got %v with %d bytes", i, ev.Type, len(ev.Data))
+				}
+			}
+
+			// An unreliable packet belongs after the last reliable one; sent
+			// before it in the same datagram, ENet would drop it
+			if err := sp.Send(1, []byte("reliable"), true); err != nil {
+				t.Fatal(err)
+			}
+			if err := sp.Send(1, []byte("unreliable"), false); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []string{"reliable", "unreliable"} {
+				if ev := next(t, client); ev.Type != EventReceive || ev.Channel != 1 || string(ev.Data) != want {
+					t.Fatalf("got %+v, want %q", ev, want)
+				}
+			}
+			if err := cp.Send(2, nil, true); err == nil {
+				t.Error("Send on a channel that wasn't negotiated succeeded")
+			}
+			if cp.RTT() <= 0 || cp.RTT() > defaultRoundTrip {
+				t.Errorf("RTT %d", cp.RTT())
+			}
+
+			cp.Disconnect()
+			if ev := next(t, server); ev.Type != EventDisconnect || ev.Peer != sp {
+				t.Fatalf("server event %+v", ev)
+			}
+			if ev := next(t, client); ev.Type != EventDisconnect || ev.Peer != cp {
+				t.Fatalf("client event %+v", ev)
+			}
+			if err := cp.Send(0, []byte("late"), true); err == nil {
+				t.Error("Send after disconnect succeeded")
+			}
+		})
+	}
+}
+
+func TestHostDisconnectLater(t *testing.T) {
+	server, client, sp, _ := pair(t, Growtopia)
+	for _, msg := range []string{"one", "two", "three"} {
+		sp.Send(0, []byte(msg), true)
+	}
+	sp.DisconnectLater()
+	for _, want := range []string{"one", "two", "three"} {
+		if ev := next(t, client); ev.Type != EventReceive || string(ev.Data) != want {
+			t.Fatalf("got %+v, want %q", ev, want)
+		}
+	}
+	if ev := next(t, client); ev.Type != EventDisconnect {
+		t.Fatalf("got %+v, want the disconnect", ev)
+	}
+	if ev := next(t, server); ev.Type != EventDisconnect {
+		t.Fatalf("server event %+v", ev)
+	}
+}
+
+func TestHostDisconnectNow(t *testing.T) {
+	server, client, sp, cp := pair(t, Growtopia)
+	cp.DisconnectNow()
+	if ev := next(t, server); ev.Type != EventDisconnect || ev.Peer != sp {
+		t.Fatalf("server event %+v", ev)
+	}
+	if ev, _ := client.Service(100 * time.Millisecond); ev != nil {
+		t.Fatalf("client got %+v after DisconnectNow", ev)
+	}
+}
+
+func TestHostTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the 5s minimum timeout")
+	}
+	server, client, _, cp := pair(t, Growtopia)
+	server.mu.Lock()
+	server.conn.Close() // Gone without a word, unlike Close
+	server.mu.Unlock()
+	// Resends double from the first timeout to 32 times it, so a short round
+	// trip gets the peer dropped right after the 5s minimum
+	client.mu.Lock()
+	cp.roundTripTime, cp.roundTripVar = 10, 0
+	client.mu.Unlock()
+	cp.Send(0, []byte("anyone?"), true)
+	ev, err := client.Service(2 * timeoutMinimum * time.Millisecond)
+	if err != nil || ev == nil || ev.Type != EventDisconnect {
+		t.Fatalf("got %+v, %v", ev, err)
+	}
+}
+
+func TestDialUnreachable(t *testing.T) {
+	// Nobody answers on the port of a socket that was just closed
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	client, _, err := Dial("127.0.0.1", port, nil, Growtopia)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if ev, _ := client.Service(200 * time.Millisecond); ev != nil {
+		t.Fatalf("got %+v from nobody", ev)
+	}
+}
+
+func TestIntegrity(t *testing.T) {
+	for _, port := range []uint16{0, 1, 17091, 0xFFFF} {
+		for range 100 {
+			var b [integritySize]byte
+			integrity(b[:], port)
+			rand1, check, magic := binary.BigEndian.Uint16(b[0:]), binary.BigEndian.Uint16(b[2:]), binary.BigEndian.Uint16(b[4:])
+			if rand1 > port || rand1^check != port || magic&^0x61D2 != 0x920D {
+				t.Fatalf("port %d: header % x", port, b)
+			}
+		}
+	}
+}
+
+// The client header in front of a server that doesn't expect it must not get
+// through
+func TestHeaderMismatch(t *testing.T) {
+	server, err := Listen("127.0.0.1:0", Config{Channels: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client, _, err := Dial("127.0.0.1", server.Addr().(*net.UDPAddr).Port, nil, Growtopia)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if ev, _ := server.Service(300 * time.Millisecond); ev != nil {
+		t.Fatalf("server got %+v", ev)
+	}
+}
@@ -0,0 +1,183 @@
+//go:build cgo
+
+// Package cenet builds the C ENet of network/enet from its single header so
+// the pure Go host can be tested against it on any platform with cgo. It only
+// wraps what the interop tests use.
+package cenet
+
+/*
+#cgo CFLAGS: -Wno-address-of-packed-member
+#cgo windows LDFLAGS: -lWs2_32 -lWinmm
+
+#define ENET_IMPLEMENTATION
+#include "../../../enet/enet.h"
+#include <stdlib.h>
+
+static ENetHost* cenet_host(const char* ip, int port, int peers, int newPacket) {
+	ENetAddress address;
+	ENetAddress* bind = NULL;
+	if (ip != NULL) {
+		enet_address_set_host_ip(&address, ip);
+		address.port = (enet_uint16)port;
+		bind = &address;
+	}
+	ENetHost* host = enet_host_create(bind, peers, 2, 0, 0);
+	if (host == NULL) return NULL;
+	host->checksum = enet_crc32;
+	host->usingNewPacket = newPacket;
+	enet_host_compress_with_range_coder(host);
+	return host;
+}
+
+static ENetPeer* cenet_connect(ENetHost* host, const char* ip, int port) {
+	ENetAddress address;
+	enet_address_set_host_ip(&address, ip);
+	address.port = (enet_uint16)port;
+	return enet_host_connect(host, &address, 2, 0);
+}
+
+static int cenet_send(ENetPeer* peer, int channel, const void* data, size_t size, int reliable) {
+	ENetPacket* packet = enet_packet_create(data, size, reliable ? ENET_PACKET_FLAG_RELIABLE : 0);
+	if (enet_peer_send(peer, (enet_uint8)channel, packet) < 0) {
+		enet_packet_destroy(packet);
+		return -1;
+	}
+	return 0;
+}
+
+static int cenet_port(ENetHost* host) {
+	return host->address.port;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+func init() {
+	C.enet_initialize()
+}
+
+// Event types, as in ENetEventType
+const (
+	EventNone       = C.ENET_EVENT_TYPE_NONE
+	EventConnect    = C.ENET_EVENT_TYPE_CONNECT
+	EventDisconnect = C.ENET_EVENT_TYPE_DISCONNECT
+	EventReceive    = C.ENET_EVENT_TYPE_RECEIVE
+)
+
+// Host is an ENetHost with the CRC32 checksum and the range coder on, like
+// every host of the game. Not safe for concurrent use.
+type Host struct {
+	host *C.ENetHost
+}
+
+// Peer is a peer of a Host
+type Peer struct {
+	peer *C.ENetPeer
+}
+
+// Event is one result of Service
+type Event struct {
+	Type    int
+	Peer    *Peer
+	Channel int
+	Data    []byte
+}
+
+// NewServer listens on 127.0.0.1:port, 0 for any port
+func NewServer(port int, newPacket bool) (*Host, error) {
+	ip := C.CString("127.0.0.1")
+	defer C.free(unsafe.Pointer(ip))
+	return newHost(ip, port, 32, newPacket)
+}
+
+// NewClient makes a host with a single peer for Connect
+func NewClient(newPacket bool) (*Host, error) {
+	return newHost(nil, 0, 1, newPacket)
+}
+
+func newHost(ip *C.char, port, peers int, newPacket bool) (*Host, error) {
+	np := C.int(0)
+	if newPacket {
+		np = 1
+	}
+	h := C.cenet_host(ip, C.int(port), C.int(peers), np)
+	if h == nil {
+		return nil, fmt.Errorf("enet_host_create failed")
+	}
+	return &Host{host: h}, nil
+}
+
+// Port is the port the host is bound to
+func (h *Host) Port() int {
+	return int(C.cenet_port(h.host))
+}
+
+// Connect starts connecting to ip:port
+func (h *Host) Connect(ip string, port int) (*Peer, error) {
+	cip := C.CString(ip)
+	defer C.free(unsafe.Pointer(cip))
+	p := C.cenet_connect(h.host, cip, C.int(port))
+	if p == nil {
+		return nil, fmt.Errorf("enet_host_connect failed")
+	}
+	return &Peer{peer: p}, nil
+}
+
+// Service runs enet_host_service for up to timeoutMs and returns the event,
+// nil if none came
+func (h *Host) Service(timeoutMs int) (*Event, error) {
+	var ev C.ENetEvent
+	rc := C.enet_host_service(h.host, &ev, C.enet_uint32(timeoutMs))
+	if rc < 0 {
+		return nil, fmt.Errorf("enet_host_service failed")
+	}
+	if rc == 0 {
+		return nil, nil
+	}
+	out := &Event{Type: int(ev._type), Peer: &Peer{peer: ev.peer}, Channel: int(ev.channelID)}
+	if ev.packet != nil {
+		out.Data = C.GoBytes(unsafe.Pointer(ev.packet.data), C.int(ev.packet.dataLength))
+		C.enet_packet_destroy(ev.packet)
+	}
+	return out, nil
+}
+
+// Flush sends everything queued without waiting for Service
+func (h *Host) Flush() {
+	C.enet_host_flush(h.host)
+}
+
+func (h *Host) Destroy() {
+	C.enet_host_destroy(h.host)
+}
+
+// Send queues data on channel
+func (p *Peer) Send(channel int, data []byte, reliable bool) error {
+	r := C.int(0)
+	if reliable {
+		r = 1
+	}
+	var ptr unsafe.Pointer
+	if len(data) > 0 {
+		ptr = C.CBytes(data)
+		defer C.free(ptr)
+	}
+	if C.cenet_send(p.peer, C.int(channel), ptr, C.size_t(len(data)), r) < 0 {
+		return fmt.Errorf("enet_peer_send failed")
+	}
+	return nil
+}
+
+// Disconnect asks the remote end to disconnect
+func (p *Peer) Disconnect() {
+	C.enet_peer_disconnect(p.peer, 0)
+}
+
+// Same tells whether p and o are the same ENetPeer
+func (p *Peer) Same(o *Peer) bool {
+	return p.peer == o.peer
+}
@@ -0,0 +1,160 @@
+//go:build cgo
+
+package genet
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"vortenixgo/network/genet/internal/cenet"
+)
+
+// cnext services the C host until it has an event, failing the test if none
+// comes
+func cnext(t *testing.T, h *cenet.Host) *cenet.Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		ev, err := h.Service(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev != nil {
+			return ev
+		}
+	}
+	t.Fatal("no event from the C host")
+	return nil
+}
+
+// interopPackets are sent both ways: a small one, one split into fragments
+// and one the range coder shrinks
+func interopPackets() [][]byte {
+	big := make([]byte, 100_000)
+	for i := range big {
+		big[i] = byte(i * 7 / 3)
+	}
+	text := []byte(strings.Repeat("action|join_request\nname|START\n", 20))
+	return [][]byte{[]byte("hello"), big, text}
+}
+
+// The game client side: a C client with the new packet header against a Go
+// server
+func TestInteropCClient(t *testing.T) {
+	server, err := Listen("127.0.0.1:0", Growtopia)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client, err := cenet.NewClient(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Destroy()
+	cp, err := client.Connect("127.0.0.1", server.Addr().(*net.UDPAddr).Port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ev := cnext(t, client); ev.Type != cenet.EventConnect {
+		t.Fatalf("C client event %+v", ev)
+	}
+	client.Flush() // Service returns the event before acknowledging the VERIFY_CONNECT
+	ev := next(t, server)
+	if ev.Type != EventConnect {
+		t.Fatalf("server event %+v", ev)
+	}
+	sp := ev.Peer
+
+	packets := interopPackets()
+	for _, data := range packets {
+		if err := cp.Send(0, data, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := sp.Send(1, data, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.Flush()
+	received := 0
+	for i, want := range packets {
+		// The C host only takes in and acknowledges packets while serviced
+		for received <= i {
+			ev := cnext(t, client)
+			if ev.Type != cenet.EventReceive || ev.Channel != 1 || !bytes.Equal(ev.Data, packets[received]) {
+				t.Fatalf("C client packet %d: type %d, %d bytes", received, ev.Type, len(ev.Data))
+			}
+			received++
+		}
+		ev := next(t, server)
+		if ev.Type != EventReceive || ev.Channel != 0 || !bytes.Equal(ev.Data, want) {
+			t.Fatalf("server packet %d: %v with %d bytes", i, ev.Type, len(ev.Data))
+		}
+	}
+
+	cp.Disconnect()
+	if ev := cnext(t, client); ev.Type != cenet.EventDisconnect {
+		t.Fatalf("C client event %+v", ev)
+	}
+	if ev := next(t, server); ev.Type != EventDisconnect || ev.Peer != sp {
+		t.Fatalf("server event %+v", ev)
+	}
+}
+
+// A plain Go client against a C server; the C host can't read the new
+// packet header, so the server side of the game can't be tested this way
+func TestInteropCServer(t *testing.T) {
+	server, err := cenet.NewServer(0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Destroy()
+	client, cp, err := Dial("127.0.0.1", server.Port(), nil, Config{Channels: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ev := cnext(t, server)
+	if ev.Type != cenet.EventConnect {
+		t.Fatalf("C server event %+v", ev)
+	}
+	sp := ev.Peer
+	if ev := next(t, client); ev.Type != EventConnect || ev.Peer != cp {
+		t.Fatalf("client event %+v", ev)
+	}
+
+	packets := interopPackets()
+	for _, data := range packets {
+		if err := cp.Send(1, data, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := sp.Send(0, data, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, want := range packets {
+		ev := cnext(t, server)
+		if ev.Type != cenet.EventReceive || ev.Channel != 1 || !bytes.Equal(ev.Data, want) {
+			t.Fatalf("C server packet %d: type %d, %d bytes", i, ev.Type, len(ev.Data))
+		}
+	}
+	for i, want := range packets {
+		ev := next(t, client)
+		if ev.Type != EventReceive || ev.Channel != 0 || !bytes.Equal(ev.Data, want) {
+			t.Fatalf("client packet %d: %v with %d bytes", i, ev.Type, len(ev.Data))
+		}
+	}
+
+	cp.Disconnect()
+	if ev := cnext(t, server); ev.Type != cenet.EventDisconnect || !ev.Peer.Same(sp) {
+		t.Fatalf("C server event %+v", ev)
+	}
+	server.Flush() // The acknowledgement of the DISCONNECT
+	if ev := next(t, client); ev.Type != EventDisconnect || ev.Peer != cp {
+		t.Fatalf("client event %+v", ev)
+	}
+}
@@ -0,0 +1,476 @@
+package genet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+type peerState int
+
+const (
+	stateDisconnected peerState = iota
+	stateConnecting
+	stateAcknowledgingConnect
+	stateConnected
+	stateDisconnectLater
+	stateDisconnecting
+	stateAcknowledgingDisconnect
+	stateZombie // Disconnected, the event is still to be reported
+)
+
+// outgoingCommand is a command waiting to be sent or acknowledged
+type outgoingCommand struct {
+	cmd           []byte // Encoded fixed part, sequence numbers filled in
+	data          []byte // Payload, or this fragment of it
+	reliableSeq   uint16
+	unreliableSeq uint16
+	sendAttempts  int
+	sentTime      uint32
+	rtTimeout     uint32
+	rtTimeoutMax  uint32
+}
+
+func (c *outgoingCommand) number() uint8  { return c.cmd[0] & cmdMask }
+func (c *outgoingCommand) channel() uint8 { return c.cmd[1] }
+
+// incomingCommand is a received packet waiting for its turn, or for the rest
+// of its fragments
+type incomingCommand struct {
+	reliableSeq   uint16
+	unreliableSeq uint16
+	fragment      bool
+	data          []byte
+	fragmentCount uint32
+	fragmentsLeft uint32
+	fragments     []uint32
+}
+
+type acknowledgement struct {
+	cmd      uint8
+	channel  uint8
+	seq      uint16
+	sentTime uint16
+}
+
+type channel struct {
+	outgoingReliableSeq   uint16
+	outgoingUnreliableSeq uint16
+	incomingReliableSeq   uint16
+	incomingUnreliableSeq uint16
+	usedReliableWindows   uint16
+	reliableWindows       [reliableWindows]uint16
+	reliable              []*incomingCommand // In sequence order
+	unreliable            []*incomingCommand // In arrival order
+}
+
+// Peer is one connection of a Host. Its methods may be called from any
+// goroutine.
+type Peer struct {
+	host   *Host
+	id     uint16 // Index in host.peers, the peer ID the remote end sends to
+	addr   *net.UDPAddr
+	state  peerState
+	events *eventQueue  // Where the peer's events go, nil for the host's queue
+	proxy  string       // Proxy endpoint (proxyKey), empty when direct
+	relay  *socks5Relay // The proxy's association, nil until it's set up
+
+	outgoingPeerID      uint16
+	connectID           uint32
+	incomingSessionID   uint8
+	outgoingSessionID   uint8
+	mtu                 uint32
+	windowSize          uint32
+	channels            []channel
+	data                uint32 // Disconnect data, kept for DisconnectLater
+	outgoingReliableSeq uint16 // Of the connection commands on channel 0xFF
+	outgoingUnseqGroup  uint16
+	incomingUnseqGroup  uint16
+	unsequencedWindow   [unsequencedWindowSize / 32]uint32
+
+	outgoingReliable   []*outgoingCommand
+	outgoingUnreliable []*outgoingCommand
+	sentReliable       []*outgoingCommand
+	acks               []acknowledgement
+	reliableInTransit  uint32
+
+	roundTripTime   uint32
+	roundTripVar    uint32
+	lastReceiveTime uint32
+	earliestTimeout uint32
+	nextTimeout     uint32
+}
+
+func newPeer(h *Host, id uint16, addr *net.UDPAddr) *Peer {
+	return &Peer{
+		host:              h,
+		id:                id,
+		addr:              addr,
+		outgoingPeerID:    maxPeerID,
+		incomingSessionID: 0xFF,
+		outgoingSessionID: 0xFF,
+		mtu:               defaultMTU,
+		windowSize:        maxWindowSize,
+		roundTripTime:     defaultRoundTrip,
+	}
+}
+
+// RemoteIP and RemotePort give the address the peer was connected to
+func (p *Peer) RemoteIP() string {
+	return p.addr.IP.String()
+}
+
+func (p *Peer) RemotePort() int {
+	return p.addr.Port
+}
+
+// RTT is the smoothed round trip time in milliseconds
+func (p *Peer) RTT() int {
+	p.host.mu.Lock()
+	defer p.host.mu.Unlock()
+	return int(p.roundTripTime)
+}
+
+// Send queues data on channel, reliable or not. Packets too big for one
+// datagram always go as reliable fragments, like in ENet.
+func (p *Peer) Send(ch uint8, data []byte, reliable bool) error {
+	h := p.host
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if p.state != stateConnected {
+		return fmt.Errorf("peer not connected")
+	}
+	if int(ch) >= len(p.channels) {
+		return fmt.Errorf("no channel %d", ch)
+	}
+	if len(data) > h.cfg.MaxPacketSize {
+		return fmt.Errorf("packet of %d bytes over the %d limit", len(data), h.cfg.MaxPacketSize)
+	}
+	data = append([]byte(nil), data...)
+
+	fragmentLength := int(p.mtu) - h.outgoingHeaderSize() - fragmentCmdSize - checksumSize
+	if len(data) > fragmentLength {
+		count := (len(data) + fragmentLength - 1) / fragmentLength
+		if count > maxFragmentCount {
+			return fmt.Errorf("packet of %d bytes needs too many fragments", len(data))
+		}
+		startSeq := p.channels[ch].outgoingReliableSeq + 1
+		for number, offset := 0, 0; offset < len(data); number, offset = number+1, offset+fragmentLength {
+			end := min(offset+fragmentLength, len(data))
+			cmd := command(cmdSendFragment|flagAcknowledge, ch)
+			binary.BigEndian.PutUint16(cmd[4:], startSeq)
+			binary.BigEndian.PutUint16(cmd[6:], uint16(end-offset))
+			binary.BigEndian.PutUint32(cmd[8:], uint32(count))
+			binary.BigEndian.PutUint32(cmd[12:], uint32(number))
+			binary.BigEndian.PutUint32(cmd[16:], uint32(len(data)))
+			binary.BigEndian.PutUint32(cmd[20:], uint32(offset))
+			p.queueOutgoing(&outgoingCommand{cmd: cmd, data: data[offset:end]})
+		}
+	} else if reliable {
+		cmd := command(cmdSendReliable|flagAcknowledge, ch)
+		binary.BigEndian.PutUint16(cmd[4:], uint16(len(data)))
+		p.queueOutgoing(&outgoingCommand{cmd: cmd, data: data})
+	} else {
+		cmd := command(cmdSendUnreliable, ch)
+		binary.BigEndian.PutUint16(cmd[6:], uint16(len(data)))
+		p.queueOutgoing(&outgoingCommand{cmd: cmd, data: data})
+	}
+	h.wakeLoop()
+	return nil
+}
+
+// Disconnect asks the remote end to disconnect. Queued packets are dropped;
+// the EventDisconnect comes once the request is acknowledged or times out.
+func (p *Peer) Disconnect() {
+	p.host.mu.Lock()
+	defer p.host.mu.Unlock()
+	p.disconnect()
+	p.host.wakeLoop()
+}
+
+// DisconnectLater disconnects once everything queued has been delivered
+func (p *Peer) DisconnectLater() {
+	p.host.mu.Lock()
+	defer p.host.mu.Unlock()
+	if (p.state == stateConnected || p.state == stateDisconnectLater) && !p.idle() {
+		p.state = stateDisconnectLater
+		return
+	}
+	p.disconnect()
+	p.host.wakeLoop()
+}
+
+// DisconnectNow tells the remote end once, without waiting for an answer,
+// and drops the peer. No EventDisconnect is generated.
+func (p *Peer) DisconnectNow() {
+	p.host.mu.Lock()
+	defer p.host.mu.Unlock()
+	p.disconnectNow()
+}
+
+func (p *Peer) disconnect() {
+	switch p.state {
+	case stateDisconnecting, stateDisconnected, stateAcknowledgingDisconnect, stateZombie:
+		return
+	}
+	p.resetQueues()
+	connected := p.state == stateConnected || p.state == stateDisconnectLater
+	flags := uint8(flagUnsequenced)
+	if connected {
+		flags = flagAcknowledge
+	}
+	cmd := command(cmdDisconnect|flags, 0xFF)
+	binary.BigEndian.PutUint32(cmd[4:], p.data)
+	p.queueOutgoing(&outgoingCommand{cmd: cmd})
+	if connected {
+		p.state = stateDisconnecting
+		return
+	}
+	p.host.flushPeer(p, p.host.now(), false)
+	p.host.removePeer(p)
+}
+
+func (p *Peer) disconnectNow() {
+	if p.state == stateDisconnected {
+		return
+	}
+	if p.state != stateZombie && p.state != stateDisconnecting {
+		p.resetQueues()
+		cmd := command(cmdDisconnect|flagUnsequenced, 0xFF)
+		p.queueOutgoing(&outgoingCommand{cmd: cmd})
+		p.host.flushPeer(p, p.host.now(), false)
+	}
+	p.host.removePeer(p)
+}
+
+func (p *Peer) idle() bool {
+	return len(p.outgoingReliable) == 0 && len(p.outgoingUnreliable) == 0 && len(p.sentReliable) == 0
+}
+
+func (p *Peer) resetQueues() {
+	p.outgoingReliable = nil
+	p.outgoingUnreliable = nil
+	p.sentReliable = nil
+	p.acks = nil
+	p.reliableInTransit = 0
+	for i := range p.channels {
+		p.channels[i].reliable = nil
+		p.channels[i].unreliable = nil
+	}
+}
+
+// queueOutgoing numbers a command and queues it, enet_peer_setup_outgoing_command
+func (p *Peer) queueOutgoing(c *outgoingCommand) {
+	ch := c.channel()
+	switch {
+	case ch == 0xFF:
+		p.outgoingReliableSeq++
+		c.reliableSeq = p.outgoingReliableSeq
+	case c.cmd[0]&flagAcknowledge != 0:
+		channel := &p.channels[ch]
+		channel.outgoingReliableSeq++
+		channel.outgoingUnreliableSeq = 0
+		c.reliableSeq = channel.outgoingReliableSeq
+	case c.cmd[0]&flagUnsequenced != 0:
+		p.outgoingUnseqGroup++
+	default:
+		channel := &p.channels[ch]
+		channel.outgoingUnreliableSeq++
+		c.reliableSeq = channel.outgoingReliableSeq
+		c.unreliableSeq = channel.outgoingUnreliableSeq
+	}
+	binary.BigEndian.PutUint16(c.cmd[2:], c.reliableSeq)
+	switch c.number() {
+	case cmdSendUnreliable:
+		binary.BigEndian.PutUint16(c.cmd[4:], c.unreliableSeq)
+	case cmdSendUnsequenced:
+		binary.BigEndian.PutUint16(c.cmd[4:], p.outgoingUnseqGroup)
+	}
+
+	if c.cmd[0]&flagAcknowledge != 0 {
+		p.outgoingReliable = append(p.outgoingReliable, c)
+	} else {
+		p.outgoingUnreliable = append(p.outgoingUnreliable, c)
+	}
+}
+
+func (p *Peer) ping() {
+	if p.state != stateConnected {
+		return
+	}
+	p.queueOutgoing(&outgoingCommand{cmd: command(cmdPing|flagAcknowledge, 0xFF)})
+}
+
+// removeSentReliable drops an acknowledged command and returns its number,
+// or 0 when it wasn't outstanding
+func (p *Peer) removeSentReliable(seq uint16, ch uint8) uint8 {
+	var c *outgoingCommand
+	for i, sent := range p.sentReliable {
+		if sent.reliableSeq == seq && sent.channel() == ch {
+			c = sent
+			p.sentReliable = append(p.sentReliable[:i], p.sentReliable[i+1:]...)
+			break
+		}
+	}
+	wasSent := c != nil
+	if c == nil {
+		// Possibly already queued again for a resend
+		for i, out := range p.outgoingReliable {
+			if out.sendAttempts < 1 {
+				return 0
+			}
+			if out.reliableSeq == seq && out.channel() == ch {
+				c = out
+				p.outgoingReliable = append(p.outgoingReliable[:i], p.outgoingReliable[i+1:]...)
+				break
+			}
+		}
+		if c == nil {
+			return 0
+		}
+	}
+
+	if int(ch) < len(p.channels) {
+		channel := &p.channels[ch]
+		window := seq / reliableWindowSize
+		if channel.reliableWindows[window] > 0 {
+			channel.reliableWindows[window]--
+			if channel.reliableWindows[window] == 0 {
+				channel.usedReliableWindows &^= 1 << window
+			}
+		}
+	}
+	if wasSent && c.data != nil {
+		p.reliableInTransit -= uint32(len(c.data))
+	}
+	if len(p.sentReliable) > 0 {
+		first := p.sentReliable[0]
+		p.nextTimeout = first.sentTime + first.rtTimeout
+	}
+	return c.number()
+}
+
+// checkTimeouts resends what wasn't acknowledged in time and reports whether
+// the peer timed out
+func (p *Peer) checkTimeouts(now uint32) bool {
+	var resend []*outgoingCommand
+	kept := p.sentReliable[:0]
+	for _, c := range p.sentReliable {
+		if timeDifference(now, c.sentTime) < c.rtTimeout {
+			kept = append(kept, c)
+			continue
+		}
+		if p.earliestTimeout == 0 || timeLess(c.sentTime, p.earliestTimeout) {
+			p.earliestTimeout = c.sentTime
+		}
+		if p.earliestTimeout != 0 &&
+			(timeDifference(now, p.earliestTimeout) >= timeoutMaximum ||
+				(c.rtTimeout >= c.rtTimeoutMax && timeDifference(now, p.earliestTimeout) >= timeoutMinimum)) {
+			return true
+		}
+		if c.data != nil {
+			p.reliableInTransit -= uint32(len(c.data))
+		}
+		c.rtTimeout *= 2
+		resend = append(resend, c)
+	}
+	p.sentReliable = kept
+	if len(resend) > 0 {
+		p.outgoingReliable = append(resend, p.outgoingReliable...)
+	}
+	if len(p.sentReliable) > 0 {
+		first := p.sentReliable[0]
+		p.nextTimeout = first.sentTime + first.rtTimeout
+	}
+	return false
+}
+
+// updateRoundTrip folds an acknowledgement's round trip into the estimate
+func (p *Peer) updateRoundTrip(sample uint32) {
+	p.roundTripVar -= p.roundTripVar / 4
+	if sample >= p.roundTripTime {
+		p.roundTripTime += (sample - p.roundTripTime) / 8
+		p.roundTripVar += (sample - p.roundTripTime) / 4
+	} else {
+		p.roundTripTime -= (p.roundTripTime - sample) / 8
+		p.roundTripVar += (p.roundTripTime - sample) / 4
+	}
+}
+
+// inReliableWindow tells whether seq may be queued on channel, the window
+// test of enet_peer_queue_incoming_command
+func (c *channel) inReliableWindow(seq uint16) bool {
+	window := seq / reliableWindowSize
+	current := c.incomingReliableSeq / reliableWindowSize
+	if seq < c.incomingReliableSeq {
+		window += reliableWindows
+	}
+	return window >= current && window < current+freeReliableWindows-1
+}
+
+// queueReliable files a reliable command (or a fragmented packet's start)
+// in sequence order. It returns the queued command, which is existing when
+// the sequence number was already there, or nil for a duplicate.
+func (c *channel) queueReliable(in *incomingCommand) (queued *incomingCommand) {
+	if in.reliableSeq == c.incomingReliableSeq {
+		return nil
+	}
+	distance := func(seq uint16) uint16 { return seq - c.incomingReliableSeq }
+	d := distance(in.reliableSeq)
+	i := len(c.reliable)
+	for i > 0 && distance(c.reliable[i-1].reliableSeq) >= d {
+		if c.reliable[i-1].reliableSeq == in.reliableSeq {
+			return c.reliable[i-1]
+		}
+		i--
+	}
+	c.reliable = append(c.reliable, nil)
+	copy(c.reliable[i+1:], c.reliable[i:])
+	c.reliable[i] = in
+	return in
+}
+
+// dispatchReliable hands over the packets that are now in order
+func (p *Peer) dispatchReliable(ch uint8) {
+	c := &p.channels[ch]
+	n := 0
+	for _, in := range c.reliable {
+		if in.fragmentsLeft > 0 || in.reliableSeq != c.incomingReliableSeq+1 {
+			break
+		}
+		c.incomingReliableSeq = in.reliableSeq
+		if in.fragmentCount > 0 {
+			c.incomingReliableSeq += uint16(in.fragmentCount - 1)
+		}
+		p.host.pushEvent(&Event{Type: EventReceive, Peer: p, Channel: ch, Data: in.data})
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	c.reliable = c.reliable[n:]
+	c.incomingUnreliableSeq = 0
+	p.dispatchUnreliable(ch)
+}
+
+// dispatchUnreliable hands over the unreliable packets sent after the last
+// delivered reliable one and drops the ones that can no longer be delivered
+func (p *Peer) dispatchUnreliable(ch uint8) {
+	c := &p.channels[ch]
+	kept := c.unreliable[:0]
+	for _, in := range c.unreliable {
+		switch {
+		case in.reliableSeq == c.incomingReliableSeq:
+			if in.fragmentsLeft > 0 {
+				kept = append(kept, in)
+			} else if in.unreliableSeq > c.incomingUnreliableSeq {
+				c.incomingUnreliableSeq = in.unreliableSeq
+				p.host.pushEvent(&Event{Type: EventReceive, Peer: p, Channel: ch, Data: in.data})
+			}
+		case c.inReliableWindow(in.reliableSeq):
+			kept = append(kept, in) // Waits for its reliable packet
+		}
+	}
+	clear(c.unreliable[len(kept):])
+	c.unreliable = kept
+}
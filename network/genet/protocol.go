@@ -0,0 +1,113 @@
+package genet
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math/rand"
+)
+
+// Wire constants, named after their ENet counterparts
+const (
+	cmdAcknowledge            = 1
+	cmdConnect                = 2
+	cmdVerifyConnect          = 3
+	cmdDisconnect             = 4
+	cmdPing                   = 5
+	cmdSendReliable           = 6
+	cmdSendUnreliable         = 7
+	cmdSendFragment           = 8
+	cmdSendUnsequenced        = 9
+	cmdBandwidthLimit         = 10
+	cmdThrottleConfigure      = 11
+	cmdSendUnreliableFragment = 12
+	cmdCount                  = 13
+	cmdMask                   = 0x0F
+
+	flagAcknowledge = 1 << 7
+	flagUnsequenced = 1 << 6
+
+	headerFlagCompressed = 1 << 14
+	headerFlagSentTime   = 1 << 15
+	headerFlagMask       = headerFlagCompressed | headerFlagSentTime
+	headerSessionMask    = 3 << 12
+	headerSessionShift   = 12
+
+	integritySize    = 6 // The Growtopia header's extra words, before the peer ID
+	checksumSize     = 4
+	fragmentCmdSize  = 24
+	socks5HeaderSize = 10
+
+	maxPeerID         = 0xFFF
+	minMTU            = 576
+	maxMTU            = 4096
+	minWindowSize     = 4096
+	maxWindowSize     = 65536
+	minChannelCount   = 1
+	maxChannelCount   = 255
+	maxPacketCommands = 32
+	maxFragmentCount  = 1024 * 1024
+
+	defaultMTU         = 1400
+	defaultRoundTrip   = 500
+	pingInterval       = 500
+	timeoutLimit       = 32
+	timeoutMinimum     = 5000
+	timeoutMaximum     = 30000
+	throttleInterval   = 5000
+	throttleAccelerate = 2
+	throttleDecelerate = 2
+
+	reliableWindows       = 16
+	reliableWindowSize    = 0x1000
+	freeReliableWindows   = 8
+	unsequencedWindowSize = 1024
+	unsequencedWindows    = 64
+	maxPendingUnreliable  = 256 // Unreliable packets held for a missing reliable one
+)
+
+// commandSizes is the fixed part of every command, payload not included
+var commandSizes = [cmdCount]int{0, 8, 48, 44, 8, 4, 6, 8, 24, 8, 12, 16, 24}
+
+// timeLess and timeDifference compare millisecond clocks that wrap, like
+// ENET_TIME_LESS and ENET_TIME_DIFFERENCE
+func timeLess(a, b uint32) bool {
+	return a-b >= 86400000
+}
+
+func timeDifference(a, b uint32) uint32 {
+	if a-b >= 86400000 {
+		return b - a
+	}
+	return a - b
+}
+
+// integrity fills the three words the game client puts in front of the ENet
+// header. The server checks them against the port it was reached on.
+func integrity(b []byte, port uint16) {
+	rand1 := uint16(rand.Int31() % (int32(port) + 1))
+	rand2 := uint16(rand.Int31())
+	binary.BigEndian.PutUint16(b[0:], rand1)
+	binary.BigEndian.PutUint16(b[2:], rand1^port)
+	binary.BigEndian.PutUint16(b[4:], rand2&0x61D2|0x920D)
+}
+
+// checksum is enet_crc32 over the header and the uncompressed commands. The
+// checksum field itself must hold the connect ID (or 0) while it's computed.
+func checksum(parts ...[]byte) uint32 {
+	var crc uint32
+	for _, p := range parts {
+		crc = crc32.Update(crc, crc32.IEEETable, p)
+	}
+	return crc
+}
+
+// command starts a command of the given number with its fixed size
+func command(cmd, channel uint8) []byte {
+	b := make([]byte, commandSizes[cmd&cmdMask])
+	b[0] = cmd
+	b[1] = channel
+	return b
+}
+
+func be16(b []byte) uint16 { return binary.BigEndian.Uint16(b) }
+func be32(b []byte) uint32 { return binary.BigEndian.Uint32(b) }
@@ -0,0 +1,432 @@
+package genet
+
+// A port of ENet's adaptive range coder (enet_range_coder_compress and
+// enet_range_coder_decompress), the compressor the game enables with
+// enet_host_compress_with_range_coder. The model has to evolve exactly like the
+// C one, so the arithmetic keeps the C types: the symbol fields wrap as
+// uint8/uint16 and the few spots that C computes in int use int here too.
+
+const (
+	rangeCoderTop    = 1 << 24
+	rangeCoderBottom = 1 << 16
+
+	contextSymbolDelta   = 3
+	contextSymbolMinimum = 1
+	contextEscapeMinimum = 1
+
+	subcontextOrder       = 2
+	subcontextSymbolDelta = 2
+	subcontextEscapeDelta = 5
+
+	rangeCoderSymbols = 4096
+)
+
+// symbol is both a node of a context's binary tree (value, count, under,
+// left, right, offsets to the children) and a context of its own (symbols,
+// escapes, total). parent links a symbol to the context one order down.
+type symbol struct {
+	value   uint8
+	count   uint8
+	under   uint16
+	left    uint16
+	right   uint16
+	symbols uint16
+	escapes uint16
+	total   uint16
+	parent  uint16
+}
+
+// rangeCoder is the model shared by every packet of a host. Compress and
+// decompress rebuild it from scratch, the table is only kept to save the
+// allocation.
+type rangeCoder struct {
+	symbols [rangeCoderSymbols]symbol
+	next    uint16
+}
+
+func (rc *rangeCoder) create(value uint8, count uint16) uint16 {
+	i := rc.next
+	rc.next++
+	rc.symbols[i] = symbol{value: value, count: uint8(count), under: count}
+	return i
+}
+
+// createContext makes the root context, always at index 0
+func (rc *rangeCoder) createContext(escapes, minimum uint16) uint16 {
+	i := rc.create(0, 0)
+	c := &rc.symbols[i]
+	c.escapes = escapes
+	c.total = escapes + 256*minimum
+	c.symbols = 0
+	return i
+}
+
+func (rc *rangeCoder) reset() {
+	rc.next = 0
+	rc.createContext(contextEscapeMinimum, contextSymbolMinimum)
+}
+
+func (rc *rangeCoder) rescaleSymbols(i uint16) uint16 {
+	var total uint16
+	for {
+		s := &rc.symbols[i]
+		s.count -= s.count >> 1
+		s.under = uint16(s.count)
+		if s.left != 0 {
+			s.under += rc.rescaleSymbols(i + s.left)
+		}
+		total += s.under
+		if s.right == 0 {
+			break
+		}
+		i += s.right
+	}
+	return total
+}
+
+func (rc *rangeCoder) rescale(ctx, minimum uint16) {
+	c := &rc.symbols[ctx]
+	if c.symbols != 0 {
+		c.total = rc.rescaleSymbols(ctx + c.symbols)
+	} else {
+		c.total = 0
+	}
+	c.escapes -= c.escapes >> 1
+	c.total += c.escapes + 256*minimum
+}
+
+// encodeSymbol finds value in ctx, adding it when missing. count is 0 for a
+// new symbol of a subcontext, which is then coded as an escape.
+func (rc *rangeCoder) encodeSymbol(ctx uint16, value uint8, update, minimum uint16) (sym, under, count uint16) {
+	under = uint16(value) * minimum
+	count = minimum
+	c := &rc.symbols[ctx]
+	if c.symbols == 0 {
+		sym = rc.create(value, update)
+		c.symbols = sym - ctx
+		return
+	}
+	node := ctx + c.symbols
+	for {
+		n := &rc.symbols[node]
+		switch {
+		case value < n.value:
+			n.under += update
+			if n.left != 0 {
+				node += n.left
+				continue
+			}
+			sym = rc.create(value, update)
+			n.left = sym - node
+		case value > n.value:
+			under += n.under
+			if n.right != 0 {
+				node += n.right
+				continue
+			}
+			sym = rc.create(value, update)
+			n.right = sym - node
+		default:
+			count += uint16(n.count)
+			under += n.under - uint16(n.count)
+			n.under += update
+			n.count += uint8(update)
+			sym = node
+		}
+		return
+	}
+}
+
+// decodeSymbol finds the symbol code falls in. Only the root context
+// (minimum > 0) can create one, a subcontext miss means corrupt input.
+func (rc *rangeCoder) decodeSymbol(ctx, code, update, minimum uint16) (sym uint16, value uint8, under, count uint16, ok bool) {
+	count = minimum
+	c := &rc.symbols[ctx]
+	if c.symbols == 0 {
+		if minimum == 0 {
+			return
+		}
+		value = uint8(code / minimum)
+		under = code - code%minimum
+		sym = rc.create(value, update)
+		c.symbols = sym - ctx
+		return sym, value, under, count, true
+	}
+	node := ctx + c.symbols
+	for {
+		n := &rc.symbols[node]
+		after := uint16(int(under) + int(n.under) + (int(n.value)+1)*int(minimum))
+		before := uint16(n.count) + minimum
+		switch {
+		case code >= after:
+			under += n.under
+			if n.right != 0 {
+				node += n.right
+				continue
+			}
+			if minimum == 0 {
+				return
+			}
+			value = uint8(int(n.value) + 1 + int(code-after)/int(minimum))
+			under = code - (code-after)%minimum
+			sym = rc.create(value, update)
+			n.right = sym - node
+		case int(code) < int(after)-int(before):
+			n.under += update
+			if n.left != 0 {
+				node += n.left
+				continue
+			}
+			if minimum == 0 {
+				return
+			}
+			d := int(after) - int(before) - int(code) - 1
+			value = uint8(int(n.value) - 1 - d/int(minimum))
+			under = uint16(int(code) - d%int(minimum))
+			sym = rc.create(value, update)
+			n.left = sym - node
+		default:
+			value = n.value
+			count += uint16(n.count)
+			under = uint16(int(after) - int(before))
+			n.under += update
+			n.count += uint8(update)
+			sym = node
+		}
+		return sym, value, under, count, true
+	}
+}
+
+// compress codes in and returns nil when the result would exceed limit bytes
+func (rc *rangeCoder) compress(in []byte, limit int) []byte {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]byte, 0, limit)
+	var low uint32
+	rng := ^uint32(0)
+	encode := func(under, count, total uint32) bool {
+		rng /= total
+		low += under * rng
+		rng *= count
+		for {
+			if low^(low+rng) >= rangeCoderTop {
+				if rng >= rangeCoderBottom {
+					break
+				}
+				rng = -low & (rangeCoderBottom - 1)
+			}
+			if len(out) >= limit {
+				return false
+			}
+			out = append(out, byte(low>>24))
+			rng <<= 8
+			low <<= 8
+		}
+		return true
+	}
+
+	rc.reset()
+	var predicted uint16
+	order := 0
+	for _, value := range in {
+		parent := &predicted
+		found := false
+		for sub := predicted; sub != 0; sub = rc.symbols[sub].parent {
+			sym, under, count := rc.encodeSymbol(sub, value, subcontextSymbolDelta, 0)
+			*parent = sym
+			parent = &rc.symbols[sym].parent
+			s := &rc.symbols[sub]
+			total := s.total
+			if count > 0 {
+				if !encode(uint32(s.escapes)+uint32(under), uint32(count), uint32(total)) {
+					return nil
+				}
+			} else {
+				if s.escapes > 0 && s.escapes < total && !encode(0, uint32(s.escapes), uint32(total)) {
+					return nil
+				}
+				s.escapes += subcontextEscapeDelta
+				s.total += subcontextEscapeDelta
+			}
+			s.total += subcontextSymbolDelta
+			if count > 0xFF-2*subcontextSymbolDelta || s.total > rangeCoderBottom-0x100 {
+				rc.rescale(sub, 0)
+			}
+			if count > 0 {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			sym, under, count := rc.encodeSymbol(0, value, contextSymbolDelta, contextSymbolMinimum)
+			*parent = sym
+			root := &rc.symbols[0]
+			if !encode(uint32(root.escapes)+uint32(under), uint32(count), uint32(root.total)) {
+				return nil
+			}
+			root.total += contextSymbolDelta
+			if count > 0xFF-2*contextSymbolDelta+contextSymbolMinimum || root.total > rangeCoderBottom-0x100 {
+				rc.rescale(0, contextSymbolMinimum)
+			}
+		}
+
+		if order >= subcontextOrder {
+			predicted = rc.symbols[predicted].parent
+		} else {
+			order++
+		}
+		if rc.next >= rangeCoderSymbols-subcontextOrder {
+			rc.reset()
+			predicted = 0
+			order = 0
+		}
+	}
+
+	for low != 0 {
+		if len(out) >= limit {
+			return nil
+		}
+		out = append(out, byte(low>>24))
+		low <<= 8
+	}
+	return out
+}
+
+// decompress decodes in, failing on corrupt input or output over limit bytes
+func (rc *rangeCoder) decompress(in []byte, limit int) ([]byte, bool) {
+	if len(in) == 0 {
+		return nil, false
+	}
+	out := make([]byte, 0, 2*len(in))
+	pos := 0
+	var low, code uint32
+	rng := ^uint32(0)
+	for shift := 24; shift >= 0; shift -= 8 {
+		if pos < len(in) {
+			code |= uint32(in[pos]) << shift
+			pos++
+		}
+	}
+	read := func(total uint16) uint16 {
+		rng /= uint32(total)
+		return uint16((code - low) / rng)
+	}
+	decode := func(under, count uint32) {
+		low += under * rng
+		rng *= count
+		for {
+			if low^(low+rng) >= rangeCoderTop {
+				if rng >= rangeCoderBottom {
+					break
+				}
+				rng = -low & (rangeCoderBottom - 1)
+			}
+			code <<= 8
+			if pos < len(in) {
+				code |= uint32(in[pos])
+				pos++
+			}
+			rng <<= 8
+			low <<= 8
+		}
+	}
+
+	rc.reset()
+	var predicted uint16
+	order := 0
+	for {
+		var (
+			value  uint8
+			under  uint16
+			count  uint16
+			bottom uint16
+			sub    uint16
+			ok     bool
+		)
+		parent := &predicted
+		found := false
+		for sub = predicted; sub != 0; sub = rc.symbols[sub].parent {
+			s := &rc.symbols[sub]
+			if s.escapes == 0 {
+				continue
+			}
+			total := s.total
+			if s.escapes >= total {
+				continue
+			}
+			c := read(total)
+			if c < s.escapes {
+				decode(0, uint32(s.escapes))
+				continue
+			}
+			c -= s.escapes
+			bottom, value, under, count, ok = rc.decodeSymbol(sub, c, subcontextSymbolDelta, 0)
+			if !ok {
+				return nil, false
+			}
+			decode(uint32(s.escapes)+uint32(under), uint32(count))
+			s.total += subcontextSymbolDelta
+			if count > 0xFF-2*subcontextSymbolDelta || s.total > rangeCoderBottom-0x100 {
+				rc.rescale(sub, 0)
+			}
+			found = true
+			break
+		}
+
+		if !found {
+			root := &rc.symbols[0]
+			total := root.total
+			if total == 0 {
+				return nil, false
+			}
+			c := read(total)
+			if c < root.escapes {
+				decode(0, uint32(root.escapes))
+				break
+			}
+			c -= root.escapes
+			bottom, value, under, count, _ = rc.decodeSymbol(0, c, contextSymbolDelta, contextSymbolMinimum)
+			decode(uint32(root.escapes)+uint32(under), uint32(count))
+			root.total += contextSymbolDelta
+			if count > 0xFF-2*contextSymbolDelta+contextSymbolMinimum || root.total > rangeCoderBottom-0x100 {
+				rc.rescale(0, contextSymbolMinimum)
+			}
+		}
+
+		// Teach the contexts that escaped the symbol they missed
+		for patch := predicted; patch != sub; patch = rc.symbols[patch].parent {
+			sym, _, count := rc.encodeSymbol(patch, value, subcontextSymbolDelta, 0)
+			*parent = sym
+			parent = &rc.symbols[sym].parent
+			p := &rc.symbols[patch]
+			if count == 0 {
+				p.escapes += subcontextEscapeDelta
+				p.total += subcontextEscapeDelta
+			}
+			p.total += subcontextSymbolDelta
+			if count > 0xFF-2*subcontextSymbolDelta || p.total > rangeCoderBottom-0x100 {
+				rc.rescale(patch, 0)
+			}
+		}
+		*parent = bottom
+
+		if len(out) >= limit {
+			return nil, false
+		}
+		out = append(out, value)
+
+		if order >= subcontextOrder {
+			predicted = rc.symbols[predicted].parent
+		} else {
+			order++
+		}
+		if rc.next >= rangeCoderSymbols-subcontextOrder {
+			rc.reset()
+			predicted = 0
+			order = 0
+		}
+	}
+	return out, true
+}
@@ -0,0 +1,73 @@
+package genet
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestRangeCoderRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func(n, alphabet int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(r.Intn(alphabet))
+		}
+		return b
+	}
+	tests := []struct {
+		name   string
+		in     []byte
+		shrink bool
+	}{
+		{"one byte", []byte{42}, false},
+		{"text", []byte(strings.Repeat("action|enter_game\nworld|START\n", 40)), true},
+		{"zeros", make([]byte, 1400), true},
+		{"small alphabet", random(1400, 4), true},
+		{"random", random(1400, 256), false},
+		{"model reset", random(20000, 256), false}, // Runs out of symbols many times
+	}
+	var rc rangeCoder
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := rc.compress(tt.in, 2*len(tt.in)+16)
+			if c == nil {
+				t.Fatal("compress failed")
+			}
+			if tt.shrink && len(c) >= len(tt.in)/2 {
+				t.Errorf("%d bytes compressed to %d", len(tt.in), len(c))
+			}
+			out, ok := rc.decompress(c, len(tt.in))
+			if !ok || !bytes.Equal(out, tt.in) {
+				t.Fatalf("round trip failed (ok %v, %d bytes back)", ok, len(out))
+			}
+		})
+	}
+}
+
+func TestRangeCoderLimits(t *testing.T) {
+	var rc rangeCoder
+	in := []byte(strings.Repeat("abcdefgh", 100))
+	c := rc.compress(in, len(in))
+	if c == nil {
+		t.Fatal("compress failed")
+	}
+	if rc.compress(in, len(c)-1) != nil {
+		t.Error("compress went over its limit")
+	}
+	if _, ok := rc.decompress(c, len(in)-1); ok {
+		t.Error("decompress went over its limit")
+	}
+	if rc.compress(nil, 10) != nil {
+		t.Error("compressed nothing")
+	}
+
+	// Garbage must fail or decode to something, never panic
+	r := rand.New(rand.NewSource(2))
+	for range 1000 {
+		garbage := make([]byte, 1+r.Intn(200))
+		r.Read(garbage)
+		rc.decompress(garbage, 4096)
+	}
+}
@@ -0,0 +1,427 @@
+package genet
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// receive handles one datagram, enet_protocol_handle_incoming_commands.
+// relay is the SOCKS5 association it came through, nil if none.
+func (h *Host) receive(data []byte, addr *net.UDPAddr, relay *socks5Relay) {
+	// Only the client's packets carry the game header, servers answer with
+	// the plain one
+	offset := 0
+	if h.cfg.NewPacket && h.server {
+		offset = integritySize
+	}
+	if len(data) < offset+2 {
+		return
+	}
+	peerID := be16(data[offset:])
+	sessionID := uint8((peerID & headerSessionMask) >> headerSessionShift)
+	flags := peerID & headerFlagMask
+	peerID &^= headerFlagMask | headerSessionMask
+
+	headerSize := offset + 2 + checksumSize
+	if flags&headerFlagSentTime != 0 {
+		headerSize += 2
+	}
+	if len(data) < headerSize {
+		return
+	}
+
+	var p *Peer
+	if peerID != maxPeerID {
+		if int(peerID) >= len(h.peers) || h.peers[peerID] == nil {
+			return
+		}
+		p = h.peers[peerID]
+		if p.state == stateDisconnected || p.state == stateZombie || !addr.IP.Equal(p.addr.IP) || addr.Port != p.addr.Port ||
+			p.relay != relay || (p.outgoingPeerID < maxPeerID && sessionID != p.incomingSessionID) {
+			return
+		}
+	}
+
+	if flags&headerFlagCompressed != 0 {
+		body, ok := h.coder.decompress(data[headerSize:], maxMTU-headerSize)
+		if !ok {
+			return
+		}
+		data = append(append(make([]byte, 0, headerSize+len(body)), data[:headerSize]...), body...)
+	} else {
+		data = append([]byte(nil), data...) // The read buffer is reused, payloads are kept
+	}
+
+	sum := data[headerSize-checksumSize : headerSize]
+	desired := be32(sum)
+	var seed uint32
+	if p != nil {
+		seed = p.connectID
+	}
+	binary.LittleEndian.PutUint32(sum, seed)
+	if checksum(data) != desired {
+		return
+	}
+
+	var sentTime uint16
+	if flags&headerFlagSentTime != 0 {
+		sentTime = be16(data[offset+2:])
+	}
+	pos := headerSize
+	for pos+4 <= len(data) {
+		number := data[pos] & cmdMask
+		if number >= cmdCount {
+			break
+		}
+		size := commandSizes[number]
+		if size == 0 || pos+size > len(data) {
+			break
+		}
+		cmd := data[pos : pos+size]
+		pos += size
+		if p == nil && (number != cmdConnect || pos < len(data)) {
+			break
+		}
+
+		var ok bool
+		switch number {
+		case cmdAcknowledge:
+			ok = h.handleAcknowledge(p, cmd)
+		case cmdConnect:
+			if p != nil || !h.server || relay != nil {
+				return
+			}
+			if p = h.handleConnect(cmd, addr); p == nil {
+				return
+			}
+			ok = true
+		case cmdVerifyConnect:
+			ok = h.handleVerifyConnect(p, cmd)
+		case cmdDisconnect:
+			ok = h.handleDisconnect(p, cmd)
+		case cmdPing:
+			ok = p.state == stateConnected || p.state == stateDisconnectLater
+		case cmdSendReliable, cmdSendUnreliable, cmdSendUnsequenced, cmdSendFragment, cmdSendUnreliableFragment:
+			ok = h.handleSend(p, cmd, data, &pos)
+		case cmdBandwidthLimit, cmdThrottleConfigure:
+			// No bandwidth throttling here, the values are only checked for
+			ok = p.state == stateConnected || p.state == stateDisconnectLater
+		}
+		if !ok {
+			return
+		}
+
+		if p != nil && cmd[0]&flagAcknowledge != 0 {
+			if flags&headerFlagSentTime == 0 {
+				return
+			}
+			switch p.state {
+			case stateDisconnecting, stateAcknowledgingConnect, stateDisconnected, stateZombie:
+			case stateAcknowledgingDisconnect:
+				if number == cmdDisconnect {
+					p.acks = append(p.acks, acknowledgement{cmd[0], cmd[1], be16(cmd[2:]), sentTime})
+				}
+			default:
+				p.acks = append(p.acks, acknowledgement{cmd[0], cmd[1], be16(cmd[2:]), sentTime})
+			}
+		}
+	}
+}
+
+func (h *Host) handleAcknowledge(p *Peer, cmd []byte) bool {
+	if p.state == stateDisconnected || p.state == stateZombie {
+		return true
+	}
+	now := h.now()
+	sentTime := uint32(be16(cmd[6:])) | now&0xFFFF0000
+	if sentTime&0x8000 > now&0x8000 {
+		sentTime -= 0x10000
+	}
+	if timeLess(now, sentTime) {
+		return true
+	}
+	p.lastReceiveTime = now
+	p.earliestTimeout = 0
+	p.updateRoundTrip(timeDifference(now, sentTime))
+
+	switch number := p.removeSentReliable(be16(cmd[4:]), cmd[1]); p.state {
+	case stateAcknowledgingConnect:
+		if number != cmdVerifyConnect {
+			return false
+		}
+		p.state = stateConnected
+		h.pushEvent(&Event{Type: EventConnect, Peer: p})
+	case stateDisconnecting:
+		if number != cmdDisconnect {
+			return false
+		}
+		h.removePeer(p)
+		h.pushEvent(&Event{Type: EventDisconnect, Peer: p})
+	case stateDisconnectLater:
+		if p.idle() {
+			p.disconnect()
+		}
+	}
+	return true
+}
+
+// handleConnect accepts a client, enet_protocol_handle_connect. It returns
+// nil for a retransmitted CONNECT or when there is no room.
+func (h *Host) handleConnect(cmd []byte, addr *net.UDPAddr) *Peer {
+	channelCount := be32(cmd[16:])
+	if channelCount < minChannelCount || channelCount > maxChannelCount {
+		return nil
+	}
+	connectID := binary.LittleEndian.Uint32(cmd[40:])
+	for _, other := range h.peers {
+		if other != nil && other.state != stateConnecting && other.addr.IP.Equal(addr.IP) &&
+			other.addr.Port == addr.Port && other.connectID == connectID {
+			return nil
+		}
+	}
+	if h.peerCount() >= h.cfg.Peers {
+		return nil
+	}
+	id := h.freeSlot()
+
+	channelCount = min(channelCount, uint32(h.cfg.Channels))
+	p := newPeer(h, uint16(id), addr)
+	h.peers[id] = p
+	p.channels = make([]channel, channelCount)
+	p.state = stateAcknowledgingConnect
+	p.connectID = connectID
+	p.outgoingPeerID = be16(cmd[4:])
+
+	incomingSession := cmd[6]
+	if incomingSession == 0xFF {
+		incomingSession = p.outgoingSessionID
+	}
+	incomingSession = (incomingSession + 1) & (headerSessionMask >> headerSessionShift)
+	if incomingSession == p.outgoingSessionID {
+		incomingSession = (incomingSession + 1) & (headerSessionMask >> headerSessionShift)
+	}
+	p.outgoingSessionID = incomingSession
+
+	outgoingSession := cmd[7]
+	if outgoingSession == 0xFF {
+		outgoingSession = p.incomingSessionID
+	}
+	outgoingSession = (outgoingSession + 1) & (headerSessionMask >> headerSessionShift)
+	if outgoingSession == p.incomingSessionID {
+		outgoingSession = (outgoingSession + 1) & (headerSessionMask >> headerSessionShift)
+	}
+	p.incomingSessionID = outgoingSession
+
+	p.mtu = min(max(be32(cmd[8:]), minMTU), maxMTU)
+	windowSize := min(max(be32(cmd[12:]), minWindowSize), maxWindowSize)
+
+	verify := command(cmdVerifyConnect|flagAcknowledge, 0xFF)
+	binary.BigEndian.PutUint16(verify[4:], p.id)
+	verify[6] = incomingSession
+	verify[7] = outgoingSession
+	binary.BigEndian.PutUint32(verify[8:], p.mtu)
+	binary.BigEndian.PutUint32(verify[12:], windowSize)
+	binary.BigEndian.PutUint32(verify[16:], channelCount)
+	copy(verify[28:40], cmd[28:40]) // The throttle settings, echoed
+	copy(verify[40:44], cmd[40:44])
+	p.queueOutgoing(&outgoingCommand{cmd: verify})
+	return p
+}
+
+func (h *Host) handleVerifyConnect(p *Peer, cmd []byte) bool {
+	if p.state != stateConnecting {
+		return true
+	}
+	channelCount := be32(cmd[16:])
+	if channelCount < minChannelCount || channelCount > maxChannelCount ||
+		be32(cmd[28:]) != throttleInterval || be32(cmd[32:]) != throttleAccelerate ||
+		be32(cmd[36:]) != throttleDecelerate || binary.LittleEndian.Uint32(cmd[40:]) != p.connectID {
+		h.removePeer(p)
+		h.pushEvent(&Event{Type: EventDisconnect, Peer: p})
+		return false
+	}
+	p.removeSentReliable(1, 0xFF)
+	if int(channelCount) < len(p.channels) {
+		p.channels = p.channels[:channelCount]
+	}
+	p.outgoingPeerID = be16(cmd[4:])
+	p.incomingSessionID = cmd[6]
+	p.outgoingSessionID = cmd[7]
+	p.mtu = min(p.mtu, min(max(be32(cmd[8:]), minMTU), maxMTU))
+	p.windowSize = min(p.windowSize, min(max(be32(cmd[12:]), minWindowSize), maxWindowSize))
+	p.state = stateConnected
+	h.pushEvent(&Event{Type: EventConnect, Peer: p})
+	return true
+}
+
+func (h *Host) handleDisconnect(p *Peer, cmd []byte) bool {
+	switch p.state {
+	case stateDisconnected, stateZombie, stateAcknowledgingDisconnect:
+		return true
+	}
+	p.resetQueues()
+	switch {
+	case p.state == stateDisconnecting || p.state == stateConnecting:
+		h.removePeer(p)
+		h.pushEvent(&Event{Type: EventDisconnect, Peer: p})
+	case p.state != stateConnected && p.state != stateDisconnectLater:
+		h.removePeer(p)
+	case cmd[0]&flagAcknowledge != 0:
+		p.state = stateAcknowledgingDisconnect // Reported once acknowledged
+	default:
+		h.removePeer(p)
+		h.pushEvent(&Event{Type: EventDisconnect, Peer: p})
+	}
+	return true
+}
+
+// handleSend takes a packet command and its payload, which starts at *pos
+func (h *Host) handleSend(p *Peer, cmd, data []byte, pos *int) bool {
+	number := cmd[0] & cmdMask
+	ch := cmd[1]
+	if int(ch) >= len(p.channels) || (p.state != stateConnected && p.state != stateDisconnectLater) {
+		return false
+	}
+	var length int
+	switch number {
+	case cmdSendReliable:
+		length = int(be16(cmd[4:]))
+	default:
+		length = int(be16(cmd[6:]))
+	}
+	if length > h.cfg.MaxPacketSize || *pos+length > len(data) {
+		return false
+	}
+	payload := data[*pos : *pos+length]
+	*pos += length
+	if p.state == stateDisconnectLater {
+		return true // Discarded, like enet_peer_queue_incoming_command does
+	}
+
+	c := &p.channels[ch]
+	seq := be16(cmd[2:])
+	switch number {
+	case cmdSendReliable:
+		if !c.inReliableWindow(seq) {
+			return true
+		}
+		in := &incomingCommand{reliableSeq: seq, data: payload}
+		if c.queueReliable(in) == in {
+			p.dispatchReliable(ch)
+		}
+
+	case cmdSendUnreliable:
+		if !c.inReliableWindow(seq) {
+			return true
+		}
+		unreliableSeq := be16(cmd[4:])
+		if seq == c.incomingReliableSeq && unreliableSeq <= c.incomingUnreliableSeq {
+			return true
+		}
+		if len(c.unreliable) < maxPendingUnreliable {
+			c.unreliable = append(c.unreliable, &incomingCommand{reliableSeq: seq, unreliableSeq: unreliableSeq, data: payload})
+			p.dispatchUnreliable(ch)
+		}
+
+	case cmdSendUnsequenced:
+		group := uint32(be16(cmd[4:]))
+		index := group % unsequencedWindowSize
+		if group < uint32(p.incomingUnseqGroup) {
+			group += 0x10000
+		}
+		if group >= uint32(p.incomingUnseqGroup)+unsequencedWindows*unsequencedWindowSize {
+			return true
+		}
+		group &= 0xFFFF
+		if uint16(group-index) != p.incomingUnseqGroup {
+			p.incomingUnseqGroup = uint16(group - index)
+			clear(p.unsequencedWindow[:])
+		} else if p.unsequencedWindow[index/32]&(1<<(index%32)) != 0 {
+			return true
+		}
+		p.unsequencedWindow[index/32] |= 1 << (index % 32)
+		h.pushEvent(&Event{Type: EventReceive, Peer: p, Channel: ch, Data: payload})
+
+	case cmdSendFragment, cmdSendUnreliableFragment:
+		return h.handleFragment(p, cmd, payload)
+	}
+	return true
+}
+
+// handleFragment files one fragment, enet_protocol_handle_send_fragment and
+// its unreliable twin
+func (h *Host) handleFragment(p *Peer, cmd, payload []byte) bool {
+	ch := cmd[1]
+	c := &p.channels[ch]
+	reliable := cmd[0]&cmdMask == cmdSendFragment
+	seq := be16(cmd[2:])
+	startSeq := be16(cmd[4:])
+	count := be32(cmd[8:])
+	number := be32(cmd[12:])
+	total := be32(cmd[16:])
+	offset := be32(cmd[20:])
+	windowSeq := seq
+	if reliable {
+		windowSeq = startSeq
+	}
+	if !c.inReliableWindow(windowSeq) {
+		return true
+	}
+	if count > maxFragmentCount || number >= count || total > uint32(h.cfg.MaxPacketSize) ||
+		offset >= total || uint32(len(payload)) > total-offset {
+		return false
+	}
+
+	var start *incomingCommand
+	if reliable {
+		in := &incomingCommand{reliableSeq: startSeq, fragment: true}
+		start = c.queueReliable(in)
+		if start == nil {
+			return true // Already delivered
+		}
+		if start == in {
+			start.data = make([]byte, total)
+			start.fragmentCount, start.fragmentsLeft = count, count
+			start.fragments = make([]uint32, (count+31)/32)
+		} else if !start.fragment || uint32(len(start.data)) != total || start.fragmentCount != count {
+			return false
+		}
+	} else {
+		if seq == c.incomingReliableSeq && startSeq <= c.incomingUnreliableSeq {
+			return true
+		}
+		for _, in := range c.unreliable {
+			if in.fragment && in.reliableSeq == seq && in.unreliableSeq == startSeq {
+				if uint32(len(in.data)) != total || in.fragmentCount != count {
+					return false
+				}
+				start = in
+				break
+			}
+		}
+		if start == nil {
+			if len(c.unreliable) >= maxPendingUnreliable {
+				return true
+			}
+			start = &incomingCommand{reliableSeq: seq, unreliableSeq: startSeq, fragment: true,
+				data: make([]byte, total), fragmentCount: count, fragmentsLeft: count,
+				fragments: make([]uint32, (count+31)/32)}
+			c.unreliable = append(c.unreliable, start)
+		}
+	}
+
+	if start.fragments[number/32]&(1<<(number%32)) != 0 {
+		return true
+	}
+	start.fragments[number/32] |= 1 << (number % 32)
+	start.fragmentsLeft--
+	copy(start.data[offset:], payload)
+	if start.fragmentsLeft == 0 {
+		if reliable {
+			p.dispatchReliable(ch)
+		} else {
+			p.dispatchUnreliable(ch)
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package genet
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"vortenixgo/network/transport"
+)
+
+// Shared spreads the peers of many transports over a few client hosts, so
+// hundreds of bots need a handful of sockets and service goroutines instead
+// of one each. Every peer's events go straight to its own transport.
+//
+// A host takes at most perHost peers and never two behind the same proxy
+// endpoint: proxies may tell UDP associations apart by client address only,
+// and every peer of a host sends from the same port. Bots sharing a proxy
+// end up on different hosts, new ones if need be.
+type Shared struct {
+	cfg Config
+
+	mu     sync.Mutex
+	hosts  []*Host
+	closed bool
+}
+
+// NewShared makes hosts configured like cfg, of perHost peers each
+func NewShared(cfg Config, perHost int) *Shared {
+	cfg.Peers = perHost
+	return &Shared{cfg: cfg}
+}
+
+// NewTransport returns a transport whose connections live on the shared
+// hosts. Its signature matches the bot's transport factory.
+func (s *Shared) NewTransport() (transport.Transport, error) {
+	return &Transport{cfg: s.cfg, shared: s, events: newEventQueue()}, nil
+}
+
+// connect adds a peer on the least busy host that can take it
+func (s *Shared) connect(ip string, port int, proxy *transport.Proxy, events *eventQueue) (*Peer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, fmt.Errorf("shared hosts closed")
+	}
+
+	var best *Host
+	bestPeers := 0
+	for _, h := range s.hosts {
+		h.mu.Lock()
+		room, peers := h.hasRoom(proxy), h.peerCount()
+		h.mu.Unlock()
+		if room && (best == nil || peers < bestPeers) {
+			best, bestPeers = h, peers
+		}
+	}
+	if best == nil {
+		h, err := NewClient(s.cfg)
+		if err != nil {
+			return nil, err
+		}
+		s.hosts = append(s.hosts, h)
+		log.Printf("[genet] Shared host %d on %s", len(s.hosts), h.Addr())
+		best = h
+	}
+	return best.connect(ip, port, proxy, events)
+}
+
+// Hosts is the number of hosts made so far
+func (s *Shared) Hosts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.hosts)
+}
+
+// Close closes every host, dropping their peers
+func (s *Shared) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, h := range s.hosts {
+		h.Close()
+	}
+	s.hosts = nil
+	s.closed = true
+}
@@ -0,0 +1,200 @@
+package genet
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"vortenixgo/network/transport"
+)
+
+// echoServer listens on a new host that sends back whatever it receives
+func echoServer(t *testing.T) int {
+	t.Helper()
+	server, err := Listen("127.0.0.1:0", Growtopia)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		server.Close()
+		<-done
+	})
+	go func() {
+		defer close(done)
+		for {
+			ev, err := server.Service(time.Second)
+			if err != nil {
+				return
+			}
+			if ev != nil && ev.Type == EventReceive {
+				ev.Peer.Send(0, ev.Data, true)
+			}
+		}
+	}()
+	return server.Addr().(*net.UDPAddr).Port
+}
+
+// expect services tr until an event of type want, failing on any other
+func expect(t *testing.T, tr transport.Transport, want transport.EventType) *transport.Event {
+	t.Helper()
+	ev, err := tr.Service(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev == nil || ev.Type != want {
+		t.Fatalf("got %+v, want type %v", ev, want)
+	}
+	return ev
+}
+
+// connectShared connects a new transport of s to port and waits for it
+func connectShared(t *testing.T, s *Shared, port int, proxy *transport.Proxy) transport.Transport {
+	t.Helper()
+	tr, err := s.NewTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tr.Close)
+	if err := tr.Connect("127.0.0.1", port, proxy); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, tr, transport.EventConnect)
+	return tr
+}
+
+func TestShared(t *testing.T) {
+	port := echoServer(t)
+	s := NewShared(Growtopia, 4)
+	defer s.Close()
+
+	trs := make([]transport.Transport, 10)
+	for i := range trs {
+		trs[i] = connectShared(t, s, port, nil)
+	}
+	if n := s.Hosts(); n != 3 {
+		t.Fatalf("%d hosts for 10 peers of 4 per host, want 3", n)
+	}
+
+	// Every transport sees its own traffic only
+	for i, tr := range trs {
+		if err := tr.Send([]byte(fmt.Sprint("bot ", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, tr := range trs {
+		if ev := expect(t, tr, transport.EventReceive); string(ev.Data) != fmt.Sprint("bot ", i) {
+			t.Errorf("transport %d got %q", i, ev.Data)
+		}
+	}
+
+	// A closed transport's slot is taken by the next one
+	trs[0].Close()
+	tr := connectShared(t, s, port, nil)
+	if n := s.Hosts(); n != 3 {
+		t.Errorf("%d hosts after reusing a slot, want 3", n)
+	}
+	tr.Send([]byte("reused"))
+	if ev := expect(t, tr, transport.EventReceive); string(ev.Data) != "reused" {
+		t.Errorf("got %q", ev.Data)
+	}
+
+	s.Close()
+	if err := tr.Connect("127.0.0.1", port, nil); err == nil {
+		t.Error("Connect after Close succeeded")
+	}
+}
+
+// Close sends what is still queued before telling the server goodbye
+func TestTransportCloseFlushes(t *testing.T) {
+	server, err := Listen("127.0.0.1:0", Growtopia)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	tr, err := NewTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Connect("127.0.0.1", server.Addr().(*net.UDPAddr).Port, nil); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, tr, transport.EventConnect)
+	if ev := next(t, server); ev.Type != EventConnect {
+		t.Fatalf("server event %+v", ev)
+	}
+
+	for i := 0; i < 5; i++ {
+		tr.Send([]byte(fmt.Sprint("packet ", i)))
+	}
+	tr.Close()
+	for i := 0; i < 5; i++ {
+		if ev := next(t, server); ev.Type != EventReceive || string(ev.Data) != fmt.Sprint("packet ", i) {
+			t.Fatalf("server event %d: %+v", i, ev)
+		}
+	}
+	if ev := next(t, server); ev.Type != EventDisconnect {
+		t.Fatalf("server event %+v", ev)
+	}
+}
+
+func TestSharedSOCKS5(t *testing.T) {
+	port := echoServer(t)
+	socks := newSOCKS5Server(t, "user", "secret")
+	s := NewShared(Growtopia, 8)
+	defer s.Close()
+
+	// Plenty of room, but a host never has two peers behind one proxy
+	a := connectShared(t, s, port, socks.proxy("user", "secret"))
+	b := connectShared(t, s, port, socks.proxy("user", "secret"))
+	if n := s.Hosts(); n != 2 {
+		t.Fatalf("%d hosts for two peers behind one proxy, want 2", n)
+	}
+	c := connectShared(t, s, port, nil)
+	if n := s.Hosts(); n != 2 {
+		t.Errorf("%d hosts after a direct peer, want 2", n)
+	}
+
+	for name, tr := range map[string]transport.Transport{"a": a, "b": b, "c": c} {
+		tr.Send([]byte(name))
+		if ev := expect(t, tr, transport.EventReceive); string(ev.Data) != name {
+			t.Errorf("%s got %q", name, ev.Data)
+		}
+	}
+	if got := socks.relayed.Load(); got < 2 {
+		t.Errorf("%d datagrams went through the proxy", got)
+	}
+}
+
+func TestHostConnectLimits(t *testing.T) {
+	port := echoServer(t)
+	socks := newSOCKS5Server(t, "user", "secret")
+	cfg := Growtopia
+	cfg.Peers = 1
+	h, err := NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if _, err := h.Connect("127.0.0.1", port, socks.proxy("user", "secret")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Connect("127.0.0.1", port, nil); err == nil {
+		t.Error("Connect on a full host succeeded")
+	}
+
+	cfg.Peers = 0
+	h2, err := NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h2.Close()
+	if _, err := h2.Connect("127.0.0.1", port, socks.proxy("user", "secret")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h2.Connect("127.0.0.1", port, socks.proxy("user", "secret")); err == nil {
+		t.Error("second peer behind the same proxy accepted")
+	}
+}
@@ -0,0 +1,120 @@
+package genet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"vortenixgo/network/transport"
+)
+
+const socks5Timeout = 10 * time.Second
+
+// socks5Relay is a SOCKS5 UDP association, set up with the same handshake
+// the C host uses. A peer's datagrams go to addr behind a 10 byte header
+// naming their destination; the association lasts as long as its TCP
+// control connection. Relays are per peer, so the peers of one host can use
+// different proxies over the host's single socket.
+type socks5Relay struct {
+	control net.Conn
+	addr    *net.UDPAddr
+}
+
+// dialSOCKS5 negotiates a UDP ASSOCIATE with proxy, with username/password
+// authentication when the proxy has credentials
+func dialSOCKS5(proxy *transport.Proxy) (*socks5Relay, error) {
+	control, err := net.DialTimeout("tcp", proxyKey(proxy), socks5Timeout)
+	if err != nil {
+		return nil, err
+	}
+	relay, err := socks5Associate(control, proxy)
+	if err != nil {
+		control.Close()
+		return nil, err
+	}
+	if relay.IP.IsUnspecified() {
+		relay.IP = control.RemoteAddr().(*net.TCPAddr).IP // "Same host as the control connection"
+	}
+	return &socks5Relay{control: control, addr: relay}, nil
+}
+
+// proxyKey names a proxy endpoint; a host never has two peers behind the
+// same one, since proxies may tell associations apart by client address only
+func proxyKey(proxy *transport.Proxy) string {
+	return net.JoinHostPort(proxy.Host, fmt.Sprint(proxy.Port))
+}
+
+func socks5Associate(control net.Conn, proxy *transport.Proxy) (*net.UDPAddr, error) {
+	control.SetDeadline(time.Now().Add(socks5Timeout))
+	defer control.SetDeadline(time.Time{})
+
+	method := byte(0) // No authentication
+	if proxy.User != "" && proxy.Password != "" {
+		method = 2 // Username/password
+	}
+	if _, err := control.Write([]byte{5, 1, method}); err != nil {
+		return nil, err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(control, reply); err != nil {
+		return nil, err
+	}
+	if reply[0] != 5 || reply[1] != method {
+		return nil, fmt.Errorf("authentication method refused")
+	}
+
+	if method == 2 {
+		if len(proxy.User) > 255 || len(proxy.Password) > 255 {
+			return nil, fmt.Errorf("credentials too long")
+		}
+		auth := append([]byte{1, byte(len(proxy.User))}, proxy.User...)
+		auth = append(append(auth, byte(len(proxy.Password))), proxy.Password...)
+		if _, err := control.Write(auth); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(control, reply); err != nil {
+			return nil, err
+		}
+		if reply[1] != 0 {
+			return nil, fmt.Errorf("authentication failed")
+		}
+	}
+
+	// UDP ASSOCIATE from 0.0.0.0:0, the relay address comes back
+	if _, err := control.Write([]byte{5, 3, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return nil, err
+	}
+	res := make([]byte, 10)
+	if _, err := io.ReadFull(control, res); err != nil {
+		return nil, err
+	}
+	if res[0] != 5 || res[1] != 0 || res[3] != 1 {
+		return nil, fmt.Errorf("UDP associate refused (reply %d, address type %d)", res[1], res[3])
+	}
+	return &net.UDPAddr{IP: net.IP(res[4:8]), Port: int(binary.BigEndian.Uint16(res[8:]))}, nil
+}
+
+// wrap puts the relay header for to in front of p
+func (r *socks5Relay) wrap(p []byte, to *net.UDPAddr) []byte {
+	b := make([]byte, socks5HeaderSize, socks5HeaderSize+len(p))
+	b[3] = 1 // IPv4
+	copy(b[4:8], to.IP.To4())
+	binary.BigEndian.PutUint16(b[8:], uint16(to.Port))
+	return append(b, p...)
+}
+
+// unwrapSOCKS5 strips the relay header from a datagram and returns the
+// address the relay says it came from
+func unwrapSOCKS5(b []byte) ([]byte, *net.UDPAddr, bool) {
+	if len(b) < socks5HeaderSize || b[2] != 0 || b[3] != 1 {
+		return nil, nil, false // Fragmented or not IPv4
+	}
+	from := &net.UDPAddr{IP: net.IP(append([]byte(nil), b[4:8]...)), Port: int(binary.BigEndian.Uint16(b[8:]))}
+	return b[socks5HeaderSize:], from, true
+}
+
+func (r *socks5Relay) close() {
+	r.control.Close()
+}
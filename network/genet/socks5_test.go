@@ -0,0 +1,166 @@
+package genet
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vortenixgo/network/transport"
+)
+
+// socks5Server is just enough of a SOCKS5 proxy for UDP ASSOCIATE with
+// username/password authentication. relayed counts the datagrams it passed
+// on to a destination.
+type socks5Server struct {
+	ln      net.Listener
+	user    string
+	pass    string
+	relayed atomic.Int32
+}
+
+func newSOCKS5Server(t *testing.T, user, pass string) *socks5Server {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &socks5Server{ln: ln, user: user, pass: pass}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *socks5Server) proxy(user, pass string) *transport.Proxy {
+	return &transport.Proxy{Host: "127.0.0.1", Port: s.ln.Addr().(*net.TCPAddr).Port, User: user, Password: pass}
+}
+
+func (s *socks5Server) serve(c net.Conn) {
+	defer c.Close()
+	greeting := make([]byte, 3)
+	if _, err := io.ReadFull(c, greeting); err != nil || greeting[0] != 5 || greeting[2] != 2 {
+		c.Write([]byte{5, 0xFF})
+		return
+	}
+	c.Write([]byte{5, 2})
+	auth := make([]byte, 2)
+	io.ReadFull(c, auth)
+	user := make([]byte, auth[1])
+	io.ReadFull(c, user)
+	io.ReadFull(c, auth[:1])
+	pass := make([]byte, auth[0])
+	io.ReadFull(c, pass)
+	if string(user) != s.user || string(pass) != s.pass {
+		c.Write([]byte{1, 1})
+		return
+	}
+	c.Write([]byte{1, 0})
+
+	req := make([]byte, 10)
+	if _, err := io.ReadFull(c, req); err != nil || req[1] != 3 {
+		return
+	}
+	relay, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return
+	}
+	defer relay.Close()
+	port := relay.LocalAddr().(*net.UDPAddr).Port
+	c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, byte(port >> 8), byte(port)}) // Relay on the proxy's own address
+
+	go func() {
+		var client *net.UDPAddr
+		buf := make([]byte, 65536)
+		for {
+			n, from, err := relay.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if client == nil || from.String() == client.String() {
+				client = from
+				if n < socks5HeaderSize {
+					continue
+				}
+				to := &net.UDPAddr{IP: net.IP(buf[4:8]), Port: int(binary.BigEndian.Uint16(buf[8:]))}
+				relay.WriteToUDP(buf[socks5HeaderSize:n], to)
+				s.relayed.Add(1)
+				continue
+			}
+			header := []byte{0, 0, 0, 1}
+			header = append(header, from.IP.To4()...)
+			header = binary.BigEndian.AppendUint16(header, uint16(from.Port))
+			relay.WriteToUDP(append(header, buf[:n]...), client)
+		}
+	}()
+	io.Copy(io.Discard, c) // The association ends with the control connection
+}
+
+func TestTransportSOCKS5(t *testing.T) {
+	server, err := Listen("127.0.0.1:0", Growtopia)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	port := server.Addr().(*net.UDPAddr).Port
+	socks := newSOCKS5Server(t, "user", "secret")
+
+	tr, err := NewTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	// Refused credentials end in a disconnect
+	if err := tr.Connect("127.0.0.1", port, socks.proxy("user", "wrong")); err != nil {
+		t.Fatal(err)
+	}
+	if ev, err := tr.Service(5 * time.Second); err != nil || ev == nil || ev.Type != transport.EventDisconnect {
+		t.Fatalf("got %+v, %v", ev, err)
+	}
+	if tr.HasPeer() {
+		t.Error("peer kept after a failed connection")
+	}
+
+	if err := tr.Connect("127.0.0.1", port, socks.proxy("user", "secret")); err != nil {
+		t.Fatal(err)
+	}
+	if ev, err := tr.Service(5 * time.Second); err != nil || ev == nil || ev.Type != transport.EventConnect || ev.RemotePort != port {
+		t.Fatalf("got %+v, %v", ev, err)
+	}
+	sp := next(t, server).Peer
+	if err := tr.Send([]byte("through the proxy")); err != nil {
+		t.Fatal(err)
+	}
+	if ev := next(t, server); ev.Type != EventReceive || string(ev.Data) != "through the proxy" {
+		t.Fatalf("server event %+v", ev)
+	}
+	sp.Send(0, []byte("and back"), true)
+	if ev, err := tr.Service(5 * time.Second); err != nil || ev == nil || ev.Type != transport.EventReceive || string(ev.Data) != "and back" {
+		t.Fatalf("got %+v, %v", ev, err)
+	}
+	if socks.relayed.Load() == 0 {
+		t.Error("nothing went through the proxy")
+	}
+
+	tr.Disconnect()
+	if tr.HasPeer() {
+		t.Error("peer kept after Disconnect")
+	}
+	if ev, err := tr.Service(100 * time.Millisecond); ev != nil || err != nil {
+		t.Fatalf("got %+v, %v after Disconnect", ev, err)
+	}
+
+	tr.Close()
+	if _, err := tr.Service(time.Millisecond); err == nil {
+		t.Error("Service after Close succeeded")
+	}
+}
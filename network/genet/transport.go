@@ -0,0 +1,150 @@
+package genet
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"vortenixgo/network/transport"
+)
+
+// Transport is the transport.Transport backed by this package: one peer,
+// either on a client host of its own, kept from one Connect to the next like
+// the C wrapper's, or on a host of a Shared.
+type Transport struct {
+	cfg    Config
+	shared *Shared     // Where the peers live, nil for a host of our own
+	events *eventQueue // The peers' events
+
+	mu     sync.Mutex
+	host   *Host // Our own host, made on the first Connect
+	peer   *Peer
+	closed bool
+}
+
+// NewTransport returns a client set up like the game's, on a host of its
+// own. It's the pure Go alternative to enet.NewTransport.
+func NewTransport() (transport.Transport, error) {
+	return &Transport{cfg: Growtopia, events: newEventQueue()}, nil
+}
+
+func (t *Transport) Connect(ip string, port int, proxy *transport.Proxy) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return fmt.Errorf("transport closed")
+	}
+	if t.peer != nil {
+		t.peer.DisconnectNow()
+		t.peer = nil
+	}
+
+	var peer *Peer
+	var err error
+	if t.shared != nil {
+		peer, err = t.shared.connect(ip, port, proxy, t.events)
+	} else {
+		if t.host == nil {
+			if t.host, err = NewClient(t.cfg); err != nil {
+				return err
+			}
+		}
+		peer, err = t.host.connect(ip, port, proxy, t.events)
+	}
+	if err != nil {
+		return err
+	}
+	t.peer = peer
+	return nil
+}
+
+func (t *Transport) Send(data []byte) error {
+	return t.SendOn(0, data, true)
+}
+
+func (t *Transport) SendOn(channel uint8, data []byte, reliable bool) error {
+	t.mu.Lock()
+	peer := t.peer
+	t.mu.Unlock()
+	if peer == nil {
+		return fmt.Errorf("not connected")
+	}
+	return peer.Send(channel, data, reliable)
+}
+
+func (t *Transport) Service(timeout time.Duration) (*transport.Event, error) {
+	event, err := t.events.wait(timeout)
+	if err != nil || event == nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if event.Peer != t.peer {
+		return nil, nil // From a connection replaced or dropped since
+	}
+	ev := &transport.Event{RemoteIP: event.Peer.RemoteIP(), RemotePort: event.Peer.RemotePort()}
+	switch event.Type {
+	case EventConnect:
+		ev.Type = transport.EventConnect
+	case EventDisconnect:
+		ev.Type = transport.EventDisconnect
+		t.peer = nil
+	case EventReceive:
+		ev.Type = transport.EventReceive
+		ev.Data = event.Data
+	default:
+		return nil, nil
+	}
+	return ev, nil
+}
+
+func (t *Transport) Disconnect() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.peer != nil {
+		t.peer.DisconnectNow() // No EventDisconnect for a peer we dropped ourselves
+		t.peer = nil
+	}
+}
+
+func (t *Transport) HasPeer() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.peer != nil
+}
+
+func (t *Transport) RTT() int {
+	t.mu.Lock()
+	peer := t.peer
+	t.mu.Unlock()
+	if peer == nil {
+		return 0
+	}
+	return peer.RTT()
+}
+
+// Wake makes a waiting Service return
+func (t *Transport) Wake() {
+	t.events.wake()
+}
+
+func (t *Transport) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.peer != nil {
+		// Send what is queued first, DisconnectNow drops it
+		h := t.peer.host
+		h.mu.Lock()
+		h.flushPeer(t.peer, h.now(), false)
+		h.mu.Unlock()
+		t.peer.DisconnectNow()
+		t.peer = nil
+	}
+	if t.host != nil {
+		t.host.Close()
+		t.host = nil
+	}
+	t.closed = true
+	t.events.close()
+}
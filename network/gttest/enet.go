@@ -0,0 +1,87 @@
+package gttest
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"vortenixgo/network/genet"
+)
+
+// ENetServer serves a GameServer on a local UDP port. It runs on the pure Go
+// ENet host, which unlike the C one reads the new packet header the bot's
+// client host sends.
+type ENetServer struct {
+	Port int
+
+	game *GameServer
+	host *genet.Host
+	done chan struct{}
+
+	// Sessions by peer, only touched by the service loop
+	sessions map[*genet.Peer]*Session
+}
+
+// enetConn is a Session's view of its peer
+type enetConn struct {
+	peer *genet.Peer
+}
+
+func (c *enetConn) Send(data []byte) error {
+	return c.peer.Send(0, data, true)
+}
+
+// Close disconnects once the queued packets are delivered
+func (c *enetConn) Close() error {
+	c.peer.DisconnectLater()
+	return nil
+}
+
+// ServeENet listens on 127.0.0.1:port and runs game for every peer
+func ServeENet(game *GameServer, port int) (*ENetServer, error) {
+	host, err := genet.Listen(fmt.Sprintf("127.0.0.1:%d", port), genet.Growtopia)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on port %d: %w", port, err)
+	}
+	s := &ENetServer{
+		Port:     port,
+		game:     game,
+		host:     host,
+		done:     make(chan struct{}),
+		sessions: make(map[*genet.Peer]*Session),
+	}
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the service loop and closes the host
+func (s *ENetServer) Close() {
+	s.host.Close()
+	<-s.done
+}
+
+func (s *ENetServer) serve() {
+	defer close(s.done)
+	for {
+		event, err := s.host.Service(time.Second)
+		if err != nil {
+			return // Closed
+		}
+		if event == nil {
+			continue
+		}
+
+		switch event.Type {
+		case genet.EventConnect:
+			s.sessions[event.Peer] = s.game.Accept(&enetConn{peer: event.Peer})
+		case genet.EventReceive:
+			if sess := s.sessions[event.Peer]; sess != nil {
+				sess.Receive(event.Data)
+			}
+		case genet.EventDisconnect:
+			delete(s.sessions, event.Peer)
+		default:
+			log.Printf("[gttest] Unexpected ENet event %d", event.Type)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package gttest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"vortenixgo/bot"
+	"vortenixgo/network/transport"
+)
+
+// Conn is one client connection as seen by the game server. ENetServer
+// provides one per peer; tests can use their own.
+type Conn interface {
+	Send(data []byte) error
+	Close() error
+}
+
+// GameFault makes the game server misbehave during login
+type GameFault int
+
+const (
+	GameFaultNone        GameFault = iota
+	GameFaultNoHello               // Never greet: the bot sits in "Connecting..." until it times out
+	GameFaultLogonFail             // Answer the login with logon_fail: the bot reconnects
+	GameFaultBanned                // Answer the login with a ban message
+	GameFaultMaintenance           // Answer the login with the maintenance message
+	GameFaultDrop                  // Close the connection instead of answering the login
+)
+
+// JoinFault makes a join_request fail
+type JoinFault int
+
+const (
+	JoinFaultLocked      JoinFault = iota + 1 // "That world is locked", then OnFailedToEnterWorld
+	JoinFaultFull                             // "Too many people in that world", then OnFailedToEnterWorld
+	JoinFaultBanned                           // "You are banned from that world", then OnFailedToEnterWorld
+	JoinFaultUnexplained                      // OnFailedToEnterWorld alone
+	JoinFaultSilent                           // No answer at all
+)
+
+// InventoryItem is one slot of the inventory sent after enter_game
+type InventoryItem struct {
+	ID    uint16
+	Count uint8
+}
+
+// GameServer scripts the server side of a Growtopia session: HELLO, the
+// OnSendToServer redirect to a sub-server, OnSuperMainStartAcceptLogon, then
+// the inventory, a ping request and map data for every join_request that
+// FailJoins doesn't fail. Configure it before the first connection.
+type GameServer struct {
+	// Where OnSendToServer sends a fresh login, usually the server's own address
+	SubServerIP   string
+	SubServerPort int
+	// Number of OnSendToServer hops before the login is accepted
+	Redirects int
+	// Hash announced in OnSuperMainStartAcceptLogon, compared with items.dat
+	ServerHash uint32
+	Inventory  []InventoryItem
+	Fault      GameFault
+
+	mu      sync.Mutex
+	hops    map[string]int // UUIDToken -> redirects done
+	nextID  int
+	packets []string // Received packets, for assertions
+	joins   []string // Names of join requests, with the door
+	failing map[string][]JoinFault
+}
+
+// NewGameServer returns a game server that redirects once to host:port
+func NewGameServer(host string, port int) *GameServer {
+	return &GameServer{
+		SubServerIP:   host,
+		SubServerPort: port,
+		Redirects:     1,
+		ServerHash:    0x5ca1ab1e,
+		Inventory:     []InventoryItem{{ID: 18, Count: 1}, {ID: 32, Count: 1}, {ID: 2, Count: 200}},
+		hops:          make(map[string]int),
+	}
+}
+
+// Received lists the packets received so far, one line per packet such as
+// "GENERIC_TEXT action|enter_game" or "GAME_PACKET PING_REPLY"
+func (g *GameServer) Received() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.packets...)
+}
+
+// Joins lists the name field of every join_request so far, "WORLD" or
+// "WORLD|DOOR"
+func (g *GameServer) Joins() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.joins...)
+}
+
+// FailJoins makes the next joins of world fail with faults, one each, in
+// order; joins after that succeed
+func (g *GameServer) FailJoins(world string, faults ...JoinFault) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.failing == nil {
+		g.failing = make(map[string][]JoinFault)
+	}
+	g.failing[strings.ToUpper(world)] = faults
+}
+
+// Session is one connection to the game server
+type Session struct {
+	game  *GameServer
+	conn  Conn
+	netID int32
+	name  string
+}
+
+// Accept starts a session on a new connection and greets the client
+func (g *GameServer) Accept(c Conn) *Session {
+	g.mu.Lock()
+	g.nextID++
+	s := &Session{game: g, conn: c, netID: int32(g.nextID)}
+	fault := g.Fault
+	g.mu.Unlock()
+
+	if fault != GameFaultNoHello {
+		s.send(binary.LittleEndian.AppendUint32(nil, bot.NET_MESSAGE_SERVER_HELLO))
+	}
+	return s
+}
+
+// ServeMemory answers connections to ip:port on n with game, so bots using
+// n.NewTransport play against it without ENet
+func ServeMemory(game *GameServer, n *transport.Network, ip string, port int) {
+	n.Listen(ip, port, func(c *transport.Conn) func(data []byte) {
+		return game.Accept(c).Receive
+	})
+}
+
+// Receive handles one packet from the client
+func (s *Session) Receive(data []byte) {
+	if len(data) < 4 {
+		return
+	}
+	ev := bot.NewPacketEvent("", bot.CaptureIn, time.Time{}, data)
+	s.game.record(ev)
+
+	switch {
+	case ev.Text != nil:
+		s.handleText(textMap(ev.Text))
+	case ev.Tank != nil && ev.Tank.Type == bot.TankPacketTypeName(uint8(bot.NET_GAME_PACKET_DISCONNECT)):
+		s.conn.Close()
+	}
+}
+
+func (s *Session) handleText(fields map[string]string) {
+	switch fields["action"] {
+	case "":
+		if _, ok := fields["protocol"]; ok {
+			s.login(fields)
+		}
+	case "enter_game":
+		s.console("Welcome to the gttest server!")
+		s.send(gamePacket(bot.TankPacketStruct{Type: uint8(bot.NET_GAME_PACKET_SEND_INVENTORY_STATE), NetID: -1},
+			inventoryState(s.game.inventory())))
+		s.Ping()
+	case "join_request":
+		s.joinWorld(fields["name"])
+	case "quit_to_exit":
+		s.call("OnRequestWorldSelectMenu", "")
+	}
+}
+
+// login answers the login packet: a redirect until the client has made
+// enough hops, then the logon acceptance
+func (s *Session) login(fields map[string]string) {
+	g := s.game
+	g.mu.Lock()
+	fault := g.Fault
+	uuid := fields["UUIDToken"]
+	hops, known := g.hops[uuid]
+	if !known {
+		uuid = fmt.Sprintf("uuid-%d", s.netID)
+		hops = 0
+	}
+	redirect := hops < g.Redirects
+	if redirect {
+		g.hops[uuid] = hops + 1
+	}
+	subIP, subPort, hash := g.SubServerIP, g.SubServerPort, g.ServerHash
+	g.mu.Unlock()
+
+	switch fault {
+	case GameFaultLogonFail:
+		s.text(bot.NET_MESSAGE_GAME_MESSAGE, "action|logon_fail\n")
+		return
+	case GameFaultBanned:
+		s.text(bot.NET_MESSAGE_GAME_MESSAGE, "action|log\nmsg|`4Sorry, this account is currently banned.``\n")
+		return
+	case GameFaultMaintenance:
+		s.text(bot.NET_MESSAGE_GAME_MESSAGE, "action|log\nmsg|`4Server under maintenance. View GT Twitter for updates.``\n")
+		return
+	case GameFaultDrop:
+		s.conn.Close()
+		return
+	}
+
+	s.name = fields["tankIDName"]
+	if redirect {
+		s.call("OnSendToServer", subPort, int(s.netID)*1000, "user"+fmt.Sprint(s.netID),
+			fmt.Sprintf("%s|0|%s", subIP, uuid), 1, s.name)
+		return
+	}
+	s.call("OnSuperMainStartAcceptLogonHrdxs47254722215a", hash,
+		"ubistatic-a.akamaihd.net", "0098/gttest/cache/", "", "proto=225|choosemusic=audio/mp3/about_theme.mp3")
+}
+
+func (s *Session) joinWorld(field string) {
+	name, _, _ := strings.Cut(strings.ToUpper(field), "|")
+	if name == "" {
+		name = "START"
+	}
+	g := s.game
+	g.mu.Lock()
+	g.joins = append(g.joins, field)
+	var fault JoinFault
+	if f := g.failing[name]; len(f) > 0 {
+		fault, g.failing[name] = f[0], f[1:]
+	}
+	g.mu.Unlock()
+
+	switch fault {
+	case JoinFaultLocked:
+		s.console("`4That world is locked.``")
+	case JoinFaultFull:
+		s.console("`4Oops:`` Too many people in that world!")
+	case JoinFaultBanned:
+		s.console("`4You are banned from that world.``")
+	case JoinFaultSilent:
+		return
+	}
+	if fault != 0 {
+		s.call("OnFailedToEnterWorld", 1)
+		return
+	}
+
+	world := BlankWorld(name, 20, 15)
+	s.send(gamePacket(bot.TankPacketStruct{Type: uint8(bot.NET_GAME_PACKET_SEND_MAP_DATA), NetID: -1}, world))
+	s.call("OnSpawn", fmt.Sprintf("spawn|avatar\nnetID|%d\nuserID|%d\ncolrect|0|0|20|30\nposXY|320|320\nname|``%s``\ncountry|us\ninvis|0\nmstate|0\nsmstate|0\nonlineID|\ntype|local\n",
+		s.netID, 1000+s.netID, s.name))
+	s.console("World `w" + name + "`` entered.")
+}
+
+// Ping sends a NET_GAME_PACKET_PING_REQUEST
+func (s *Session) Ping() {
+	s.send(gamePacket(bot.TankPacketStruct{Type: uint8(bot.NET_GAME_PACKET_PING_REQUEST), Value: 1}, nil))
+}
+
+// Close drops the connection
+func (s *Session) Close() {
+	s.conn.Close()
+}
+
+func (s *Session) console(msg string) {
+	s.call("OnConsoleMessage", msg)
+}
+
+// call sends a CALL_FUNCTION packet with the variants in order
+func (s *Session) call(variants ...interface{}) {
+	extra, err := variantList(variants...)
+	if err != nil {
+		log.Printf("[gttest] %v: %v", variants[0], err)
+		return
+	}
+	s.send(gamePacket(bot.TankPacketStruct{Type: uint8(bot.NET_GAME_PACKET_CALL_FUNCTION), NetID: -1}, extra))
+}
+
+func (s *Session) text(msgType uint32, text string) {
+	s.send(append(binary.LittleEndian.AppendUint32(nil, msgType), text...))
+}
+
+func (s *Session) send(data []byte) {
+	s.conn.Send(data)
+}
+
+func (g *GameServer) inventory() []InventoryItem {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]InventoryItem(nil), g.Inventory...)
+}
+
+func (g *GameServer) record(ev *bot.PacketEvent) {
+	line := ev.Type
+	if ev.Tank != nil {
+		line += " " + ev.Tank.Type
+	}
+	for _, f := range ev.Text {
+		if f.Key == "action" {
+			line += " action|" + f.Value
+		}
+	}
+	g.mu.Lock()
+	g.packets = append(g.packets, line)
+	g.mu.Unlock()
+}
+
+func textMap(fields []bot.TextField) map[string]string {
+	m := make(map[string]string, len(fields))
+	for _, f := range fields {
+		m[f.Key] = f.Value
+	}
+	return m
+}
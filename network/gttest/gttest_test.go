@@ -0,0 +1,241 @@
+package gttest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"vortenixgo/bot"
+)
+
+// memConn records what the server sends
+type memConn struct {
+	sent   [][]byte
+	closed bool
+}
+
+func (c *memConn) Send(data []byte) error { c.sent = append(c.sent, data); return nil }
+func (c *memConn) Close() error           { c.closed = true; return nil }
+
+// take decodes and forgets the packets sent so far
+func (c *memConn) take() []*bot.PacketEvent {
+	var evs []*bot.PacketEvent
+	for _, data := range c.sent {
+		evs = append(evs, bot.NewPacketEvent("", bot.CaptureIn, time.Time{}, data))
+	}
+	c.sent = nil
+	return evs
+}
+
+// summary names each packet by message type, tank type and function
+func summary(evs []*bot.PacketEvent) []string {
+	var out []string
+	for _, ev := range evs {
+		line := ev.Type
+		if ev.Tank != nil {
+			line += " " + ev.Tank.Type
+		}
+		if len(ev.Variants) > 0 {
+			line += " " + ev.Variants[0].(string)
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+func text(s string) []byte {
+	return append([]byte{2, 0, 0, 0}, s...)
+}
+
+func TestGameSessionLogin(t *testing.T) {
+	g := NewGameServer("127.0.0.1", 17091)
+
+	c1 := &memConn{}
+	s1 := g.Accept(c1)
+	s1.Receive(text("protocol|225\ntankIDName|tester\nltoken|abc\n"))
+	evs := c1.take()
+	if got, want := summary(evs), []string{"SERVER_HELLO", "GAME_PACKET CALL_FUNCTION OnSendToServer"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("first login: got %v, want %v", got, want)
+	}
+	redirect := evs[1].Variants
+	if redirect[1] != int32(17091) || redirect[4] != "127.0.0.1|0|uuid-1" || redirect[6] != "tester" {
+		t.Fatalf("unexpected redirect %v", redirect)
+	}
+	s1.Receive(append([]byte{4, 0, 0, 0}, (&bot.TankPacketStruct{Type: uint8(bot.NET_GAME_PACKET_DISCONNECT)}).Serialize()...))
+	if !c1.closed {
+		t.Fatal("DISCONNECT did not close the connection")
+	}
+
+	c2 := &memConn{}
+	s2 := g.Accept(c2)
+	s2.Receive(text("protocol|225\ntankIDName|tester\nuser|user1\ntoken|1000\nUUIDToken|uuid-1\n"))
+	s2.Receive(text("action|enter_game\n"))
+	s2.Receive(text("action|join_request\nname|buy\ninvitedWorld|0\n"))
+	want := []string{
+		"SERVER_HELLO",
+		"GAME_PACKET CALL_FUNCTION OnSuperMainStartAcceptLogonHrdxs47254722215a",
+		"GAME_PACKET CALL_FUNCTION OnConsoleMessage",
+		"GAME_PACKET SEND_INVENTORY_STATE",
+		"GAME_PACKET PING_REQUEST",
+		"GAME_PACKET SEND_MAP_DATA",
+		"GAME_PACKET CALL_FUNCTION OnSpawn",
+		"GAME_PACKET CALL_FUNCTION OnConsoleMessage",
+	}
+	evs = c2.take()
+	if got := summary(evs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("second login:\ngot  %v\nwant %v", got, want)
+	}
+	if evs[1].Variants[1] != uint32(g.ServerHash) {
+		t.Errorf("logon hash %v, want %d", evs[1].Variants[1], g.ServerHash)
+	}
+	if got := int(evs[5].Tank.ExtendedDataLength); got != len(BlankWorld("BUY", 20, 15)) {
+		t.Errorf("map data is %d bytes", got)
+	}
+
+	received := []string{
+		"GENERIC_TEXT", "GAME_PACKET DISCONNECT", "GENERIC_TEXT",
+		"GENERIC_TEXT action|enter_game", "GENERIC_TEXT action|join_request",
+	}
+	if got := g.Received(); !reflect.DeepEqual(got, received) {
+		t.Errorf("received %v, want %v", got, received)
+	}
+}
+
+func TestGameSessionFaults(t *testing.T) {
+	tests := []struct {
+		fault  GameFault
+		want   []string
+		closed bool
+	}{
+		{GameFaultNoHello, []string{"GAME_PACKET CALL_FUNCTION OnSendToServer"}, false}, // Only the greeting is skipped
+		{GameFaultLogonFail, []string{"SERVER_HELLO", "GAME_MESSAGE"}, false},
+		{GameFaultBanned, []string{"SERVER_HELLO", "GAME_MESSAGE"}, false},
+		{GameFaultMaintenance, []string{"SERVER_HELLO", "GAME_MESSAGE"}, false},
+		{GameFaultDrop, []string{"SERVER_HELLO"}, true},
+	}
+	for _, tt := range tests {
+		g := NewGameServer("127.0.0.1", 17091)
+		g.Fault = tt.fault
+		c := &memConn{}
+		g.Accept(c).Receive(text("protocol|225\ntankIDName|tester\n"))
+		if got := summary(c.take()); !reflect.DeepEqual(got, tt.want) || c.closed != tt.closed {
+			t.Errorf("fault %d: sent %v closed %v, want %v closed %v", tt.fault, got, c.closed, tt.want, tt.closed)
+		}
+	}
+}
+
+// TestGameSessionBot replays what the server sends through a real bot's
+// packet handlers
+func TestGameSessionBot(t *testing.T) {
+	g := NewGameServer("127.0.0.1", 17092)
+	c := &memConn{}
+	s := g.Accept(c)
+	s.Receive(text("protocol|225\ntankIDName|tester\n"))
+	s = g.Accept(c)
+	s.Receive(text("protocol|225\ntankIDName|tester\nUUIDToken|uuid-1\n"))
+	s.Receive(text("action|enter_game\n"))
+
+	var buf bytes.Buffer
+	w, err := bot.NewCaptureWriter(&buf, "bot_tester", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range c.sent {
+		w.Write(bot.CaptureRecord{Time: time.Now(), Dir: bot.CaptureIn, Data: data})
+	}
+	w.Close()
+	r, err := bot.NewCaptureReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := bot.NewBot("bot_tester", bot.BotTypeLegacy, "tester", "secret", "")
+	if _, err := b.Replay(r, nil); err != nil {
+		t.Fatal(err)
+	}
+	b.Lock()
+	defer b.Unlock()
+	if b.Login.UUIDToken != "uuid-1" || b.Server.Enet.NowConnectedPort != 17092 || b.Login.User != "user1" {
+		t.Errorf("redirect not applied: uuid %q port %d user %q", b.Login.UUIDToken, b.Server.Enet.NowConnectedPort, b.Login.User)
+	}
+	if b.Local.ServerHash != int(g.ServerHash) {
+		t.Errorf("server hash %d, want %d", b.Local.ServerHash, g.ServerHash)
+	}
+	if len(b.Local.Inventory) != len(g.Inventory) {
+		t.Errorf("inventory has %d items, want %d", len(b.Local.Inventory), len(g.Inventory))
+	}
+	if b.Status != "online" {
+		t.Errorf("status %q after the ping request, want online", b.Status)
+	}
+}
+
+func TestLoginServerFaults(t *testing.T) {
+	s := NewLoginServer("127.0.0.1", 17091)
+	defer s.Close()
+	client := s.srv.Client()
+
+	post := func(endpoint string, form url.Values) (int, string) {
+		t.Helper()
+		resp, err := client.PostForm(s.URL+endpoint, form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if _, body := post(EndpointServerData, nil); !strings.Contains(body, "server|127.0.0.1\nport|17091\n") || !strings.HasSuffix(body, "RTENDMARKERBS1001\n") {
+		t.Errorf("unexpected server data %q", body)
+	}
+
+	s.Inject(EndpointServerData, Fault{Status: http.StatusBadGateway, Times: 2})
+	for i := 0; i < 2; i++ {
+		if status, _ := post(EndpointServerData, nil); status != http.StatusBadGateway {
+			t.Errorf("request %d: status %d, want 502", i, status)
+		}
+	}
+	if status, _ := post(EndpointServerData, nil); status != http.StatusOK {
+		t.Errorf("fault outlived Times: status %d", status)
+	}
+	if got := s.Hits(EndpointServerData); got != 4 {
+		t.Errorf("%d hits, want 4", got)
+	}
+
+	if _, body := post(EndpointCheckToken, url.Values{"refreshToken": {"nope"}}); body != BodyTokenInvalid {
+		t.Errorf("unknown token: %q", body)
+	}
+	s.AddAccount("tester", "secret")
+	if _, body := post(EndpointValidate, url.Values{"_token": {"form-token-1"}, "growId": {"tester"}, "password": {"secret"}}); !strings.Contains(body, `"token":"ltoken-tester"`) {
+		t.Errorf("validate: %q", body)
+	}
+	if _, body := post(EndpointCheckToken, url.Values{"refreshToken": {"ltoken-tester"}}); !strings.Contains(body, `"status":"success"`) {
+		t.Errorf("issued token rejected: %q", body)
+	}
+}
+
+func TestVariantList(t *testing.T) {
+	variants := []interface{}{"OnSendToServer", 17091, int32(-1), uint32(0xFFFFFFFF), float32(-0.25), bot.Vector2{X: 1600, Y: 928.5}, bot.Vector3{X: 1, Y: 2, Z: 3}}
+	data, err := variantList(variants...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vl bot.VariantList
+	if err := vl.Parse(data); err != nil {
+		t.Fatal(err)
+	}
+	want := append([]interface{}{}, variants...)
+	want[1] = int32(17091) // Sent as int32
+	if !reflect.DeepEqual(vl.Variants, want) {
+		t.Errorf("got %#v\nwant %#v", vl.Variants, want)
+	}
+
+	if _, err := variantList("OnConsoleMessage", int64(1)); err == nil {
+		t.Error("encoded an int64")
+	}
+}
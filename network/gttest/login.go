@@ -0,0 +1,228 @@
+// Package gttest runs fake Growtopia services for tests: the HTTPS login
+// endpoints (LoginServer) and the game server (GameServer), each with failure
+// injection.
+package gttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Paths served by LoginServer, also used as the keys for Inject and Hits
+const (
+	EndpointServerData = "/growtopia/server_data.php"
+	EndpointCheckToken = "/player/growid/checktoken"
+	EndpointDashboard  = "/player/login/dashboard"
+	EndpointLoginForm  = "/player/growid/login"
+	EndpointValidate   = "/player/growid/login/validate"
+)
+
+// Response bodies of the real service that the bot reacts to
+const (
+	BodyTokenInvalid   = `{"status":"error","message":"Token is invalid."}`
+	BodyTooManyPeople  = "Oops, too many people trying to login at once. Please try again in a few seconds."
+	BodyDashboardBlock = `{"status":"failed","message":"Access denied."}`
+	BodyNoMarker       = "<html><body>Access denied</body></html>"
+)
+
+// Fault replaces the normal response of an endpoint
+type Fault struct {
+	Status int           // HTTP status, 0 means 200
+	Body   string        // Sent instead of the normal body
+	Delay  time.Duration // Wait before answering
+	Times  int           // Number of requests affected, 0 means until cleared
+}
+
+// LoginServer is a TLS server speaking server_data.php, checktoken, the login
+// dashboard and the GrowID login form. Point server.server_data_url at
+// ServerDataURL() and server.login_url at URL.
+type LoginServer struct {
+	URL string
+
+	srv *httptest.Server
+
+	mu        sync.Mutex
+	gameHost  string
+	gamePort  int
+	accounts  map[string]string // GrowID -> password
+	tokens    map[string]bool   // Issued ltokens
+	formToken string
+	faults    map[string]*Fault
+	hits      map[string]int
+}
+
+// NewLoginServer starts a login server whose server_data.php sends bots to the
+// game server at gameHost:gamePort
+func NewLoginServer(gameHost string, gamePort int) *LoginServer {
+	s := &LoginServer{
+		gameHost:  gameHost,
+		gamePort:  gamePort,
+		accounts:  make(map[string]string),
+		tokens:    make(map[string]bool),
+		formToken: "form-token-1",
+		faults:    make(map[string]*Fault),
+		hits:      make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(EndpointServerData, s.wrap(EndpointServerData, s.serverData))
+	mux.HandleFunc(EndpointCheckToken, s.wrap(EndpointCheckToken, s.checkToken))
+	mux.HandleFunc(EndpointDashboard, s.wrap(EndpointDashboard, s.dashboard))
+	mux.HandleFunc(EndpointLoginForm, s.wrap(EndpointLoginForm, s.loginForm))
+	mux.HandleFunc(EndpointValidate, s.wrap(EndpointValidate, s.validate))
+	s.srv = httptest.NewTLSServer(mux)
+	s.URL = s.srv.URL
+	return s
+}
+
+// ServerDataURL is the server_data.php address
+func (s *LoginServer) ServerDataURL() string {
+	return s.URL + EndpointServerData
+}
+
+// Close shuts the server down
+func (s *LoginServer) Close() {
+	s.srv.Close()
+}
+
+// AddAccount lets name log in with password through the GrowID form
+func (s *LoginServer) AddAccount(name, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[name] = password
+}
+
+// IssueToken makes token valid for checktoken, as if a previous login had
+// returned it
+func (s *LoginServer) IssueToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = true
+}
+
+// Inject makes endpoint answer with f. A zero Fault clears it.
+func (s *LoginServer) Inject(endpoint string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f == (Fault{}) {
+		delete(s.faults, endpoint)
+		return
+	}
+	s.faults[endpoint] = &f
+}
+
+// Hits returns how many requests endpoint has received
+func (s *LoginServer) Hits(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[endpoint]
+}
+
+// wrap counts the request and applies an injected fault
+func (s *LoginServer) wrap(endpoint string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.hits[endpoint]++
+		var fault Fault
+		if f := s.faults[endpoint]; f != nil {
+			fault = *f
+			if f.Times > 0 {
+				if f.Times--; f.Times == 0 {
+					delete(s.faults, endpoint)
+				}
+			}
+		}
+		s.mu.Unlock()
+
+		if fault.Delay > 0 {
+			time.Sleep(fault.Delay)
+		}
+		if fault.Status == 0 && fault.Body == "" {
+			h(w, r)
+			return
+		}
+		if fault.Status == 0 {
+			fault.Status = http.StatusOK
+		}
+		w.WriteHeader(fault.Status)
+		fmt.Fprint(w, fault.Body)
+	}
+}
+
+func (s *LoginServer) serverData(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	host, port := s.gameHost, s.gamePort
+	s.mu.Unlock()
+
+	fmt.Fprintf(w, "server|%s\nport|%d\ntype|1\ntype2|1\n#maint|Mock server maintenance\n", host, port)
+	fmt.Fprintf(w, "loginurl|%s\nmeta|gttest-meta\nRTENDMARKERBS1001\n", strings.TrimPrefix(s.URL, "https://"))
+}
+
+func (s *LoginServer) checkToken(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("refreshToken")
+	s.mu.Lock()
+	valid := s.tokens[token]
+	s.mu.Unlock()
+
+	if !valid {
+		fmt.Fprint(w, BodyTokenInvalid)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "success", "message": "Account Validated.", "token": token, "accountType": "growtopia"})
+}
+
+func (s *LoginServer) dashboard(w http.ResponseWriter, r *http.Request) {
+	for _, c := range []string{"AWSALBTG", "AWSALBTGCORS", "AWSALB", "AWSALBCORS"} {
+		http.SetCookie(w, &http.Cookie{Name: c, Value: strings.ToLower(c) + "-cookie", Path: "/"})
+	}
+	http.SetCookie(w, &http.Cookie{Name: "growtopia_game_session", Value: "dashboard-session", Path: "/", HttpOnly: true})
+
+	link := func(provider, href string) string {
+		return fmt.Sprintf(`<a onclick="optionChose('%s');" href="%s">%s</a>`, provider, html.EscapeString(href), provider)
+	}
+	fmt.Fprintf(w, "<html><body>%s%s%s</body></html>",
+		link("Grow", s.URL+EndpointLoginForm+"?token=dashboard-token"),
+		link("Google", s.URL+"/player/login/google"),
+		link("Apple", s.URL+"/player/login/apple"))
+}
+
+func (s *LoginServer) loginForm(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	formToken := s.formToken
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: "XSRF-TOKEN", Value: "xsrf-cookie", Path: "/"})
+	http.SetCookie(w, &http.Cookie{Name: "growtopia_session", Value: "form-session", Path: "/", HttpOnly: true})
+	fmt.Fprintf(w, `<html><body><form method="POST" action="%s">`+
+		`<input type="hidden" name="_token" value="%s">`+
+		`<input name="growId"><input name="password" type="password"></form></body></html>`,
+		s.URL+EndpointValidate, html.EscapeString(formToken))
+}
+
+func (s *LoginServer) validate(w http.ResponseWriter, r *http.Request) {
+	name, password := r.FormValue("growId"), r.FormValue("password")
+	s.mu.Lock()
+	ok := r.FormValue("_token") == s.formToken && name != "" && s.accounts[name] == password
+	token := "ltoken-" + name
+	if ok {
+		s.tokens[token] = true
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, map[string]string{"status": "error", "message": "Invalid GrowID or password."})
+		return
+	}
+	writeJSON(w, map[string]string{"status": "success", "message": "Account Validated.", "token": token, "url": "", "accountType": "growtopia"})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,103 @@
+package gttest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"vortenixgo/bot"
+)
+
+// gamePacket builds a NET_MESSAGE_GAME_PACKET carrying p and its extended data
+func gamePacket(p bot.TankPacketStruct, extra []byte) []byte {
+	if len(extra) > 0 {
+		p.Flags |= 8 // Has extended data
+	}
+	p.ExtendedDataLength = uint32(len(extra))
+	data := binary.LittleEndian.AppendUint32(nil, bot.NET_MESSAGE_GAME_PACKET)
+	data = append(data, p.Serialize()...)
+	return append(data, extra...)
+}
+
+// variantList encodes the variants of a CALL_FUNCTION packet as
+// bot.VariantList.Parse reads them. Variants must be float32, string,
+// bot.Vector2, bot.Vector3, uint32, int32 or int (sent as int32).
+func variantList(variants ...interface{}) ([]byte, error) {
+	le := binary.LittleEndian
+	data := []byte{byte(len(variants))}
+	for i, v := range variants {
+		switch v := v.(type) {
+		case float32:
+			data = le.AppendUint32(append(data, byte(i), 1), math.Float32bits(v))
+		case string:
+			data = le.AppendUint32(append(data, byte(i), 2), uint32(len(v)))
+			data = append(data, v...)
+		case bot.Vector2:
+			data = le.AppendUint32(append(data, byte(i), 3), math.Float32bits(v.X))
+			data = le.AppendUint32(data, math.Float32bits(v.Y))
+		case bot.Vector3:
+			data = le.AppendUint32(append(data, byte(i), 4), math.Float32bits(v.X))
+			data = le.AppendUint32(data, math.Float32bits(v.Y))
+			data = le.AppendUint32(data, math.Float32bits(v.Z))
+		case uint32:
+			data = le.AppendUint32(append(data, byte(i), 5), v)
+		case int32:
+			data = le.AppendUint32(append(data, byte(i), 9), uint32(v))
+		case int:
+			data = le.AppendUint32(append(data, byte(i), 9), uint32(int32(v)))
+		default:
+			return nil, fmt.Errorf("variant %d: cannot encode %T", i, v)
+		}
+	}
+	return data, nil
+}
+
+// inventoryState encodes the extended data of NET_GAME_PACKET_SEND_INVENTORY_STATE
+// as the bot reads it: 5 header bytes, the slot count, then {flags, id, count}
+func inventoryState(items []InventoryItem) []byte {
+	data := []byte{1, 0, 0, 0, 0, byte(len(items))}
+	for _, it := range items {
+		data = append(data, 0)
+		data = binary.LittleEndian.AppendUint16(data, it.ID)
+		data = append(data, it.Count)
+	}
+	return data
+}
+
+// BlankWorld encodes a world of the given size with dirt (item 2) on the
+// bottom half and bedrock (item 8) on the last row, no tile extras, no dropped
+// items and sunny weather
+func BlankWorld(name string, width, height int) []byte {
+	le := binary.LittleEndian
+	data := le.AppendUint16(nil, 0x19) // Version
+	data = le.AppendUint32(data, 0)    // Flags
+	data = le.AppendUint16(data, uint16(len(name)))
+	data = append(data, name...)
+	data = le.AppendUint32(data, uint32(width))
+	data = le.AppendUint32(data, uint32(height))
+	data = le.AppendUint32(data, uint32(width*height))
+	data = append(data, 0, 0, 0, 0, 0)
+
+	for y := 0; y < height; y++ {
+		var fg, bg uint16
+		switch {
+		case y == height-1:
+			fg, bg = 8, 14
+		case y >= height/2:
+			fg, bg = 2, 14
+		}
+		for x := 0; x < width; x++ {
+			data = le.AppendUint16(data, fg)
+			data = le.AppendUint16(data, bg)
+			data = le.AppendUint16(data, 0) // Parent
+			data = le.AppendUint16(data, 0) // Flags
+		}
+	}
+
+	data = append(data, make([]byte, 12)...)
+	data = le.AppendUint32(data, 0) // Dropped items
+	data = le.AppendUint32(data, 0) // Last dropped UID
+	data = le.AppendUint16(data, 0) // Base weather
+	data = le.AppendUint16(data, 0)
+	return le.AppendUint16(data, 0) // Current weather
+}
@@ -15,6 +15,7 @@ import (
 	"strings"
 	"time"
 	"vortenixgo/bot"
+	"vortenixgo/config"
 	"vortenixgo/network/ws"
 
 	"golang.org/x/net/html"
@@ -24,7 +25,6 @@ import (
 const (
 	GrowtopiaUserAgent = "UbiServices_SDK_2022.Release.9_PC64_ansi_static"
 	ChromeUserAgent    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/139.0.0.0 Safari/537.36 Edg/139.0.0.0"
-	ServerDataURL      = "https://www.growtopia1.com/growtopia/server_data.php"
 )
 
 // HTTPHandler handles all HTTP requests for a bot
@@ -81,19 +81,20 @@ func (h *HTTPHandler) GetMeta(b *bot.Bot) error {
 	data.Set("platform", b.Login.PlatformID)
 	data.Set("protocol", b.Login.Protocol)
 
-	req, err := http.NewRequest("POST", ServerDataURL, strings.NewReader(data.Encode()))
+	serverDataURL := config.Get().Server.ServerDataURL
+	req, err := http.NewRequest("POST", serverDataURL, strings.NewReader(data.Encode()))
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("Host", "www.growtopia1.com")
+	req.Header.Set("Host", req.URL.Host)
 	req.Header.Set("User-Agent", GrowtopiaUserAgent)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "*/*")
 
-	log.Printf("[HTTP][%s] Requesting Meta: %s", b.Name, ServerDataURL)
+	log.Printf("[HTTP][%s] Requesting Meta: %s", b.Name, serverDataURL)
 	if ws.GlobalHub != nil {
-		ws.GlobalHub.BroadcastDebug(b.ID, "HTTPS", "POST "+ServerDataURL+"\nBody: "+data.Encode(), false)
+		ws.GlobalHub.BroadcastDebug(b.ID, "HTTPS", "POST "+serverDataURL+"\nBody: "+data.Encode(), false)
 	}
 
 	resp, err := h.Client.Do(req)
@@ -165,7 +166,7 @@ func (h *HTTPHandler) CheckToken(b *bot.Bot) (string, error) {
 	data.Set("clientData", loginPkt)
 
 	valKey := "40db4045f2d8c572efe8c4a060605726"
-	targetURL := "https://login.growtopiagame.com/player/growid/checktoken?valKey=" + valKey
+	targetURL := config.Get().Server.LoginURL + "/player/growid/checktoken?valKey=" + valKey
 
 	req, err := http.NewRequest("POST", targetURL, strings.NewReader(data.Encode()))
 	if err != nil {
@@ -247,7 +248,7 @@ func (h *HTTPHandler) GetDashboard(b *bot.Bot) error {
 	loginPkt := b.Login.LoginPkt
 	b.Unlock()
 
-	targetURL := "https://login.growtopiagame.com/player/login/dashboard?valKey=40db4045f2d8c572efe8c4a060605726"
+	targetURL := config.Get().Server.LoginURL + "/player/login/dashboard?valKey=40db4045f2d8c572efe8c4a060605726"
 
 	// Generate cookies for Dashboard request
 	now := time.Now().Unix()
@@ -599,7 +600,8 @@ func (h *HTTPHandler) getTokenLegacy(b *bot.Bot) error {
 		return fmt.Errorf("form token is empty")
 	}
 
-	targetURL := "https://login.growtopiagame.com/player/growid/login/validate"
+	loginBase := config.Get().Server.LoginURL
+	targetURL := loginBase + "/player/growid/login/validate"
 
 	data := url.Values{}
 	data.Set("_token", formToken)
@@ -616,7 +618,7 @@ func (h *HTTPHandler) getTokenLegacy(b *bot.Bot) error {
 	req.Header.Set("sec-ch-ua", `"Google Chrome";v="131", "Chromium";v="131", "Not_A Brand";v="24"`)
 	req.Header.Set("sec-ch-ua-mobile", "?1")
 	req.Header.Set("sec-ch-ua-platform", "\"Windows\"")
-	req.Header.Set("Origin", "https://login.growtopiagame.com")
+	req.Header.Set("Origin", loginBase)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Upgrade-Insecure-Requests", "1")
 	req.Header.Set("User-Agent", ChromeUserAgent)
@@ -756,13 +758,16 @@ func (h *HTTPHandler) getTokenExternal(b *bot.Bot) error {
 		ws.GlobalHub.BroadcastBotUpdate()
 	}
 
-	// Prepare Cookies
+	// Prepare Cookies, scoped to the login host
+	domain := "login.growtopiagame.com"
+	if u, err := url.Parse(config.Get().Server.LoginURL); err == nil && u.Hostname() != "" {
+		domain = u.Hostname()
+	}
 	cookies := []string{}
 	addCookie := func(name, value string, httpOnly, secure bool) {
 		if value == "" {
 			return
 		}
-		domain := "login.growtopiagame.com"
 		flag1 := "FALSE"
 		path := "/"
 		flag2 := "FALSE"
@@ -0,0 +1,267 @@
+package transport
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AcceptFunc is called for every connection made to a listener. It returns
+// the handler for the packets the client sends, which runs on a goroutine of
+// its own, one packet at a time.
+type AcceptFunc func(c *Conn) func(data []byte)
+
+// Network links Memory transports to in-process servers by address
+type Network struct {
+	mu        sync.Mutex
+	listeners map[string]AcceptFunc
+}
+
+// NewNetwork returns an empty network
+func NewNetwork() *Network {
+	return &Network{listeners: make(map[string]AcceptFunc)}
+}
+
+// Listen serves ip:port with accept, replacing any previous listener.
+// A nil accept stops listening; open connections stay up.
+func (n *Network) Listen(ip string, port int, accept AcceptFunc) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	key := fmt.Sprintf("%s:%d", ip, port)
+	if accept == nil {
+		delete(n.listeners, key)
+		return
+	}
+	n.listeners[key] = accept
+}
+
+func (n *Network) listener(ip string, port int) AcceptFunc {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.listeners[fmt.Sprintf("%s:%d", ip, port)]
+}
+
+// NewTransport returns a client transport on this network. Its signature
+// matches the bot's transport factory.
+func (n *Network) NewTransport() (Transport, error) {
+	return &Memory{net: n, notify: make(chan struct{}, 1), woken: make(chan struct{}, 1)}, nil
+}
+
+// Memory is a Transport whose peers are in-process servers. Connecting to an
+// address nobody listens on fails with an EventDisconnect.
+type Memory struct {
+	net *Network
+
+	mu     sync.Mutex
+	conn   *Conn
+	events []*Event
+	notify chan struct{} // Signalled when events is appended to
+	woken  chan struct{} // Signalled by Wake
+	closed bool
+}
+
+func (m *Memory) Connect(ip string, port int, proxy *Proxy) error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return fmt.Errorf("transport closed")
+	}
+	if m.conn != nil {
+		m.conn.shut()
+		m.conn = nil
+	}
+	accept := m.net.listener(ip, port)
+	if accept == nil {
+		m.pushLocked(&Event{Type: EventDisconnect, RemoteIP: ip, RemotePort: port})
+		m.mu.Unlock()
+		return nil
+	}
+	c := &Conn{
+		Proxy:  proxy,
+		client: m,
+		ip:     ip,
+		port:   port,
+		in:     make(chan []byte, 1024),
+		done:   make(chan struct{}),
+	}
+	m.conn = c
+	m.pushLocked(&Event{Type: EventConnect, RemoteIP: ip, RemotePort: port})
+	m.mu.Unlock()
+
+	go c.serve(accept(c))
+	return nil
+}
+
+// SendOn is Send: the in-memory connection is ordered and lossless on every
+// channel
+func (m *Memory) SendOn(channel uint8, data []byte, reliable bool) error {
+	return m.Send(data)
+}
+
+func (m *Memory) Send(data []byte) error {
+	m.mu.Lock()
+	c := m.conn
+	m.mu.Unlock()
+	if c == nil {
+		return fmt.Errorf("not connected")
+	}
+	return c.deliver(append([]byte(nil), data...))
+}
+
+func (m *Memory) Service(timeout time.Duration) (*Event, error) {
+	var timer *time.Timer
+	for {
+		m.mu.Lock()
+		if m.closed {
+			m.mu.Unlock()
+			return nil, fmt.Errorf("transport closed")
+		}
+		if len(m.events) > 0 {
+			ev := m.events[0]
+			m.events[0] = nil
+			m.events = m.events[1:]
+			m.mu.Unlock()
+			return ev, nil
+		}
+		m.mu.Unlock()
+
+		if timer == nil {
+			timer = time.NewTimer(timeout)
+			defer timer.Stop()
+		}
+		select {
+		case <-m.notify:
+		case <-m.woken:
+			return nil, nil
+		case <-timer.C:
+			return nil, nil
+		}
+	}
+}
+
+func (m *Memory) Disconnect() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conn != nil {
+		m.conn.shut()
+		m.conn = nil
+	}
+}
+
+func (m *Memory) HasPeer() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.conn != nil
+}
+
+func (m *Memory) RTT() int {
+	return 0
+}
+
+func (m *Memory) Wake() {
+	select {
+	case m.woken <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Memory) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conn != nil {
+		m.conn.shut()
+		m.conn = nil
+	}
+	m.closed = true
+	m.events = nil
+	select {
+	case m.notify <- struct{}{}: // Wake a waiting Service
+	default:
+	}
+}
+
+// push queues an event from c, unless c has been replaced or dropped
+func (m *Memory) push(c *Conn, ev *Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conn != c {
+		return
+	}
+	if ev.Type == EventDisconnect {
+		m.conn = nil
+	}
+	m.pushLocked(ev)
+}
+
+func (m *Memory) pushLocked(ev *Event) {
+	if m.closed {
+		return
+	}
+	m.events = append(m.events, ev)
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Conn is the server's side of a Memory connection
+type Conn struct {
+	Proxy *Proxy // As passed to Connect
+
+	client *Memory
+	ip     string
+	port   int
+	in     chan []byte // Packets from the client
+	once   sync.Once
+	done   chan struct{} // Closed when either side hangs up
+}
+
+// Send delivers data to the client as an EventReceive
+func (c *Conn) Send(data []byte) error {
+	select {
+	case <-c.done:
+		return fmt.Errorf("connection closed")
+	default:
+	}
+	c.client.push(c, &Event{Type: EventReceive, RemoteIP: c.ip, RemotePort: c.port, Data: append([]byte(nil), data...)})
+	return nil
+}
+
+// Close hangs up: the client gets an EventDisconnect
+func (c *Conn) Close() error {
+	c.client.push(c, &Event{Type: EventDisconnect, RemoteIP: c.ip, RemotePort: c.port})
+	c.shut()
+	return nil
+}
+
+// Done is closed once the connection is over, whichever side ended it
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Conn) shut() {
+	c.once.Do(func() { close(c.done) })
+}
+
+// deliver hands a packet to the server, waiting while its queue is full
+func (c *Conn) deliver(data []byte) error {
+	select {
+	case c.in <- data:
+		return nil
+	case <-c.done:
+		return fmt.Errorf("connection closed")
+	}
+}
+
+func (c *Conn) serve(handle func(data []byte)) {
+	for {
+		select {
+		case data := <-c.in:
+			if handle != nil {
+				handle(data)
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package transport
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// next waits for the next event, failing the test if none comes
+func next(t *testing.T, tr Transport) *Event {
+	t.Helper()
+	ev, err := tr.Service(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev == nil {
+		t.Fatal("no event")
+	}
+	return ev
+}
+
+func TestMemory(t *testing.T) {
+	n := NewNetwork()
+	received := make(chan []byte, 10)
+	var server *Conn
+	n.Listen("127.0.0.1", 17091, func(c *Conn) func([]byte) {
+		server = c
+		c.Send([]byte("hello"))
+		return func(data []byte) { received <- data }
+	})
+
+	tr, err := n.NewTransport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	if err := tr.Connect("127.0.0.1", 17091, ParseProxy("10.0.0.1:1080:user:pass")); err != nil {
+		t.Fatal(err)
+	}
+	if !tr.HasPeer() {
+		t.Fatal("no peer after Connect")
+	}
+	if got := next(t, tr); got.Type != EventConnect || got.RemoteIP != "127.0.0.1" || got.RemotePort != 17091 {
+		t.Fatalf("first event %+v", got)
+	}
+	if got := next(t, tr); got.Type != EventReceive || string(got.Data) != "hello" {
+		t.Fatalf("second event %+v", got)
+	}
+	if want := (&Proxy{"10.0.0.1", 1080, "user", "pass"}); !reflect.DeepEqual(server.Proxy, want) {
+		t.Errorf("server saw proxy %+v", server.Proxy)
+	}
+
+	for _, msg := range []string{"one", "two"} {
+		if err := tr.Send([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"one", "two"} {
+		select {
+		case got := <-received:
+			if string(got) != want {
+				t.Errorf("server got %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("server never got %q", want)
+		}
+	}
+
+	server.Close()
+	if got := next(t, tr); got.Type != EventDisconnect {
+		t.Fatalf("event after server Close %+v", got)
+	}
+	if tr.HasPeer() || tr.Send([]byte("late")) == nil {
+		t.Error("peer still usable after the server hung up")
+	}
+	if ev, err := tr.Service(10 * time.Millisecond); ev != nil || err != nil {
+		t.Errorf("idle Service returned %+v, %v", ev, err)
+	}
+}
+
+func TestMemoryDisconnect(t *testing.T) {
+	n := NewNetwork()
+	var server *Conn
+	n.Listen("127.0.0.1", 17091, func(c *Conn) func([]byte) {
+		server = c
+		return nil
+	})
+	tr, _ := n.NewTransport()
+
+	// Nobody listens on 17092: the attempt fails like an ENet timeout
+	tr.Connect("127.0.0.1", 17092, nil)
+	if got := next(t, tr); got.Type != EventDisconnect || got.RemotePort != 17092 {
+		t.Fatalf("connect to a closed port: %+v", got)
+	}
+
+	tr.Connect("127.0.0.1", 17091, nil)
+	next(t, tr)
+	tr.Disconnect()
+	select {
+	case <-server.Done():
+	case <-time.After(time.Second):
+		t.Fatal("server not told about Disconnect")
+	}
+	if server.Send([]byte("late")) == nil {
+		t.Error("server could send on a dropped connection")
+	}
+	if ev, _ := tr.Service(10 * time.Millisecond); ev != nil {
+		t.Errorf("Disconnect produced %+v", ev)
+	}
+
+	tr.Close()
+	if _, err := tr.Service(time.Millisecond); err == nil {
+		t.Error("Service works after Close")
+	}
+	if err := tr.Connect("127.0.0.1", 17091, nil); err == nil {
+		t.Error("Connect works after Close")
+	}
+}
+
+func TestMemoryWake(t *testing.T) {
+	tr, _ := NewNetwork().NewTransport()
+	defer tr.Close()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		tr.(Waker).Wake()
+	}()
+	start := time.Now()
+	if ev, err := tr.Service(5 * time.Second); ev != nil || err != nil {
+		t.Fatalf("got %+v, %v", ev, err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Wake didn't end the wait")
+	}
+}
+
+func TestParseProxy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *Proxy
+	}{
+		{"", nil},
+		{"1.2.3.4", nil},
+		{"1.2.3.4:1080", &Proxy{Host: "1.2.3.4", Port: 1080}},
+		{"1.2.3.4:1080:u:p", &Proxy{"1.2.3.4", 1080, "u", "p"}},
+		{"1.2.3.4:1080:u", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseProxy(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseProxy(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package transport is what a bot needs from the network: one connection to
+// the game server at a time, reliable packets and a polled event loop. The cgo
+// ENet wrapper is one backend (enet.NewTransport), the pure Go ENet another
+// (genet.NewTransport); Memory is a third, for tests and simulations without a
+// network.
+package transport
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventType is the kind of event returned by Service
+type EventType int
+
+const (
+	EventConnect    EventType = iota + 1 // The peer accepted the connection
+	EventDisconnect                      // The peer went away or the connection attempt failed
+	EventReceive                         // A packet arrived
+)
+
+// Event is one result of Service. Data is only set for EventReceive and is
+// owned by the caller.
+type Event struct {
+	Type       EventType
+	RemoteIP   string
+	RemotePort int
+	Data       []byte
+}
+
+// Proxy is a SOCKS5 proxy the transport connects through
+type Proxy struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+}
+
+// ParseProxy reads "ip:port" or "ip:port:user:pass". Anything else means no
+// proxy.
+func ParseProxy(s string) *Proxy {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 && len(parts) != 4 {
+		return nil
+	}
+	port, _ := strconv.Atoi(parts[1])
+	p := &Proxy{Host: parts[0], Port: port}
+	if len(parts) == 4 {
+		p.User, p.Password = parts[2], parts[3]
+	}
+	return p
+}
+
+// Transport is a client host holding at most one peer. Service is called from
+// a single goroutine; the other methods may be called from any goroutine.
+type Transport interface {
+	// Connect starts connecting to ip:port, replacing the current peer.
+	// Success is reported later by an EventConnect.
+	Connect(ip string, port int, proxy *Proxy) error
+	// Send queues data on the reliable channel of the current peer
+	Send(data []byte) error
+	// SendOn queues data on the given channel of the current peer, reliable
+	// or not. Send is SendOn(0, data, true).
+	SendOn(channel uint8, data []byte, reliable bool) error
+	// Service waits up to timeout for the next event. It returns nil, nil
+	// when nothing happened.
+	Service(timeout time.Duration) (*Event, error)
+	// Disconnect drops the current peer at once, without an EventDisconnect,
+	// and keeps the host for the next Connect
+	Disconnect()
+	// HasPeer reports whether a peer is connected or connecting
+	HasPeer() bool
+	// RTT is the round trip time to the peer in milliseconds
+	RTT() int
+	// Close tells the peer goodbye and frees the host. The transport can't be
+	// used afterwards.
+	Close()
+}
+
+// Waker is implemented by transports that send without being serviced and
+// whose Service returns as soon as an event is queued, so a caller can wait
+// in Service for long. Wake makes a waiting Service return nil, nil.
+type Waker interface {
+	Wake()
+}
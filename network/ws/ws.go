@@ -1,13 +1,17 @@
 package ws
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"runtime/debug"
 	"sync"
 	"time"
 
 	"vortenixgo/bot"
+	"vortenixgo/config"
 	"vortenixgo/database"
 
 	"github.com/gorilla/websocket"
@@ -28,20 +32,27 @@ type Hub struct {
 	register     chan *Client
 	unregister   chan *Client
 	mu           sync.Mutex
+	Items        *database.Provider
 	OnConnect    func(*bot.Bot, *Hub)
 	OnDisconnect func(*bot.Bot, *Hub)
 }
 
 var GlobalHub *Hub
 
-func NewHub() *Hub {
+func NewHub(items *database.Provider) *Hub {
 	h := &Hub{
 		broadcast:  make(chan []byte, 10),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		clients:    make(map[*Client]bool),
+		Items:      items,
 	}
 	GlobalHub = h
+
+	// Let every UI know when items.dat is reloaded
+	items.Subscribe(func(db *database.ItemDatabase) {
+		h.broadcastToClients(databaseInfoMessage(db))
+	})
 	return h
 }
 
@@ -132,10 +143,16 @@ type Client struct {
 	hub  *Hub
 	conn *websocket.Conn
 	send chan []byte
+
+	// Cancels the packet inspector subscription. Only touched by readPump.
+	stopInspect func()
 }
 
 func (c *Client) readPump() {
 	defer func() {
+		if c.stopInspect != nil {
+			c.stopInspect()
+		}
 		c.hub.unregister <- c
 		c.conn.Close()
 	}()
@@ -145,129 +162,239 @@ func (c *Client) readPump() {
 			log.Printf("error: %v", err)
 			break
 		}
+		c.handleMessage(message)
+	}
+}
 
-		// Handle incoming message
-		var req map[string]interface{}
-		if err := json.Unmarshal(message, &req); err != nil {
-			continue
+// handleMessage handles one request from the UI. A panic is logged and reported
+// back as an ERROR instead of killing the process.
+func (c *Client) handleMessage(message []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[WS] Recovered panic handling %.200s: %v\n%s", message, r, debug.Stack())
+			msg := map[string]interface{}{
+				"type": "ERROR",
+				"data": fmt.Sprintf("internal error: %v", r),
+			}
+			data, _ := json.Marshal(msg)
+			c.trySend(data)
 		}
+	}()
 
-		msgType, _ := req["type"].(string)
-		data, _ := req["data"].(map[string]interface{})
+	var req map[string]interface{}
+	if err := json.Unmarshal(message, &req); err != nil {
+		return
+	}
 
-		switch msgType {
-		case "ADD_BOT":
-			bType := bot.BotType(data["type"].(string))
-			name := data["name"].(string)
-			pass := data["pass"].(string)
-			glog := data["glog"].(string)
-			proxy := ""
-			if p, ok := data["proxy"].(string); ok {
-				proxy = p
-			}
-			log.Printf("Adding bot: type=%s, name=%s, glog=%s, proxy=%s", bType, name, glog, proxy)
+	msgType, _ := req["type"].(string)
+	data, _ := req["data"].(map[string]interface{})
+
+	switch msgType {
+	case "ADD_BOT":
+		bType, _ := data["type"].(string)
+		name, _ := data["name"].(string)
+		pass, _ := data["pass"].(string)
+		glog, _ := data["glog"].(string)
+		proxy, _ := data["proxy"].(string)
+		if bType == "" || (name == "" && pass == "") {
+			c.sendError("ADD_BOT needs a type and a name or token")
+			return
+		}
+		log.Printf("Adding bot: type=%s, name=%s, glog=%s, proxy=%s", bType, name, glog, proxy)
 
-			newBot, err := bot.BotManager.AddBot(bType, name, pass, glog, proxy)
-			if err == nil {
-				newBot.OnDebug = func(cat, msg string, isErr bool) {
-					c.hub.BroadcastDebug(newBot.ID, cat, msg, isErr)
-				}
-				c.hub.BroadcastBotUpdate()
-			} else {
-				log.Printf("Error adding bot: %v", err)
-				msg := map[string]interface{}{
-					"type": "ERROR",
-					"data": err.Error(),
-				}
-				data, _ := json.Marshal(msg)
-				c.send <- data
+		newBot, err := bot.BotManager.AddBot(bot.BotType(bType), name, pass, glog, proxy)
+		if err == nil {
+			newBot.OnDebug = func(cat, msg string, isErr bool) {
+				c.hub.BroadcastDebug(newBot.ID, cat, msg, isErr)
 			}
-		case "REMOVE_BOT":
-			id, _ := data["id"].(string)
-			err := bot.BotManager.RemoveBot(id)
-			if err == nil {
-				c.hub.BroadcastBotUpdate()
+			c.hub.BroadcastBotUpdate()
+		} else {
+			log.Printf("Error adding bot: %v", err)
+			msg := map[string]interface{}{
+				"type": "ERROR",
+				"data": err.Error(),
 			}
-		case "BOT_ACTION":
-			id, _ := data["id"].(string)
-			action, _ := data["action"].(string)
-			b, ok := bot.BotManager.GetBot(id)
-			if ok {
-				switch action {
-				case "CONNECT":
-					if c.hub.OnConnect != nil {
-						c.hub.OnConnect(b, c.hub)
-					} else {
-						b.Connect()
-						c.hub.BroadcastBotUpdate()
-					}
-				case "DISCONNECT":
-					if c.hub.OnDisconnect != nil {
-						c.hub.OnDisconnect(b, c.hub)
-					} else {
-						b.Disconnect()
-						c.hub.BroadcastBotUpdate()
-					}
-				case "INVENTORY_ACTION":
-					subAction, _ := data["sub_action"].(string)
-					itemID, _ := data["item_id"].(float64)
-					switch subAction {
-					case "WEAR", "UNWEAR":
-						b.WearItem(int32(itemID))
-					case "DROP":
-						b.DropItem(int32(itemID))
-					case "TRASH":
-						b.TrashItem(int32(itemID))
+			data, _ := json.Marshal(msg)
+			c.send <- data
+		}
+	case "REMOVE_BOT":
+		id, _ := data["id"].(string)
+		err := bot.BotManager.RemoveBot(id)
+		if err == nil {
+			c.hub.BroadcastBotUpdate()
+		}
+	case "BOT_ACTION":
+		id, _ := data["id"].(string)
+		action, _ := data["action"].(string)
+		b, ok := bot.BotManager.GetBot(id)
+		if ok {
+			switch action {
+			case "CONNECT":
+				if c.hub.OnConnect != nil {
+					c.hub.OnConnect(b, c.hub)
+				} else {
+					b.Connect()
+					c.hub.BroadcastBotUpdate()
+				}
+			case "DISCONNECT":
+				if c.hub.OnDisconnect != nil {
+					c.hub.OnDisconnect(b, c.hub)
+				} else {
+					b.Disconnect()
+					c.hub.BroadcastBotUpdate()
+				}
+			case "INVENTORY_ACTION":
+				subAction, _ := data["sub_action"].(string)
+				itemID, _ := data["item_id"].(float64)
+				switch subAction {
+				case "WEAR", "UNWEAR":
+					b.WearItem(int32(itemID))
+				case "DROP":
+					b.DropItem(int32(itemID))
+				case "TRASH":
+					b.TrashItem(int32(itemID))
+				}
+			case "SAY":
+				text, _ := data["text"].(string)
+				b.Say(text)
+			case "WARP", "LEAVE":
+				// "NAME" or "NAME|DOORID"; the join can take a while, so
+				// the result comes back on its own
+				world, _ := data["world"].(string)
+				if action == "LEAVE" {
+					world = "EXIT"
+				}
+				go func() {
+					defer b.RecoverPanic("Warp", nil)
+					if err := b.Warp(context.Background(), world); err != nil {
+						data, _ := json.Marshal(map[string]interface{}{"type": "ERROR", "data": err.Error()})
+						c.trySend(data) // The client may be gone by now
 					}
-				case "SAY":
-					text, _ := data["text"].(string)
-					b.Say(text)
-				case "WARP":
-					world, _ := data["world"].(string)
-					b.Warp(world)
-				case "LEAVE":
-					b.Warp("EXIT")
+					c.hub.BroadcastBotUpdate()
+				}()
+			case "MOVE":
+				// Keys held until the next MOVE, or for "ms" milliseconds
+				left, _ := data["left"].(bool)
+				right, _ := data["right"].(bool)
+				jump, _ := data["jump"].(bool)
+				ms, _ := data["ms"].(float64)
+				in := bot.MoveInput{Left: left, Right: right, Jump: jump}
+				var err error
+				if ms > 0 {
+					err = b.Nudge(in, time.Duration(ms)*time.Millisecond)
+				} else {
+					err = b.Move(in)
 				}
-			}
-		case "UPDATE_BOT_CONFIG":
-			id, _ := data["id"].(string)
-			b, ok := bot.BotManager.GetBot(id)
-			if ok {
-				b.Lock()
-				if g, ok := data["glog"].(string); ok {
-					b.Glog = g
+				if err != nil {
+					c.sendError(err.Error())
 				}
-				if p, ok := data["proxy"].(string); ok {
-					b.Proxy = p
+			case "START_CAPTURE":
+				if _, err := b.StartCapture(config.Get().Server.CaptureDir); err != nil {
+					c.sendError(err.Error())
 				}
-				if s, ok := data["show_enet"].(bool); ok {
-					b.ShowENet = s
+				c.hub.BroadcastBotUpdate()
+			case "STOP_CAPTURE":
+				if err := b.StopCapture(); err != nil {
+					c.sendError(err.Error())
 				}
-				b.Unlock()
 				c.hub.BroadcastBotUpdate()
 			}
-		case "EXECUTE_LUA":
-			id, _ := data["id"].(string)
-			script, _ := data["script"].(string)
-			log.Printf("Executing Lua on bot %s: %s", id, script)
-			// TODO: Actually run in Lua state
-
-		// Database queries
-		case "GET_ITEM":
-			c.handleGetItem(data)
-		case "SEARCH_ITEMS":
-			c.handleSearchItems(data)
-		case "GET_ITEMS_BY_RARITY":
-			c.handleGetItemsByRarity(data)
-		case "GET_DATABASE_INFO":
-			c.handleGetDatabaseInfo()
+		}
+	case "UPDATE_BOT_CONFIG":
+		id, _ := data["id"].(string)
+		b, ok := bot.BotManager.GetBot(id)
+		if ok {
+			b.Lock()
+			if g, ok := data["glog"].(string); ok {
+				b.Glog = g
+			}
+			if p, ok := data["proxy"].(string); ok {
+				b.Proxy = p
+			}
+			if s, ok := data["show_enet"].(bool); ok {
+				b.ShowENet.Store(s)
+			}
+			b.Unlock()
+			c.hub.BroadcastBotUpdate()
+		}
+	case "EXECUTE_LUA":
+		id, _ := data["id"].(string)
+		script, _ := data["script"].(string)
+		log.Printf("Executing Lua on bot %s: %s", id, script)
+		// TODO: Actually run in Lua state
+
+	// Database queries
+	case "GET_ITEM":
+		c.handleGetItem(data)
+	case "SEARCH_ITEMS":
+		c.handleSearchItems(data)
+	case "GET_ITEMS_BY_RARITY":
+		c.handleGetItemsByRarity(data)
+	case "GET_DATABASE_INFO":
+		c.handleGetDatabaseInfo()
+	case "GET_SEEDS":
+		c.handleGetSeeds(data)
+
+	// Config
+	case "GET_CONFIG":
+		c.handleGetConfig()
+
+	// Bots sharing worlds
+	case "GET_CREWS":
+		c.handleGetCrews()
+	case "SET_ROLE":
+		id, _ := data["id"].(string)
+		role, _ := data["role"].(string) // Empty to let the coordinator choose
+		if role != "" && !bot.Role(role).Valid() {
+			c.sendError(fmt.Sprintf("Unknown role %q, want breaker, planter, collector, guard or empty", role))
+			return
+		}
+		bot.BotManager.Coordinator.SetRole(id, bot.Role(role))
+		c.handleGetCrews()
+
+	// Packet inspector
+	case "INSPECT_SUBSCRIBE":
+		c.handleInspectSubscribe(data)
+	case "INSPECT_UNSUBSCRIBE":
+		if c.stopInspect != nil {
+			c.stopInspect()
+			c.stopInspect = nil
 		}
 	}
 }
 
+// handleInspectSubscribe replaces this client's packet inspector subscription.
+// Events that don't fit in the send buffer are dropped rather than slowing the
+// bot down.
+func (c *Client) handleInspectSubscribe(data map[string]interface{}) {
+	var filter bot.PacketFilter
+	raw, _ := json.Marshal(data)
+	if err := json.Unmarshal(raw, &filter); err != nil {
+		c.sendError("invalid inspector filter: " + err.Error())
+		return
+	}
+	if filter.Dir != "" && filter.Dir != "in" && filter.Dir != "out" {
+		c.sendError(`inspector dir must be "in", "out" or empty`)
+		return
+	}
+
+	if c.stopInspect != nil {
+		c.stopInspect()
+	}
+	c.stopInspect = bot.Inspector.Subscribe(filter, func(ev *bot.PacketEvent) {
+		msg := map[string]interface{}{
+			"type": "PACKET_EVENT",
+			"data": ev,
+		}
+		if data, err := json.Marshal(msg); err == nil {
+			c.trySend(data)
+		}
+	})
+}
+
 // Database query handlers
 func (c *Client) handleGetItem(data map[string]interface{}) {
-	db := database.GetGlobalItemDB()
+	db := c.hub.Items.DB()
 	if db == nil || !db.Loaded {
 		c.sendError("Database not loaded")
 		return
@@ -276,34 +403,75 @@ func (c *Client) handleGetItem(data map[string]interface{}) {
 	// Support both ID and name queries
 	if idFloat, ok := data["id"].(float64); ok {
 		item := db.GetItem(uint32(idFloat))
-		c.sendItemResponse(item)
+		c.sendItemResponse(db, item)
 	} else if name, ok := data["name"].(string); ok {
 		item := db.GetItemByName(name)
-		c.sendItemResponse(item)
+		c.sendItemResponse(db, item)
 	} else {
 		c.sendError("Missing 'id' or 'name' parameter")
 	}
 }
 
 func (c *Client) handleSearchItems(data map[string]interface{}) {
-	db := database.GetGlobalItemDB()
+	db := c.hub.Items.DB()
 	if db == nil || !db.Loaded {
 		c.sendError("Database not loaded")
 		return
 	}
 
-	query, ok := data["query"].(string)
-	if !ok {
-		c.sendError("Missing 'query' parameter")
+	query, _ := data["query"].(string)
+	q := database.SearchQuery{Query: query}
+	q.Fuzzy, _ = data["fuzzy"].(bool)
+	q.Sort, _ = data["sort"].(string)
+	if v, ok := data["min_rarity"].(float64); ok {
+		r := uint16(v)
+		q.MinRarity = &r
+	}
+	if v, ok := data["max_rarity"].(float64); ok {
+		r := uint16(v)
+		q.MaxRarity = &r
+	}
+	if v, ok := data["clothing_type"].(float64); ok {
+		t := uint8(v)
+		q.ClothingType = &t
+	}
+	if v, ok := data["action_type"].(float64); ok {
+		t := uint8(v)
+		q.ActionType = &t
+	}
+	if flags, ok := data["flags"].([]interface{}); ok {
+		for _, f := range flags {
+			if name, ok := f.(string); ok {
+				q.Flags = append(q.Flags, name)
+			}
+		}
+	}
+	if v, ok := data["page"].(float64); ok {
+		q.Page = int(v)
+	}
+	if v, ok := data["page_size"].(float64); ok {
+		q.PageSize = int(v)
+	}
+
+	if query == "" && q.MinRarity == nil && q.MaxRarity == nil && q.ClothingType == nil && q.ActionType == nil && len(q.Flags) == 0 {
+		c.sendError("Missing 'query' parameter or filters")
 		return
 	}
 
-	items := db.SearchItems(query)
-	c.sendItemsResponse(items)
+	result := db.Search(q)
+	msg := map[string]interface{}{
+		"type":      "ITEMS_DATA",
+		"data":      result.Items,
+		"total":     result.Total,
+		"page":      result.Page,
+		"page_size": result.PageSize,
+	}
+	payload, _ := json.Marshal(msg)
+	c.send <- payload
 }
 
 func (c *Client) handleGetItemsByRarity(data map[string]interface{}) {
-	db := database.GetGlobalItemDB()
+	db := c.hub.Items.DB()
 	if db == nil || !db.Loaded {
 		c.sendError("Database not loaded")
 		return
@@ -320,25 +488,77 @@ func (c *Client) handleGetItemsByRarity(data map[string]interface{}) {
 }
 
 func (c *Client) handleGetDatabaseInfo() {
-	db := database.GetGlobalItemDB()
+	db := c.hub.Items.DB()
 	if db == nil || !db.Loaded {
 		c.sendError("Database not loaded")
 		return
 	}
 
+	c.send <- databaseInfoMessage(db)
+}
+
+// handleGetSeeds lists the seeds in a bot's inventory with what they grow into
+func (c *Client) handleGetSeeds(data map[string]interface{}) {
+	db := c.hub.Items.DB()
+	if db == nil || !db.Loaded {
+		c.sendError("Database not loaded")
+		return
+	}
+	id, _ := data["id"].(string)
+	b, ok := bot.BotManager.GetBot(id)
+	if !ok {
+		c.sendError("Bot not found")
+		return
+	}
+
+	msg := map[string]interface{}{
+		"type": "SEEDS",
+		"data": map[string]interface{}{
+			"id":    id,
+			"seeds": b.InventorySeeds(),
+		},
+	}
+	payload, _ := json.Marshal(msg)
+	c.send <- payload
+}
+
+func databaseInfoMessage(db *database.ItemDatabase) []byte {
 	msg := map[string]interface{}{
 		"type": "DATABASE_INFO",
 		"data": map[string]interface{}{
 			"loaded":     db.Loaded,
 			"version":    db.Version,
 			"item_count": db.ItemCount,
+			"hash":       db.Hash,
+			"report":     db.Report,
+		},
+	}
+	data, _ := json.Marshal(msg)
+	return data
+}
+
+func (c *Client) handleGetConfig() {
+	msg := map[string]interface{}{
+		"type": "CONFIG",
+		"data": map[string]interface{}{
+			"path":   config.Path(),
+			"config": config.Get(),
 		},
 	}
 	data, _ := json.Marshal(msg)
 	c.send <- data
 }
 
-func (c *Client) sendItemResponse(item *database.Item) {
+func (c *Client) handleGetCrews() {
+	msg := map[string]interface{}{
+		"type": "CREWS",
+		"data": bot.BotManager.Coordinate(),
+	}
+	data, _ := json.Marshal(msg)
+	c.send <- data
+}
+
+func (c *Client) sendItemResponse(db *database.ItemDatabase, item *database.Item) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("Recovered from panic in sendItemResponse: %v", r)
@@ -353,6 +573,7 @@ func (c *Client) sendItemResponse(item *database.Item) {
 	msg := map[string]interface{}{
 		"type": "ITEM_DATA",
 		"data": item,
+		"meta": db.Meta(item.ID), // Seed/block pair, tree grow time, splices
 	}
 	data, _ := json.Marshal(msg)
 
@@ -385,6 +606,22 @@ func (c *Client) sendError(message string) {
 	c.send <- data
 }
 
+// trySend queues data without blocking. It reports false when the buffer is
+// full or the hub already closed the channel.
+func (c *Client) trySend(data []byte) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	select {
+	case c.send <- data:
+		return true
+	default:
+		return false
+	}
+}
+
 func (c *Client) writePump() {
 	for {
 		message, ok := <-c.send
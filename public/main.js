@@ -46,6 +46,7 @@ document.addEventListener('DOMContentLoaded', () => {
 
         socket.onopen = () => {
             console.log('Connected to VortenixGO Server');
+            updateInspector();
         };
 
         socket.onmessage = (event) => {
@@ -73,15 +74,17 @@ document.addEventListener('DOMContentLoaded', () => {
                 alert('Error: ' + msg.data);
             } else if (msg.type === 'DEBUG_LOG') {
                 appendDebugLog(msg.data);
+            } else if (msg.type === 'PACKET_EVENT') {
+                appendPacketEvent(msg.data);
             } else if (msg.type === 'ITEMS_DATA') {
                 // Bulk cache search results
                 if (msg.data && Array.isArray(msg.data)) {
                     msg.data.forEach(item => cacheItem(item));
                 }
-                renderDBItemList(msg.data);
+                renderDBItemList(msg.data, msg.total);
             } else if (msg.type === 'ITEM_DATA') {
                 cacheItem(msg.data);
-                renderItemDetail(msg.data);
+                renderItemDetail(msg.data, msg.meta);
             } else if (msg.type === 'DATABASE_INFO') {
                 renderDatabaseInfo(msg.data);
             }
@@ -123,7 +126,7 @@ document.addEventListener('DOMContentLoaded', () => {
 
             if (s === 'online') badgeClass = 'online';
             else if (s.includes('connect') || s.includes('get')) badgeClass = 'warning';
-            else if (s === 'http_block' || s === 'offline') badgeClass = 'danger';
+            else if (s === 'http_block' || s === 'offline' || s === 'crashed') badgeClass = 'danger';
 
             el.innerHTML = `
                 <div class="info">
@@ -148,6 +151,8 @@ document.addEventListener('DOMContentLoaded', () => {
             updateBotDashboard(bot);
             renderBotList();
         }
+        inspectEvents.textContent = '';
+        updateInspector();
     }
 
     function deselectBot() {
@@ -156,6 +161,8 @@ document.addEventListener('DOMContentLoaded', () => {
         botDashboard.classList.add('hidden');
         document.getElementById('bot-console').innerHTML = '<div class="log-line system">> Console cleared...</div>';
         debugLogsContainer.innerHTML = '';
+        inspectLive.checked = false;
+        updateInspector();
         renderBotList();
     }
 
@@ -187,6 +194,7 @@ document.addEventListener('DOMContentLoaded', () => {
         document.getElementById('detail-played-age').textContent = `${bot.play_time || '0h'} | ${bot.age || 0}d`;
         document.getElementById('detail-ping').textContent = `${bot.ping || 0} ms`;
         document.getElementById('debug-enet').checked = bot.show_enet || false;
+        document.getElementById('debug-capture').checked = !!bot.capture;
 
         const glogGroup = document.getElementById('detail-glog-group');
         const glogInput = document.getElementById('detail-glog');
@@ -223,7 +231,7 @@ document.addEventListener('DOMContentLoaded', () => {
                 statusText.style.color = 'var(--success)';
                 statusDot.className = 'status-dot online';
                 btnDisconnect.disabled = false;
-            } else if (s === 'http_block' || s === 'offline' || s === 'disconnected' || s === 'idle') {
+            } else if (s === 'http_block' || s === 'offline' || s === 'disconnected' || s === 'idle' || s === 'crashed') {
                 statusText.style.color = 'var(--danger)';
                 statusDot.className = 'status-dot offline';
                 btnDisconnect.disabled = true;
@@ -357,6 +365,7 @@ document.addEventListener('DOMContentLoaded', () => {
 
             row.innerHTML = `
                 <td class="inv-info-cell">
+                    <img class="item-icon" src="/items/${item.id}/icon.png" alt="" loading="lazy" onerror="this.remove()">
                     <span class="inv-count">${item.count}x</span>
                     <span class="inv-sep">|</span>
                     <span class="inv-name">${name} [${item.id}]</span>
@@ -625,6 +634,47 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     };
 
+    document.getElementById('debug-capture').onchange = (e) => {
+        if (selectedBotId) {
+            socket.send(JSON.stringify({
+                type: 'BOT_ACTION',
+                data: { id: selectedBotId, action: e.target.checked ? 'START_CAPTURE' : 'STOP_CAPTURE' }
+            }));
+        }
+    };
+
+    // --- Packet Inspector ---
+    const INSPECT_MAX_EVENTS = 200;
+    const inspectLive = document.getElementById('inspect-live');
+    const inspectDir = document.getElementById('inspect-dir');
+    const inspectTypes = document.getElementById('inspect-types');
+    const inspectEvents = document.getElementById('inspect-events');
+
+    // (Re)subscribes with the current filter, or unsubscribes when not live
+    function updateInspector() {
+        if (!socket || socket.readyState !== WebSocket.OPEN) return;
+        if (!inspectLive.checked || !selectedBotId) {
+            socket.send(JSON.stringify({ type: 'INSPECT_UNSUBSCRIBE' }));
+            return;
+        }
+        const types = inspectTypes.value.split(',').map(t => t.trim()).filter(t => t);
+        socket.send(JSON.stringify({
+            type: 'INSPECT_SUBSCRIBE',
+            data: { bots: [selectedBotId], dir: inspectDir.value, types }
+        }));
+    }
+
+    function appendPacketEvent(ev) {
+        if (ev.bot_id !== selectedBotId) return;
+        const lines = inspectEvents.textContent ? inspectEvents.textContent.split('\n') : [];
+        lines.push(JSON.stringify(ev));
+        inspectEvents.textContent = lines.slice(-INSPECT_MAX_EVENTS).join('\n');
+        inspectEvents.scrollTop = inspectEvents.scrollHeight;
+    }
+
+    [inspectLive, inspectDir, inspectTypes].forEach(el => { el.onchange = updateInspector; });
+    document.getElementById('clear-inspect').onclick = () => { inspectEvents.textContent = ''; };
+
     // --- Database View Implementation ---
     const dbSearchInput = document.getElementById('db-search-input');
     const dbItemList = document.getElementById('db-item-list');
@@ -649,10 +699,10 @@ document.addEventListener('DOMContentLoaded', () => {
         };
     }
 
-    function renderDBItemList(items) {
+    function renderDBItemList(items, total) {
         if (!dbItemList) return;
         dbItemList.innerHTML = '';
-        dbItemCount.textContent = items ? items.length : 0;
+        dbItemCount.textContent = total !== undefined ? total : (items ? items.length : 0);
 
         if (!items || items.length === 0) {
             dbItemList.innerHTML = '<div class="empty-state">No items found</div>';
@@ -676,8 +726,7 @@ document.addEventListener('DOMContentLoaded', () => {
                 const cached = window.getItem(item.ID);
                 if (cached) {
                     renderItemDetail(cached);
-                } else {
-                    // This fallback should rarely happen if search results just populated cache
+                    // Fetch again for the derived metadata (splices, seed pair)
                     socket.send(JSON.stringify({ type: 'GET_ITEM', data: { id: item.ID } }));
                 }
             };
@@ -685,7 +734,49 @@ document.addEventListener('DOMContentLoaded', () => {
         });
     }
 
-    function renderItemDetail(item) {
+    function formatDuration(seconds) {
+        if (!seconds) return "Instant";
+        const h = Math.floor(seconds / 3600);
+        const m = Math.floor((seconds % 3600) / 60);
+        const s = seconds % 60;
+        let str = "";
+        if (h > 0) str += `${h}h `;
+        if (m > 0) str += `${m}m `;
+        if (s > 0) str += `${s}s`;
+        return str.trim() || "0s";
+    }
+
+    // Item name from the cache, falls back to the ID (the cache fills asynchronously)
+    function itemLabel(id) {
+        const cached = itemCache.byId.get(id);
+        return cached && cached.Name ? `${cached.Name} (${id})` : `#${id}`;
+    }
+
+    function renderItemMeta(meta) {
+        const pair = document.getElementById('item-pair');
+        const treeTime = document.getElementById('item-tree-time');
+        const splice = document.getElementById('item-splice');
+        const usedIn = document.getElementById('item-used-in');
+        if (!pair || !treeTime || !splice || !usedIn) return;
+
+        if (!meta) {
+            pair.textContent = treeTime.textContent = splice.textContent = usedIn.textContent = "-";
+            return;
+        }
+
+        if (meta.is_seed) pair.textContent = meta.block_id ? `Grows ${itemLabel(meta.block_id)}` : "-";
+        else pair.textContent = meta.seed_id ? `Seed ${itemLabel(meta.seed_id)}` : "-";
+
+        treeTime.textContent = meta.seed_id || meta.is_seed ? formatDuration(meta.grow_time) : "-";
+        splice.textContent = meta.recipe
+            ? `${itemLabel(meta.recipe.seeds[0])} + ${itemLabel(meta.recipe.seeds[1])}`
+            : "-";
+        usedIn.textContent = meta.used_in && meta.used_in.length
+            ? meta.used_in.map(r => itemLabel(r.result)).join(', ')
+            : "-";
+    }
+
+    function renderItemDetail(item, meta) {
         if (!item || !itemInfoContent) return;
 
         if (dbNoSelection) dbNoSelection.classList.add('hidden');
@@ -699,21 +790,15 @@ document.addEventListener('DOMContentLoaded', () => {
 
         // General Info
         document.getElementById('item-name').textContent = item.Name || "Unknown Item";
+        const icon = document.getElementById('item-icon');
+        if (icon) {
+            icon.classList.remove('hidden');
+            icon.src = `/items/${item.ID}/icon.png`;
+        }
         document.getElementById('item-id').textContent = `ID: ${item.ID}`;
         document.getElementById('item-rarity').textContent = item.Rarity || 0;
 
-        let growTime = item.GrowTime || 0;
-        let growTimeStr = "";
-        if (growTime === 0) growTimeStr = "Instant";
-        else {
-            const h = Math.floor(growTime / 3600);
-            const m = Math.floor((growTime % 3600) / 60);
-            const s = growTime % 60;
-            if (h > 0) growTimeStr += `${h}h `;
-            if (m > 0) growTimeStr += `${m}m `;
-            if (s > 0) growTimeStr += `${s}s`;
-        }
-        document.getElementById('item-grow-time').textContent = growTimeStr || "0s";
+        document.getElementById('item-grow-time').textContent = formatDuration(item.GrowTime || 0);
         document.getElementById('item-description').textContent = item.Description || "No description available.";
 
         // Gameplay Section
@@ -753,6 +838,7 @@ document.addEventListener('DOMContentLoaded', () => {
 
         document.getElementById('item-ingredient').textContent = item.Ingredient || 0;
         document.getElementById('item-cooking-ing').textContent = item.CookingIngredient || 0;
+        renderItemMeta(meta);
 
         // Pet Section
         document.getElementById('item-pet-name').textContent = item.PetName || "-";
@@ -838,7 +924,15 @@ document.addEventListener('DOMContentLoaded', () => {
         // Update UI info
         document.getElementById('world-name').textContent = world.name || 'EXIT';
         document.getElementById('world-dimensions').textContent = `${world.width}x${world.height}`;
-        document.getElementById('world-tiles').textContent = `${world.tile_count || 0} tiles`;
+        const tilesEl = document.getElementById('world-tiles');
+        const report = world.parse_report;
+        tilesEl.textContent = `${world.tile_count || 0} tiles`;
+        tilesEl.title = '';
+        if (report && report.unknown_types) {
+            const types = Object.entries(report.unknown_types).map(([type, count]) => `${type} x${count}`);
+            tilesEl.textContent += ` (unparsed: ${Object.keys(report.unknown_types).join(', ')})`;
+            tilesEl.title = `Unparsed extra types: ${types.join(', ')}\nTiles recovered: ${report.recovered_tiles}`;
+        }
 
         drawMapCanvas();
     }